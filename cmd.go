@@ -1,13 +1,34 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/howeyc/gopass"
 	"github.com/johnathanhowell/masterkey/repl"
 	"github.com/johnathanhowell/masterkey/vault"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 var (
+	// readPassphrase prompts for and reads a passphrase without echoing it.
+	// It is a variable so that tests can replace it.
+	readPassphrase = func(prompt string) (string, error) {
+		fmt.Print(prompt)
+		passphrase, err := gopass.GetPasswd()
+		return string(passphrase), err
+	}
+
 	listCmd = func(v *vault.Vault) repl.Command {
 		return repl.Command{
 			Name:   "list",
@@ -16,6 +37,22 @@ var (
 		}
 	}
 
+	favoriteCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "favorite",
+			Action: favorite(v),
+			Usage:  "favorite [-remove] [location]: mark [location] as a favorite, listing it first, or unmark it with -remove",
+		}
+	}
+
+	aliasCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "alias",
+			Action: alias(v),
+			Usage:  "alias [location] [alias], alias -remove [alias]: make [alias] another name for the credential at [location], or remove [alias] with -remove",
+		}
+	}
+
 	saveCmd = func(v *vault.Vault, vaultPath string) repl.Command {
 		return repl.Command{
 			Name:   "save",
@@ -28,7 +65,7 @@ var (
 		return repl.Command{
 			Name:   "get",
 			Action: get(v),
-			Usage:  "get [location]: get the credential at [location]",
+			Usage:  "get [-reveal] [location]: get the credential at [location], showing card details unmasked if -reveal is set",
 		}
 	}
 
@@ -40,29 +77,343 @@ var (
 		}
 	}
 
+	editCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "edit",
+			Action: edit(v),
+			Usage:  "edit [location] [username] [password]: replace the username and password of a credential, keeping the old ones in its history",
+		}
+	}
+
+	searchCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "search",
+			Action: search(v),
+			Usage:  "search [query]: fuzzy search the locations stored inside this vault",
+		}
+	}
+
+	statsCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "stats",
+			Action: stats(v),
+			Usage:  "stats: show statistics about the credentials stored inside this vault",
+		}
+	}
+
+	duplicatesCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "duplicates",
+			Action: duplicates(v),
+			Usage:  "duplicates: list groups of logins that are likely duplicates of one another",
+		}
+	}
+
+	metaCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "meta",
+			Action: meta(v),
+			Usage:  "meta [-name name] [-description text]: show the name, description and timestamps of this vault, or set its name and description",
+		}
+	}
+
+	normalizeCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "normalize",
+			Action: normalize(v),
+			Usage:  "normalize [on|off]: show whether locations are matched ignoring case and Unicode normalization, or turn it on or off",
+		}
+	}
+
+	keySlotCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "keyslot",
+			Action: keySlot(v),
+			Usage:  "keyslot [-add [-keyfile path] [-yubikey slot] [-fido2 device] | -gpg recipient | -tpm | -pkcs11 label -module path | -threshold k -approvers names] [-remove slot]: list the key slots that unlock this vault, add one unlocked by another passphrase and optionally a keyfile, YubiKey or FIDO2 security key, add one unlocked by the OpenPGP key of [recipient], add one sealed by this machine's TPM, add one wrapped by the key [label] of the PKCS#11 token whose module is at [path], add one unlocked by the passphrases of any [k] of the comma separated approvers [names], or remove [slot]",
+		}
+	}
+
+	hiddenCmd = func(v *vault.Vault, vaultPath string) repl.Command {
+		return repl.Command{
+			Name:   "hidden",
+			Action: hidden(v, vaultPath),
+			Usage:  "hidden: create an empty hidden vault inside this vault, opened by opening the vault with another passphrase, and save both. This vault becomes a decoy whose passphrase can be given up under duress. Any existing hidden vault is replaced",
+		}
+	}
+
+	logCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "log",
+			Action: operationLog(v),
+			Usage:  "log: list the modifications recorded in this vault's operation log and verify it, printing the hash to compare with a backup's",
+		}
+	}
+
+	rotateKeyCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "rotatekey",
+			Action: rotateKey(v),
+			Usage:  "rotatekey: re-encrypt the vault with a new data key, such as after a suspected memory compromise, forgetting changes that could be undone",
+		}
+	}
+
+	cipherCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "cipher",
+			Action: changeCipher(v),
+			Usage:  "cipher [name]: show the cipher the vault is encrypted with, or re-encrypt it using [name]: xsalsa20poly1305, aes256gcm, aes256gcmsiv or xchacha20poly1305. Other passphrase key slots must be removed first",
+		}
+	}
+
+	paddingCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "padding",
+			Action: padding(v),
+			Usage:  "padding [scheme]: show how the vault is padded to hide its size, or pad it using [scheme]: padme, the default, poweroftwo, which hides more but can double the vault's size, or none",
+		}
+	}
+
+	compressionCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "compression",
+			Action: compression(v),
+			Usage:  "compression [algorithm]: show how the vault's credentials are compressed before they are encrypted, or re-encrypt them compressed using [algorithm]: gzip, which can make vaults with long notes several times smaller, or none, the default",
+		}
+	}
+
+	backupsCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "backups",
+			Action: backups(v),
+			Usage:  "backups [count [dir]]: show how many previous versions of the vault file are kept when it is saved, as vault.db.bak.1 to vault.db.bak.[count], or keep [count] of them, in [dir] rather than next to the vault if given. 0 keeps none",
+		}
+	}
+
+	signCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "sign",
+			Action: sign(v),
+			Usage:  "sign [on|off]: show the public key the vault file is signed with on every save, which masterkey -verify checks its signature against without decrypting it, or start signing it with a new key, or stop signing it",
+		}
+	}
+
+	splitKeyCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "splitkey",
+			Action: splitKey(v),
+			Usage:  "splitkey [n] [k]: split the vault's key into n shares, any k of which open the vault using -shares, such as to give to trustees who can recover the vault if its passphrases are lost",
+		}
+	}
+
+	exportCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "export",
+			Action: export(v),
+			Usage:  "export -plaintext [-format json|keepass] [path]: export every credential, unencrypted, as JSON or KeePass 2 XML to [path]",
+		}
+	}
+
+	importCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "import",
+			Action: importCredentials(v),
+			Usage:  "import -format csv|kdbx|1password|lastpass|bitwarden|pass|chrome|firefox [-location col] [-username col] [-password col] [-notes col] [-url col] [path]: import credentials from [path]",
+		}
+	}
+
+	totpCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "totp",
+			Action: totp(v),
+			Usage:  "totp [location]: print the current one-time password for [location]",
+		}
+	}
+
+	hotpCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "hotp",
+			Action: hotp(v),
+			Usage:  "hotp [location]: print the next counter-based one-time password for [location]",
+		}
+	}
+
+	addNoteCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "addnote",
+			Action: addNote(v),
+			Usage:  "addnote [location] [text]: add a secure note containing [text] to the vault",
+		}
+	}
+
+	addLinkCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "addlink",
+			Action: addLink(v),
+			Usage:  "addlink [location] [target] [username]: add a login to the vault that always uses the password stored at [target]",
+		}
+	}
+
+	addSSHKeyCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "addsshkey",
+			Action: addSSHKey(v),
+			Usage:  "addsshkey [location] [keyfile]: add the ssh private key in [keyfile] to the vault",
+		}
+	}
+
+	sshAgentCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "sshagent",
+			Action: sshAgent(v),
+			Usage:  "sshagent [location]: add the ssh key at [location] to the running ssh-agent",
+		}
+	}
+
+	addCardCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "addcard",
+			Action: addCard(v),
+			Usage:  "addcard [-holder name] [-expiry MM/YY] [-cvv cvv] [-pin pin] [location] [number]: add a payment card to the vault",
+		}
+	}
+
+	addIdentityCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "addidentity",
+			Action: addIdentity(v),
+			Usage:  "addidentity [-address address] [-phone phone] [-email email] [-document name=number]... [location] [name]: add an identity to the vault",
+		}
+	}
+
+	rotateCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "rotate",
+			Action: rotate(v),
+			Usage:  "rotate [-length n] [-nosymbols] [-noambiguous] [-pronounceable] [-words n] [location]: replace the password at [location] with a generated one, keeping the old password in its history",
+		}
+	}
+
+	generatedCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "generated",
+			Action: generated(v),
+			Usage:  "generated [location]: show the passwords recently generated for [location], newest first",
+		}
+	}
+
+	undoCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "undo",
+			Action: undo(v),
+			Usage:  "undo: revert the most recent change to the vault since it was last saved",
+		}
+	}
+
+	templateCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "template",
+			Action: template(v),
+			Usage:  "template [-username username] [-url url] [-tags tag,tag] [-field name=value]... [-generate] [name], template -remove [name], template: save a template for addfromtemplate, remove it with -remove, or list the templates",
+		}
+	}
+
+	addFromTemplateCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "addfromtemplate",
+			Action: addFromTemplate(v),
+			Usage:  "addfromtemplate [location] [template]: add a credential at [location] pre-populated from [template]",
+		}
+	}
+
+	policyCmd = func(v *vault.Vault) repl.Command {
+		return repl.Command{
+			Name:   "policy",
+			Action: policy(v),
+			Usage:  "policy [-min n] [-max n] [-nosymbols] [-symbols chars] [-require lower,upper,digits,symbols] [location]: set the password policy generated passwords for [location] conform to. Without options the policy is removed",
+		}
+	}
+
 	genCmd = func(v *vault.Vault) repl.Command {
 		return repl.Command{
 			Name:   "gen",
 			Action: gen(v),
-			Usage:  "gen [location] [username]: generate a password and add it to the vault",
+			Usage:  "gen [-length n] [-nosymbols] [-noambiguous] [-pronounceable] [-words n] [-randomuser] [-plusaddress email] [location] [username]: generate a password, or with -words a diceware passphrase, and add it to the vault. Without options a mnemonic passphrase is generated. With -randomuser or -plusaddress the username may be omitted and is generated",
 		}
 	}
 )
 
 func list(v *vault.Vault) repl.ActionFunc {
 	return func(args []string) (string, error) {
-		locations, err := v.Locations()
+		metadata, err := v.LocationsMetadata()
 		if err != nil {
 			return "", err
 		}
+		// Favorites are listed first, marked with a star.
+		sort.SliceStable(metadata, func(i, j int) bool {
+			return metadata[i].Favorite && !metadata[j].Favorite
+		})
 		printstring := "Locations stored in this vault: "
-		for _, loc := range locations {
-			printstring += "\n" + loc
+		for _, md := range metadata {
+			printstring += "\n" + md.Location
+			if md.Kind != vault.KindLogin {
+				printstring += fmt.Sprintf(" (%v)", md.Kind)
+			}
+			if md.Favorite {
+				printstring += " *"
+			}
 		}
 		return printstring, nil
 	}
 }
 
+func favorite(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("favorite")
+		remove := fs.Bool("remove", false, "unmark the location as a favorite")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() != 1 {
+			return "", fmt.Errorf("favorite requires one argument. See help for usage.")
+		}
+
+		if err := v.SetFavorite(fs.Arg(0), !*remove); err != nil {
+			return "", err
+		}
+		if *remove {
+			return fmt.Sprintf("%v removed from favorites", fs.Arg(0)), nil
+		}
+		return fmt.Sprintf("%v added to favorites", fs.Arg(0)), nil
+	}
+}
+
+func alias(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("alias")
+		remove := fs.Bool("remove", false, "remove the alias")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+
+		if *remove {
+			if fs.NArg() != 1 {
+				return "", fmt.Errorf("alias -remove requires one argument. See help for usage.")
+			}
+			if err := v.Unalias(fs.Arg(0)); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%v removed successfully", fs.Arg(0)), nil
+		}
+		if fs.NArg() != 2 {
+			return "", fmt.Errorf("alias requires two arguments. See help for usage.")
+		}
+		if err := v.Alias(fs.Arg(0), fs.Arg(1)); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v is now an alias of %v", fs.Arg(1), fs.Arg(0)), nil
+	}
+}
+
 func save(v *vault.Vault, savePath string) repl.ActionFunc {
 	return func(args []string) (string, error) {
 		if err := v.Save(savePath); err != nil {
@@ -74,16 +425,37 @@ func save(v *vault.Vault, savePath string) repl.ActionFunc {
 
 func get(v *vault.Vault) repl.ActionFunc {
 	return func(args []string) (string, error) {
-		if len(args) == 0 {
+		fs := newFlagSet("get")
+		reveal := fs.Bool("reveal", false, "show card numbers, CVVs, PINs and document numbers unmasked")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() == 0 {
 			return "", fmt.Errorf("get requires at least one argument. See help for usage.")
 		}
-		location := args[0]
+		location := fs.Arg(0)
 		cred, err := v.Get(location)
 		if err != nil {
 			return "", err
 		}
 
-		return fmt.Sprintf("Username: %v\nPassword: %v", cred.Username, cred.Password), nil
+		var res string
+		switch cred.Kind {
+		case vault.KindNote:
+			return cred.Notes, nil
+		case vault.KindSSHKey:
+			return fmt.Sprintf("Public key: %v", cred.SSHKey.PublicKey), nil
+		case vault.KindCard:
+			res = cred.Card.Display(*reveal)
+		case vault.KindIdentity:
+			res = cred.Identity.Display(*reveal)
+		default:
+			res = fmt.Sprintf("Username: %v\nPassword: %v", cred.Username, cred.Password)
+		}
+		if cred.Notes != "" {
+			res += fmt.Sprintf("\nNotes:\n%v", cred.Notes)
+		}
+		return res, nil
 	}
 }
 
@@ -103,23 +475,1023 @@ func add(v *vault.Vault) repl.ActionFunc {
 		if err != nil {
 			return "", err
 		}
-		return fmt.Sprintf("%v added successfully", location), nil
+		return fmt.Sprintf("%v added successfully", location) + strengthWarning(password), nil
+	}
+}
+
+func edit(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 3 {
+			return "", fmt.Errorf("edit requires three arguments. See help for usage.")
+		}
+		location := args[0]
+		cred, err := v.Get(location)
+		if err != nil {
+			return "", err
+		}
+		edited := *cred
+		edited.Username = args[1]
+		edited.Password = args[2]
+		if err = v.Edit(location, edited); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v edited successfully", location) + strengthWarning(edited.Password), nil
+	}
+}
+
+// strengthWarning returns a warning to append to a command's output if
+// `password` is weak, or an empty string if it is not.
+func strengthWarning(password string) string {
+	strength := vault.EstimateStrength(password)
+	if !strength.Weak() {
+		return ""
+	}
+	return fmt.Sprintf("\nwarning: weak password, could be cracked in %v. %v", strength.CrackTime.Round(time.Second), strings.Join(strength.Feedback, ". "))
+}
+
+// generateFlags defines the flags shared by the gen and rotate commands on
+// `fs`, returning a function which builds the GenerateOptions they describe
+// once `fs` has been parsed.
+func generateFlags(fs *flag.FlagSet) func() vault.GenerateOptions {
+	var opts vault.GenerateOptions
+	fs.IntVar(&opts.Length, "length", vault.DefaultGenerateLength, "the length of the password")
+	fs.BoolVar(&opts.NoSymbols, "nosymbols", false, "exclude symbols from the password")
+	fs.BoolVar(&opts.ExcludeAmbiguous, "noambiguous", false, "exclude easily confused characters from the password")
+	fs.IntVar(&opts.Words, "words", 0, "generate a diceware passphrase of this many words")
+	pronounceable := fs.Bool("pronounceable", false, "generate a password of pronounceable syllables")
+	return func() vault.GenerateOptions {
+		if opts.Words > 0 {
+			opts.Mode = vault.ModePassphrase
+		} else if *pronounceable {
+			opts.Mode = vault.ModePronounceable
+		}
+		return opts
 	}
 }
 
 func gen(v *vault.Vault) repl.ActionFunc {
 	return func(args []string) (string, error) {
-		if len(args) != 2 {
+		fs := newFlagSet("gen")
+		opts := generateFlags(fs)
+		randomUser := fs.Bool("randomuser", false, "generate a random username")
+		plusAddress := fs.String("plusaddress", "", "generate a username by plus addressing this email address")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		generateUsername := *randomUser || *plusAddress != ""
+		if fs.NArg() != 2 && !(generateUsername && fs.NArg() == 1) {
 			return "", fmt.Errorf("gen requires two arguments. See help for usage.")
 		}
 
-		location := args[0]
-		username := args[1]
+		location := fs.Arg(0)
+		username := fs.Arg(1)
 
-		if err := v.Generate(location, username); err != nil {
+		var err error
+		if fs.NFlag() == 0 {
+			err = v.Generate(location, username)
+		} else {
+			o := opts()
+			if generateUsername {
+				o.Username = &vault.UsernameStyle{Email: *plusAddress}
+			}
+			err = v.GenerateWithOptions(location, username, o)
+		}
+		if err != nil {
 			return "", err
 		}
 
 		return fmt.Sprintf("%v generated successfully", location), nil
 	}
 }
+
+func rotate(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("rotate")
+		opts := generateFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() != 1 {
+			return "", fmt.Errorf("rotate requires one argument. See help for usage.")
+		}
+
+		if _, _, err := v.Rotate(fs.Arg(0), opts()); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v rotated successfully", fs.Arg(0)), nil
+	}
+}
+
+func generated(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("generated requires one argument. See help for usage.")
+		}
+
+		history, err := v.PasswordHistory(args[0])
+		if err != nil {
+			return "", err
+		}
+		printstring := fmt.Sprintf("Passwords generated for %v:", args[0])
+		for i := len(history) - 1; i >= 0; i-- {
+			printstring += fmt.Sprintf("\n%v: %v", history[i].GeneratedAt.Format(time.RFC3339), history[i].Password)
+		}
+		return printstring, nil
+	}
+}
+
+func undo(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if err := v.Undo(); err != nil {
+			return "", err
+		}
+		return "undone successfully", nil
+	}
+}
+
+func addNote(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) < 2 {
+			return "", fmt.Errorf("addnote requires at least two arguments. See help for usage.")
+		}
+
+		if err := v.AddNote(args[0], strings.Join(args[1:], " ")); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v added successfully", args[0]), nil
+	}
+}
+
+func addLink(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 3 {
+			return "", fmt.Errorf("addlink requires three arguments. See help for usage.")
+		}
+
+		if err := v.AddLink(args[0], args[1], args[2]); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v added successfully", args[0]), nil
+	}
+}
+
+func addCard(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("addcard")
+		var card vault.Card
+		fs.StringVar(&card.Holder, "holder", "", "the name of the card holder")
+		fs.StringVar(&card.Expiry, "expiry", "", "the expiry date of the card")
+		fs.StringVar(&card.CVV, "cvv", "", "the card verification value")
+		fs.StringVar(&card.PIN, "pin", "", "the card's pin")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() != 2 {
+			return "", fmt.Errorf("addcard requires two arguments. See help for usage.")
+		}
+
+		card.Number = fs.Arg(1)
+		if err := v.AddCard(fs.Arg(0), card); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v added successfully", fs.Arg(0)), nil
+	}
+}
+
+// charClasses maps the names of character classes accepted by the policy
+// command to the classes.
+var charClasses = map[string]vault.CharClass{
+	"lower":   vault.ClassLower,
+	"upper":   vault.ClassUpper,
+	"digits":  vault.ClassDigits,
+	"symbols": vault.ClassSymbols,
+}
+
+func policy(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("policy")
+		var p vault.Policy
+		fs.IntVar(&p.MinLength, "min", 0, "the minimum password length")
+		fs.IntVar(&p.MaxLength, "max", 0, "the maximum password length")
+		fs.StringVar(&p.Symbols, "symbols", "", "the symbols the site allows")
+		noSymbols := fs.Bool("nosymbols", false, "the site does not allow symbols")
+		require := fs.String("require", "", "comma separated character classes the password must contain")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() != 1 {
+			return "", fmt.Errorf("policy requires one argument. See help for usage.")
+		}
+
+		if *noSymbols {
+			p.Classes = vault.ClassAll &^ vault.ClassSymbols
+		}
+		if *require != "" {
+			for _, name := range strings.Split(*require, ",") {
+				class, ok := charClasses[name]
+				if !ok {
+					return "", fmt.Errorf("unknown character class %q", name)
+				}
+				p.Required |= class
+			}
+		}
+		if err := v.SetPolicy(fs.Arg(0), p); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("policy for %v set successfully", fs.Arg(0)), nil
+	}
+}
+
+func template(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("template")
+		var t vault.Template
+		fields := make(pairsFlag)
+		fs.StringVar(&t.Username, "username", "", "the username")
+		fs.StringVar(&t.URL, "url", "", "the URL")
+		tags := fs.String("tags", "", "comma separated tags")
+		fs.Var(fields, "field", "a custom field, as name=value")
+		generate := fs.Bool("generate", false, "generate a password for each credential")
+		remove := fs.Bool("remove", false, "remove the template")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+
+		if fs.NArg() == 0 {
+			names, err := v.Templates()
+			if err != nil {
+				return "", err
+			}
+			return "Templates stored in this vault: \n" + strings.Join(names, "\n"), nil
+		}
+		if fs.NArg() != 1 {
+			return "", fmt.Errorf("template requires one argument. See help for usage.")
+		}
+		if *remove {
+			if err := v.DeleteTemplate(fs.Arg(0)); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%v removed successfully", fs.Arg(0)), nil
+		}
+
+		if *tags != "" {
+			t.Tags = strings.Split(*tags, ",")
+		}
+		if len(fields) > 0 {
+			t.Fields = fields
+		}
+		if *generate {
+			t.Generate = &vault.GenerateOptions{}
+		}
+		if err := v.SetTemplate(fs.Arg(0), t); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("template %v saved successfully", fs.Arg(0)), nil
+	}
+}
+
+func addFromTemplate(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("addfromtemplate requires two arguments. See help for usage.")
+		}
+
+		if err := v.AddFromTemplate(args[0], args[1]); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v added successfully", args[0]), nil
+	}
+}
+
+// pairsFlag is a repeatable flag of name=value pairs, such as document
+// numbers or custom fields.
+type pairsFlag map[string]string
+
+func (d pairsFlag) String() string {
+	return ""
+}
+
+func (d pairsFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("%q must be of the form name=value", value)
+	}
+	d[value[:i]] = value[i+1:]
+	return nil
+}
+
+func addIdentity(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("addidentity")
+		var identity vault.Identity
+		documents := make(pairsFlag)
+		fs.StringVar(&identity.Address, "address", "", "the postal address")
+		fs.StringVar(&identity.Phone, "phone", "", "the phone number")
+		fs.StringVar(&identity.Email, "email", "", "the email address")
+		fs.Var(documents, "document", "a document number, as name=number")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() < 2 {
+			return "", fmt.Errorf("addidentity requires at least two arguments. See help for usage.")
+		}
+
+		identity.Name = strings.Join(fs.Args()[1:], " ")
+		if len(documents) > 0 {
+			identity.Documents = documents
+		}
+		if err := v.AddIdentity(fs.Arg(0), identity); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v added successfully", fs.Arg(0)), nil
+	}
+}
+
+func addSSHKey(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("addsshkey requires two arguments. See help for usage.")
+		}
+
+		private, err := ioutil.ReadFile(args[1])
+		if err != nil {
+			return "", err
+		}
+		passphrase, err := readPassphrase("Passphrase for " + args[1] + " (empty for none): ")
+		if err != nil {
+			return "", err
+		}
+		if err = v.AddSSHKey(args[0], vault.SSHKey{PrivateKey: string(private), Passphrase: passphrase}); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v added successfully", args[0]), nil
+	}
+}
+
+func sshAgent(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("sshagent requires one argument. See help for usage.")
+		}
+
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return "", fmt.Errorf("no ssh-agent is running: SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+
+		if err = v.AddSSHKeyToAgent(args[0], agent.NewClient(conn), 0); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v added to ssh-agent", args[0]), nil
+	}
+}
+
+func search(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("search requires one argument. See help for usage.")
+		}
+
+		matches, err := v.Search(args[0])
+		if err != nil {
+			return "", err
+		}
+		printstring := "Matching locations: "
+		for _, match := range matches {
+			printstring += "\n" + match.Location
+		}
+		return printstring, nil
+	}
+}
+
+func totp(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("totp requires one argument. See help for usage.")
+		}
+
+		code, remaining, err := v.TOTP(args[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v (expires in %vs)", code, remaining), nil
+	}
+}
+
+func hotp(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("hotp requires one argument. See help for usage.")
+		}
+		return v.HOTP(args[0])
+	}
+}
+
+func stats(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		st, err := v.Stats()
+		if err != nil {
+			return "", err
+		}
+
+		printstring := fmt.Sprintf("Entries: %v\nSize: %v bytes\nMissing usernames: %v", st.Entries, st.Size, st.MissingUsername)
+		if !st.Oldest.IsZero() {
+			printstring += fmt.Sprintf("\nOldest change: %v\nNewest change: %v", st.Oldest.Format(time.RFC3339), st.Newest.Format(time.RFC3339))
+		}
+
+		var folders []string
+		for folder := range st.Folders {
+			folders = append(folders, folder)
+		}
+		sort.Strings(folders)
+		for _, folder := range folders {
+			printstring += fmt.Sprintf("\n%v: %v", folder, st.Folders[folder])
+		}
+		return printstring, nil
+	}
+}
+
+func duplicates(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		groups, err := v.FindDuplicates()
+		if err != nil {
+			return "", err
+		}
+		if len(groups) == 0 {
+			return "no duplicates found", nil
+		}
+
+		var lines []string
+		for _, group := range groups {
+			lines = append(lines, fmt.Sprintf("%v: %v", group.Reason, strings.Join(group.Locations, ", ")))
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+}
+
+func meta(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		m, err := v.Meta()
+		if err != nil {
+			return "", err
+		}
+		fs := newFlagSet("meta")
+		fs.StringVar(&m.Name, "name", m.Name, "the name of the vault")
+		fs.StringVar(&m.Description, "description", m.Description, "a description of the vault")
+		if err = fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NFlag() > 0 {
+			if err = v.SetMeta(m); err != nil {
+				return "", err
+			}
+			return "vault metadata set successfully", nil
+		}
+
+		printstring := fmt.Sprintf("Name: %v\nDescription: %v", m.Name, m.Description)
+		if !m.CreatedAt.IsZero() {
+			printstring += fmt.Sprintf("\nCreated: %v", m.CreatedAt.Format(time.RFC3339))
+		}
+		printstring += fmt.Sprintf("\nModified: %v\nFormat version: %v", m.ModifiedAt.Format(time.RFC3339), m.Version)
+		return printstring, nil
+	}
+}
+
+func normalize(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		switch {
+		case len(args) == 0:
+			enabled, err := v.NormalizesLocations()
+			if err != nil {
+				return "", err
+			}
+			if enabled {
+				return "location normalization is on", nil
+			}
+			return "location normalization is off", nil
+		case len(args) == 1 && (args[0] == "on" || args[0] == "off"):
+			if err := v.SetNormalizeLocations(args[0] == "on"); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("location normalization turned %v", args[0]), nil
+		default:
+			return "", fmt.Errorf("normalize requires on or off. See help for usage.")
+		}
+	}
+}
+
+func hidden(v *vault.Vault, vaultPath string) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 0 {
+			return "", fmt.Errorf("hidden takes no arguments. See help for usage.")
+		}
+		passphrase, err := readPassphrase("Passphrase for the hidden vault: ")
+		if err != nil {
+			return "", err
+		}
+		confirm, err := readPassphrase("Enter the same passphrase again: ")
+		if err != nil {
+			return "", err
+		}
+		if passphrase != confirm {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+		if passphrase == "" {
+			return "", fmt.Errorf("a hidden vault needs a passphrase")
+		}
+
+		h, err := v.NewHidden(passphrase)
+		if err != nil {
+			return "", err
+		}
+		defer h.Close()
+		if err = h.Save(vaultPath); err != nil {
+			return "", err
+		}
+		return "hidden vault created. Open the vault with its passphrase to use it", nil
+	}
+}
+
+func operationLog(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		ops, err := v.Log()
+		if err != nil {
+			return "", err
+		}
+		head, err := v.VerifyLog()
+		if err != nil {
+			return "", err
+		}
+		if len(ops) == 0 {
+			return "no operations recorded", nil
+		}
+
+		var printstring string
+		for _, op := range ops {
+			printstring += fmt.Sprintf("%v %v\n", op.Time.Format(time.RFC3339), op.User)
+			for _, line := range strings.Split(strings.TrimSpace(op.Changes.String()), "\n") {
+				if line != "" {
+					printstring += "  " + line + "\n"
+				}
+			}
+		}
+		return printstring + "log verified, head " + head, nil
+	}
+}
+
+func changeCipher(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) > 1 {
+			return "", fmt.Errorf("cipher takes at most one argument. See help for usage.")
+		}
+		if len(args) == 0 {
+			for name, id := range ciphers {
+				if id == v.Cipher() {
+					return name, nil
+				}
+			}
+			return "", vault.ErrUnsupportedCipher
+		}
+
+		id, ok := ciphers[args[0]]
+		if !ok {
+			return "", fmt.Errorf("unknown cipher %q. See help for usage.", args[0])
+		}
+		passphrase, err := readPassphrase("Passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if err = v.ChangeCipher(passphrase, id); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("vault re-encrypted using %v", args[0]), nil
+	}
+}
+
+// paddings maps the names accepted by the padding command to the schemes they
+// select.
+var paddings = map[string]vault.Padding{
+	"padme":      vault.PaddingPadme,
+	"poweroftwo": vault.PaddingPowerOfTwo,
+	"none":       vault.PaddingNone,
+}
+
+func padding(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) > 1 {
+			return "", fmt.Errorf("padding takes at most one argument. See help for usage.")
+		}
+		if len(args) == 0 {
+			current, err := v.Padding()
+			if err != nil {
+				return "", err
+			}
+			for name, scheme := range paddings {
+				if scheme == current {
+					return name, nil
+				}
+			}
+			return "", fmt.Errorf("unknown padding scheme %v", current)
+		}
+
+		scheme, ok := paddings[args[0]]
+		if !ok {
+			return "", fmt.Errorf("unknown padding scheme %q. See help for usage.", args[0])
+		}
+		if err := v.SetPadding(scheme); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("vault padded using %v", args[0]), nil
+	}
+}
+
+// compressions maps the names accepted by the compression command to the
+// algorithms they select.
+var compressions = map[string]vault.Compression{
+	"none": vault.CompressionNone,
+	"gzip": vault.CompressionGzip,
+}
+
+func compression(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) > 1 {
+			return "", fmt.Errorf("compression takes at most one argument. See help for usage.")
+		}
+		if len(args) == 0 {
+			for name, algorithm := range compressions {
+				if algorithm == v.Compression() {
+					return name, nil
+				}
+			}
+			return "", vault.ErrUnsupportedCompression
+		}
+
+		algorithm, ok := compressions[args[0]]
+		if !ok {
+			return "", fmt.Errorf("unknown compression algorithm %q. See help for usage.", args[0])
+		}
+		if err := v.SetCompression(algorithm); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("vault compressed using %v", args[0]), nil
+	}
+}
+
+func backups(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) > 2 {
+			return "", fmt.Errorf("backups takes at most two arguments. See help for usage.")
+		}
+		if len(args) == 0 {
+			opts, err := v.Backups()
+			if err != nil {
+				return "", err
+			}
+			if opts.Dir != "" {
+				return fmt.Sprintf("%v backups in %v", opts.Count, opts.Dir), nil
+			}
+			return fmt.Sprintf("%v backups", opts.Count), nil
+		}
+
+		count, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "", err
+		}
+		opts := vault.BackupOptions{Count: count}
+		if len(args) == 2 {
+			opts.Dir = args[1]
+		}
+		if err = v.SetBackups(opts); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("keeping %v backups", count), nil
+	}
+}
+
+func sign(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) > 1 {
+			return "", fmt.Errorf("sign takes at most one argument. See help for usage.")
+		}
+		if len(args) == 1 {
+			switch args[0] {
+			case "on":
+				_, key, err := ed25519.GenerateKey(rand.Reader)
+				if err != nil {
+					return "", err
+				}
+				if err = v.SetSigningKey(key); err != nil {
+					return "", err
+				}
+			case "off":
+				if err := v.SetSigningKey(nil); err != nil {
+					return "", err
+				}
+				return "vault will no longer be signed", nil
+			default:
+				return "", fmt.Errorf("sign takes on or off. See help for usage.")
+			}
+		}
+		publicKey, err := v.SigningPublicKey()
+		if err != nil {
+			return "", err
+		}
+		if publicKey == nil {
+			return "vault is not signed", nil
+		}
+		return hex.EncodeToString(publicKey), nil
+	}
+}
+
+func splitKey(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("splitkey requires two arguments. See help for usage.")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "", err
+		}
+		k, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "", err
+		}
+		shares, err := v.SplitKey(n, k)
+		if err != nil {
+			return "", err
+		}
+
+		var lines []string
+		for _, share := range shares {
+			lines = append(lines, share.String())
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+}
+
+func rotateKey(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		if len(args) != 0 {
+			return "", fmt.Errorf("rotatekey takes no arguments. See help for usage.")
+		}
+		if err := v.RotateDataKey(); err != nil {
+			return "", err
+		}
+		return "data key rotated successfully", nil
+	}
+}
+
+func keySlot(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("keyslot")
+		add := fs.Bool("add", false, "add a key slot")
+		keyfile := fs.String("keyfile", "", "the keyfile the added slot needs along with its passphrase")
+		yubikeySlot := fs.Int("yubikey", 0, "the slot of the YubiKey whose challenge-response the added slot needs")
+		fido2Device := fs.String("fido2", "", "the path of the FIDO2 security key to enroll in the added slot")
+		gpgRecipient := fs.String("gpg", "", "the OpenPGP key to add a slot for")
+		tpm := fs.Bool("tpm", false, "add a key slot sealed by this machine's TPM")
+		pkcs11Label := fs.String("pkcs11", "", "the label of the PKCS#11 token's key to add a slot for")
+		pkcs11Module := fs.String("module", "", "the path of the PKCS#11 module of the token")
+		threshold := fs.Int("threshold", 0, "the number of approvers needed to unlock the added threshold slot")
+		approvers := fs.String("approvers", "", "the comma separated names of the approvers of the added threshold slot")
+		remove := fs.Int("remove", -1, "the number of the key slot to remove")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() != 0 {
+			return "", fmt.Errorf("keyslot takes no arguments. See help for usage.")
+		}
+
+		switch {
+		case (*add || *gpgRecipient != "" || *tpm || *pkcs11Label != "" || *threshold > 0) && *remove >= 0:
+			return "", fmt.Errorf("keyslot cannot both add and remove a slot. See help for usage.")
+		case *threshold > 0:
+			if *approvers == "" {
+				return "", fmt.Errorf("keyslot -threshold requires -approvers. See help for usage.")
+			}
+			var slotApprovers []vault.Approver
+			for _, name := range strings.Split(*approvers, ",") {
+				name = strings.TrimSpace(name)
+				passphrase, err := readPassphrase(fmt.Sprintf("Passphrase of approver %v: ", name))
+				if err != nil {
+					return "", err
+				}
+				confirm, err := readPassphrase("Enter the same passphrase again: ")
+				if err != nil {
+					return "", err
+				}
+				if passphrase != confirm {
+					return "", fmt.Errorf("passphrases do not match")
+				}
+				slotApprovers = append(slotApprovers, vault.Approver{Name: name, Passphrase: passphrase})
+			}
+			slot, err := v.AddThresholdKeySlot(*threshold, slotApprovers)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("added key slot %v unlocked by %v of %v", slot, *threshold, *approvers), nil
+		case *pkcs11Label != "":
+			if *pkcs11Module == "" {
+				return "", fmt.Errorf("keyslot -pkcs11 requires -module. See help for usage.")
+			}
+			slot, err := v.AddPKCS11KeySlot(*pkcs11Label, vault.PKCS11Tool{Module: *pkcs11Module})
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("added key slot %v for %v", slot, *pkcs11Label), nil
+		case *tpm:
+			slot, err := v.AddTPMKeySlot(vault.TPM2Tools{})
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("added key slot %v sealed by the TPM", slot), nil
+		case *gpgRecipient != "":
+			slot, err := v.AddGPGKeySlot(*gpgRecipient, vault.GPGEncrypt)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("added key slot %v for %v", slot, *gpgRecipient), nil
+		case *add:
+			passphrase, err := readPassphrase("Passphrase for the new key slot: ")
+			if err != nil {
+				return "", err
+			}
+			confirm, err := readPassphrase("Enter the same passphrase again: ")
+			if err != nil {
+				return "", err
+			}
+			if passphrase != confirm {
+				return "", fmt.Errorf("passphrases do not match")
+			}
+			if passphrase == "" && *keyfile == "" && *yubikeySlot == 0 && *fido2Device == "" {
+				return "", fmt.Errorf("a key slot needs a passphrase, a keyfile, a YubiKey or a security key")
+			}
+			opts := vault.KeySlotOptions{Keyfile: *keyfile}
+			if *yubikeySlot != 0 {
+				fmt.Println("Touch your YubiKey if it flashes.")
+				opts.ChallengeResponse = vault.YubiKeyChallengeResponse(*yubikeySlot)
+			}
+			if *fido2Device != "" {
+				fmt.Println("Touch your security key when it flashes.")
+				opts.FIDO2 = vault.FIDO2Device{Path: *fido2Device}
+			}
+			slot, err := v.AddKeySlotWithOptions(passphrase, opts)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("added key slot %v", slot), nil
+		case *remove >= 0:
+			if err := v.RemoveKeySlot(*remove); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("removed key slot %v", *remove), nil
+		}
+
+		var lines []string
+		for _, slot := range v.ListKeySlots() {
+			kdf := "scrypt"
+			if slot.KDF.KDF == vault.KDFArgon2id {
+				kdf = "argon2id"
+			}
+			line := fmt.Sprintf("%v: %v", slot.Slot, kdf)
+			if slot.Keyfile {
+				line += ", keyfile"
+			}
+			if slot.ChallengeResponse {
+				line += ", challenge-response"
+			}
+			if slot.FIDO2 {
+				line += ", FIDO2"
+			}
+			if slot.GPGRecipient != "" {
+				line = fmt.Sprintf("%v: gpg, %v", slot.Slot, slot.GPGRecipient)
+			}
+			if slot.TPM {
+				line = fmt.Sprintf("%v: tpm", slot.Slot)
+			}
+			if slot.PKCS11Label != "" {
+				line = fmt.Sprintf("%v: pkcs11, %v", slot.Slot, slot.PKCS11Label)
+			}
+			if slot.Threshold > 0 {
+				line = fmt.Sprintf("%v: %v of %v", slot.Slot, slot.Threshold, strings.Join(slot.Approvers, ", "))
+			}
+			if slot.Current {
+				line += " (current)"
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+}
+
+// newFlagSet returns a FlagSet used to parse the arguments of the command
+// `name`. Parse errors are returned rather than printed.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+func export(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("export")
+		plaintext := fs.Bool("plaintext", false, "confirm that the export will not be encrypted")
+		format := fs.String("format", "json", "the format of the export")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() != 1 {
+			return "", fmt.Errorf("export requires one argument. See help for usage.")
+		}
+		if !*plaintext {
+			return "", fmt.Errorf("export writes every password unencrypted. Pass -plaintext to confirm.")
+		}
+
+		if *format != "json" && *format != "keepass" {
+			return "", fmt.Errorf("unsupported export format %q. See help for usage.", *format)
+		}
+
+		exportPath := fs.Arg(0)
+		f, err := os.OpenFile(exportPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		if *format == "keepass" {
+			err = v.ExportKeePassXML(f)
+		} else {
+			err = v.ExportJSON(f, vault.ExportOptions{Indent: true})
+		}
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("exported to %v", exportPath), nil
+	}
+}
+
+func importCredentials(v *vault.Vault) repl.ActionFunc {
+	return func(args []string) (string, error) {
+		fs := newFlagSet("import")
+		format := fs.String("format", "", "the format of the file to import")
+		var mapping vault.ColumnMapping
+		fs.StringVar(&mapping.Location, "location", "", "csv column containing the location")
+		fs.StringVar(&mapping.Username, "username", "", "csv column containing the username")
+		fs.StringVar(&mapping.Password, "password", "", "csv column containing the password")
+		fs.StringVar(&mapping.Notes, "notes", "", "csv column containing the notes")
+		fs.StringVar(&mapping.URL, "url", "", "csv column containing the url")
+		if err := fs.Parse(args); err != nil {
+			return "", err
+		}
+		if fs.NArg() != 1 {
+			return "", fmt.Errorf("import requires one argument. See help for usage.")
+		}
+
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		var report vault.ImportReport
+		switch *format {
+		case "csv":
+			report, err = v.ImportCSV(f, mapping)
+		case "kdbx":
+			var passphrase string
+			passphrase, err = readPassphrase("Password for " + fs.Arg(0) + ": ")
+			if err != nil {
+				return "", err
+			}
+			report, err = v.ImportKDBX(f, passphrase)
+		case "1password":
+			report, err = v.ImportOnePassword(f)
+		case "lastpass":
+			report, err = v.ImportLastPass(f)
+		case "bitwarden":
+			report, err = v.ImportBitwarden(f)
+		case "pass":
+			report, err = v.ImportPass(fs.Arg(0), nil)
+		case "chrome":
+			report, err = v.ImportChrome(f)
+		case "firefox":
+			report, err = v.ImportFirefox(f)
+		default:
+			return "", fmt.Errorf("unsupported import format %q. See help for usage.", *format)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		printstring := fmt.Sprintf("imported %v credentials", len(report.Imported))
+		for _, location := range report.Skipped {
+			printstring += "\nskipped existing location " + location
+		}
+		return printstring, nil
+	}
+}
@@ -1,12 +1,34 @@
 package main
 
 import (
-	"github.com/johnathanhowell/masterkey/vault"
-	"reflect"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/johnathanhowell/masterkey/vault"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
+// relaxDefaults runs the test `t` without a minimum passphrase strength or
+// KDF calibration, as the tests use short passphrases, restoring the defaults
+// when it ends.
+func relaxDefaults(t *testing.T) {
+	min, target := vault.MinPassphraseEntropy, vault.KDFTargetDuration
+	vault.MinPassphraseEntropy, vault.KDFTargetDuration = 0, 0
+	t.Cleanup(func() {
+		vault.MinPassphraseEntropy, vault.KDFTargetDuration = min, target
+	})
+}
+
 func TestListCommand(t *testing.T) {
+	relaxDefaults(t)
 	v, err := vault.New("testpass")
 	if err != nil {
 		t.Fatal(err)
@@ -23,7 +45,7 @@ func TestListCommand(t *testing.T) {
 		t.Fatal("expected empty vault to have empty list()")
 	}
 
-	err = v.Add("testlocation", vault.Credential{"testuser", "testpass"})
+	err = v.Add("testlocation", vault.Credential{Username: "testuser", Password: "testpass"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -36,9 +58,68 @@ func TestListCommand(t *testing.T) {
 	if res != "Locations stored in this vault: \ntestlocation" {
 		t.Fatal("incorrect output from list cmd")
 	}
+
+	err = v.AddNote("testnote", "some notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = listcmd([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "Locations stored in this vault: \ntestlocation\ntestnote (note)" {
+		t.Fatalf("list cmd did not mark notes: %q", res)
+	}
+
+	if _, err = favorite(v)([]string{"testnote"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err = listcmd([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "Locations stored in this vault: \ntestnote (note) *\ntestlocation" {
+		t.Fatalf("list cmd did not list favorites first: %q", res)
+	}
+	if _, err = favorite(v)([]string{"-remove", "testnote"}); err != nil {
+		t.Fatal(err)
+	}
+	if favorites, _ := v.Favorites(); len(favorites) != 0 {
+		t.Fatal("favorite -remove did not unmark the location")
+	}
+}
+
+func TestAddNoteCmd(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addnotecmd := addNote(v)
+	_, err = addnotecmd([]string{"testnote"})
+	if err == nil {
+		t.Fatal("expected addnote cmd to fail with one arg")
+	}
+	res, err := addnotecmd([]string{"testnote", "some", "notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "testnote added successfully" {
+		t.Fatal("addnote returned the incorrect result")
+	}
+
+	res, err = get(v)([]string{"testnote"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "some notes" {
+		t.Fatalf("get cmd did not print the note: %q", res)
+	}
 }
 
 func TestGetCmd(t *testing.T) {
+	relaxDefaults(t)
 	v, err := vault.New("testpass")
 	if err != nil {
 		t.Fatal(err)
@@ -58,6 +139,7 @@ func TestGetCmd(t *testing.T) {
 }
 
 func TestAddCmd(t *testing.T) {
+	relaxDefaults(t)
 	v, err := vault.New("testpass")
 	if err != nil {
 		t.Fatal(err)
@@ -72,8 +154,8 @@ func TestAddCmd(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if res != "testlocation added successfully" {
-		t.Fatal("add returned the incorrect result")
+	if !strings.HasPrefix(res, "testlocation added successfully\nwarning: weak password") {
+		t.Fatal("add returned the incorrect result", res)
 	}
 	cred, err := v.Get("testlocation")
 	if err != nil {
@@ -87,7 +169,65 @@ func TestAddCmd(t *testing.T) {
 	}
 }
 
+func TestEditCmd(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", vault.Credential{Username: "testusername", Password: "testpassword", Notes: "notes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	editcmd := edit(v)
+	if _, err = editcmd([]string{"testlocation"}); err == nil {
+		t.Fatal("expected edit cmd to fail with one arg")
+	}
+	res, err := editcmd([]string{"testlocation", "newusername", "vX9#qL2!mR7$wK4@"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "testlocation edited successfully" {
+		t.Fatal("edit returned the incorrect result", res)
+	}
+	cred, err := v.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "newusername" || cred.Password != "vX9#qL2!mR7$wK4@" || cred.Notes != "notes" {
+		t.Fatal("edit cmd did not edit the credential")
+	}
+	if len(cred.History) != 1 || cred.History[0].Credential.Password != "testpassword" {
+		t.Fatal("edit cmd did not keep the previous version")
+	}
+}
+
+func TestUndoCmd(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = undo(v)([]string{}); err != vault.ErrNothingToUndo {
+		t.Fatal("expected undo cmd to fail with nothing to undo, got", err)
+	}
+	if _, err = add(v)([]string{"testlocation", "testusername", "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := undo(v)([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "undone successfully" {
+		t.Fatal("undo returned the incorrect result", res)
+	}
+	if _, err = v.Get("testlocation"); err != vault.ErrNoSuchCredential {
+		t.Fatal("undo cmd did not revert the add")
+	}
+}
+
 func TestGenCommand(t *testing.T) {
+	relaxDefaults(t)
 	v, err := vault.New("testpass")
 	if err != nil {
 		t.Fatal(err)
@@ -115,9 +255,141 @@ func TestGenCommand(t *testing.T) {
 	if cred.Password == "" {
 		t.Fatal("gencmd did not generate a password")
 	}
+
+	_, err = gencmd([]string{"-length", "12", "-nosymbols", "bank", "testusername"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err = v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cred.Password) != 12 || strings.ContainsAny(cred.Password, "!#$%&*+-.:;=?@^_~") {
+		t.Fatalf("gencmd did not apply the options, got %q", cred.Password)
+	}
+
+	_, err = gencmd([]string{"-words", "4", "tv", "testusername"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err = v.Get("tv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strings.Fields(cred.Password)) != 4 {
+		t.Fatalf("gencmd did not generate a 4 word passphrase, got %q", cred.Password)
+	}
+
+	_, err = gencmd([]string{"-plusaddress", "me@example.com", "forum"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err = v.Get("forum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cred.Username, "me+") || !strings.HasSuffix(cred.Username, "@example.com") {
+		t.Fatalf("gencmd did not generate a plus-addressed username, got %q", cred.Username)
+	}
+}
+
+func TestRotateCommand(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("github", vault.Credential{Username: "testuser", Password: "oldpass"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rotatecmd := rotate(v)
+	if _, err = rotatecmd([]string{}); err == nil {
+		t.Fatal("expected rotate cmd to fail with no args")
+	}
+	res, err := rotatecmd([]string{"-length", "16", "github"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "github rotated successfully" {
+		t.Fatal("rotate did not return successfully")
+	}
+	cred, err := v.Get("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cred.Password) != 16 || len(cred.History) != 1 {
+		t.Fatal("rotate cmd did not rotate the password")
+	}
+
+	res, err = generated(v)([]string{"github"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res, "Passwords generated for github:\n") || !strings.HasSuffix(res, ": "+cred.Password) {
+		t.Fatalf("generated cmd did not show the rotated password: %q", res)
+	}
+}
+
+func TestPolicyCommand(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policycmd := policy(v)
+	if _, err = policycmd([]string{"-require", "digits,emoji", "bank"}); err == nil {
+		t.Fatal("expected policy cmd to fail with an unknown class")
+	}
+	if _, err = policycmd([]string{"-max", "12", "-nosymbols", "-require", "digits", "bank"}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := v.GetPolicy("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p != (vault.Policy{MaxLength: 12, Classes: vault.ClassLower | vault.ClassUpper | vault.ClassDigits, Required: vault.ClassDigits}) {
+		t.Fatalf("policy cmd set the wrong policy %+v", *p)
+	}
+
+	if _, err = gen(v)([]string{"-length", "20", "bank", "testuser"}); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Check(cred.Password) != nil {
+		t.Fatalf("gen cmd generated %q, which does not conform to the policy", cred.Password)
+	}
+}
+
+func TestMetaCommand(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metacmd := meta(v)
+	if _, err = metacmd([]string{"-name", "personal"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = metacmd([]string{"-description", "home accounts"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := metacmd([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res, "Name: personal\nDescription: home accounts\nCreated: ") {
+		t.Fatalf("meta cmd did not show the metadata: %q", res)
+	}
 }
 
 func TestSaveCommand(t *testing.T) {
+	relaxDefaults(t)
 	v, err := vault.New("testpass")
 	if err != nil {
 		t.Fatal(err)
@@ -125,7 +397,7 @@ func TestSaveCommand(t *testing.T) {
 
 	savecmd := save(v, "testvault")
 
-	testcredential := vault.Credential{"testuser", "testpass"}
+	testcredential := vault.Credential{Username: "testuser", Password: "testpass"}
 
 	err = v.Add("testlocation", testcredential)
 	if err != nil {
@@ -148,7 +420,354 @@ func TestSaveCommand(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(cred, &testcredential) {
+	if cred.Username != testcredential.Username || cred.Password != testcredential.Password {
 		t.Fatalf("expected on-disk vault to have test credential after save cmd, wanted %v got %v\n", testcredential, cred)
 	}
 }
+
+func TestAddCardCmd(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addcardcmd := addCard(v)
+	_, err = addcardcmd([]string{"visa"})
+	if err == nil {
+		t.Fatal("expected addcard cmd to fail with one arg")
+	}
+	_, err = addcardcmd([]string{"-expiry", "12/25", "-cvv", "123", "visa", "4111111111111111"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getcmd := get(v)
+	res, err := getcmd([]string{"visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "Number: •••• •••• •••• 1111\nExpiry: 12/25\nCVV: •••" {
+		t.Fatalf("get cmd did not mask the card: %q", res)
+	}
+	res, err = getcmd([]string{"-reveal", "visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "Number: 4111111111111111\nExpiry: 12/25\nCVV: 123" {
+		t.Fatalf("get cmd did not reveal the card: %q", res)
+	}
+}
+
+func TestAddIdentityCmd(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addidentitycmd := addIdentity(v)
+	_, err = addidentitycmd([]string{"me"})
+	if err == nil {
+		t.Fatal("expected addidentity cmd to fail with one arg")
+	}
+	_, err = addidentitycmd([]string{"-document", "passport", "me", "Test", "User"})
+	if err == nil {
+		t.Fatal("expected addidentity cmd to fail with an invalid document")
+	}
+	_, err = addidentitycmd([]string{"-email", "test@example.com", "-document", "passport=X123", "me", "Test", "User"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getcmd := get(v)
+	res, err := getcmd([]string{"me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "Name: Test User\nEmail: test@example.com\npassport: ••••" {
+		t.Fatalf("get cmd did not mask the identity: %q", res)
+	}
+	res, err = getcmd([]string{"-reveal", "me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "Name: Test User\nEmail: test@example.com\npassport: X123" {
+		t.Fatalf("get cmd did not reveal the identity: %q", res)
+	}
+}
+
+func TestSSHKeyCommands(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile("testkey", pem.EncodeToMemory(block), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testkey")
+
+	defer func(old func(string) (string, error)) { readPassphrase = old }(readPassphrase)
+	readPassphrase = func(string) (string, error) { return "", nil }
+
+	res, err := addSSHKey(v)([]string{"web", "testkey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "web added successfully" {
+		t.Fatal("addsshkey returned the incorrect result")
+	}
+	res, err = get(v)([]string{"web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res, "Public key: ssh-ed25519 ") {
+		t.Fatalf("get cmd did not print the public key: %q", res)
+	}
+
+	dir, err := ioutil.TempDir("", "masterkey-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := net.Listen("unix", filepath.Join(dir, "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	keyring := agent.NewKeyring()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		agent.ServeAgent(keyring, conn)
+	}()
+
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Setenv("SSH_AUTH_SOCK", l.Addr().String())
+	if _, err = sshAgent(v)([]string{"web"}); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := keyring.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatal("sshagent cmd did not add the key to the agent")
+	}
+}
+
+func TestSearchCommand(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searchcmd := search(v)
+	_, err = searchcmd([]string{})
+	if err == nil {
+		t.Fatal("expected search cmd to fail with no args")
+	}
+
+	err = v.Add("github.com/personal", vault.Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("bank", vault.Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := searchcmd([]string{"gh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "Matching locations: \ngithub.com/personal" {
+		t.Fatalf("incorrect output from search cmd: %q", res)
+	}
+}
+
+func TestTOTPCommand(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("github", vault.Credential{Username: "testuser", Password: "testpass", TOTPSecret: "JBSWY3DPEHPK3PXP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totpcmd := totp(v)
+	_, err = totpcmd([]string{})
+	if err == nil {
+		t.Fatal("expected totp cmd to fail with no args")
+	}
+	res, err := totpcmd([]string{"github"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) < 6 || !strings.Contains(res, "(expires in ") {
+		t.Fatalf("incorrect output from totp cmd: %q", res)
+	}
+}
+
+func TestHOTPCommand(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("server", vault.Credential{Username: "testuser", HOTPSecret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hotpcmd := hotp(v)
+	_, err = hotpcmd([]string{})
+	if err == nil {
+		t.Fatal("expected hotp cmd to fail with no args")
+	}
+	res, err := hotpcmd([]string{"server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "755224" {
+		t.Fatalf("incorrect output from hotp cmd: %q", res)
+	}
+}
+
+func TestStatsCommand(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Add("work/github", vault.Credential{Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := stats(v)([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res, "Entries: 1\n") || !strings.Contains(res, "Missing usernames: 1") {
+		t.Fatalf("incorrect output from stats cmd: %q", res)
+	}
+	if !strings.HasSuffix(res, "\nwork: 1") {
+		t.Fatalf("stats cmd did not print folder counts: %q", res)
+	}
+}
+
+func TestExportCommand(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation", vault.Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exportcmd := export(v)
+	_, err = exportcmd([]string{})
+	if err == nil {
+		t.Fatal("expected export cmd to fail with no args")
+	}
+	_, err = exportcmd([]string{"testexport.json"})
+	if err == nil {
+		t.Fatal("expected export cmd to fail without -plaintext")
+	}
+	if _, err = os.Stat("testexport.json"); !os.IsNotExist(err) {
+		t.Fatal("export cmd wrote a file without -plaintext")
+	}
+
+	res, err := exportcmd([]string{"-plaintext", "testexport.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testexport.json")
+	if res != "exported to testexport.json" {
+		t.Fatal("export returned the incorrect result")
+	}
+	exported, err := ioutil.ReadFile("testexport.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(exported), `"password": "testpass"`) {
+		t.Fatal("export cmd did not export the vault")
+	}
+
+	_, err = exportcmd([]string{"-plaintext", "-format", "keepass", "testexport.xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testexport.xml")
+	exported, err = ioutil.ReadFile("testexport.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(exported), "<KeePassFile>") {
+		t.Fatal("export cmd did not export the vault as KeePass XML")
+	}
+}
+
+func TestImportCommand(t *testing.T) {
+	relaxDefaults(t)
+	v, err := vault.New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("existing", vault.Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile("testimport.csv", []byte("Title,Login,Secret\nbank,testuser,testpass\nexisting,testuser,testpass\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testimport.csv")
+
+	importcmd := importCredentials(v)
+	_, err = importcmd([]string{})
+	if err == nil {
+		t.Fatal("expected import cmd to fail with no args")
+	}
+	_, err = importcmd([]string{"-format", "xml", "testimport.csv"})
+	if err == nil {
+		t.Fatal("expected import cmd to fail with an unsupported format")
+	}
+
+	res, err := importcmd([]string{"-format", "csv", "-location", "Title", "-username", "Login", "-password", "Secret", "testimport.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "imported 1 credentials\nskipped existing location existing" {
+		t.Fatalf("import returned the incorrect result: %q", res)
+	}
+	cred, err := v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" {
+		t.Fatal("import cmd did not import the credential")
+	}
+}
@@ -1,28 +1,182 @@
 package main
 
 import (
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"strings"
+	"time"
 
+	"filippo.io/age"
 	"github.com/howeyc/gopass"
 	"github.com/johnathanhowell/masterkey/repl"
 	"github.com/johnathanhowell/masterkey/vault"
 )
 
-const usage = `Usage: masterkey [-new] vault`
+const usage = `Usage: masterkey [-new [-argon2id | -scryptn n] [-cipher name] [-force]] [-keyfile path] [-yubikey slot] [-fido2 device] [-gpg [-recipients list]] [-age [-identity path] [-recipients list]] [-cache ttl | -forget] [-tpm] [-pkcs11 module] [-threshold] [-shares k] [-readonly] vault
+       masterkey -verify pubkey vault
+       masterkey -pqkeygen path`
+
+// ciphers maps the names accepted by -cipher to the ciphers they select.
+var ciphers = map[string]vault.CipherID{
+	"xsalsa20poly1305":  vault.CipherXSalsa20Poly1305,
+	"aes256gcm":         vault.CipherAES256GCM,
+	"aes256gcmsiv":      vault.CipherAES256GCMSIV,
+	"xchacha20poly1305": vault.CipherXChaCha20Poly1305,
+}
 
 func die(err error) {
 	fmt.Println(err)
 	os.Exit(1)
 }
 
+// confirm asks the user `question`, returning true if they answer yes.
+func confirm(question string) bool {
+	fmt.Print(question + " [y/N] ")
+	var answer string
+	fmt.Scanln(&answer)
+	return strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+}
+
+// ageKeys returns the identities and recipients of an age vault. The
+// identities are read from `identityPath`, and the vault is encrypted to
+// their recipients along with the comma separated `recipientList`, either of
+// which may hold post-quantum hybrid keys. If neither is given, the vault is
+// encrypted to a passphrase, which is confirmed if `create` is true.
+func ageKeys(identityPath string, recipientList string, create bool) ([]age.Identity, []age.Recipient, error) {
+	var identities []age.Identity
+	var recipients []age.Recipient
+	if identityPath != "" {
+		data, err := ioutil.ReadFile(identityPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Hybrid identities are parsed by masterkey, and every other
+		// line by age.
+		var ageLines []string
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "MASTERKEY-PQ-SECRET-KEY-") {
+				identity, err := vault.ParseHybridIdentity(line)
+				if err != nil {
+					return nil, nil, err
+				}
+				identities = append(identities, identity)
+			} else if line != "" && !strings.HasPrefix(line, "#") {
+				ageLines = append(ageLines, line)
+			}
+		}
+		if len(ageLines) > 0 {
+			parsed, err := age.ParseIdentities(strings.NewReader(strings.Join(ageLines, "\n")))
+			if err != nil {
+				return nil, nil, err
+			}
+			identities = append(identities, parsed...)
+		}
+		for _, identity := range identities {
+			switch identity := identity.(type) {
+			case *age.X25519Identity:
+				recipients = append(recipients, identity.Recipient())
+			case *vault.HybridIdentity:
+				recipients = append(recipients, identity.Recipient())
+			}
+		}
+	}
+	if recipientList != "" {
+		for _, s := range strings.Split(recipientList, ",") {
+			s = strings.TrimSpace(s)
+			var recipient age.Recipient
+			var err error
+			if strings.HasPrefix(s, "masterkey-pq1") {
+				recipient, err = vault.ParseHybridRecipient(s)
+			} else {
+				recipient, err = age.ParseX25519Recipient(s)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			recipients = append(recipients, recipient)
+		}
+	}
+	if len(identities) > 0 || len(recipients) > 0 {
+		return identities, recipients, nil
+	}
+
+	fmt.Print("Passphrase: ")
+	passphrase, err := gopass.GetPasswd()
+	if err != nil {
+		return nil, nil, err
+	}
+	if create {
+		fmt.Print("Enter the same passphrase again: ")
+		again, err := gopass.GetPasswd()
+		if err != nil {
+			return nil, nil, err
+		}
+		if string(passphrase) != string(again) {
+			return nil, nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	identity, err := age.NewScryptIdentity(string(passphrase))
+	if err != nil {
+		return nil, nil, err
+	}
+	recipient, err := age.NewScryptRecipient(string(passphrase))
+	if err != nil {
+		return nil, nil, err
+	}
+	return []age.Identity{identity}, []age.Recipient{recipient}, nil
+}
+
 func main() {
 	createVault := flag.Bool("new", false, "whether to create a new vault at the specified location")
+	force := flag.Bool("force", false, "whether to create the new vault even if its passphrase is weak")
+	readOnly := flag.Bool("readonly", false, "whether to open the vault without allowing changes to it")
+	useArgon2id := flag.Bool("argon2id", false, "whether to derive the new vault's key using Argon2id rather than scrypt")
+	cipherName := flag.String("cipher", "xsalsa20poly1305", "the cipher the new vault is encrypted with: xsalsa20poly1305, aes256gcm, aes256gcmsiv or xchacha20poly1305")
+	scryptN := flag.Int("scryptn", 0, "the scrypt CPU/memory cost, a power of two, used to derive the new vault's key, which is calibrated to take about a second on this machine if unset")
+	keyfile := flag.String("keyfile", "", "the path of a keyfile needed along with the passphrase to open the vault, generated for a new vault if it does not exist")
+	yubikeySlot := flag.Int("yubikey", 0, "the slot, 1 or 2, of a YubiKey whose HMAC-SHA1 challenge-response is needed along with the passphrase to open the vault")
+	useGPG := flag.Bool("gpg", false, "whether to unlock the vault using gpg-agent, or to encrypt the new vault to the OpenPGP keys of -recipients")
+	gpgRecipients := flag.String("recipients", "", "the comma separated OpenPGP keys a new vault created with -gpg is encrypted to, or the age recipients a vault opened with -age is encrypted to")
+	useAge := flag.Bool("age", false, "whether the vault is an age file, encrypted to a passphrase or to the X25519 keys of -identity and -recipients")
+	cacheTTL := flag.Duration("cache", 0, "how long to cache the vault's key in the operating system's keychain, such as 15m, so that it can be reopened without its passphrase")
+	forget := flag.Bool("forget", false, "whether to delete the vault's key cached by -cache, then exit")
+	ageIdentity := flag.String("identity", "", "the path of an age identity file used to decrypt a vault opened with -age")
+	useTPM := flag.Bool("tpm", false, "whether to unlock the vault using a key slot sealed by this machine's TPM, falling back to the passphrase")
+	pkcs11Module := flag.String("pkcs11", "", "the path of the PKCS#11 module of the token whose key unlocks the vault, such as a smartcard or HSM, rather than a passphrase")
+	useThreshold := flag.Bool("threshold", false, "whether to unlock the vault using a threshold key slot, asking each of its approvers for their passphrase in turn")
+	shareCount := flag.Int("shares", 0, "the number of key shares made by splitkey to open the vault with, rather than a passphrase")
+	pqKeygen := flag.String("pqkeygen", "", "the path to write a new post-quantum hybrid identity to, for use with -age, printing its recipient, then exit")
+	verifyKey := flag.String("verify", "", "the hex encoded Ed25519 public key, shown by sign, to verify the vault's signature against without decrypting it, then exit")
+	fido2Device := flag.String("fido2", "", "the path of a FIDO2 security key, such as /dev/hidraw0, whose hmac-secret is needed along with the passphrase to open the vault")
 
 	flag.Parse()
 
+	if *pqKeygen != "" {
+		identity, err := vault.GenerateHybridIdentity()
+		if err != nil {
+			die(err)
+		}
+		recipient := identity.Recipient().String()
+		contents := fmt.Sprintf("# recipient: %v\n%v\n", recipient, identity)
+		f, err := os.OpenFile(*pqKeygen, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			die(err)
+		}
+		if _, err = f.WriteString(contents); err == nil {
+			err = f.Close()
+		}
+		if err != nil {
+			die(err)
+		}
+		fmt.Println(recipient)
+		return
+	}
+
 	if len(flag.Args()) != 1 {
 		fmt.Println(usage)
 		flag.PrintDefaults()
@@ -32,18 +186,155 @@ func main() {
 	vaultPath := flag.Args()[0]
 	var v *vault.Vault
 
-	if !*createVault {
-		fmt.Print("Password for " + vaultPath + ": ")
-		passphrase, err := gopass.GetPasswd()
+	if *verifyKey != "" {
+		publicKey, err := hex.DecodeString(*verifyKey)
 		if err != nil {
 			die(err)
 		}
+		if err = vault.Verify(vaultPath, publicKey); err != nil {
+			die(err)
+		}
+		fmt.Printf("%v is signed by %v.\n", vaultPath, *verifyKey)
+		return
+	}
+
+	keychain := vault.DefaultKeychain()
+	if *forget {
+		if err := vault.ForgetKey(vaultPath, keychain); err != nil {
+			die(err)
+		}
+		return
+	}
+	if *cacheTTL > 0 && !*createVault && !*useAge {
+		var err error
+		if v, err = vault.OpenCached(vaultPath, keychain, *readOnly); err != nil && err != vault.ErrNotCached {
+			die(err)
+		}
+		if v != nil {
+			fmt.Printf("Opened %v using its cached key.\n", vaultPath)
+		}
+	}
+	if *useTPM && v == nil && !*createVault && !*useAge {
+		var err error
+		if v, err = vault.OpenWithOptions(vaultPath, "", vault.OpenOptions{TPM: vault.TPM2Tools{}, ReadOnly: *readOnly, Lock: true}); err != nil {
+			fmt.Printf("Could not unlock %v using the TPM: %v\n", vaultPath, err)
+		} else {
+			fmt.Printf("Opened %v using the TPM.\n", vaultPath)
+		}
+	}
+
+	if v != nil {
+		// The vault was opened using its cached key or the TPM.
+	} else if *shareCount > 0 && !*createVault {
+		var shares []vault.Share
+		for i := 0; i < *shareCount; i++ {
+			fmt.Printf("Share %v of %v: ", i+1, *shareCount)
+			s, err := gopass.GetPasswd()
+			if err != nil {
+				die(err)
+			}
+			share, err := vault.ParseShare(string(s))
+			if err != nil {
+				die(err)
+			}
+			shares = append(shares, share)
+		}
+		fmt.Printf("Opening %v...\n", vaultPath)
+		var err error
+		if v, err = vault.OpenWithShares(vaultPath, shares); err != nil {
+			die(err)
+		}
+	} else if *useAge {
+		if *readOnly {
+			die(fmt.Errorf("-readonly cannot be used with -age"))
+		}
+		identities, recipients, err := ageKeys(*ageIdentity, *gpgRecipients, *createVault)
+		if err != nil {
+			die(err)
+		}
+		if *createVault {
+			if v, err = vault.NewAge(recipients...); err != nil {
+				die(err)
+			}
+			if err = v.Save(vaultPath); err != nil {
+				die(err)
+			}
+		} else {
+			fmt.Printf("Opening %v...\n", vaultPath)
+			if v, err = vault.OpenAge(vaultPath, identities, recipients); err != nil {
+				die(err)
+			}
+		}
+	} else if !*createVault {
+		var passphrase []byte
+		opts := vault.OpenOptions{Keyfile: *keyfile, ReadOnly: *readOnly, Throttle: true, Lock: true}
+		delay, err := vault.AttemptDelay(vaultPath)
+		if err != nil {
+			die(err)
+		}
+		if delay > 0 {
+			fmt.Printf("Too many failed attempts to open %v, waiting %v.\n", vaultPath, delay.Round(time.Second))
+			time.Sleep(delay)
+		}
+		if *pkcs11Module != "" {
+			opts.PKCS11 = vault.PKCS11Tool{Module: *pkcs11Module}
+		} else if *useThreshold {
+			opts.Approve = func(name string) (string, error) {
+				fmt.Printf("Passphrase of approver %v, or nothing if absent: ", name)
+				passphrase, err := gopass.GetPasswd()
+				return string(passphrase), err
+			}
+		} else if *useGPG {
+			opts.GPGDecrypt = vault.GPGDecryptBytes
+		} else {
+			fmt.Print("Password for " + vaultPath + ": ")
+			if passphrase, err = gopass.GetPasswd(); err != nil {
+				die(err)
+			}
+		}
 		fmt.Printf("Opening %v...\n", vaultPath)
 
-		v, err = vault.Open(vaultPath, string(passphrase))
+		if *yubikeySlot != 0 {
+			fmt.Println("Touch your YubiKey if it flashes.")
+			opts.ChallengeResponse = vault.YubiKeyChallengeResponse(*yubikeySlot)
+		}
+		if *fido2Device != "" {
+			fmt.Println("Touch your security key when it flashes.")
+			opts.FIDO2 = vault.FIDO2Device{Path: *fido2Device}
+		}
+		v, err = vault.OpenWithOptions(vaultPath, string(passphrase), opts)
 		if err != nil {
 			die(err)
 		}
+
+		// Vaults created with weaker than the current default parameters
+		// for their KDF are upgraded while the passphrase is at hand, if
+		// the user agrees, as the parameters may have been chosen on
+		// purpose, such as using -scryptn for a slow machine.
+		if recommended := (vault.KDFParams{KDF: v.KDFParams().KDF}); !*readOnly && !*useGPG && !*useThreshold && *pkcs11Module == "" && v.KDFParams().Weaker(recommended) &&
+			confirm("The vault's key derivation parameters are weaker than the current defaults. Upgrade them?") {
+			if err = v.UpgradeKDF(string(passphrase), recommended); err != nil {
+				die(err)
+			}
+			fmt.Println("Upgraded the vault's key derivation parameters.")
+		}
+		if *cacheTTL > 0 {
+			if err = v.CacheKey(vaultPath, keychain, *cacheTTL); err != nil {
+				fmt.Printf("could not cache the vault's key: %v\n", err)
+			}
+		}
+	} else if *useGPG {
+		var recipients []string
+		if *gpgRecipients != "" {
+			recipients = strings.Split(*gpgRecipients, ",")
+		}
+		var err error
+		if v, err = vault.NewWithGPG(recipients, vault.GPGEncrypt); err != nil {
+			die(err)
+		}
+		if err = v.Save(vaultPath); err != nil {
+			die(err)
+		}
 	} else {
 		fmt.Print("Enter a passphrase for " + vaultPath + ": ")
 		passphrase1, err := gopass.GetPasswd()
@@ -58,7 +349,37 @@ func main() {
 		if string(passphrase1) != string(passphrase2) {
 			die(fmt.Errorf("passphrases do not match"))
 		}
-		v, err = vault.New(string(passphrase1))
+		opts := vault.Options{KDF: vault.KDFParams{N: *scryptN}}
+		opts.Force = *force
+		if *useArgon2id {
+			opts.KDF = vault.KDFParams{KDF: vault.KDFArgon2id}
+		}
+		cipher, ok := ciphers[*cipherName]
+		if !ok {
+			die(fmt.Errorf("unknown cipher %q", *cipherName))
+		}
+		opts.Cipher = cipher
+		if *keyfile != "" {
+			if _, err = os.Stat(*keyfile); os.IsNotExist(err) {
+				if err = vault.GenerateKeyfile(*keyfile); err != nil {
+					die(err)
+				}
+				fmt.Printf("Generated keyfile %v. Keep it safe: the vault cannot be opened without it.\n", *keyfile)
+			}
+			opts.Keyfile = *keyfile
+		}
+		if *yubikeySlot != 0 {
+			fmt.Println("Touch your YubiKey if it flashes.")
+			opts.ChallengeResponse = vault.YubiKeyChallengeResponse(*yubikeySlot)
+		}
+		if *fido2Device != "" {
+			fmt.Println("Touch your security key when it flashes.")
+			opts.FIDO2 = vault.FIDO2Device{Path: *fido2Device}
+		}
+		v, err = vault.NewWithOptions(string(passphrase1), opts)
+		if _, ok := err.(*vault.WeakPassphraseError); ok {
+			die(fmt.Errorf("%v. Pass -force to use it anyway.", err))
+		}
 		if err != nil {
 			die(err)
 		}
@@ -68,12 +389,28 @@ func main() {
 		}
 	}
 
+	// Vaults opened otherwise than with OpenWithOptions, such as new ones,
+	// are locked now, so another masterkey cannot open them for writing.
+	if !*readOnly {
+		if err := v.LockFile(vaultPath); err != nil {
+			die(err)
+		}
+	}
+
+	if !v.MemoryLocked() {
+		fmt.Println("Warning: could not lock the vault's keys into memory, so they may be written to swap. Raising the locked memory limit using `ulimit -l` may help.")
+	}
+
 	r := repl.New("masterkey > ")
 
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, os.Interrupt, os.Kill)
 	go func() {
 		<-sigchan
+		if *readOnly {
+			r.Stop()
+			return
+		}
 		fmt.Println("\nCaught quit signal, saving vault")
 		err := v.Save(vaultPath)
 		if err != nil {
@@ -83,10 +420,45 @@ func main() {
 	}()
 
 	r.AddCommand(listCmd(v))
+	r.AddCommand(favoriteCmd(v))
+	r.AddCommand(aliasCmd(v))
 	r.AddCommand(saveCmd(v, vaultPath))
 	r.AddCommand(getCmd(v))
 	r.AddCommand(addCmd(v))
+	r.AddCommand(editCmd(v))
+	r.AddCommand(undoCmd(v))
+	r.AddCommand(addNoteCmd(v))
+	r.AddCommand(addLinkCmd(v))
+	r.AddCommand(addCardCmd(v))
+	r.AddCommand(addIdentityCmd(v))
+	r.AddCommand(rotateCmd(v))
+	r.AddCommand(generatedCmd(v))
+	r.AddCommand(policyCmd(v))
+	r.AddCommand(templateCmd(v))
+	r.AddCommand(addFromTemplateCmd(v))
+	r.AddCommand(addSSHKeyCmd(v))
+	r.AddCommand(sshAgentCmd(v))
 	r.AddCommand(genCmd(v))
+	r.AddCommand(searchCmd(v))
+	r.AddCommand(statsCmd(v))
+	r.AddCommand(duplicatesCmd(v))
+	r.AddCommand(metaCmd(v))
+	r.AddCommand(normalizeCmd(v))
+	r.AddCommand(keySlotCmd(v))
+	r.AddCommand(rotateKeyCmd(v))
+	r.AddCommand(cipherCmd(v))
+	r.AddCommand(paddingCmd(v))
+	r.AddCommand(compressionCmd(v))
+	r.AddCommand(backupsCmd(v))
+	r.AddCommand(signCmd(v))
+	r.AddCommand(splitKeyCmd(v))
+	r.AddCommand(logCmd(v))
+	r.AddCommand(hiddenCmd(v, vaultPath))
+	r.AddCommand(exportCmd(v))
+	r.AddCommand(importCmd(v))
+	r.AddCommand(totpCmd(v))
+	r.AddCommand(hotpCmd(v))
 
 	r.Loop()
+	v.Close()
 }
@@ -0,0 +1,133 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"filippo.io/age"
+)
+
+// ageMagic starts every age file.
+const ageMagic = "age-encryption.org/"
+
+var (
+	// ErrAgeVault is returned by Open for a vault saved as an age file,
+	// which must be opened using OpenAge, and by methods that manage the
+	// key slots of such a vault, which has none.
+	ErrAgeVault = errors.New("vault is stored as an age file")
+
+	// ErrNoAgeRecipients is returned if a vault stored as an age file is
+	// given no recipients.
+	ErrNoAgeRecipients = errors.New("no age recipients")
+)
+
+// NewAge creates a new, empty, vault which is saved as an age file, rather
+// than in masterkey's own format, encrypted to `recipients`: X25519 or
+// HybridRecipient recipients, or a single scrypt passphrase recipient. Such a
+// vault can be decrypted and audited using standard age tools, as its
+// contents are stored as JSON, unless it is only encrypted to
+// HybridRecipients, which age does not know.
+func NewAge(recipients ...age.Recipient) (*Vault, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoAgeRecipients
+	}
+	keys, enclave := newVaultKeys()
+	v := &Vault{
+		keys:          keys,
+		enclave:       enclave,
+		ageRecipients: recipients,
+		undo:          newUndoStack(),
+	}
+	if err := v.encrypt(newPayload()); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// OpenAge reads a vault saved as an age file from `filename` and decrypts it
+// using any of `identities`. The recipients of an age file are not recorded
+// in it, so Save encrypts the vault to `recipients`, which must be given
+// again, such as the recipients of `identities`.
+func OpenAge(filename string, identities []age.Identity, recipients []age.Recipient) (*Vault, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoAgeRecipients
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := age.Decrypt(f, identities...)
+	if err != nil {
+		return nil, err
+	}
+	p := &payload{}
+	if err = json.NewDecoder(r).Decode(p); err != nil {
+		return nil, ErrCouldNotDecrypt
+	}
+	p.init()
+	if err = migratePayload(p); err != nil {
+		return nil, err
+	}
+
+	keys, enclave := newVaultKeys()
+	v := &Vault{
+		keys:          keys,
+		enclave:       enclave,
+		ageRecipients: recipients,
+		undo:          newUndoStack(),
+	}
+	if err = v.seal(p); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// SaveAge saves the vault to `filename` as an age file encrypted to
+// `recipients`, whose contents are the vault's JSON encoded payload. The
+// vault itself is unchanged, so this can be used to keep an age copy of a
+// vault.
+func (v *Vault) SaveAge(filename string, recipients ...age.Recipient) error {
+	if len(recipients) == 0 {
+		return ErrNoAgeRecipients
+	}
+	var buf bytes.Buffer
+	if err := v.writeAge(&buf, recipients); err != nil {
+		return err
+	}
+	return writeAtomic(filename, func(w io.Writer) error {
+		_, err := io.Copy(w, &buf)
+		return err
+	})
+}
+
+// writeAge writes the vault to `out` as an age file encrypted to
+// `recipients`.
+func (v *Vault) writeAge(out io.Writer, recipients []age.Recipient) error {
+	p, err := v.decrypt()
+	if err != nil {
+		return err
+	}
+	if _, err = p.trash(); err != nil {
+		return err
+	}
+	if _, err = p.log(); err != nil {
+		return err
+	}
+	plaintext, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	w, err := age.Encrypt(out, recipients...)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(plaintext); err != nil {
+		return err
+	}
+	return w.Close()
+}
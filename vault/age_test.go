@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestAgeVault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "masterkey-age-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "vault.age")
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewAge(identity.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open(filename, "testpass"); err != ErrAgeVault {
+		t.Fatal("expected Open to return ErrAgeVault, got", err)
+	}
+	if _, err = v.AddKeySlot("testpass", ""); err != ErrAgeVault {
+		t.Fatal("expected AddKeySlot to return ErrAgeVault, got", err)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenAge(filename, []age.Identity{other}, []age.Recipient{other.Recipient()}); err == nil {
+		t.Fatal("expected OpenAge to fail with the wrong identity")
+	}
+	v, err = OpenAge(filename, []age.Identity{identity}, []age.Recipient{identity.Recipient()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpassword" {
+		t.Fatal("credential did not survive the age round trip")
+	}
+
+	// Re-encrypt to a passphrase, as a copy readable by `age -d`.
+	recipient, err := age.NewScryptRecipient("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient.SetWorkFactor(10)
+	scryptFilename := path.Join(dir, "scrypt.age")
+	if err = v.SaveAge(scryptFilename, recipient); err != nil {
+		t.Fatal(err)
+	}
+	scryptIdentity, err := age.NewScryptIdentity("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = OpenAge(scryptFilename, []age.Identity{scryptIdentity}, []age.Recipient{recipient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("testlocation"); err != nil {
+		t.Fatal(err)
+	}
+}
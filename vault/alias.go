@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrNoSuchAlias is returned from Unalias if the alias does not exist.
+var ErrNoSuchAlias = errors.New("alias does not exist")
+
+// resolve returns the location of the credential `location` refers to, which
+// is `location` itself unless it is an alias or normalizes to another
+// location.
+func (p *payload) resolve(location string) string {
+	location = p.canonical(location)
+	if target, ok := p.Aliases[location]; ok {
+		return target
+	}
+	return location
+}
+
+// inUse returns true if `location` is the location of a credential or an
+// alias.
+func (p *payload) inUse(location string) bool {
+	_, isCredential := p.Credentials[location]
+	_, isAlias := p.Aliases[location]
+	return isCredential || isAlias
+}
+
+// removeAliases removes every alias of `location`.
+func (p *payload) removeAliases(location string) {
+	for alias, target := range p.Aliases {
+		if target == location {
+			delete(p.Aliases, alias)
+		}
+	}
+}
+
+// retargetAliases points every alias of `oldLocation` at `newLocation`.
+func (p *payload) retargetAliases(oldLocation string, newLocation string) {
+	for alias, target := range p.Aliases {
+		if target == oldLocation {
+			p.Aliases[alias] = newLocation
+		}
+	}
+}
+
+// Alias makes `alias` another name for the credential at `location`, so that
+// Get, Edit and the other methods that take a single location act on the
+// same credential through either name. Deleting an alias removes only the
+// alias, and aliases are removed along with their credential. Alias returns
+// ErrNoSuchCredential if `location` does not exist and ErrCredentialExists if
+// `alias` is already in use.
+func (v *Vault) Alias(location string, alias string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	location = p.resolve(location)
+	if _, ok := p.Credentials[location]; !ok {
+		return ErrNoSuchCredential
+	}
+	if err = p.available(alias); err != nil {
+		return err
+	}
+	p.Aliases[alias] = location
+
+	return v.encrypt(p)
+}
+
+// Unalias removes `alias`, leaving the credential it refers to untouched.
+func (v *Vault) Unalias(alias string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	alias = p.canonical(alias)
+	if _, ok := p.Aliases[alias]; !ok {
+		return ErrNoSuchAlias
+	}
+	delete(p.Aliases, alias)
+
+	return v.encrypt(p)
+}
+
+// Aliases returns the aliases of the credential at `location`, sorted.
+func (v *Vault) Aliases(location string) ([]string, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	location = p.resolve(location)
+	if _, ok := p.Credentials[location]; !ok {
+		return nil, ErrNoSuchCredential
+	}
+	var aliases []string
+	for alias, target := range p.Aliases {
+		if target == location {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
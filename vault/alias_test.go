@@ -0,0 +1,74 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("google", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.Alias("nonexistent", "gmail"); err != ErrNoSuchCredential {
+		t.Fatal("expected ErrNoSuchCredential aliasing a nonexistent location, got", err)
+	}
+	if err = v.Alias("google", "google"); err != ErrCredentialExists {
+		t.Fatal("expected ErrCredentialExists aliasing an existing location, got", err)
+	}
+	if err = v.Alias("google", "gmail"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Alias("gmail", "youtube"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("gmail", Credential{Username: "other"}); err != ErrCredentialExists {
+		t.Fatal("expected ErrCredentialExists adding at an alias, got", err)
+	}
+
+	if err = v.Edit("youtube", Credential{Username: "testuser", Password: "newpass"}); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("gmail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "newpass" {
+		t.Fatal("edit through an alias did not change the aliased credential")
+	}
+	aliases, err := v.Aliases("google")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(aliases, []string{"gmail", "youtube"}) {
+		t.Fatalf("unexpected aliases %v", aliases)
+	}
+
+	if err = v.Rename("google", "alphabet"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("gmail"); err != nil {
+		t.Fatal("alias did not follow renamed credential:", err)
+	}
+	if err = v.Delete("youtube"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("alphabet"); err != nil {
+		t.Fatal("deleting an alias deleted the credential:", err)
+	}
+	if err = v.Unalias("youtube"); err != ErrNoSuchAlias {
+		t.Fatal("expected ErrNoSuchAlias removing a deleted alias, got", err)
+	}
+
+	if err = v.Delete("alphabet"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("gmail"); err != ErrNoSuchCredential {
+		t.Fatal("expected alias to be removed with its credential, got", err)
+	}
+}
@@ -0,0 +1,42 @@
+package vault
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeAtomic replaces the file at `filename` with the contents written by
+// `write`, so that a crash or a full disk leaves either the old file or the
+// new one, never a truncated one. The contents are written to a temporary
+// file in the same directory, flushed to disk, and renamed over `filename`,
+// then the directory is flushed so that the rename itself survives a crash.
+// The temporary file is removed if anything fails.
+func writeAtomic(filename string, write func(io.Writer) error) (err error) {
+	dir := filepath.Dir(filename)
+	tempfile, err := ioutil.TempFile(dir, "masterkey-temp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tempfile.Close()
+			os.Remove(tempfile.Name())
+		}
+	}()
+
+	if err = write(tempfile); err != nil {
+		return err
+	}
+	if err = tempfile.Sync(); err != nil {
+		return err
+	}
+	if err = tempfile.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tempfile.Name(), filename); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package vault
+
+// syncDir does nothing on platforms whose directories cannot be flushed, such
+// as Windows, where NTFS journals renames.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package vault
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "masterkey-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "pass.db")
+	if err = ioutil.WriteFile(filename, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	errFull := errors.New("no space left on device")
+	err = writeAtomic(filename, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errFull
+	})
+	if err != errFull {
+		t.Fatal("expected the write's error, got", err)
+	}
+	if contents, err := ioutil.ReadFile(filename); err != nil || string(contents) != "old" {
+		t.Fatal("expected a failed write to leave the file unchanged, got", string(contents), err)
+	}
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 1 {
+		t.Fatal("expected a failed write to remove its temporary file, got", files, err)
+	}
+
+	err = writeAtomic(filename, func(w io.Writer) error {
+		_, err := w.Write([]byte("new"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents, err := ioutil.ReadFile(filename); err != nil || string(contents) != "new" {
+		t.Fatal("expected the file to be replaced, got", string(contents), err)
+	}
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 1 {
+		t.Fatal("expected only the file to remain, got", files, err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package vault
+
+import (
+	"os"
+)
+
+// syncDir flushes the directory `dir` to disk, persisting the files renamed
+// into it.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
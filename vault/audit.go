@@ -0,0 +1,157 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Finding is a credential flagged by an audit.
+type Finding struct {
+	Location string
+	// Reason describes why the credential was flagged.
+	Reason string
+}
+
+// AuditFilter restricts an audit to part of the vault. The zero value audits
+// the whole vault.
+type AuditFilter struct {
+	// Tag, if set, restricts the audit to credentials with this tag.
+	Tag string
+	// Folder, if set, restricts the audit to credentials in this folder or
+	// its subfolders.
+	Folder string
+}
+
+// matches returns true if the credential `cred` at `location` passes the
+// filter.
+func (f AuditFilter) matches(location string, cred *Credential) bool {
+	if f.Tag != "" && !hasTag(cred.Tags, f.Tag) {
+		return false
+	}
+	return inFolder(location, cleanFolder(f.Folder))
+}
+
+// ReuseGroup is a set of locations whose credentials share the same password.
+type ReuseGroup struct {
+	// Locations are the locations sharing the password, sorted.
+	Locations []string
+}
+
+// AuditReuse returns every group of two or more logins that share an
+// identical password, sorted by their first location. Passwords are compared
+// using HMACs keyed with a random salt that exists only for the duration of
+// the audit, so no unsalted digest of a password is ever computed.
+func (v *Vault) AuditReuse() ([]ReuseGroup, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, sha256.Size)
+	if _, err = io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	byPassword := make(map[string][]string)
+	var order []string
+	for _, location := range p.sortedLogins() {
+		mac := hmac.New(sha256.New, salt)
+		mac.Write([]byte(p.Credentials[location].Password))
+		key := string(mac.Sum(nil))
+		if _, ok := byPassword[key]; !ok {
+			order = append(order, key)
+		}
+		byPassword[key] = append(byPassword[key], location)
+	}
+
+	var groups []ReuseGroup
+	for _, key := range order {
+		if len(byPassword[key]) > 1 {
+			groups = append(groups, ReuseGroup{Locations: byPassword[key]})
+		}
+	}
+	return groups, nil
+}
+
+// sortedLogins returns the locations of the logins in `p` that have a
+// password, sorted.
+func (p *payload) sortedLogins() []string {
+	var locations []string
+	for location, cred := range p.Credentials {
+		if cred.kind() == KindLogin && cred.Password != "" {
+			locations = append(locations, location)
+		}
+	}
+	sort.Strings(locations)
+	return locations
+}
+
+// AuditWeak returns a finding, sorted by location, for every login whose
+// password is estimated by EstimateStrength to score below `threshold`, such
+// as short, common, dictionary or sequential passwords. The reason for each
+// finding is the estimate's feedback.
+func (v *Vault) AuditWeak(threshold Strength) ([]Finding, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, location := range p.sortedLogins() {
+		strength := EstimateStrength(p.Credentials[location].Password)
+		if strength.Score < threshold {
+			findings = append(findings, Finding{
+				Location: location,
+				Reason:   strings.Join(strength.Feedback, ". "),
+			})
+		}
+	}
+	return findings, nil
+}
+
+// passwordChangedAt returns the time at which the credential's current
+// password was set, or the zero time if it is unknown.
+func (c *Credential) passwordChangedAt() time.Time {
+	changedAt := c.CreatedAt
+	for i := len(c.History) - 1; i >= 0; i-- {
+		if c.History[i].Credential.Password != c.Password {
+			changedAt = c.History[i].ReplacedAt
+			break
+		}
+	}
+	return changedAt
+}
+
+// AuditStale returns a finding, sorted by location, for every login matching
+// `filter` whose password has not been changed within `olderThan`. Logins
+// without timestamps, such as those added before timestamps were recorded,
+// are ignored.
+func (v *Vault) AuditStale(olderThan time.Duration, filter AuditFilter) ([]Finding, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var findings []Finding
+	for _, location := range p.sortedLogins() {
+		cred := p.Credentials[location]
+		changedAt := cred.passwordChangedAt()
+		if !filter.matches(location, cred) || changedAt.IsZero() {
+			continue
+		}
+		if age := now.Sub(changedAt); age > olderThan {
+			findings = append(findings, Finding{
+				Location: location,
+				Reason:   fmt.Sprintf("password unchanged for %v days", int(age.Hours()/24)),
+			})
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,143 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAuditReuse(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := map[string]Credential{
+		"github":  {Username: "testuser", Password: "shared"},
+		"gitlab":  {Username: "testuser", Password: "shared"},
+		"email":   {Username: "testuser", Password: "unique"},
+		"bank":    {Username: "testuser", Password: "other"},
+		"backup":  {Username: "testuser", Password: "other"},
+		"nouser":  {Username: "testuser"},
+		"nouser2": {Username: "testuser"},
+	}
+	for location, cred := range creds {
+		if err = v.Add(location, cred); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := v.AuditReuse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ReuseGroup{
+		{Locations: []string{"backup", "bank"}},
+		{Locations: []string{"github", "gitlab"}},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Fatalf("unexpected reuse groups %v", groups)
+	}
+}
+
+func TestAuditWeak(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := map[string]Credential{
+		"short":      {Password: "x7"},
+		"common":     {Password: "letmein"},
+		"sequential": {Password: "abcdefgh"},
+		"strong":     {Password: "vX9#qL2!mR7$wK4@"},
+	}
+	for location, cred := range creds {
+		if err = v.Add(location, cred); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	findings, err := v.AuditWeak(StrengthStrong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locations []string
+	for _, finding := range findings {
+		if finding.Reason == "" {
+			t.Fatalf("finding for %v has no reason", finding.Location)
+		}
+		locations = append(locations, finding.Location)
+	}
+	if !reflect.DeepEqual(locations, []string{"common", "sequential", "short"}) {
+		t.Fatalf("unexpected weak passwords %v", locations)
+	}
+
+	findings, err = v.AuditWeak(StrengthVeryWeak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Fatalf("expected no findings below the lowest strength, got %v", findings)
+	}
+}
+
+func TestAuditStale(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-400 * 24 * time.Hour)
+	creds := map[string]Credential{
+		"work/vpn":   {Password: "old", Tags: []string{"work"}, CreatedAt: old, UpdatedAt: old},
+		"work/email": {Password: "new", Tags: []string{"work"}},
+		"bank":       {Password: "old", CreatedAt: old, UpdatedAt: old},
+		"rotated":    {Password: "old", CreatedAt: old, UpdatedAt: old},
+	}
+	for location, cred := range creds {
+		if err = v.Add(location, cred); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err = v.Rotate("rotated", GenerateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Tag("bank", "finance"); err != nil {
+		t.Fatal(err)
+	}
+
+	locations := func(findings []Finding) []string {
+		var locations []string
+		for _, finding := range findings {
+			locations = append(locations, finding.Location)
+		}
+		return locations
+	}
+
+	findings, err := v.AuditStale(365*24*time.Hour, AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(locations(findings), []string{"bank", "work/vpn"}) {
+		t.Fatalf("unexpected stale credentials %v", findings)
+	}
+	if findings[0].Reason != "password unchanged for 400 days" {
+		t.Fatalf("unexpected reason %q", findings[0].Reason)
+	}
+
+	findings, err = v.AuditStale(365*24*time.Hour, AuditFilter{Folder: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(locations(findings), []string{"work/vpn"}) {
+		t.Fatalf("unexpected stale credentials in folder %v", findings)
+	}
+	findings, err = v.AuditStale(365*24*time.Hour, AuditFilter{Tag: "finance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(locations(findings), []string{"bank"}) {
+		t.Fatalf("unexpected stale credentials with tag %v", findings)
+	}
+}
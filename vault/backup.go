@@ -0,0 +1,170 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// backupVersion is the current version of the encrypted backup format.
+const backupVersion = 1
+
+var (
+	// backupMagic identifies a masterkey encrypted backup archive.
+	backupMagic = [8]byte{'M', 'K', 'B', 'A', 'C', 'K', 'U', 'P'}
+
+	// ErrInvalidBackup is returned from ImportEncrypted if the file is not
+	// a masterkey backup archive.
+	ErrInvalidBackup = errors.New("file is not a masterkey backup archive")
+
+	// ErrUnsupportedBackupVersion is returned from ImportEncrypted if the
+	// archive was written by a newer version of masterkey.
+	ErrUnsupportedBackupVersion = errors.New("backup archive version is not supported")
+)
+
+type (
+	// backupHeader is the unencrypted header at the start of a backup
+	// archive. It records everything needed to derive the archive's key.
+	backupHeader struct {
+		Magic   [8]byte
+		Version uint32
+		ScryptN uint32
+		ScryptR uint32
+		ScryptP uint32
+		Salt    [32]byte
+		Nonce   [24]byte
+	}
+
+	// backupContents is the encrypted body of a backup archive.
+	backupContents struct {
+		Version     int              `json:"version"`
+		CreatedAt   time.Time        `json:"created_at"`
+		Credentials []jsonCredential `json:"credentials"`
+	}
+)
+
+// key derives the archive key for `passphrase` using the parameters in the
+// header.
+func (h *backupHeader) key(passphrase string) (*[32]byte, error) {
+	derived, err := scrypt.Key([]byte(passphrase), h.Salt[:], int(h.ScryptN), int(h.ScryptR), int(h.ScryptP), keyLen)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	copy(key[:], derived)
+	return &key, nil
+}
+
+// ExportEncrypted writes every credential in the vault, including its
+// history, to a portable backup archive at `filename` encrypted with
+// `passphrase`. The archive is independent of the vault file and
+// `passphrase` need not match the vault's passphrase.
+func (v *Vault) ExportEncrypted(filename string, passphrase string) error {
+	p, err := v.decrypt()
+	if err != nil {
+		return err
+	}
+
+	contents := backupContents{
+		Version:     backupVersion,
+		CreatedAt:   time.Now(),
+		Credentials: []jsonCredential{},
+	}
+	for location, cred := range p.Credentials {
+		contents.Credentials = append(contents.Credentials, cred.toJSON(location, true))
+	}
+	plaintext, err := json.Marshal(contents)
+	if err != nil {
+		return err
+	}
+
+	header := backupHeader{
+		Magic:   backupMagic,
+		Version: backupVersion,
+		ScryptN: DefaultScryptN,
+		ScryptR: DefaultScryptR,
+		ScryptP: DefaultScryptP,
+	}
+	if _, err = io.ReadFull(rand.Reader, header.Salt[:]); err != nil {
+		panic(err)
+	}
+	if _, err = io.ReadFull(rand.Reader, header.Nonce[:]); err != nil {
+		panic(err)
+	}
+	key, err := header.key(passphrase)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err = binary.Write(&buf, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	archive := secretbox.Seal(buf.Bytes(), plaintext, &header.Nonce, key)
+
+	return ioutil.WriteFile(filename, archive, 0600)
+}
+
+// ImportEncrypted decrypts the backup archive at `filename` using
+// `archivePassphrase` and returns a new vault, protected by
+// `vaultPassphrase`, containing the archive's credentials.
+func ImportEncrypted(filename string, archivePassphrase string, vaultPassphrase string) (*Vault, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var header backupHeader
+	if err = binary.Read(f, binary.BigEndian, &header); err != nil || header.Magic != backupMagic {
+		return nil, ErrInvalidBackup
+	}
+	if header.Version > backupVersion {
+		return nil, ErrUnsupportedBackupVersion
+	}
+
+	ciphertext, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	key, err := header.key(archivePassphrase)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, success := secretbox.Open(nil, ciphertext, &header.Nonce, key)
+	if !success {
+		return nil, ErrCouldNotDecrypt
+	}
+
+	var contents backupContents
+	if err = json.Unmarshal(plaintext, &contents); err != nil {
+		return nil, err
+	}
+
+	v, err := New(vaultPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	err = v.Batch(func(tx *Tx) error {
+		for _, jc := range contents.Credentials {
+			if err := tx.Add(jc.Location, jc.credential()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,69 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestExportImportEncrypted(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass", Tags: []string{"work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Edit("testlocation", Credential{Username: "testuser", Password: "testpass2", Tags: []string{"work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.ExportEncrypted("backup.mkb", "backuppass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("backup.mkb")
+
+	if _, err = ImportEncrypted("backup.mkb", "testpass", "newpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ImportEncrypted with the wrong passphrase to return ErrCouldNotDecrypt")
+	}
+
+	restored, err := ImportEncrypted("backup.mkb", "backuppass", "newpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := restored.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass2" || cred.Tags[0] != "work" {
+		t.Fatal("ImportEncrypted did not restore the credential")
+	}
+	if len(cred.History) != 1 || cred.History[0].Credential.Password != "testpass" {
+		t.Fatal("ImportEncrypted did not restore the credential history")
+	}
+
+	err = restored.Save("restored.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("restored.db")
+	if _, err = Open("restored.db", "newpass"); err != nil {
+		t.Fatal("restored vault was not protected by the new vault passphrase")
+	}
+}
+
+func TestImportEncryptedInvalid(t *testing.T) {
+	err := ioutil.WriteFile("notabackup.mkb", []byte("not a backup archive, just some text"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("notabackup.mkb")
+
+	if _, err = ImportEncrypted("notabackup.mkb", "testpass", "testpass"); err != ErrInvalidBackup {
+		t.Fatal("expected ImportEncrypted on a non-backup file to return ErrInvalidBackup")
+	}
+}
@@ -0,0 +1,129 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// maxBackups is the maximum number of previous versions of a vault Save may
+// keep.
+const maxBackups = 100
+
+// DefaultBackups is the number of previous versions of a vault file Save keeps
+// for vaults that do not set their own using SetBackups.
+var DefaultBackups = 3
+
+// ErrInvalidBackups is returned from SetBackups if the number of backups is
+// negative or more than 100.
+var ErrInvalidBackups = errors.New("the number of backups must be between 0 and 100")
+
+// BackupOptions configures the backups of a vault file Save keeps.
+type BackupOptions struct {
+	// Count is the number of previous versions kept, from vault.db.bak.1,
+	// the most recent, to vault.db.bak.Count. Zero keeps none.
+	Count int
+	// Dir is the directory the backups are kept in, or the vault file's
+	// own directory if it is empty.
+	Dir string
+}
+
+// SetBackups configures the backups Save keeps of the vault file's previous
+// versions, so that a bad write or a mistaken change can be undone by
+// restoring one. The backups are encrypted as the vault was when it was
+// replaced, so they would open with the passphrases and keys it had then:
+// the first Save after ChangePassphrase, RemoveKeySlot or RotateDataKey
+// therefore removes them rather than keeping another. Backups would also
+// show when a hidden vault was saved, as only its section of the file
+// changes, so a hidden vault's Save removes them too.
+func (v *Vault) SetBackups(opts BackupOptions) error {
+	if opts.Count < 0 || opts.Count > maxBackups {
+		return ErrInvalidBackups
+	}
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+	p.Backups = &opts
+	return v.encrypt(p)
+}
+
+// Backups returns the backups Save keeps of the vault file, which are
+// DefaultBackups in its own directory unless set by SetBackups. A hidden
+// vault's file is backed up as its outer vault's is, if it is open.
+func (v *Vault) Backups() (BackupOptions, error) {
+	if v.outer != nil && v.outer.vault != nil {
+		return v.outer.vault.Backups()
+	}
+	p, err := v.decrypt()
+	if err != nil {
+		return BackupOptions{}, err
+	}
+	if v.outer != nil || p.Backups == nil {
+		return BackupOptions{Count: DefaultBackups}, nil
+	}
+	return *p.Backups, nil
+}
+
+// backupPath returns the path of the backup numbered `n` of the vault at
+// `filename` under `opts`.
+func (opts BackupOptions) backupPath(filename string, n int) string {
+	dir := opts.Dir
+	if dir == "" {
+		dir = filepath.Dir(filename)
+	}
+	return filepath.Join(dir, fmt.Sprintf("%v.bak.%d", filepath.Base(filename), n))
+}
+
+// rotateBackups keeps the vault file at `filename` as its most recent backup
+// before it is replaced, renumbering the older ones and removing the oldest
+// beyond the count. The file is copied rather than moved, so it stays in
+// place if writing its replacement fails. Nothing is done if it does not
+// exist yet. If the vault's keys changed since it was last saved, or it is a
+// hidden vault, every backup is removed instead.
+func (v *Vault) rotateBackups(filename string) error {
+	opts, err := v.Backups()
+	if err != nil {
+		return err
+	}
+	if v.pruneBackups || v.outer != nil {
+		return opts.removeBackups(filename)
+	}
+	if opts.Count == 0 {
+		return nil
+	}
+	current, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer current.Close()
+
+	if err = os.Remove(opts.backupPath(filename, opts.Count)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := opts.Count - 1; n >= 1; n-- {
+		if err = os.Rename(opts.backupPath(filename, n), opts.backupPath(filename, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return writeAtomic(opts.backupPath(filename, 1), func(w io.Writer) error {
+		_, err := io.Copy(w, current)
+		return err
+	})
+}
+
+// removeBackups removes every backup of the vault file at `filename`, up to
+// the most that could have been kept.
+func (opts BackupOptions) removeBackups(filename string) error {
+	for n := 1; n <= maxBackups; n++ {
+		if err := os.Remove(opts.backupPath(filename, n)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package vault
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackups(t *testing.T) {
+	relaxDefaults(t)
+	dir, err := ioutil.TempDir("", "masterkey-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "pass.db")
+
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts, err := v.Backups(); err != nil || opts != (BackupOptions{Count: DefaultBackups}) {
+		t.Fatal("expected the default backups, got", opts, err)
+	}
+	if err = v.SetBackups(BackupOptions{Count: -1}); err != ErrInvalidBackups {
+		t.Fatal("expected ErrInvalidBackups, got", err)
+	}
+	if err = v.SetBackups(BackupOptions{Count: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filename + ".bak.1"); !os.IsNotExist(err) {
+		t.Fatal("expected no backup of a new vault, got", err)
+	}
+
+	var versions [][]byte
+	for _, location := range []string{"location1", "location2", "location3"} {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, contents)
+		if err = v.Add(location, Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+			t.Fatal(err)
+		}
+		if err = v.Save(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for n, version := range []int{2, 1} {
+		backup, err := ioutil.ReadFile(fmt.Sprintf("%v.bak.%d", filename, n+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(backup) != string(versions[version]) {
+			t.Fatal("backup", n+1, "is not the expected previous version")
+		}
+	}
+	if _, err = os.Stat(filename + ".bak.3"); !os.IsNotExist(err) {
+		t.Fatal("expected only two backups to be kept, got", err)
+	}
+	backup, err := Open(filename+".bak.1", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locations, err := backup.Locations(); err != nil || len(locations) != 2 {
+		t.Fatal("expected the backup to hold the previous version, got", locations, err)
+	}
+
+	backups := filepath.Join(dir, "backups")
+	if err = os.Mkdir(backups, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SetBackups(BackupOptions{Count: 1, Dir: backups}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(backups, "pass.db.bak.1")); err != nil {
+		t.Fatal("expected a backup in the configured directory, got", err)
+	}
+}
+
+func TestBackupsRemovedOnRekey(t *testing.T) {
+	relaxDefaults(t)
+	dir, err := ioutil.TempDir("", "masterkey-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "pass.db")
+
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := func() {
+		for i := 0; i < 2; i++ {
+			if err := v.Save(filename); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	save()
+	if _, err = os.Stat(filename + ".bak.1"); err != nil {
+		t.Fatal("expected a backup by default, got", err)
+	}
+
+	for name, revoke := range map[string]func() error{
+		"ChangePassphrase": func() error { return v.ChangePassphrase("testpass", "newpass") },
+		"RotateDataKey":    v.RotateDataKey,
+		"RemoveKeySlot": func() error {
+			if _, err := v.AddKeySlot("otherpass", ""); err != nil {
+				return err
+			}
+			save()
+			return v.RemoveKeySlot(1)
+		},
+	} {
+		if err = revoke(); err != nil {
+			t.Fatal(name, err)
+		}
+		if err = v.Save(filename); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(filename + ".bak.1"); !os.IsNotExist(err) {
+			t.Fatal("expected", name, "to remove the backups, got", err)
+		}
+		save()
+		if _, err = os.Stat(filename + ".bak.1"); err != nil {
+			t.Fatal("expected backups to be kept again after", name, "got", err)
+		}
+	}
+
+	hidden, err := v.NewHidden("hiddenpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = hidden.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filename + ".bak.1"); !os.IsNotExist(err) {
+		t.Fatal("expected a hidden vault's Save to remove the backups, got", err)
+	}
+}
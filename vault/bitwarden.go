@@ -0,0 +1,218 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidBitwarden is returned from ImportBitwarden if the export is
+	// not valid Bitwarden JSON.
+	ErrInvalidBitwarden = errors.New("invalid bitwarden export")
+	// ErrEncryptedBitwarden is returned from ImportBitwarden if the export
+	// is encrypted. Only unencrypted exports can be imported.
+	ErrEncryptedBitwarden = errors.New("encrypted bitwarden exports are not supported")
+)
+
+// Bitwarden item types.
+const (
+	bitwardenLogin    = 1
+	bitwardenNote     = 2
+	bitwardenCard     = 3
+	bitwardenIdentity = 4
+)
+
+type (
+	// bitwardenExport is an unencrypted Bitwarden JSON export.
+	bitwardenExport struct {
+		Encrypted bool `json:"encrypted"`
+		Folders   []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"folders"`
+		Items []bitwardenItem `json:"items"`
+	}
+
+	// bitwardenItem is an item in a Bitwarden export.
+	bitwardenItem struct {
+		FolderID     *string    `json:"folderId"`
+		Type         int        `json:"type"`
+		Name         string     `json:"name"`
+		Notes        *string    `json:"notes"`
+		Favorite     bool       `json:"favorite"`
+		CreationDate time.Time  `json:"creationDate"`
+		RevisionDate time.Time  `json:"revisionDate"`
+		DeletedDate  *time.Time `json:"deletedDate"`
+		Fields       []struct {
+			Name  string  `json:"name"`
+			Value *string `json:"value"`
+		} `json:"fields"`
+		Login *struct {
+			URIs []struct {
+				URI string `json:"uri"`
+			} `json:"uris"`
+			Username *string `json:"username"`
+			Password *string `json:"password"`
+			TOTP     *string `json:"totp"`
+		} `json:"login"`
+		Card     map[string]*string `json:"card"`
+		Identity map[string]*string `json:"identity"`
+
+		PasswordHistory []struct {
+			LastUsedDate time.Time `json:"lastUsedDate"`
+			Password     string    `json:"password"`
+		} `json:"passwordHistory"`
+	}
+)
+
+// bitwardenDocuments maps the keys of a Bitwarden identity's document
+// numbers to the names of the documents.
+var bitwardenDocuments = []struct{ key, document string }{
+	{"ssn", "social security number"},
+	{"passportNumber", "passport"},
+	{"licenseNumber", "driving license"},
+}
+
+// bitwardenString returns the string pointed to by `s`, or the empty string
+// if `s` is nil. Bitwarden exports missing values as null.
+func bitwardenString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// bitwardenJoin joins the non-empty values of `m` at `keys` using `sep`.
+func bitwardenJoin(m map[string]*string, sep string, keys ...string) string {
+	var parts []string
+	for _, key := range keys {
+		if part := bitwardenString(m[key]); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// credential converts the Bitwarden item to a Credential.
+func (item *bitwardenItem) credential() Credential {
+	c := Credential{
+		Notes:     bitwardenString(item.Notes),
+		Favorite:  item.Favorite,
+		CreatedAt: item.CreationDate,
+		UpdatedAt: item.RevisionDate,
+	}
+	if item.Login != nil {
+		c.Username = bitwardenString(item.Login.Username)
+		c.Password = bitwardenString(item.Login.Password)
+		if len(item.Login.URIs) > 0 {
+			c.URL = item.Login.URIs[0].URI
+		}
+		c.TOTPSecret = bitwardenString(item.Login.TOTP)
+	}
+
+	switch item.Type {
+	case bitwardenNote:
+		c.Kind = KindNote
+	case bitwardenCard:
+		c.Kind = KindCard
+		c.Card = &Card{
+			Number: bitwardenString(item.Card["number"]),
+			Holder: bitwardenString(item.Card["cardholderName"]),
+			Expiry: bitwardenJoin(item.Card, "/", "expMonth", "expYear"),
+			CVV:    bitwardenString(item.Card["code"]),
+		}
+		c.importField("brand", bitwardenString(item.Card["brand"]))
+	case bitwardenIdentity:
+		c.Kind = KindIdentity
+		c.Identity = &Identity{
+			Name:    bitwardenJoin(item.Identity, " ", "title", "firstName", "middleName", "lastName"),
+			Address: bitwardenJoin(item.Identity, ", ", "address1", "address2", "address3", "city", "state", "postalCode", "country"),
+			Phone:   bitwardenString(item.Identity["phone"]),
+			Email:   bitwardenString(item.Identity["email"]),
+		}
+		for _, d := range bitwardenDocuments {
+			if number := bitwardenString(item.Identity[d.key]); number != "" {
+				if c.Identity.Documents == nil {
+					c.Identity.Documents = make(map[string]string)
+				}
+				c.Identity.Documents[d.document] = number
+			}
+		}
+		c.importField("company", bitwardenString(item.Identity["company"]))
+		c.importField("username", bitwardenString(item.Identity["username"]))
+	}
+
+	for _, field := range item.Fields {
+		c.importField(field.Name, bitwardenString(field.Value))
+	}
+
+	history := item.PasswordHistory
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].LastUsedDate.Before(history[j].LastUsedDate)
+	})
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	for _, h := range history {
+		c.History = append(c.History, CredentialVersion{
+			Credential: Credential{Username: c.Username, Password: h.Password},
+			ReplacedAt: h.LastUsedDate,
+		})
+	}
+	return c
+}
+
+// ImportBitwarden imports the items of the unencrypted Bitwarden JSON export
+// read from `r`. Folders become folder prefixes of each item's location, and
+// an item's name is the last segment. Logins, secure notes, cards and
+// identities are imported as entries of the corresponding kind, and details
+// without a corresponding Credential field are stored as custom fields. TOTP
+// seeds are stored as each credential's TOTPSecret. Deleted items are not
+// imported, and existing locations are skipped.
+func (v *Vault) ImportBitwarden(r io.Reader) (ImportReport, error) {
+	var report ImportReport
+
+	var export bitwardenExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return report, ErrInvalidBitwarden
+	}
+	if export.Encrypted {
+		return report, ErrEncryptedBitwarden
+	}
+
+	// Bitwarden nests folders by separating their names with "/".
+	folders := make(map[string]string)
+	for _, folder := range export.Folders {
+		var segments []string
+		for _, segment := range strings.Split(folder.Name, "/") {
+			if segment = strings.TrimSpace(segment); segment != "" {
+				segments = append(segments, segment)
+			}
+		}
+		folders[folder.ID] = strings.Join(segments, FolderSeparator)
+	}
+
+	err := v.Batch(func(tx *Tx) error {
+		for i := range export.Items {
+			item := &export.Items[i]
+			if item.DeletedDate != nil {
+				continue
+			}
+			cred := item.credential()
+			location := importLocation(folders[bitwardenString(item.FolderID)], item.Name, cred.URL)
+			if err := report.add(tx, location, cred); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,156 @@
+package vault
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testBitwarden = `{
+  "encrypted": false,
+  "folders": [
+    {"id": "f1", "name": "work/code"}
+  ],
+  "items": [
+    {
+      "id": "i1",
+      "folderId": "f1",
+      "type": 1,
+      "name": "github",
+      "notes": "some notes",
+      "favorite": true,
+      "fields": [{"name": "pin", "value": "1234", "type": 1}],
+      "login": {
+        "uris": [{"match": null, "uri": "https://github.com/login"}],
+        "username": "testuser",
+        "password": "testpass",
+        "totp": "JBSWY3DPEHPK3PXP"
+      },
+      "passwordHistory": [
+        {"lastUsedDate": "2020-02-01T00:00:00.000Z", "password": "newer"},
+        {"lastUsedDate": "2020-01-01T00:00:00.000Z", "password": "older"}
+      ],
+      "creationDate": "2019-01-01T00:00:00.000Z",
+      "revisionDate": "2020-03-01T00:00:00.000Z",
+      "deletedDate": null
+    },
+    {
+      "id": "i2",
+      "folderId": null,
+      "type": 2,
+      "name": "wifi",
+      "notes": "secret note",
+      "favorite": false,
+      "secureNote": {"type": 0}
+    },
+    {
+      "id": "i3",
+      "folderId": null,
+      "type": 3,
+      "name": "visa",
+      "notes": null,
+      "favorite": false,
+      "card": {"cardholderName": "Test User", "brand": "Visa", "number": "4111111111111111", "expMonth": "12", "expYear": "2025", "code": "123"}
+    },
+    {
+      "id": "i4",
+      "folderId": null,
+      "type": 4,
+      "name": "me",
+      "notes": null,
+      "favorite": false,
+      "identity": {"title": null, "firstName": "Test", "middleName": null, "lastName": "User", "address1": "1 Main St", "city": "Springfield", "email": "test@example.com", "passportNumber": "X1234567"}
+    },
+    {
+      "id": "i5",
+      "folderId": null,
+      "type": 1,
+      "name": "deleted",
+      "favorite": false,
+      "login": {"username": "testuser", "password": "testpass"},
+      "deletedDate": "2020-03-01T00:00:00.000Z"
+    }
+  ]
+}`
+
+func TestImportBitwarden(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := v.ImportBitwarden(strings.NewReader(testBitwarden))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(report.Imported)
+	if !reflect.DeepEqual(report.Imported, []string{"me", "visa", "wifi", "work/code/github"}) {
+		t.Fatalf("unexpected imported locations %v", report.Imported)
+	}
+
+	cred, err := v.Get("work/code/github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" || cred.Notes != "some notes" || cred.URL != "https://github.com/login" {
+		t.Fatal("ImportBitwarden did not import login data")
+	}
+	if !cred.Favorite || cred.TOTPSecret != "JBSWY3DPEHPK3PXP" || cred.Fields["pin"] != "1234" {
+		t.Fatal("ImportBitwarden did not import the favorite flag and fields", cred.Fields)
+	}
+	if cred.CreatedAt.Year() != 2019 || cred.UpdatedAt.Month() != 3 {
+		t.Fatal("ImportBitwarden did not import timestamps")
+	}
+	if len(cred.History) != 2 || cred.History[0].Credential.Password != "older" || cred.History[1].Credential.Password != "newer" {
+		t.Fatal("ImportBitwarden did not import password history in order")
+	}
+
+	cred, err = v.Get("wifi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Notes != "secret note" || cred.Kind != KindNote {
+		t.Fatal("ImportBitwarden did not import the secure note")
+	}
+
+	cred, err = v.Get("visa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Kind != KindCard || !reflect.DeepEqual(*cred.Card, Card{Number: "4111111111111111", Holder: "Test User", Expiry: "12/2025", CVV: "123"}) {
+		t.Fatal("ImportBitwarden did not import the card", cred.Card)
+	}
+	if cred.Fields["brand"] != "Visa" {
+		t.Fatal("ImportBitwarden did not import the card brand")
+	}
+
+	cred, err = v.Get("me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Kind != KindIdentity || cred.Identity == nil {
+		t.Fatal("ImportBitwarden did not import the identity as an identity")
+	}
+	if cred.Identity.Name != "Test User" || cred.Identity.Address != "1 Main St, Springfield" || cred.Identity.Email != "test@example.com" {
+		t.Fatal("ImportBitwarden did not import the identity", cred.Identity)
+	}
+	if !reflect.DeepEqual(cred.Identity.Documents, map[string]string{"passport": "X1234567"}) {
+		t.Fatal("ImportBitwarden did not import the identity documents", cred.Identity.Documents)
+	}
+}
+
+func TestImportBitwardenInvalid(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.ImportBitwarden(strings.NewReader("not json")); err != ErrInvalidBitwarden {
+		t.Fatal("expected ErrInvalidBitwarden, got", err)
+	}
+	if _, err := v.ImportBitwarden(strings.NewReader(`{"encrypted": true, "items": []}`)); err != ErrEncryptedBitwarden {
+		t.Fatal("expected ErrEncryptedBitwarden, got", err)
+	}
+}
@@ -0,0 +1,248 @@
+//go:build bbolt
+
+package vault
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltTimeout is how long opening a bbolt database waits for another process
+// that has it open.
+const boltTimeout = time.Second
+
+var (
+	// boltVaultBucket holds a vault's header, in which nothing is secret,
+	// the sealed index and trash of its envelope, and the IDs of its
+	// entries in the envelope's order. boltEntriesBucket holds each sealed
+	// entry as a record keyed by its ID, and boltLogBucket each sealed
+	// chunk of the operation log keyed by its position.
+	boltVaultBucket   = []byte("vault")
+	boltEntriesBucket = []byte("entries")
+	boltLogBucket     = []byte("log")
+
+	boltHeaderKey = []byte("header")
+	boltIndexKey  = []byte("index")
+	boltIDsKey    = []byte("ids")
+	boltTrashKey  = []byte("trash")
+)
+
+// ErrNoBoltVault is returned from OpenBolt if the database holds no vault.
+var ErrNoBoltVault = errors.New("no vault is stored in the bbolt database")
+
+// BoltDB is a bbolt database a vault is stored in, available when masterkey
+// is built with the bbolt tag, which needs go.etcd.io/bbolt. Each of the
+// vault's credentials is stored sealed as its own record of the entries
+// bucket, keyed by the ID of its entry, which is derived from its location
+// without revealing it, so that a credential's record is found without
+// reading the others. Each save is a single bbolt transaction, so a crash
+// during it leaves the previous vault intact. A *BoltDB is a Storage, locked
+// using a lock file next to the database.
+type BoltDB struct {
+	// Path is the path of the database file.
+	Path string
+	lock storageLock
+}
+
+// open opens the database, returning a *VaultLockedError if another process
+// keeps it open for longer than boltTimeout.
+func (db *BoltDB) open() (*bolt.DB, error) {
+	bdb, err := bolt.Open(db.Path, 0600, &bolt.Options{Timeout: boltTimeout})
+	if err == bolt.ErrTimeout {
+		return nil, &VaultLockedError{}
+	}
+	return bdb, err
+}
+
+// Load reads the vault stored in the database, returning its contents as
+// Save would have written them to a file.
+func (db *BoltDB) Load() ([]byte, error) {
+	// bbolt would create a missing database.
+	if _, err := os.Stat(db.Path); err != nil {
+		return nil, err
+	}
+	bdb, err := db.open()
+	if err != nil {
+		return nil, err
+	}
+	defer bdb.Close()
+
+	var header []byte
+	var env envelope
+	err = bdb.View(func(tx *bolt.Tx) error {
+		vault, entries := tx.Bucket(boltVaultBucket), tx.Bucket(boltEntriesBucket)
+		if vault == nil || entries == nil || vault.Get(boltHeaderKey) == nil {
+			return ErrNoBoltVault
+		}
+		// Values are only valid during the transaction.
+		header = append([]byte(nil), vault.Get(boltHeaderKey)...)
+		env.Index = append([]byte(nil), vault.Get(boltIndexKey)...)
+		ids := vault.Get(boltIDsKey)
+		if len(ids)%entryIDSize != 0 {
+			return ErrCouldNotDecrypt
+		}
+		for ; len(ids) > 0; ids = ids[entryIDSize:] {
+			id := append([]byte(nil), ids[:entryIDSize]...)
+			entry := entries.Get(id)
+			if entry == nil {
+				return ErrCouldNotDecrypt
+			}
+			env.IDs = append(env.IDs, id)
+			env.Entries = append(env.Entries, append([]byte(nil), entry...))
+		}
+		if trash := vault.Get(boltTrashKey); trash != nil {
+			env.Trash = append([]byte(nil), trash...)
+		}
+		if log := tx.Bucket(boltLogBucket); log != nil {
+			for i := 0; ; i++ {
+				chunk := log.Get(boltLogKey(i))
+				if chunk == nil {
+					break
+				}
+				env.Log = append(env.Log, append([]byte(nil), chunk...))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contents := bytes.NewBuffer(header)
+	if err = gob.NewEncoder(contents).Encode(&env); err != nil {
+		return nil, err
+	}
+	return contents.Bytes(), nil
+}
+
+// Store replaces the vault stored in the database with `contents`, as Save
+// would write them to a file, in a single transaction. Only the records whose
+// entries or chunks of the operation log changed are written, and those no
+// longer in the vault are deleted.
+func (db *BoltDB) Store(contents []byte) error {
+	_, data, err := readHeader(contents)
+	if err != nil {
+		return err
+	}
+	var env envelope
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&env); err != nil {
+		return err
+	}
+	if len(env.IDs) != len(env.Entries) {
+		return ErrCouldNotDecrypt
+	}
+
+	bdb, err := db.open()
+	if err != nil {
+		return err
+	}
+	defer bdb.Close()
+	return bdb.Update(func(tx *bolt.Tx) error {
+		entries, err := tx.CreateBucketIfNotExists(boltEntriesBucket)
+		if err != nil {
+			return err
+		}
+		stored := make(map[string]bool)
+		for i, id := range env.IDs {
+			stored[string(id)] = true
+			if bytes.Equal(entries.Get(id), env.Entries[i]) {
+				continue
+			}
+			if err = entries.Put(id, env.Entries[i]); err != nil {
+				return err
+			}
+		}
+		// Records cannot be deleted while iterating over them.
+		var stale [][]byte
+		err = entries.ForEach(func(id []byte, _ []byte) error {
+			if !stored[string(id)] {
+				stale = append(stale, append([]byte(nil), id...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range stale {
+			if err = entries.Delete(id); err != nil {
+				return err
+			}
+		}
+
+		log, err := tx.CreateBucketIfNotExists(boltLogBucket)
+		if err != nil {
+			return err
+		}
+		for i, chunk := range env.Log {
+			if bytes.Equal(log.Get(boltLogKey(i)), chunk) {
+				continue
+			}
+			if err = log.Put(boltLogKey(i), chunk); err != nil {
+				return err
+			}
+		}
+		for i := len(env.Log); log.Get(boltLogKey(i)) != nil; i++ {
+			if err = log.Delete(boltLogKey(i)); err != nil {
+				return err
+			}
+		}
+
+		vault, err := tx.CreateBucketIfNotExists(boltVaultBucket)
+		if err != nil {
+			return err
+		}
+		if env.Trash == nil {
+			err = vault.Delete(boltTrashKey)
+		} else if !bytes.Equal(vault.Get(boltTrashKey), env.Trash) {
+			err = vault.Put(boltTrashKey, env.Trash)
+		}
+		if err != nil {
+			return err
+		}
+		if err = vault.Put(boltHeaderKey, contents[:len(contents)-len(data)]); err != nil {
+			return err
+		}
+		if err = vault.Put(boltIndexKey, env.Index); err != nil {
+			return err
+		}
+		return vault.Put(boltIDsKey, bytes.Join(env.IDs, nil))
+	})
+}
+
+// boltLogKey returns the key of the `i`th chunk of the operation log.
+func boltLogKey(i int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(i))
+	return key
+}
+
+// Lock locks the database, which does nothing if it is already locked by db.
+func (db *BoltDB) Lock() error {
+	return db.lock.acquire(db.Path)
+}
+
+// Unlock releases the lock on the database, removing the lock file.
+func (db *BoltDB) Unlock() error {
+	return db.lock.release()
+}
+
+// OpenBolt opens the vault stored in the bbolt database `db` by SaveBolt,
+// decrypting it using `passphrase` as OpenWithOptions does with `opts`. The
+// files recording failed attempts and holding the lock are kept next to the
+// database file.
+func OpenBolt(db BoltDB, passphrase string, opts OpenOptions) (*Vault, error) {
+	return openStorage(&db, db.Path, passphrase, opts)
+}
+
+// SaveBolt persists the vault to the bbolt database `db`, creating it if it
+// does not exist, replacing the vault stored in it in a single transaction, as
+// SaveStorage does.
+func (v *Vault) SaveBolt(db BoltDB) error {
+	return v.SaveStorage(&db)
+}
@@ -0,0 +1,124 @@
+//go:build bbolt
+
+package vault
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBolt(t *testing.T) {
+	relaxDefaults(t)
+	db := BoltDB{Path: "pass.bolt"}
+	defer os.Remove("pass.bolt")
+	if _, err := OpenBolt(db, "testpass", OpenOptions{}); !os.IsNotExist(err) {
+		t.Fatal("expected a missing database not to be created, got", err)
+	}
+
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, location := range []string{"location1", "location2", "location3"} {
+		if err = v.Add(location, Credential{Username: "testuser", Password: "testpass-" + location}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = v.Trash("location3"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.RestoreFromTrash("location3"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SaveBolt(db); err != nil {
+		t.Fatal(err)
+	}
+	p, err := v.decrypt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(location string) []byte {
+		bdb, err := bolt.Open("pass.bolt", 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer bdb.Close()
+		var value []byte
+		bdb.View(func(tx *bolt.Tx) error {
+			value = append([]byte(nil), tx.Bucket(boltEntriesBucket).Get(entryID(p.EntryKey, location))...)
+			return nil
+		})
+		return value
+	}
+	saved := record("location1")
+	if err = v.Delete("location2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SaveBolt(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each credential is a record keyed by the ID of its location, which
+	// is kept as it was unless the credential changed, and the deleted
+	// one's record is gone.
+	if !bytes.Equal(record("location1"), saved) {
+		t.Fatal("expected the unchanged credential's record to be kept")
+	}
+	bdb, err := bolt.Open("pass.bolt", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bdb.View(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(boltEntriesBucket)
+		if entries.Get(entryID(p.EntryKey, "location1")) == nil || entries.Get(entryID(p.EntryKey, "location3")) == nil {
+			t.Fatal("expected a record for each credential")
+		}
+		if entries.Get(entryID(p.EntryKey, "location2")) != nil {
+			t.Fatal("expected the deleted credential's record to be deleted")
+		}
+		records := 0
+		entries.ForEach(func(_ []byte, _ []byte) error {
+			records++
+			return nil
+		})
+		if records != minPaddedEntries {
+			t.Fatal("expected the records to be padded, got", records)
+		}
+		return nil
+	})
+	bdb.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = OpenBolt(db, "wrongpass", OpenOptions{}); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt, got", err)
+	}
+	vopen, err := OpenBolt(db, "testpass", OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred, err := vopen.Get("location3"); err != nil || cred.Password != "testpass-location3" {
+		t.Fatal("could not read the stored credential", err)
+	}
+	if _, err = vopen.Get("location2"); err != ErrNoSuchCredential {
+		t.Fatal("expected the deleted credential to be gone, got", err)
+	}
+	if _, err = vopen.VerifyLog(); err != nil {
+		t.Fatal("expected the operation log to be stored, got", err)
+	}
+	if log, err := vopen.Log(); err != nil || len(log) != 7 {
+		t.Fatal("expected every operation to be stored, got", len(log), err)
+	}
+
+	if err = ioutil.WriteFile("pass.bolt", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenBolt(db, "testpass", OpenOptions{}); err != ErrNoBoltVault {
+		t.Fatal("expected ErrNoBoltVault from an empty database, got", err)
+	}
+}
@@ -0,0 +1,185 @@
+package vault
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hibpPrefixLength is the number of hex characters of a password's SHA-1
+// digest sent to the Have I Been Pwned range API.
+const hibpPrefixLength = 5
+
+var (
+	// hibpRangeURL is the Have I Been Pwned range API endpoint, to which the
+	// hash prefix is appended. It is a variable so that tests can replace it.
+	hibpRangeURL = "https://api.pwnedpasswords.com/range/"
+
+	// hibpRequestInterval is the minimum time between requests to the range
+	// API.
+	hibpRequestInterval = 100 * time.Millisecond
+
+	// ErrBreachCheckFailed is returned from AuditBreached if the range API
+	// responds with an error.
+	ErrBreachCheckFailed = errors.New("breached password check failed")
+)
+
+// breachHashes returns the locations of every login with a password, keyed by
+// the uppercase hex SHA-1 digest of the password.
+func (p *payload) breachHashes() map[string][]string {
+	hashes := make(map[string][]string)
+	for _, location := range p.sortedLogins() {
+		sum := sha1.Sum([]byte(p.Credentials[location].Password))
+		hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+		hashes[hash] = append(hashes[hash], location)
+	}
+	return hashes
+}
+
+// breachFindings returns a finding for the locations of each hash in `counts`,
+// sorted by location.
+func breachFindings(hashes map[string][]string, counts map[string]int) []Finding {
+	var findings []Finding
+	for hash, count := range counts {
+		for _, location := range hashes[hash] {
+			findings = append(findings, Finding{
+				Location: location,
+				Reason:   fmt.Sprintf("password seen %v times in data breaches", count),
+			})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Location < findings[j].Location
+	})
+	return findings
+}
+
+// parseBreachLine parses a line of the form "HASH:COUNT", as returned by the
+// range API and found in the downloadable dataset. The count is optional.
+func parseBreachLine(line string) (hash string, count int, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", 0, false
+	}
+	hash, count = line, 1
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		n, err := strconv.Atoi(line[i+1:])
+		if err != nil {
+			return "", 0, false
+		}
+		hash, count = line[:i], n
+	}
+	return strings.ToUpper(hash), count, true
+}
+
+// AuditBreached checks the password of every login against the Have I Been
+// Pwned breached password corpus using `client`, or http.DefaultClient if nil,
+// returning a finding for each login whose password has appeared in a breach.
+// Only the first five hex characters of each password's SHA-1 digest are
+// sent (the k-anonymity range API), and requests are spaced to avoid
+// overwhelming the service.
+func (v *Vault) AuditBreached(ctx context.Context, client *http.Client) ([]Finding, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := p.breachHashes()
+	var prefixes []string
+	seen := make(map[string]bool)
+	for hash := range hashes {
+		if prefix := hash[:hibpPrefixLength]; !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+
+	counts := make(map[string]int)
+	for i, prefix := range prefixes {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(hibpRequestInterval):
+			}
+		}
+		if err = hibpRange(ctx, client, prefix, hashes, counts); err != nil {
+			return nil, err
+		}
+	}
+	return breachFindings(hashes, counts), nil
+}
+
+// hibpRange requests the hash suffixes matching `prefix` from the range API,
+// recording the count of each of `hashes` found in `counts`.
+func hibpRange(ctx context.Context, client *http.Client, prefix string, hashes map[string][]string, counts map[string]int) error {
+	req, err := http.NewRequest("GET", hibpRangeURL+prefix, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	// Padding hides the number of matching suffixes from observers of the
+	// response size. Padded entries have a count of zero.
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ErrBreachCheckFailed
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		suffix, count, ok := parseBreachLine(scanner.Text())
+		if !ok || count == 0 {
+			continue
+		}
+		if _, ok := hashes[prefix+suffix]; ok {
+			counts[prefix+suffix] = count
+		}
+	}
+	return scanner.Err()
+}
+
+// AuditBreachedOffline is AuditBreached using a local copy of the Have I Been
+// Pwned dataset read from `r`, one "HASH:COUNT" line per SHA-1 digest, so
+// that no network requests are made.
+func (v *Vault) AuditBreachedOffline(r io.Reader) ([]Finding, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := p.breachHashes()
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		hash, count, ok := parseBreachLine(scanner.Text())
+		if !ok || count == 0 {
+			continue
+		}
+		if _, ok := hashes[hash]; ok {
+			counts[hash] = count
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return breachFindings(hashes, counts), nil
+}
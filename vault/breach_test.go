@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testBreachCorpus is a breached password corpus containing "password" and
+// "letmein".
+var testBreachCorpus = []string{
+	"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493",
+	"B7A875FC1EA228B9061041B7CEC4BD3C52AB3CE3:152478",
+}
+
+func TestAuditBreached(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := map[string]Credential{
+		"github": {Password: "password"},
+		"gitlab": {Password: "password"},
+		"email":  {Password: "letmein"},
+		"bank":   {Password: "vX9#qL2!mR7$wK4@"},
+	}
+	for location, cred := range creds {
+		if err = v.Add(location, cred); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		requests = append(requests, prefix)
+		for _, line := range testBreachCorpus {
+			if strings.HasPrefix(line, prefix) {
+				fmt.Fprintf(w, "%v\r\n", strings.TrimPrefix(line, prefix))
+			}
+		}
+		fmt.Fprint(w, "0000000000000000000000000000000000A:0\r\n")
+	}))
+	defer server.Close()
+
+	defer func(url string) { hibpRangeURL = url }(hibpRangeURL)
+	hibpRangeURL = server.URL + "/range/"
+
+	findings, err := v.AuditBreached(context.Background(), server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Finding{
+		{Location: "email", Reason: "password seen 152478 times in data breaches"},
+		{Location: "github", Reason: "password seen 3861493 times in data breaches"},
+		{Location: "gitlab", Reason: "password seen 3861493 times in data breaches"},
+	}
+	if fmt.Sprint(findings) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected findings %v", findings)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("expected one request per distinct prefix, got %v", requests)
+	}
+	for _, prefix := range requests {
+		if len(prefix) != hibpPrefixLength {
+			t.Fatalf("AuditBreached sent more than the hash prefix: %q", prefix)
+		}
+	}
+
+	findings, err = v.AuditBreachedOffline(strings.NewReader(strings.Join(testBreachCorpus, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(findings) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected offline findings %v", findings)
+	}
+}
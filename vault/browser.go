@@ -0,0 +1,177 @@
+package vault
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// browserColumns describes the columns of a browser's saved-password CSV
+// export. Empty column names are not present in the export.
+type browserColumns struct {
+	url      string
+	username string
+	password string
+	notes    string
+	created  string
+	changed  string
+}
+
+var (
+	// chromeColumns are the columns of a Chrome password export.
+	chromeColumns = browserColumns{
+		url:      "url",
+		username: "username",
+		password: "password",
+		notes:    "note",
+	}
+
+	// firefoxColumns are the columns of a Firefox password export. Times
+	// are in milliseconds since the Unix epoch.
+	firefoxColumns = browserColumns{
+		url:      "url",
+		username: "username",
+		password: "password",
+		created:  "timeCreated",
+		changed:  "timePasswordChanged",
+	}
+)
+
+// browserLogin is a login read from a browser export.
+type browserLogin struct {
+	host string
+	cred Credential
+}
+
+// browserOrigin returns the origin (scheme, host and port) of `rawURL`, or
+// the empty string if it has no host.
+func browserOrigin(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// browserTime parses a time in milliseconds since the Unix epoch.
+func browserTime(ms string) time.Time {
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n*int64(time.Millisecond)).UTC()
+}
+
+// importBrowser imports the saved-password CSV export read from `r`, whose
+// layout is described by `columns`. Logins are deduplicated by origin and
+// username, keeping the most recently changed. Each login is stored at the
+// normalized host of its URL, or at "host/username" if the export contains
+// several usernames for the same host.
+func (v *Vault) importBrowser(r io.Reader, columns browserColumns) (ImportReport, error) {
+	var report ImportReport
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return report, err
+	}
+	indices := make(map[string]int)
+	for i, name := range header {
+		indices[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{columns.url, columns.username, columns.password} {
+		if _, ok := indices[name]; !ok {
+			return report, fmt.Errorf("%v: %q", ErrMissingColumn, name)
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return report, err
+	}
+
+	value := func(record []string, name string) string {
+		i, ok := indices[name]
+		if name == "" || !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var logins []*browserLogin
+	byOrigin := make(map[string]*browserLogin)
+	usernames := make(map[string]map[string]bool)
+	for row, record := range records {
+		login := &browserLogin{
+			host: normalizeHost(value(record, columns.url)),
+			cred: Credential{
+				Username:  value(record, columns.username),
+				Password:  value(record, columns.password),
+				Notes:     value(record, columns.notes),
+				URL:       value(record, columns.url),
+				CreatedAt: browserTime(value(record, columns.created)),
+				UpdatedAt: browserTime(value(record, columns.changed)),
+			},
+		}
+		origin := browserOrigin(login.cred.URL)
+		if login.host == "" || origin == "" {
+			// rows are numbered from 1, after the header.
+			return report, fmt.Errorf("browser export row %v has no url", row+1)
+		}
+
+		key := origin + "\x00" + login.cred.Username
+		if existing, ok := byOrigin[key]; ok {
+			if login.cred.UpdatedAt.After(existing.cred.UpdatedAt) {
+				*existing = *login
+			}
+			continue
+		}
+		byOrigin[key] = login
+		logins = append(logins, login)
+
+		if usernames[login.host] == nil {
+			usernames[login.host] = make(map[string]bool)
+		}
+		usernames[login.host][login.cred.Username] = true
+	}
+
+	err = v.Batch(func(tx *Tx) error {
+		for _, login := range logins {
+			location := login.host
+			if len(usernames[login.host]) > 1 {
+				location = importLocation(login.host, login.cred.Username, "")
+			}
+			if err := report.add(tx, location, login.cred); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	return report, nil
+}
+
+// ImportChrome imports the saved passwords in the Chrome (or other Chromium
+// based browser) CSV export read from `r`. Each login is stored at the
+// normalized host of its URL, or at "host/username" if there are several
+// logins for the host, with its URL field set. Duplicate logins for the same
+// origin and username are imported once, and existing locations are skipped.
+func (v *Vault) ImportChrome(r io.Reader) (ImportReport, error) {
+	return v.importBrowser(r, chromeColumns)
+}
+
+// ImportFirefox imports the saved passwords in the Firefox CSV export read
+// from `r`, in the same way as ImportChrome. Creation and password change
+// times are preserved, and of duplicate logins for the same origin and
+// username the most recently changed is imported.
+func (v *Vault) ImportFirefox(r io.Reader) (ImportReport, error) {
+	return v.importBrowser(r, firefoxColumns)
+}
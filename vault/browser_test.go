@@ -0,0 +1,78 @@
+package vault
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestImportChrome(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `name,url,username,password,note
+github.com,https://github.com/login,testuser,testpass,some notes
+github.com,https://github.com/session,testuser,testpass,
+mail.google.com,https://mail.google.com/,testuser,testpass,
+mail.google.com,https://mail.google.com/,testuser2,testpass2,
+`
+	report, err := v.ImportChrome(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(report.Imported)
+	if !reflect.DeepEqual(report.Imported, []string{"github.com", "mail.google.com/testuser", "mail.google.com/testuser2"}) {
+		t.Fatalf("unexpected imported locations %v", report.Imported)
+	}
+	if len(report.Skipped) != 0 {
+		t.Fatalf("duplicate logins were not deduplicated: %v", report.Skipped)
+	}
+
+	cred, err := v.Get("github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" || cred.Notes != "some notes" || cred.URL != "https://github.com/login" {
+		t.Fatal("ImportChrome did not import credential data")
+	}
+}
+
+func TestImportFirefox(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `"url","username","password","httpRealm","formActionOrigin","guid","timeCreated","timeLastUsed","timePasswordChanged"
+"https://www.github.com","testuser","oldpass",,"https://github.com","{1}","1500000000000","1500000000000","1500000000000"
+"https://www.github.com","testuser","newpass",,"https://github.com","{2}","1500000000000","1600000000000","1600000000000"
+`
+	report, err := v.ImportFirefox(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Imported, []string{"github.com"}) {
+		t.Fatalf("unexpected imported locations %v", report.Imported)
+	}
+
+	cred, err := v.Get("github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "newpass" || cred.URL != "https://www.github.com" {
+		t.Fatal("ImportFirefox did not keep the most recently changed login")
+	}
+	if cred.CreatedAt.Unix() != 1500000000 || cred.UpdatedAt.Unix() != 1600000000 {
+		t.Fatal("ImportFirefox did not import timestamps")
+	}
+
+	_, err = v.ImportFirefox(strings.NewReader("url,username\nhttps://github.com,testuser\n"))
+	if err == nil || !strings.Contains(err.Error(), ErrMissingColumn.Error()) {
+		t.Fatal("expected ImportFirefox to fail with a missing column, got", err)
+	}
+}
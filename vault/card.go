@@ -0,0 +1,94 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KindCard is a payment card, stored in Card.
+const KindCard Kind = "card"
+
+// Card is a payment card. Notes about the card are stored in the
+// credential's Notes.
+type Card struct {
+	Number string `json:"number"`
+	Holder string `json:"holder,omitempty"`
+	// Expiry is the expiry date as printed on the card, e.g. "12/25".
+	Expiry string `json:"expiry,omitempty"`
+	CVV    string `json:"cvv,omitempty"`
+	PIN    string `json:"pin,omitempty"`
+}
+
+// mask replaces every character of `s` with "•", other than spaces.
+func mask(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' {
+			return r
+		}
+		return '•'
+	}, s)
+}
+
+// MaskedNumber returns the card number with every digit masked other than
+// the last four, grouped in fours, e.g. "•••• •••• •••• 1111".
+func (card Card) MaskedNumber() string {
+	var digits []rune
+	for _, r := range card.Number {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte(' ')
+		}
+		if i < len(digits)-4 {
+			r = '•'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Display formats the card for display, one field per line. Unless `reveal`
+// is true, the number is masked by MaskedNumber and the CVV and PIN are
+// masked entirely.
+func (card Card) Display(reveal bool) string {
+	number, cvv, pin := card.Number, card.CVV, card.PIN
+	if !reveal {
+		number, cvv, pin = card.MaskedNumber(), mask(cvv), mask(pin)
+	}
+
+	var lines []string
+	add := func(name, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("%v: %v", name, value))
+		}
+	}
+	add("Number", number)
+	add("Holder", card.Holder)
+	add("Expiry", card.Expiry)
+	add("CVV", cvv)
+	add("PIN", pin)
+	return strings.Join(lines, "\n")
+}
+
+// AddCard adds the payment card `card` at `location`.
+func (v *Vault) AddCard(location string, card Card) error {
+	return v.Add(location, Credential{Kind: KindCard, Card: &card})
+}
+
+// GetCard retrieves the payment card at `location`. ErrWrongKind is
+// returned if the entry at `location` is not a card.
+func (v *Vault) GetCard(location string) (*Card, error) {
+	cred, err := v.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if cred.kind() != KindCard || cred.Card == nil {
+		return nil, ErrWrongKind
+	}
+	return cred.Card, nil
+}
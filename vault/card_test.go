@@ -0,0 +1,66 @@
+package vault
+
+import (
+	"testing"
+)
+
+func TestCardMasking(t *testing.T) {
+	card := Card{
+		Number: "4111 1111 1111 1234",
+		Holder: "Test User",
+		Expiry: "12/25",
+		CVV:    "123",
+		PIN:    "9876",
+	}
+
+	if masked := card.MaskedNumber(); masked != "•••• •••• •••• 1234" {
+		t.Fatalf("unexpected masked number %q", masked)
+	}
+	if masked := (Card{Number: "371449635398431"}).MaskedNumber(); masked != "•••• •••• •••8 431" {
+		t.Fatalf("unexpected masked amex number %q", masked)
+	}
+
+	masked := card.Display(false)
+	if masked != "Number: •••• •••• •••• 1234\nHolder: Test User\nExpiry: 12/25\nCVV: •••\nPIN: ••••" {
+		t.Fatalf("unexpected masked display %q", masked)
+	}
+	revealed := card.Display(true)
+	if revealed != "Number: 4111 1111 1111 1234\nHolder: Test User\nExpiry: 12/25\nCVV: 123\nPIN: 9876" {
+		t.Fatalf("unexpected revealed display %q", revealed)
+	}
+}
+
+func TestCards(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := Card{Number: "4111111111111111", Holder: "Test User", Expiry: "12/25", CVV: "123"}
+	if err = v.AddCard("visa", card); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := v.GetCard("visa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stored != card {
+		t.Fatal("GetCard did not return the stored card")
+	}
+
+	if err = v.AddNote("wifi", "body"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.GetCard("wifi"); err != ErrWrongKind {
+		t.Fatal("expected ErrWrongKind for a note, got", err)
+	}
+
+	cards, err := v.LocationsOfKind(KindCard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 1 || cards[0] != "visa" {
+		t.Fatalf("unexpected cards %v", cards)
+	}
+}
@@ -0,0 +1,181 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// CipherID identifies the cipher a vault's contents are encrypted with.
+type CipherID uint32
+
+const (
+	// CipherXSalsa20Poly1305 is nacl/secretbox. It is the default.
+	CipherXSalsa20Poly1305 CipherID = iota
+	// CipherAES256GCM is AES-256 in Galois/Counter Mode, for environments
+	// that may only use FIPS approved algorithms.
+	CipherAES256GCM
+	// CipherAES256GCMSIV is AES-256-GCM-SIV, as specified by RFC 8452,
+	// which unlike AES-GCM remains secure if a nonce is ever repeated.
+	CipherAES256GCMSIV
+	// CipherXChaCha20Poly1305 is XChaCha20-Poly1305. Like XSalsa20, its
+	// 24-byte nonces are long enough to be chosen at random for every
+	// seal without risk of repeating one.
+	CipherXChaCha20Poly1305
+)
+
+var (
+	// ErrUnsupportedCipher is returned if a vault is created with, or was
+	// written using, an unknown cipher.
+	ErrUnsupportedCipher = errors.New("cipher is not supported")
+
+	// ErrOtherKeySlots is returned from ChangeCipher if the vault has
+	// passphrase slots other than the one it was unlocked with, whose keys
+	// are wrapped using its cipher and cannot be rewrapped without their
+	// passphrases.
+	ErrOtherKeySlots = errors.New("vault has other passphrase key slots, which must be removed before changing its cipher")
+)
+
+// Cipher is an authenticated cipher used to encrypt a vault's contents. It
+// has the methods of crypto/cipher's AEAD, so the standard library's
+// implementations can be used directly.
+type Cipher interface {
+	// NonceSize returns the size of the nonce passed to Seal and Open.
+	NonceSize() int
+	// Overhead returns the difference between the lengths of a ciphertext
+	// and its plaintext.
+	Overhead() int
+	// Seal encrypts and authenticates `plaintext`, authenticates
+	// `additionalData`, and appends the result to `dst`.
+	Seal(dst, nonce, plaintext, additionalData []byte) []byte
+	// Open authenticates and decrypts `ciphertext`, authenticates
+	// `additionalData`, and appends the plaintext to `dst`.
+	Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// newCipher returns the cipher identified by `id`, keyed with `key`.
+func newCipher(id CipherID, key *[32]byte) (Cipher, error) {
+	switch id {
+	case CipherXSalsa20Poly1305:
+		return &secretboxCipher{key: *key}, nil
+	case CipherAES256GCM:
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case CipherAES256GCMSIV:
+		return newGCMSIV(key[:])
+	case CipherXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key[:])
+	default:
+		return nil, ErrUnsupportedCipher
+	}
+}
+
+// secretboxCipher is nacl/secretbox as a Cipher. Secretbox cannot
+// authenticate additional data, so none may be given.
+type secretboxCipher struct {
+	key [32]byte
+}
+
+func (c *secretboxCipher) NonceSize() int { return 24 }
+func (c *secretboxCipher) Overhead() int  { return secretbox.Overhead }
+
+func (c *secretboxCipher) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(additionalData) > 0 {
+		panic("vault: secretbox cannot authenticate additional data")
+	}
+	var n [24]byte
+	copy(n[:], nonce)
+	return secretbox.Seal(dst, plaintext, &n, &c.key)
+}
+
+func (c *secretboxCipher) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(additionalData) > 0 {
+		panic("vault: secretbox cannot authenticate additional data")
+	}
+	var n [24]byte
+	copy(n[:], nonce)
+	plaintext, ok := secretbox.Open(dst, ciphertext, &n, &c.key)
+	if !ok {
+		return nil, ErrCouldNotDecrypt
+	}
+	return plaintext, nil
+}
+
+// Cipher returns the cipher the vault's contents are encrypted with.
+func (v *Vault) Cipher() CipherID {
+	return v.cipher
+}
+
+// ChangeCipher verifies that `passphrase` unlocks the key slot the vault was
+// unlocked with, then re-encrypts the vault's contents using the cipher `id`
+// and rewraps the slot's key with it, such as to migrate an existing vault to
+// XChaCha20-Poly1305. The vault's other passphrase slots are wrapped using
+// its cipher too, so ErrOtherKeySlots is returned if it has any: remove them,
+// change the cipher, and add them again. GPG, TPM and PKCS#11 slots are kept.
+// Modifications that could be undone are forgotten, as they are encrypted
+// using the old cipher. The next Save writes the vault with the new cipher.
+func (v *Vault) ChangeCipher(passphrase string, id CipherID) error {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return ErrAgeVault
+	}
+	if v.outer != nil {
+		return ErrHiddenVault
+	}
+	if _, err := newCipher(id, &[32]byte{}); err != nil {
+		return err
+	}
+	slot := v.slots[v.slot]
+	if slot.gpgRecipient != "" || slot.tpm || slot.pkcs11Label != "" || slot.threshold > 0 {
+		return ErrNoPassphrase
+	}
+	for i, other := range v.slots {
+		if i != v.slot && other.gpgRecipient == "" && !other.tpm && other.pkcs11Label == "" {
+			return ErrOtherKeySlots
+		}
+	}
+
+	kek, err := v.factors.derive(passphrase, slot)
+	if err != nil {
+		return err
+	}
+	defer wipe(kek[:])
+	master, err := unwrapKey(v.cipher, &kek, slot.wrapped)
+	if err != nil || subtle.ConstantTimeCompare(master[:], v.keys.master[:]) != 1 {
+		return ErrIncorrectPassphrase
+	}
+	wipe(master[:])
+	wrapped, err := wrapKey(id, &kek, v.keys.master)
+	if err != nil {
+		return err
+	}
+	p, err := v.decrypt()
+	if err != nil {
+		return err
+	}
+
+	previous := v.cipher
+	v.cipher = id
+	if err = v.seal(p); err != nil {
+		v.cipher = previous
+		return err
+	}
+	v.slots[v.slot].wrapped = wrapped
+	for _, state := range v.undo.states {
+		wipe(state)
+	}
+	v.undo.clear()
+	return nil
+}
@@ -0,0 +1,93 @@
+package vault
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCiphers(t *testing.T) {
+	relaxDefaults(t)
+	for _, id := range []CipherID{CipherXSalsa20Poly1305, CipherAES256GCM, CipherAES256GCMSIV, CipherXChaCha20Poly1305} {
+		v, err := NewWithOptions("testpass", Options{Cipher: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+			t.Fatal(err)
+		}
+		if err = v.Save("pass.db"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = Open("pass.db", "wrongpass"); err != ErrCouldNotDecrypt {
+			t.Fatalf("expected ErrCouldNotDecrypt opening cipher %v with the wrong passphrase, got %v", id, err)
+		}
+		vopen, err := Open("pass.db", "testpass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vopen.Cipher() != id {
+			t.Fatalf("opened vault used cipher %v rather than %v", vopen.Cipher(), id)
+		}
+		cred, err := vopen.Get("testlocation")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cred.Password != "testpass" {
+			t.Fatalf("cipher %v did not round trip the vault", id)
+		}
+		os.Remove("pass.db")
+	}
+
+	if _, err := NewWithOptions("testpass", Options{Cipher: 42}); err != ErrUnsupportedCipher {
+		t.Fatal("expected ErrUnsupportedCipher with an unknown cipher, got", err)
+	}
+}
+
+func TestChangeCipher(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.AddKeySlot("otherpass", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.ChangeCipher("testpass", CipherXChaCha20Poly1305); err != ErrOtherKeySlots {
+		t.Fatal("expected ErrOtherKeySlots, got", err)
+	}
+	if err = v.RemoveKeySlot(1); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.ChangeCipher("wrongpass", CipherXChaCha20Poly1305); err != ErrIncorrectPassphrase {
+		t.Fatal("expected ErrIncorrectPassphrase, got", err)
+	}
+	if err = v.ChangeCipher("testpass", 42); err != ErrUnsupportedCipher {
+		t.Fatal("expected ErrUnsupportedCipher, got", err)
+	}
+	if err = v.ChangeCipher("testpass", CipherXChaCha20Poly1305); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vopen.Cipher() != CipherXChaCha20Poly1305 {
+		t.Fatal("expected the migrated vault to use XChaCha20-Poly1305, got", vopen.Cipher())
+	}
+	cred, err := vopen.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpass" {
+		t.Fatal("migrated vault had the wrong password:", cred.Password)
+	}
+}
@@ -0,0 +1,41 @@
+package vault
+
+import (
+	"errors"
+)
+
+// ErrLocked is returned from the methods of a vault after it has been
+// Closed.
+var ErrLocked = errors.New("vault is locked")
+
+// Close locks the vault, destroying the enclave holding its keys and
+// overwriting its encrypted contents, undo history and the secrets of its
+// unlock factors in memory with zeros. Afterwards, methods that read, modify
+// or save the vault return ErrLocked. The lock on the vault's file taken by
+// LockFile, or by OpenWithOptions with Lock set, is released. Unsaved changes
+// are lost, so the vault should be Saved first. Closing a vault that is
+// already locked does nothing.
+func (v *Vault) Close() error {
+	if v.closed {
+		return nil
+	}
+	v.closed = true
+
+	v.enclave.destroy()
+	v.keys = nil
+	wipe(v.data)
+	v.data = nil
+	for _, state := range v.undo.states {
+		wipe(state)
+	}
+	v.undo.clear()
+	wipe(v.factors.keyfile)
+	v.factors = unlockFactors{}
+	v.ageRecipients = nil
+	if v.locked != nil {
+		locked := v.locked
+		v.locked = nil
+		return locked.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package vault
+
+import (
+	"os"
+	"testing"
+)
+
+func TestClose(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if v.keys != nil || v.enclave.data != nil || v.data != nil {
+		t.Fatal("Close did not wipe the vault's keys and contents")
+	}
+	if err = v.Close(); err != nil {
+		t.Fatal("closing a locked vault failed:", err)
+	}
+
+	if _, err = v.Get("testlocation"); err != ErrLocked {
+		t.Fatal("expected Get to return ErrLocked, got", err)
+	}
+	if err = v.Add("otherlocation", Credential{}); err != ErrLocked {
+		t.Fatal("expected Add to return ErrLocked, got", err)
+	}
+	if err = v.Undo(); err != ErrLocked {
+		t.Fatal("expected Undo to return ErrLocked, got", err)
+	}
+	if err = v.ChangePassphrase("testpass", "newpass"); err != ErrLocked {
+		t.Fatal("expected ChangePassphrase to return ErrLocked, got", err)
+	}
+	if err = v.Save("pass.db"); err != ErrLocked {
+		os.Remove("pass.db")
+		t.Fatal("expected Save to return ErrLocked, got", err)
+	}
+}
@@ -0,0 +1,128 @@
+package vault
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// Compression is an algorithm the values of a vault's envelope are compressed
+// with before they are padded and sealed.
+type Compression uint32
+
+const (
+	// CompressionNone leaves values uncompressed. It is the default.
+	CompressionNone Compression = iota
+	// CompressionGzip compresses values using gzip.
+	CompressionGzip
+)
+
+// maxDecompressedSize bounds the size of a decompressed value, so that a
+// corrupt value cannot exhaust memory.
+const maxDecompressedSize = 256 << 20
+
+// ErrUnsupportedCompression is returned if a compression algorithm is not
+// known.
+var ErrUnsupportedCompression = errors.New("unsupported compression algorithm")
+
+// validate returns ErrUnsupportedCompression if `compression` is not known.
+func (compression Compression) validate() error {
+	if compression > CompressionGzip {
+		return ErrUnsupportedCompression
+	}
+	return nil
+}
+
+// compress compresses `plaintext`, returning it as it is if `compression` is
+// CompressionNone. The compressed value is locked into memory, and should be
+// wiped once it is sealed.
+func (compression Compression) compress(plaintext []byte) ([]byte, error) {
+	if compression == CompressionNone {
+		return plaintext, nil
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	lockBuffer(buf.Bytes())
+	return buf.Bytes(), nil
+}
+
+// decompress decompresses `data`, compressed by compress and then padded
+// with zeros, which are ignored, returning it as it is if `compression` is
+// CompressionNone. The decompressed value is locked into memory, and should
+// be wiped once it is decoded.
+func (compression Compression) decompress(data []byte) ([]byte, error) {
+	if compression == CompressionNone {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCouldNotDecrypt
+	}
+	// The padding follows the compressed stream, and is not another one.
+	r.Multistream(false)
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, maxDecompressedSize+1))
+	lockBuffer(buf.Bytes())
+	if err != nil || n > maxDecompressedSize {
+		wipe(buf.Bytes())
+		return nil, ErrCouldNotDecrypt
+	}
+	return buf.Bytes(), nil
+}
+
+// SetCompression re-encrypts the vault with its credentials compressed using
+// `compression` before they are encrypted, which can make a vault with long
+// notes several times smaller. The size of each compressed credential
+// depends on its contents, such as whether it repeats text found elsewhere
+// in it, so padding is kept on to hide most of that difference. The
+// compression is recorded in the vault's header, and undo history is
+// cleared, as it cannot be undone across the change.
+func (v *Vault) SetCompression(compression Compression) error {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return ErrAgeVault
+	}
+	if v.outer != nil {
+		return ErrHiddenVault
+	}
+	if err := compression.validate(); err != nil {
+		return err
+	}
+	p, err := v.decrypt()
+	if err != nil {
+		return err
+	}
+
+	previous := v.compression
+	v.compression = compression
+	if err = v.seal(p); err != nil {
+		v.compression = previous
+		return err
+	}
+	for _, state := range v.undo.states {
+		wipe(state)
+	}
+	v.undo.clear()
+	return nil
+}
+
+// Compression returns the algorithm the vault's credentials are compressed
+// with.
+func (v *Vault) Compression() Compression {
+	return v.compression
+}
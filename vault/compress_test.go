@@ -0,0 +1,75 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompressValue(t *testing.T) {
+	plaintext := []byte(strings.Repeat("compressible ", 100))
+	compressed, err := CompressionGzip.compress(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(plaintext) {
+		t.Fatal("expected the value to be compressed, got", len(compressed), "bytes")
+	}
+	padded := append(append([]byte{}, compressed...), make([]byte, 100)...)
+	decompressed, err := CompressionGzip.decompress(padded)
+	if err != nil || string(decompressed) != string(plaintext) {
+		t.Fatal("expected the padded value to be decompressed, got", err)
+	}
+	if _, err = CompressionGzip.decompress(plaintext); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt for an uncompressed value, got", err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := strings.Repeat("These notes repeat themselves at length. ", 500)
+	if err = v.Add("location1", Credential{Username: "testuser", Password: "testpassword", Notes: notes}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	uncompressed, err := ioutil.ReadFile("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.SetCompression(Compression(99)); err != ErrUnsupportedCompression {
+		t.Fatal("expected ErrUnsupportedCompression, got", err)
+	}
+	if err = v.SetCompression(CompressionGzip); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := ioutil.ReadFile("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(uncompressed)/2 {
+		t.Fatal("expected the compressed vault to be much smaller, got", len(compressed), "bytes rather than", len(uncompressed))
+	}
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vopen.Compression() != CompressionGzip {
+		t.Fatal("expected the compression to be recorded in the header, got", vopen.Compression())
+	}
+	if cred, err := vopen.Get("location1"); err != nil || cred.Notes != notes {
+		t.Fatal("could not read the compressed credential", err)
+	}
+}
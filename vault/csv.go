@@ -0,0 +1,153 @@
+package vault
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrMissingColumn is returned from ImportCSV if the CSV header does not
+// contain a column required by the ColumnMapping.
+var ErrMissingColumn = errors.New("csv is missing a required column")
+
+// ColumnMapping maps the columns of a CSV file, identified by their header,
+// to credential fields. Empty column names are not imported. A zero
+// ColumnMapping uses DefaultColumnMapping, ignoring any of its columns that
+// are missing from the CSV.
+type ColumnMapping struct {
+	Location string
+	Username string
+	Password string
+	Notes    string
+	URL      string
+	// Fields are additional columns imported as custom fields, keyed by
+	// their header.
+	Fields []string
+}
+
+// DefaultColumnMapping maps the columns "location", "username", "password",
+// "notes" and "url".
+var DefaultColumnMapping = ColumnMapping{
+	Location: "location",
+	Username: "username",
+	Password: "password",
+	Notes:    "notes",
+	URL:      "url",
+}
+
+// ImportCSV imports credentials from the CSV read from `r`, whose first row
+// must be a header naming its columns. Rows without a location are stored at
+// the host of their URL. Existing locations are skipped. The import is
+// atomic: if any row is malformed, no credentials are imported.
+func (v *Vault) ImportCSV(r io.Reader, mapping ColumnMapping) (ImportReport, error) {
+	var report ImportReport
+
+	useDefault := mapping.Location == "" && mapping.Username == "" && mapping.Password == "" && mapping.URL == ""
+	if useDefault {
+		mapping = DefaultColumnMapping
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return report, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := columns[name]
+		if !ok && useDefault {
+			return -1, nil
+		}
+		if !ok {
+			return -1, fmt.Errorf("%v: %q", ErrMissingColumn, name)
+		}
+		return i, nil
+	}
+
+	locationColumn, err := column(mapping.Location)
+	if err != nil {
+		return report, err
+	}
+	usernameColumn, err := column(mapping.Username)
+	if err != nil {
+		return report, err
+	}
+	passwordColumn, err := column(mapping.Password)
+	if err != nil {
+		return report, err
+	}
+	notesColumn, err := column(mapping.Notes)
+	if err != nil {
+		return report, err
+	}
+	urlColumn, err := column(mapping.URL)
+	if err != nil {
+		return report, err
+	}
+	fieldIndices := make(map[string]int)
+	for _, name := range mapping.Fields {
+		if fieldIndices[name], err = column(name); err != nil {
+			return report, err
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return report, err
+	}
+
+	value := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	err = v.Batch(func(tx *Tx) error {
+		for row, record := range records {
+			cred := Credential{
+				Username: value(record, usernameColumn),
+				Password: value(record, passwordColumn),
+				Notes:    value(record, notesColumn),
+				URL:      value(record, urlColumn),
+			}
+			for name, i := range fieldIndices {
+				if field := value(record, i); field != "" {
+					if cred.Fields == nil {
+						cred.Fields = make(map[string]string)
+					}
+					cred.Fields[name] = field
+				}
+			}
+
+			location := value(record, locationColumn)
+			if location == "" {
+				location = normalizeHost(cred.URL)
+			}
+			if location == "" {
+				// rows are numbered from 1, after the header.
+				return fmt.Errorf("csv row %v has no location or url", row+1)
+			}
+
+			if err := report.add(tx, location, cred); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	return report, nil
+}
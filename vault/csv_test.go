@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestImportCSV(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("existing", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `location,username,password,notes,url
+bank,testuser,testpass,"multi
+line notes",
+,testuser2,testpass2,,https://www.github.com/login
+existing,testuser3,testpass3,,
+`
+	report, err := v.ImportCSV(strings.NewReader(input), ColumnMapping{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(report.Imported)
+	if !reflect.DeepEqual(report.Imported, []string{"bank", "github.com"}) {
+		t.Fatalf("unexpected imported locations %v", report.Imported)
+	}
+	if !reflect.DeepEqual(report.Skipped, []string{"existing"}) {
+		t.Fatalf("unexpected skipped locations %v", report.Skipped)
+	}
+
+	cred, err := v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" || cred.Notes != "multi\nline notes" {
+		t.Fatal("ImportCSV did not import credential data")
+	}
+	cred, err = v.Get("github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.URL != "https://www.github.com/login" {
+		t.Fatal("ImportCSV did not import the url")
+	}
+	cred, err = v.Get("existing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpass" {
+		t.Fatal("ImportCSV overwrote an existing credential")
+	}
+}
+
+func TestImportCSVMapping(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "Title,Login,Secret,PIN\nbank,testuser,testpass,1234\n"
+	mapping := ColumnMapping{Location: "Title", Username: "Login", Password: "Secret", Fields: []string{"PIN"}}
+	_, err = v.ImportCSV(strings.NewReader(input), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" || cred.Fields["PIN"] != "1234" {
+		t.Fatal("ImportCSV did not respect the column mapping")
+	}
+
+	_, err = v.ImportCSV(strings.NewReader(input), ColumnMapping{Location: "Title", Username: "Username"})
+	if err == nil || !strings.Contains(err.Error(), ErrMissingColumn.Error()) {
+		t.Fatal("expected ImportCSV to fail with a missing column")
+	}
+}
+
+func TestImportCSVAtomic(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "location,username,password,url\nbank,testuser,testpass,\n,testuser,testpass,\n"
+	_, err = v.ImportCSV(strings.NewReader(input), ColumnMapping{})
+	if err == nil {
+		t.Fatal("expected ImportCSV to fail with a row missing a location")
+	}
+	if !strings.Contains(err.Error(), "row 2") || strings.Contains(err.Error(), "testpass") {
+		t.Fatalf("ImportCSV returned an unexpected error: %v", err)
+	}
+	if _, err = v.Get("bank"); err != ErrNoSuchCredential {
+		t.Fatal("expected a failed ImportCSV to import nothing")
+	}
+}
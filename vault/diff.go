@@ -0,0 +1,78 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Changes describes the differences between two vaults by location. Changes
+// never contains credential data, so it is safe to print.
+type Changes struct {
+	// Added are the locations that only exist in the second vault.
+	Added []string
+	// Removed are the locations that only exist in the first vault.
+	Removed []string
+	// Modified are the locations whose credentials differ between the
+	// vaults.
+	Modified []string
+}
+
+// Diff compares the vaults `a` and `b` and returns the changes required to
+// turn `a` into `b`. Differences in history and timestamps alone are not
+// reported.
+func Diff(a *Vault, b *Vault) (Changes, error) {
+	pa, err := a.decrypt()
+	if err != nil {
+		return Changes{}, err
+	}
+	pb, err := b.decrypt()
+	if err != nil {
+		return Changes{}, err
+	}
+	return diffPayloads(pa, pb), nil
+}
+
+// diffPayloads returns the changes required to turn `pa` into `pb`.
+func diffPayloads(pa *payload, pb *payload) Changes {
+	var changes Changes
+	for location, credA := range pa.Credentials {
+		credB, exists := pb.Credentials[location]
+		if !exists {
+			changes.Removed = append(changes.Removed, location)
+		} else if !credA.sameContent(credB) {
+			changes.Modified = append(changes.Modified, location)
+		}
+	}
+	for location := range pb.Credentials {
+		if _, exists := pa.Credentials[location]; !exists {
+			changes.Added = append(changes.Added, location)
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Modified)
+	return changes
+}
+
+// Empty returns true if there are no changes.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+// String returns the changes one location per line, prefixed with "+" for
+// added, "-" for removed, and "~" for modified locations.
+func (c Changes) String() string {
+	buf := new(bytes.Buffer)
+	for _, location := range c.Added {
+		fmt.Fprintln(buf, "+", location)
+	}
+	for _, location := range c.Removed {
+		fmt.Fprintln(buf, "-", location)
+	}
+	for _, location := range c.Modified {
+		fmt.Fprintln(buf, "~", location)
+	}
+	return buf.String()
+}
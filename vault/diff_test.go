@@ -0,0 +1,35 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	relaxDefaults(t)
+	a, b := newMergeTestVaults(t)
+
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Changes{
+		Added:    []string{"theirsonly"},
+		Removed:  []string{"mineonly"},
+		Modified: []string{"minenewer", "theirsnewer"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected changes %v, got %v", expected, changes)
+	}
+	if changes.String() != "+ theirsonly\n- mineonly\n~ minenewer\n~ theirsnewer\n" {
+		t.Fatalf("unexpected changes string %q", changes.String())
+	}
+
+	changes, err = Diff(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Empty() {
+		t.Fatal("expected no changes between a vault and itself")
+	}
+}
@@ -0,0 +1,163 @@
+package vault
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// maxDuplicateDistance is the largest edit distance between the normalized
+// locations of two logins with the same username for them to be considered
+// near duplicates.
+const maxDuplicateDistance = 2
+
+// Reasons given for a DuplicateGroup.
+const (
+	// DuplicateIdentical groups logins with identical usernames and
+	// passwords.
+	DuplicateIdentical = "identical username and password"
+	// DuplicateSimilar groups logins with the same username whose
+	// locations are nearly identical or refer to the same domain.
+	DuplicateSimilar = "same username and similar location"
+)
+
+// DuplicateGroup is a set of logins that are likely to be duplicates of one
+// another, such as those left behind by importing the same export twice.
+type DuplicateGroup struct {
+	// Locations are the locations of the logins, sorted.
+	Locations []string
+	// Reason is DuplicateIdentical or DuplicateSimilar.
+	Reason string
+}
+
+// editDistance returns the Levenshtein distance between `a` and `b`.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cur[j] = prev[j-1]
+			if ra[i-1] != rb[j-1] {
+				cur[j]++
+			}
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// domain returns the normalized host of the credential `cred` at `location`,
+// taken from its URL or, failing that, from the last element of its location
+// if it looks like a domain.
+func domain(location string, cred *Credential) string {
+	if host := normalizeHost(cred.URL); host != "" {
+		return host
+	}
+	if base := path.Base(location); strings.Contains(base, ".") {
+		return normalizeHost(base)
+	}
+	return ""
+}
+
+// similarLocations returns true if the logins `a` and `b` at `locationA` and
+// `locationB` are likely to be for the same account.
+func similarLocations(locationA string, a *Credential, locationB string, b *Credential) bool {
+	if !strings.EqualFold(a.Username, b.Username) {
+		return false
+	}
+	if domainA := domain(locationA, a); domainA != "" && domainA == domain(locationB, b) {
+		return true
+	}
+	normalizedA, normalizedB := normalizeLocation(locationA), normalizeLocation(locationB)
+	// Short locations are too easily within the distance of each other.
+	if len(normalizedA) <= 2*maxDuplicateDistance || len(normalizedB) <= 2*maxDuplicateDistance {
+		return normalizedA == normalizedB
+	}
+	return editDistance(normalizedA, normalizedB) <= maxDuplicateDistance
+}
+
+// FindDuplicates returns the groups of logins that are likely duplicates,
+// to help clean up the vault after an import: logins with identical
+// usernames and passwords, and logins with the same username whose locations
+// differ by at most a couple of characters or which refer to the same domain.
+// Groups are sorted by their first location, with identical groups first.
+func (v *Vault) FindDuplicates() ([]DuplicateGroup, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+	locations := p.sortedLogins()
+
+	byCredential := make(map[[2]string][]string)
+	identical := make(map[string]int)
+	var groups []DuplicateGroup
+	for _, location := range locations {
+		cred := p.Credentials[location]
+		key := [2]string{cred.Username, cred.Password}
+		byCredential[key] = append(byCredential[key], location)
+	}
+	for _, location := range locations {
+		cred := p.Credentials[location]
+		group := byCredential[[2]string{cred.Username, cred.Password}]
+		if len(group) > 1 && group[0] == location {
+			for _, member := range group {
+				identical[member] = len(groups) + 1
+			}
+			groups = append(groups, DuplicateGroup{Locations: group, Reason: DuplicateIdentical})
+		}
+	}
+
+	// Near duplicates are grouped transitively using union-find, ignoring
+	// pairs already grouped as identical.
+	parent := make(map[string]string)
+	var find func(location string) string
+	find = func(location string) string {
+		if parent[location] == location {
+			return location
+		}
+		parent[location] = find(parent[location])
+		return parent[location]
+	}
+	for _, location := range locations {
+		parent[location] = location
+	}
+	for i, a := range locations {
+		for _, b := range locations[i+1:] {
+			if identical[a] != 0 && identical[a] == identical[b] {
+				continue
+			}
+			if similarLocations(a, p.Credentials[a], b, p.Credentials[b]) {
+				parent[find(b)] = find(a)
+			}
+		}
+	}
+	similar := make(map[string][]string)
+	for _, location := range locations {
+		root := find(location)
+		similar[root] = append(similar[root], location)
+	}
+	for _, location := range locations {
+		if group := similar[location]; len(group) > 1 {
+			groups = append(groups, DuplicateGroup{Locations: group, Reason: DuplicateSimilar})
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Reason != groups[j].Reason {
+			return groups[i].Reason == DuplicateIdentical
+		}
+		return groups[i].Locations[0] < groups[j].Locations[0]
+	})
+	return groups, nil
+}
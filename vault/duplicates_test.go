@@ -0,0 +1,58 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, test := range []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"github", "github", 0},
+		{"github", "githbu", 2},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	} {
+		if d := editDistance(test.a, test.b); d != test.distance {
+			t.Fatalf("editDistance(%q, %q) = %v, expected %v", test.a, test.b, d, test.distance)
+		}
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for location, cred := range map[string]Credential{
+		"email":            {Username: "testuser", Password: "hunter2"},
+		"email (imported)": {Username: "testuser", Password: "hunter2"},
+		"Github.com":       {Username: "testuser", Password: "pass1"},
+		"web/github.com":   {Username: "TestUser", Password: "pass2"},
+		"bank":             {Username: "testuser", Password: "pass3", URL: "https://bank.example.com"},
+		"savings":          {Username: "testuser", Password: "pass4", URL: "bank.example.com/login"},
+		"aws/prod":         {Username: "admin", Password: "pass5"},
+		"aws/dev":          {Username: "developer", Password: "pass6"},
+	} {
+		if err = v.Add(location, cred); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := v.FindDuplicates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []DuplicateGroup{
+		{Locations: []string{"email", "email (imported)"}, Reason: DuplicateIdentical},
+		{Locations: []string{"Github.com", "web/github.com"}, Reason: DuplicateSimilar},
+		{Locations: []string{"bank", "savings"}, Reason: DuplicateSimilar},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Fatalf("unexpected duplicate groups %v", groups)
+	}
+}
@@ -0,0 +1,250 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	// hiddenFillerSize is the size of the hidden section of a vault, unless
+	// it holds a hidden vault too large for it. From vault version 16, the
+	// records of even a small hidden vault are sealed apart from its
+	// index, each with its own types, which no longer fit in 4 KiB.
+	hiddenFillerSize = 8192
+
+	// maxHiddenSize bounds the size of the hidden section.
+	maxHiddenSize = 64 << 20
+
+	// hiddenOverhead is the size of the salt, nonce and authenticator of a
+	// hidden section holding a hidden vault.
+	hiddenOverhead = 24 + 24 + secretbox.Overhead
+)
+
+// hiddenKDF derives the key of a hidden vault. It cannot be recorded without
+// revealing the hidden vault, so it is fixed.
+var hiddenKDF = KDFParams{KDF: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// ErrHiddenVault is returned by methods managing the key slots of a hidden
+// vault, which has none.
+var ErrHiddenVault = errors.New("vault is a hidden vault")
+
+type (
+	// outerVault is the outer vault of a hidden vault, which is written
+	// unchanged around it: either the vault itself, if the hidden vault
+	// was created in this session, or its header and data as read.
+	outerVault struct {
+		vault  *Vault
+		header []byte
+		data   []byte
+	}
+
+	// hiddenPayload is the plaintext of the hidden section of a vault
+	// holding a hidden vault.
+	hiddenPayload struct {
+		DataKey [32]byte
+		Data    []byte
+	}
+)
+
+// hiddenFiller returns a hidden section holding no hidden vault: random bytes,
+// which cannot be told apart from one that does.
+func hiddenFiller() []byte {
+	filler := make([]byte, hiddenFillerSize)
+	if _, err := io.ReadFull(rand.Reader, filler); err != nil {
+		panic(err)
+	}
+	return filler
+}
+
+// writeHiddenSection writes the hidden section `hidden` to `w`, preceded by
+// its size.
+func writeHiddenSection(w io.Writer, hidden []byte) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(hidden))); err != nil {
+		return err
+	}
+	_, err := w.Write(hidden)
+	return err
+}
+
+// empty returns true if no factors other than a passphrase are set.
+func (factors unlockFactors) empty() bool {
+	return factors.keyfile == nil && factors.respond == nil && factors.fido2 == nil && factors.gpgDecrypt == nil && factors.tpm == nil && factors.pkcs11 == nil && factors.approve == nil
+}
+
+// NewHidden creates a new, empty, hidden vault inside the vault, unlocked by
+// `passphrase`, for plausible deniability: the vault itself holds innocuous
+// decoy credentials, and its passphrase can be given up under duress without
+// revealing that the hidden vault exists. Every vault has a hidden section of
+// random bytes, which is replaced by the hidden vault, encrypted, and Open
+// opens the hidden vault if `passphrase` unlocks none of the vault's key
+// slots. Any hidden vault already inside the vault is lost, as there is no
+// telling whether there is one.
+//
+// The hidden vault is written by its Save, along with the vault as last
+// modified, whose later Saves keep the hidden vault as it was. Modifying the
+// vault after opening the hidden vault, or the other way around, in the same
+// session overwrites the other's changes, so only one should be open at a
+// time. Hidden vaults larger than 8 KiB make the hidden section larger than
+// usual, which hints at their existence, as does saving a hidden vault opened
+// by Open inside a signed vault, which invalidates its signature.
+func (v *Vault) NewHidden(passphrase string) (*Vault, error) {
+	if v.closed {
+		return nil, ErrLocked
+	}
+	if v.readOnly {
+		return nil, ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return nil, ErrAgeVault
+	}
+	if v.outer != nil {
+		return nil, ErrHiddenVault
+	}
+
+	salt := randomSalt()
+	kek, err := hiddenKDF.derive(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	keys, enclave := newVaultKeys()
+	keys.master = kek
+	wipe(kek[:])
+	hidden := &Vault{
+		keys:    keys,
+		enclave: enclave,
+		outer:   &outerVault{vault: v},
+		undo:    newUndoStack(),
+	}
+	copy(hidden.hiddenSalt[:], salt[:])
+	if err = hidden.encrypt(newPayload()); err != nil {
+		enclave.destroy()
+		return nil, err
+	}
+	return hidden, nil
+}
+
+// openHidden opens the hidden vault in the hidden section of `fh`, read along
+// with `data`, using `passphrase`.
+func openHidden(fh fileHeader, data []byte, passphrase string) (*Vault, *payload, error) {
+	if len(fh.hidden) < hiddenOverhead {
+		return nil, nil, ErrCouldNotDecrypt
+	}
+	var salt, nonce [24]byte
+	copy(salt[:], fh.hidden[:24])
+	copy(nonce[:], fh.hidden[24:48])
+	keys, enclave := newVaultKeys()
+	var err error
+	if keys.master, err = hiddenKDF.derive(passphrase, salt); err != nil {
+		enclave.destroy()
+		return nil, nil, err
+	}
+
+	plaintext := newEnclave(len(fh.hidden))
+	defer plaintext.destroy()
+	decrypted, ok := secretbox.Open(plaintext.data[:0], fh.hidden[48:], &nonce, &keys.master)
+	var hp hiddenPayload
+	if !ok || gob.NewDecoder(bytes.NewReader(decrypted)).Decode(&hp) != nil {
+		enclave.destroy()
+		return nil, nil, ErrCouldNotDecrypt
+	}
+	keys.secret = hp.DataKey
+	wipe(hp.DataKey[:])
+
+	v := &Vault{
+		data:       hp.Data,
+		keys:       keys,
+		enclave:    enclave,
+		outer:      &outerVault{header: fh.raw, data: data},
+		hiddenSalt: salt,
+		undo:       newUndoStack(),
+	}
+	p, err := v.decryptIndex()
+	if err != nil {
+		enclave.destroy()
+		return nil, nil, err
+	}
+	return v, p, nil
+}
+
+// sealHidden returns the hidden section holding the vault, a hidden vault,
+// padded to hiddenFillerSize or the next power of two.
+func (v *Vault) sealHidden() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&hiddenPayload{DataKey: v.keys.secret, Data: v.data}); err != nil {
+		return nil, err
+	}
+	lockBuffer(buf.Bytes())
+	defer wipe(buf.Bytes())
+
+	size := hiddenFillerSize
+	for size < buf.Len()+hiddenOverhead {
+		size *= 2
+	}
+	if size > maxHiddenSize {
+		return nil, errors.New("hidden vault is too large")
+	}
+	plaintext := make([]byte, size-hiddenOverhead)
+	copy(plaintext, buf.Bytes())
+	defer wipe(plaintext)
+
+	var nonce [24]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		panic(err)
+	}
+	hidden := append(append([]byte{}, v.hiddenSalt[:]...), nonce[:]...)
+	return secretbox.Seal(hidden, plaintext, &nonce, &v.keys.master), nil
+}
+
+// writeInOuter writes the vault, a hidden vault, inside its outer vault to
+// `w`. If the outer vault is open, its later Saves keep the hidden vault.
+func (v *Vault) writeInOuter(w io.Writer) error {
+	hidden, err := v.sealHidden()
+	if err != nil {
+		return err
+	}
+	header, data := v.outer.header, v.outer.data
+	if outer := v.outer.vault; outer != nil {
+		var buf bytes.Buffer
+		if err = outer.writeHeader(&buf); err != nil {
+			return err
+		}
+		header, data = buf.Bytes(), outer.data
+		outer.hidden = hidden
+	}
+
+	if _, err = w.Write(header); err != nil {
+		return err
+	}
+	if err = writeHiddenSection(w, hidden); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// rekeyHidden verifies that `oldPassphrase` is the passphrase of the vault, a
+// hidden vault, then derives its key from `newPassphrase` with a new salt.
+func (v *Vault) rekeyHidden(oldPassphrase string, newPassphrase string) error {
+	var salt [24]byte
+	copy(salt[:], v.hiddenSalt[:])
+	oldKey, err := hiddenKDF.derive(oldPassphrase, salt)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(oldKey[:], v.keys.master[:]) != 1 {
+		return ErrIncorrectPassphrase
+	}
+	salt = randomSalt()
+	if v.keys.master, err = hiddenKDF.derive(newPassphrase, salt); err != nil {
+		return err
+	}
+	copy(v.hiddenSalt[:], salt[:])
+	return nil
+}
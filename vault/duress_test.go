@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"os"
+	"testing"
+)
+
+func TestHiddenVault(t *testing.T) {
+	relaxDefaults(t)
+	decoy, err := New("duresspass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decoy.Add("decoylocation", Credential{Username: "decoyuser", Password: "decoypass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = decoy.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	info, err := os.Stat("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := info.Size()
+
+	hidden, err := decoy.NewHidden("realpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = hidden.Add("reallocation", Credential{Username: "realuser", Password: "realpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = hidden.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err = os.Stat("pass.db"); err != nil || info.Size() != size {
+		t.Fatal("a small hidden vault changed the size of the vault:", err)
+	}
+
+	// Saving the decoy keeps the hidden vault.
+	if err = decoy.Add("otherdecoy", Credential{Username: "decoyuser"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = decoy.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := Open("pass.db", "duresspass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("reallocation"); err != ErrNoSuchCredential {
+		t.Fatal("the duress passphrase revealed a hidden credential")
+	}
+	if _, err = v.Get("otherdecoy"); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err = Open("pass.db", "realpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred, err := v.Get("reallocation"); err != nil || cred.Password != "realpass" {
+		t.Fatal("could not read the hidden vault:", err)
+	}
+	if _, err = v.Get("decoylocation"); err != ErrNoSuchCredential {
+		t.Fatal("the hidden vault contains decoy credentials")
+	}
+	if _, err = v.AddKeySlot("otherpass", ""); err != ErrHiddenVault {
+		t.Fatal("expected ErrHiddenVault, got", err)
+	}
+
+	// Saving the hidden vault keeps the decoy, and its new passphrase
+	// opens it.
+	if err = v.ChangePassphrase("realpass", "newpass"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "realpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected the old hidden passphrase to fail, got", err)
+	}
+	if v, err = Open("pass.db", "newpass"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("reallocation"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err = Open("pass.db", "duresspass"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("otherdecoy"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,101 @@
+package vault
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"sync"
+	"unsafe"
+)
+
+// canarySize is the size of the canary preceding the contents of an enclave.
+const canarySize = 16
+
+var (
+	// errGuardUnsupported is returned by allocGuarded on platforms without
+	// guard pages.
+	errGuardUnsupported = errors.New("guarded memory is not supported on this platform")
+
+	// canary is written before the contents of every enclave, and checked
+	// when it is destroyed.
+	canary     [canarySize]byte
+	canaryOnce sync.Once
+)
+
+type (
+	// enclave is a buffer of secret data in guarded memory, in the style of
+	// memguard: its pages are locked into memory and excluded from core
+	// dumps, and it is placed between two inaccessible guard pages, ending
+	// at the second, so that an overflow faults rather than reading or
+	// writing past it. A random canary precedes it to detect underflows.
+	// Where guarded memory cannot be allocated, an enclave is a plain
+	// buffer with a canary.
+	enclave struct {
+		// mem is the whole allocation, including the guard pages, or nil
+		// if the enclave is not guarded.
+		mem    []byte
+		canary []byte
+		data   []byte
+		// locked is set if the enclave's pages are locked into memory.
+		locked bool
+	}
+
+	// vaultKeys are the keys of a vault, which are kept in an enclave.
+	vaultKeys struct {
+		// secret is the data key.
+		secret [32]byte
+		master [32]byte
+	}
+)
+
+// newEnclave returns an enclave of `size` bytes, which must be destroyed
+// once it is no longer needed.
+func newEnclave(size int) *enclave {
+	canaryOnce.Do(func() {
+		if _, err := io.ReadFull(rand.Reader, canary[:]); err != nil {
+			panic(err)
+		}
+	})
+
+	e := &enclave{}
+	mem, inner, err := allocGuarded(size + canarySize)
+	if err == nil {
+		e.mem = mem
+		e.locked = mlock(inner) == nil
+	} else {
+		inner = make([]byte, size+canarySize)
+	}
+	start := len(inner) - size
+	e.canary = inner[start-canarySize : start]
+	copy(e.canary, canary[:])
+	e.data = inner[start:]
+	return e
+}
+
+// check panics if the enclave's canary has been overwritten.
+func (e *enclave) check() {
+	if subtle.ConstantTimeCompare(e.canary, canary[:]) != 1 {
+		panic("vault: memory preceding guarded key material was overwritten")
+	}
+}
+
+// destroy checks the enclave's canary, wipes its contents and frees it.
+func (e *enclave) destroy() {
+	e.check()
+	wipe(e.data)
+	if e.mem != nil {
+		freeGuarded(e.mem)
+	}
+	e.mem, e.canary, e.data = nil, nil, nil
+}
+
+// newVaultKeys returns random keys for a vault, in a new enclave.
+func newVaultKeys() (*vaultKeys, *enclave) {
+	e := newEnclave(int(unsafe.Sizeof(vaultKeys{})))
+	keys := (*vaultKeys)(unsafe.Pointer(&e.data[0]))
+	if _, err := io.ReadFull(rand.Reader, e.data); err != nil {
+		panic(err)
+	}
+	return keys, e
+}
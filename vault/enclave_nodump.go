@@ -0,0 +1,7 @@
+//go:build unix && !linux
+
+package vault
+
+// noDump does nothing where pages cannot be excluded from core dumps; the
+// process's core dumps should be disabled instead.
+func noDump(b []byte) {}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package vault
+
+// allocGuarded reports that guarded memory cannot be allocated on this
+// platform, so enclaves are plain buffers.
+func allocGuarded(size int) ([]byte, []byte, error) {
+	return nil, nil, errGuardUnsupported
+}
+
+// freeGuarded is never called on this platform.
+func freeGuarded(mem []byte) error {
+	return nil
+}
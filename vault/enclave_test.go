@@ -0,0 +1,26 @@
+package vault
+
+import (
+	"testing"
+)
+
+func TestEnclave(t *testing.T) {
+	e := newEnclave(100)
+	if len(e.data) != 100 {
+		t.Fatal("enclave has the wrong size:", len(e.data))
+	}
+	copy(e.data, "secret")
+	e.destroy()
+	if e.data != nil {
+		t.Fatal("destroyed enclave still has contents")
+	}
+
+	e = newEnclave(32)
+	e.canary[0] ^= 1
+	defer func() {
+		if recover() == nil {
+			t.Fatal("destroying an enclave with an overwritten canary did not panic")
+		}
+	}()
+	e.destroy()
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package vault
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// allocGuarded maps pages holding at least `size` bytes between two guard
+// pages, returning the whole mapping and the pages between the guards.
+func allocGuarded(size int) ([]byte, []byte, error) {
+	page := os.Getpagesize()
+	n := (size + page - 1) / page * page
+	mem, err := unix.Mmap(-1, 0, n+2*page, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = unix.Mprotect(mem[:page], unix.PROT_NONE); err == nil {
+		err = unix.Mprotect(mem[page+n:], unix.PROT_NONE)
+	}
+	if err != nil {
+		unix.Munmap(mem)
+		return nil, nil, err
+	}
+	inner := mem[page : page+n]
+	noDump(inner)
+	return mem, inner, nil
+}
+
+// freeGuarded unmaps a mapping returned by allocGuarded.
+func freeGuarded(mem []byte) error {
+	return unix.Munmap(mem)
+}
@@ -0,0 +1,36 @@
+package vault
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// allocGuarded allocates pages holding at least `size` bytes between two
+// guard pages, returning the whole allocation and the pages between the
+// guards. Windows does not include private memory in crash dumps unless
+// asked to.
+func allocGuarded(size int) ([]byte, []byte, error) {
+	page := os.Getpagesize()
+	n := (size + page - 1) / page * page
+	addr, err := windows.VirtualAlloc(0, uintptr(n+2*page), windows.MEM_COMMIT|windows.MEM_RESERVE, windows.PAGE_READWRITE)
+	if err != nil {
+		return nil, nil, err
+	}
+	mem := unsafe.Slice(*(**byte)(unsafe.Pointer(&addr)), n+2*page)
+	var old uint32
+	if err = windows.VirtualProtect(addr, uintptr(page), windows.PAGE_NOACCESS, &old); err == nil {
+		err = windows.VirtualProtect(addr+uintptr(page+n), uintptr(page), windows.PAGE_NOACCESS, &old)
+	}
+	if err != nil {
+		windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+		return nil, nil, err
+	}
+	return mem, mem[page : page+n], nil
+}
+
+// freeGuarded frees an allocation returned by allocGuarded.
+func freeGuarded(mem []byte) error {
+	return windows.VirtualFree(uintptr(unsafe.Pointer(&mem[0])), 0, windows.MEM_RELEASE)
+}
@@ -0,0 +1,515 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// entryIDSize is the size of the IDs of an envelope's entries.
+const entryIDSize = 16
+
+type (
+	// envelope is the encrypted contents of a vault, from vault version 6.
+	// Rather than sealing the whole payload as one, each credential is
+	// sealed separately, so that one can be read without decrypting the
+	// others, and the rest of the payload is sealed as the index. From
+	// version 16, the entries, trash and operation log are sealed with the
+	// payload's RecordKey, and only the index with the data key, so that
+	// the records that did not change are kept as they are from one save
+	// to the next, and across sessions.
+	envelope struct {
+		Index []byte
+		// Entries are the sealed entryPayloads of the credentials, in
+		// the order of the index's Locations, followed from version 11
+		// by random entries padding their number.
+		Entries [][]byte
+		// IDs are, from version 14, the IDs of the entries, in the same
+		// order: the ID of a credential's entry is derived from its
+		// location by entryID, and the padding entries have random
+		// ones. A storage can keep each entry as a record keyed by its
+		// ID, which identifies the credential from one save to the next
+		// without revealing its location.
+		IDs [][]byte
+		// Trash and Log, from version 16, are the sealed trash, if it
+		// is not empty, and the sealed chunks of the operation log,
+		// oldest first, which are kept out of the index so that
+		// opening it does not open them too.
+		Trash []byte
+		Log   [][]byte
+	}
+
+	// indexPayload is the plaintext of an envelope's index: the payload
+	// without its credentials, trash and operation log, and the locations
+	// of the credentials.
+	indexPayload struct {
+		Payload   payload
+		Locations []string
+		// EntryTypes, in version 15, starts the gob stream that each
+		// entry continues: it defines the types of an entryPayload,
+		// followed by an empty one. Each entry then holds only its
+		// value, so that the types are compiled once for all entries
+		// rather than once for each. Before version 15, each entry is a
+		// gob stream of its own.
+		EntryTypes []byte
+		// Streams, from version 16, start the gob streams the entries
+		// continue, as EntryTypes did, and EntryStreams are the stream
+		// each credential's entry continues. Entries that changed are
+		// sealed continuing the stream of the process sealing them, and
+		// gob numbers types in the order a process first uses them, so
+		// entries sealed by different processes may continue different
+		// streams.
+		Streams      [][]byte
+		EntryStreams []int
+	}
+
+	// entryPayload is the plaintext of an envelope's entry. The location is
+	// sealed along with the credential so that entries cannot be swapped.
+	entryPayload struct {
+		Location   string
+		Credential *Credential
+	}
+
+	// entryDecoder decodes the entries continuing one of an index's
+	// Streams using a single gob.Decoder, which reads each entry's value
+	// in turn after the stream's types.
+	entryDecoder struct {
+		// r is not buffered by the decoder, as it is an io.ByteReader,
+		// so it can be reset to each entry.
+		r   bytes.Reader
+		dec *gob.Decoder
+	}
+
+	// entryEncoder seals entries continuing a gob stream of its own, which
+	// its types start.
+	entryEncoder struct {
+		stream  bytes.Buffer
+		enc     *gob.Encoder
+		types   []byte
+		scratch []byte
+		// locked is the capacity of the stream's buffer when it was
+		// last locked.
+		locked int
+	}
+
+	// entrySource is the envelope a payload was opened from, whose
+	// credentials, trash and operation log are only opened when they are
+	// needed, using the cipher of its records.
+	entrySource struct {
+		c           Cipher
+		cipher      CipherID
+		compression Compression
+		env         *envelope
+		index       *indexPayload
+		// decoders are the decoders of the index's Streams, created as
+		// they are first needed.
+		decoders []*entryDecoder
+		// trashOpened and logOpened are set once the trash and the
+		// operation log are opened into the payload, which then holds
+		// the whole of them.
+		trashOpened bool
+		logOpened   bool
+	}
+)
+
+// sealBytes compresses `plaintext` using `compression`, pads it with zeros as
+// `padding` requires, which gob and decompression ignore, and seals it using
+// `c` with a new random nonce, returning the nonce followed by the ciphertext.
+// The padded plaintext is written to `scratch`, a locked buffer which is
+// replaced if it is too small, so that it can be reused across calls.
+func sealBytes(c Cipher, plaintext []byte, padding Padding, compression Compression, scratch *[]byte) ([]byte, error) {
+	encoded, err := compression.compress(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if compression != CompressionNone {
+		defer wipe(encoded)
+	}
+	padded := encoded
+	if size := padding.bucket(len(padded)); size > len(padded) {
+		if cap(*scratch) < size {
+			wipe(*scratch)
+			*scratch = make([]byte, size)
+			lockBuffer(*scratch)
+		}
+		padded = (*scratch)[:size]
+		defer wipe(padded)
+		copy(padded, encoded)
+	}
+
+	// Every seal uses a new random nonce, so no nonce is ever reused with
+	// the same key.
+	nonce := make([]byte, c.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(err)
+	}
+	return c.Seal(nonce, nonce, padded, nil), nil
+}
+
+// sealValue gob encodes `value` as a gob stream of its own and seals it using
+// sealBytes.
+func sealValue(c Cipher, value interface{}, padding Padding, compression Compression) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	lockBuffer(buf.Bytes())
+	defer wipe(buf.Bytes())
+	var scratch []byte
+	return sealBytes(c, buf.Bytes(), padding, compression, &scratch)
+}
+
+// openBytes opens `sealed`, as returned by sealBytes, into `buf`, which must
+// be at least as long, and decompresses it using `compression`. A
+// decompressed value is not in `buf`, and should be wiped once it is decoded.
+func openBytes(c Cipher, sealed []byte, buf []byte, compression Compression) ([]byte, error) {
+	if len(sealed) < c.NonceSize()+c.Overhead() || len(buf) < len(sealed) {
+		return nil, ErrCouldNotDecrypt
+	}
+	decrypted, err := c.Open(buf[:0], sealed[:c.NonceSize()], sealed[c.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrCouldNotDecrypt
+	}
+	return compression.decompress(decrypted)
+}
+
+// openValue opens `sealed`, as returned by sealValue, into an enclave,
+// decompresses it using `compression`, and gob decodes it into `value`.
+func openValue(c Cipher, sealed []byte, value interface{}, compression Compression) error {
+	plaintext := newEnclave(len(sealed))
+	defer plaintext.destroy()
+	decrypted, err := openBytes(c, sealed, plaintext.data, compression)
+	if err != nil {
+		return err
+	}
+	if compression != CompressionNone {
+		defer wipe(decrypted)
+	}
+	if err = gob.NewDecoder(bytes.NewReader(decrypted)).Decode(value); err != nil {
+		return ErrCouldNotDecrypt
+	}
+	return nil
+}
+
+// entryID returns the ID of the entry of the credential at `location`: the
+// HMAC of the location keyed by `key`, truncated to entryIDSize.
+func entryID(key []byte, location string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(location))
+	return mac.Sum(nil)[:entryIDSize]
+}
+
+// entryHash returns the hash of the credential `cred` at `location`: the
+// SHA-256 of the entryPayload holding them encoded as JSON, which, unlike
+// gob, encodes maps in the order of their keys, so that equal credentials
+// always have the same hash.
+func entryHash(location string, cred *Credential) ([32]byte, error) {
+	data, err := json.Marshal(&entryPayload{Location: location, Credential: cred})
+	if err != nil {
+		return [32]byte{}, err
+	}
+	defer wipe(data)
+	return sha256.Sum256(data), nil
+}
+
+// newEntryEncoder returns an encoder of entries continuing a new gob stream,
+// whose start is the encoder's types.
+func newEntryEncoder() (*entryEncoder, error) {
+	e := &entryEncoder{}
+	e.enc = gob.NewEncoder(&e.stream)
+	if err := e.enc.Encode(&entryPayload{}); err != nil {
+		return nil, err
+	}
+	e.types = append([]byte(nil), e.stream.Bytes()...)
+	return e, nil
+}
+
+// seal encodes `entry`, continuing the encoder's stream, and seals it using
+// sealBytes.
+func (e *entryEncoder) seal(c Cipher, entry *entryPayload, padding Padding, compression Compression) ([]byte, error) {
+	e.stream.Reset()
+	err := e.enc.Encode(entry)
+	// The stream's buffer is only locked again when it grows, and the
+	// padded plaintext is always written to the same buffer, so that
+	// sealing each entry needs no system calls.
+	if buf := e.stream.Bytes(); cap(buf) != e.locked {
+		lockBuffer(buf[:cap(buf)])
+		e.locked = cap(buf)
+	}
+	var sealed []byte
+	if err == nil {
+		sealed, err = sealBytes(c, e.stream.Bytes(), padding, compression, &e.scratch)
+	}
+	wipe(e.stream.Bytes())
+	return sealed, err
+}
+
+// addStream returns the number of the stream starting with `types` among the
+// index's Streams, adding it if it is not one of them yet.
+func (index *indexPayload) addStream(types []byte) int {
+	for i, stream := range index.Streams {
+		if bytes.Equal(stream, types) {
+			return i
+		}
+	}
+	index.Streams = append(index.Streams, types)
+	return len(index.Streams) - 1
+}
+
+// sealEnvelope seals `p` as an envelope, its index using `c` and its records
+// using `records`, padded with `padding` and compressed using `compression`,
+// returning the encoded envelope. A payload without an EntryKey is given one.
+// If `p` is attached to the envelope it was opened from, whose records must
+// have been sealed as they would be now, the entries of the credentials that
+// did not change, the padding entries, and the trash and operation log if
+// they were not opened, are kept as they are, so that only the index and the
+// records that changed are sealed again.
+func sealEnvelope(c Cipher, records Cipher, p *payload, padding Padding, compression Compression) ([]byte, error) {
+	if p.EntryKey == nil {
+		p.EntryKey = make([]byte, keyLen)
+		if _, err := io.ReadFull(rand.Reader, p.EntryKey); err != nil {
+			panic(err)
+		}
+	}
+	source := p.source
+	var index indexPayload
+	for location := range p.Credentials {
+		index.Locations = append(index.Locations, location)
+	}
+	sort.Strings(index.Locations)
+
+	var env envelope
+	var enc *entryEncoder
+	var err error
+	for _, location := range index.Locations {
+		if source != nil {
+			keep, err := p.unchanged(location)
+			if err != nil {
+				return nil, err
+			}
+			if i, ok := source.position(location); keep && ok {
+				env.Entries = append(env.Entries, source.env.Entries[i])
+				env.IDs = append(env.IDs, source.env.IDs[i])
+				index.EntryStreams = append(index.EntryStreams, index.addStream(source.index.Streams[source.index.EntryStreams[i]]))
+				continue
+			}
+		}
+		cred := p.Credentials[location]
+		if cred == nil {
+			return nil, ErrCouldNotDecrypt
+		}
+		if enc == nil {
+			if enc, err = newEntryEncoder(); err != nil {
+				return nil, err
+			}
+		}
+		entry, err := enc.seal(records, &entryPayload{Location: location, Credential: cred}, padding, compression)
+		if err != nil {
+			return nil, err
+		}
+		env.Entries = append(env.Entries, entry)
+		env.IDs = append(env.IDs, entryID(p.EntryKey, location))
+		index.EntryStreams = append(index.EntryStreams, index.addStream(enc.types))
+	}
+
+	// The padding entries are kept too, and only as many are added or
+	// removed as the new number of credentials needs, unless there were no
+	// real entries for their sizes to be chosen from.
+	real := len(env.Entries)
+	if source != nil && len(source.index.Locations) > 0 {
+		n := len(source.index.Locations)
+		env.Entries = append(env.Entries, source.env.Entries[n:]...)
+		env.IDs = append(env.IDs, source.env.IDs[n:]...)
+	}
+	if total := padding.entries(real); len(env.Entries) > total {
+		env.Entries, env.IDs = env.Entries[:total], env.IDs[:total]
+	} else if len(env.Entries) < total {
+		// Without a real entry, padding entries are as long as an
+		// empty one.
+		empty := 0
+		if real == 0 {
+			if enc, err = newEntryEncoder(); err != nil {
+				return nil, err
+			}
+			entry, err := enc.seal(records, &entryPayload{}, padding, compression)
+			if err != nil {
+				return nil, err
+			}
+			empty = len(entry)
+		}
+		env.Entries = padding.padEntries(env.Entries, real, empty)
+		for len(env.IDs) < len(env.Entries) {
+			id := make([]byte, entryIDSize)
+			if _, err := io.ReadFull(rand.Reader, id); err != nil {
+				panic(err)
+			}
+			env.IDs = append(env.IDs, id)
+		}
+	}
+
+	if source != nil && !source.trashOpened {
+		env.Trash = source.env.Trash
+	} else if len(p.Trash) > 0 {
+		if env.Trash, err = sealValue(records, p.Trash, padding, compression); err != nil {
+			return nil, err
+		}
+	}
+	if env.Log, err = p.sealLog(records, source, padding, compression); err != nil {
+		return nil, err
+	}
+
+	index.Payload = *p
+	index.Payload.Credentials, index.Payload.Trash, index.Payload.Log = nil, nil, nil
+	if env.Index, err = sealValue(c, &index, padding, compression); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(&env); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// openIndex decodes the envelope `data` and opens its index using `c` and
+// `compression`. The returned payload has every credential's location, but no
+// credentials. The index of a version 15 envelope is given the single stream
+// its entries continue.
+func openIndex(c Cipher, data []byte, compression Compression) (*envelope, *indexPayload, error) {
+	env := &envelope{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(env); err != nil {
+		return nil, nil, ErrCouldNotDecrypt
+	}
+	index := &indexPayload{}
+	if err := openValue(c, env.Index, index, compression); err != nil {
+		return nil, nil, err
+	}
+	if len(index.Locations) > len(env.Entries) || (env.IDs != nil && len(env.IDs) != len(env.Entries)) {
+		return nil, nil, ErrCouldNotDecrypt
+	}
+	if index.EntryTypes != nil {
+		index.Streams = [][]byte{index.EntryTypes}
+		index.EntryStreams = make([]int, len(index.Locations))
+	}
+	if index.Streams != nil && len(index.EntryStreams) != len(index.Locations) {
+		return nil, nil, ErrCouldNotDecrypt
+	}
+	for _, stream := range index.EntryStreams {
+		if stream < 0 || stream >= len(index.Streams) {
+			return nil, nil, ErrCouldNotDecrypt
+		}
+	}
+	index.Payload.init()
+	for _, location := range index.Locations {
+		index.Payload.Credentials[location] = nil
+	}
+	return env, index, nil
+}
+
+// newEntryDecoder returns a decoder of the entries continuing the gob stream
+// started by `types`.
+func newEntryDecoder(types []byte) (*entryDecoder, error) {
+	d := &entryDecoder{}
+	d.dec = gob.NewDecoder(&d.r)
+	d.r.Reset(types)
+	if err := d.dec.Decode(&entryPayload{}); err != nil {
+		return nil, ErrCouldNotDecrypt
+	}
+	return d, nil
+}
+
+// decode decodes the opened entry `decrypted` into `entry`.
+func (d *entryDecoder) decode(decrypted []byte, entry *entryPayload) error {
+	d.r.Reset(decrypted)
+	defer d.r.Reset(nil)
+	if err := d.dec.Decode(entry); err != nil {
+		return ErrCouldNotDecrypt
+	}
+	return nil
+}
+
+// position returns the position of `location` among the index's locations,
+// and false if it is not one of them.
+func (s *entrySource) position(location string) (int, bool) {
+	i := sort.SearchStrings(s.index.Locations, location)
+	return i, i < len(s.index.Locations) && s.index.Locations[i] == location
+}
+
+// decoder returns the decoder of the index's `n`th stream.
+func (s *entrySource) decoder(n int) (*entryDecoder, error) {
+	if s.decoders == nil {
+		s.decoders = make([]*entryDecoder, len(s.index.Streams))
+	}
+	if s.decoders[n] == nil {
+		d, err := newEntryDecoder(s.index.Streams[n])
+		if err != nil {
+			return nil, err
+		}
+		s.decoders[n] = d
+	}
+	return s.decoders[n], nil
+}
+
+// openEntries opens the entries of the credentials at `locations`, which must
+// be among the index's locations, one at a time into a single enclave,
+// passing each credential to `fn`, and stops at the first error it returns.
+func (s *entrySource) openEntries(locations []string, fn func(location string, cred *Credential) error) error {
+	if len(locations) == 0 {
+		return nil
+	}
+	positions := make([]int, len(locations))
+	size := 0
+	for j, location := range locations {
+		i, ok := s.position(location)
+		if !ok {
+			return ErrNoSuchCredential
+		}
+		positions[j] = i
+		if len(s.env.Entries[i]) > size {
+			size = len(s.env.Entries[i])
+		}
+	}
+	plaintext := newEnclave(size)
+	defer plaintext.destroy()
+
+	for j, location := range locations {
+		var entry entryPayload
+		if err := s.openEntry(positions[j], plaintext.data, &entry); err != nil {
+			return err
+		}
+		if entry.Location != location || entry.Credential == nil {
+			return ErrCouldNotDecrypt
+		}
+		if err := fn(location, entry.Credential); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openEntry opens the `i`th entry into `buf` and decodes it into `entry`.
+// Before version 15, each entry is a gob stream of its own.
+func (s *entrySource) openEntry(i int, buf []byte, entry *entryPayload) error {
+	decrypted, err := openBytes(s.c, s.env.Entries[i], buf, s.compression)
+	if err != nil {
+		return err
+	}
+	if s.compression != CompressionNone {
+		defer wipe(decrypted)
+	}
+	if s.index.Streams == nil {
+		if gob.NewDecoder(bytes.NewReader(decrypted)).Decode(entry) != nil {
+			return ErrCouldNotDecrypt
+		}
+		return nil
+	}
+	d, err := s.decoder(s.index.EntryStreams[i])
+	if err != nil {
+		return err
+	}
+	return d.decode(decrypted, entry)
+}
@@ -0,0 +1,141 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"testing"
+)
+
+// decodeEnvelope returns the envelope of the vault's data.
+func decodeEnvelope(t *testing.T, v *Vault) envelope {
+	var env envelope
+	if err := gob.NewDecoder(bytes.NewReader(v.data)).Decode(&env); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// encodeEnvelope replaces the vault's data with `env`.
+func encodeEnvelope(t *testing.T, v *Vault, env envelope) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&env); err != nil {
+		t.Fatal(err)
+	}
+	v.data = buf.Bytes()
+}
+
+func TestEnvelope(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("alpha", Credential{Username: "alice", Password: "alphapass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("beta", Credential{Username: "bob", Password: "betapass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.AddLink("gamma", "beta", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var env envelope
+	if err = gob.NewDecoder(bytes.NewReader(v.data)).Decode(&env); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.Entries) != minPaddedEntries {
+		t.Fatal("expected an entry for each credential padded to", minPaddedEntries, "got", len(env.Entries))
+	}
+	if cred, err := v.Get("beta"); err != nil || cred.Password != "betapass" {
+		t.Fatal("could not get a credential from the envelope:", err)
+	}
+	if cred, err := v.Get("gamma"); err != nil || cred.Password != "betapass" {
+		t.Fatal("could not follow a link in the envelope:", err)
+	}
+	if _, err = v.Get("delta"); err != ErrNoSuchCredential {
+		t.Fatal("expected ErrNoSuchCredential, got", err)
+	}
+
+	// Entries are bound to their locations, so swapping them is detected.
+	env.Entries[0], env.Entries[1] = env.Entries[1], env.Entries[0]
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(&env); err != nil {
+		t.Fatal(err)
+	}
+	v.data = buf.Bytes()
+	if _, err = v.Get("alpha"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected swapped entries to fail to decrypt, got", err)
+	}
+}
+
+func TestEnvelopeKeepsRecords(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, location := range []string{"alpha", "beta", "gamma"} {
+		if err = v.Add(location, Credential{Username: "testuser", Password: location + "pass"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = v.Trash("gamma"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	saved := decodeEnvelope(t, v)
+
+	// Opening the vault starts a session with a new data key, which only
+	// seals the index again.
+	v, err = Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := decodeEnvelope(t, v)
+	if bytes.Equal(opened.Index, saved.Index) || !bytes.Equal(opened.Trash, saved.Trash) || len(opened.Log) != len(saved.Log) || len(opened.Entries) != len(saved.Entries) {
+		t.Fatal("expected only the index to be sealed again")
+	}
+	for i := range saved.Entries {
+		if !bytes.Equal(opened.Entries[i], saved.Entries[i]) || !bytes.Equal(opened.IDs[i], saved.IDs[i]) {
+			t.Fatal("expected entry", i, "to be kept")
+		}
+	}
+
+	// Editing a credential only seals its entry and the operation log
+	// again.
+	if err = v.Edit("beta", Credential{Username: "testuser", Password: "newpass"}); err != nil {
+		t.Fatal(err)
+	}
+	edited := decodeEnvelope(t, v)
+	if !bytes.Equal(edited.Entries[0], saved.Entries[0]) || bytes.Equal(edited.Entries[1], saved.Entries[1]) || !bytes.Equal(edited.Trash, saved.Trash) {
+		t.Fatal("expected only the edited credential's entry to be sealed again")
+	}
+	for i := 2; i < len(saved.Entries); i++ {
+		if !bytes.Equal(edited.Entries[i], saved.Entries[i]) {
+			t.Fatal("expected padding entry", i, "to be kept")
+		}
+	}
+	if cred, err := v.Get("beta"); err != nil || cred.Password != "newpass" {
+		t.Fatal("could not get the edited credential:", err)
+	}
+	if trashed, err := v.TrashedLocations(); err != nil || len(trashed) != 1 {
+		t.Fatal("expected the trash to be kept, got", trashed, err)
+	}
+	if _, err = v.VerifyLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotating the data key seals every record again.
+	if err = v.RotateDataKey(); err != nil {
+		t.Fatal(err)
+	}
+	rotated := decodeEnvelope(t, v)
+	if bytes.Equal(rotated.Entries[0], edited.Entries[0]) || bytes.Equal(rotated.Trash, edited.Trash) || bytes.Equal(rotated.Log[0], edited.Log[0]) {
+		t.Fatal("expected every record to be sealed again")
+	}
+}
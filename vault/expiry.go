@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"sort"
+	"time"
+)
+
+// SetExpiry sets the time at which the credential at `location` expires. A
+// zero `expiresAt` clears the expiry.
+func (v *Vault) SetExpiry(location string, expiresAt time.Time) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	location = p.resolve(location)
+	cred, err := p.credential(location)
+	if err != nil {
+		return err
+	}
+	cred.ExpiresAt = expiresAt
+	cred.UpdatedAt = time.Now()
+
+	return v.encrypt(p)
+}
+
+// ListExpired returns the metadata of every credential that has expired,
+// soonest expiry first.
+func (v *Vault) ListExpired() ([]LocationMetadata, error) {
+	return v.ListExpiringWithin(0)
+}
+
+// ListExpiringWithin returns the metadata of every credential that has
+// expired or will expire within `d`, soonest expiry first.
+func (v *Vault) ListExpiringWithin(d time.Duration) ([]LocationMetadata, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(d)
+	var expiring []LocationMetadata
+	for location, cred := range p.Credentials {
+		if !cred.ExpiresAt.IsZero() && !cred.ExpiresAt.After(deadline) {
+			expiring = append(expiring, cred.metadata(location))
+		}
+	}
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i].ExpiresAt.Before(expiring[j].ExpiresAt)
+	})
+	return expiring, nil
+}
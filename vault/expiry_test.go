@@ -0,0 +1,68 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListExpired(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.SetExpiry("testlocation", time.Now()); err != ErrNoSuchCredential {
+		t.Fatal("expected SetExpiry on non-existant location to return ErrNoSuchCredential")
+	}
+
+	now := time.Now()
+	err = v.Add("expired", Credential{Username: "testuser", Password: "testpass", ExpiresAt: now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("expiredlonger", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.SetExpiry("expiredlonger", now.Add(-48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("expiringsoon", Credential{Username: "testuser", Password: "testpass", ExpiresAt: now.Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("noexpiry", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := v.ListExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 2 || expired[0].Location != "expiredlonger" || expired[1].Location != "expired" {
+		t.Fatalf("ListExpired returned the wrong credentials: %v", expired)
+	}
+
+	expiring, err := v.ListExpiringWithin(7 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expiring) != 3 || expiring[2].Location != "expiringsoon" {
+		t.Fatalf("ListExpiringWithin returned the wrong credentials: %v", expiring)
+	}
+
+	err = v.SetExpiry("expired", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err = v.ListExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 {
+		t.Fatal("SetExpiry with a zero time did not clear the expiry")
+	}
+}
@@ -0,0 +1,164 @@
+package vault
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// ExportOptions configures a plaintext export of the vault.
+	ExportOptions struct {
+		// Prefix limits the export to locations beginning with Prefix.
+		Prefix string
+		// IncludeHistory includes the previous versions of each
+		// credential in the export.
+		IncludeHistory bool
+		// Indent pretty-prints the exported JSON.
+		Indent bool
+	}
+
+	// jsonCredential is the JSON representation of a credential used by
+	// ExportJSON.
+	jsonCredential struct {
+		Location           string              `json:"location,omitempty"`
+		Kind               Kind                `json:"kind,omitempty"`
+		Username           string              `json:"username"`
+		Password           string              `json:"password"`
+		Notes              string              `json:"notes,omitempty"`
+		URL                string              `json:"url,omitempty"`
+		Tags               []string            `json:"tags,omitempty"`
+		Fields             map[string]string   `json:"fields,omitempty"`
+		Favorite           bool                `json:"favorite,omitempty"`
+		TOTPSecret         string              `json:"totp_secret,omitempty"`
+		HOTPSecret         string              `json:"hotp_secret,omitempty"`
+		HOTPCounter        uint64              `json:"hotp_counter,omitempty"`
+		SSHKey             *SSHKey             `json:"ssh_key,omitempty"`
+		Card               *Card               `json:"card,omitempty"`
+		Identity           *Identity           `json:"identity,omitempty"`
+		Link               string              `json:"link,omitempty"`
+		CreatedAt          *time.Time          `json:"created_at,omitempty"`
+		UpdatedAt          *time.Time          `json:"updated_at,omitempty"`
+		ExpiresAt          *time.Time          `json:"expires_at,omitempty"`
+		History            []jsonVersion       `json:"history,omitempty"`
+		GeneratedPasswords []GeneratedPassword `json:"generated_passwords,omitempty"`
+	}
+
+	// jsonVersion is the JSON representation of a CredentialVersion.
+	jsonVersion struct {
+		Credential jsonCredential `json:"credential"`
+		ReplacedAt time.Time      `json:"replaced_at"`
+	}
+)
+
+// optionalTime returns a pointer to `t`, or nil if `t` is the zero time.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// toJSON converts `c` stored at `location` to its JSON representation.
+func (c *Credential) toJSON(location string, includeHistory bool) jsonCredential {
+	jc := jsonCredential{
+		Location:    location,
+		Kind:        c.Kind,
+		Username:    c.Username,
+		Password:    c.Password,
+		Notes:       c.Notes,
+		URL:         c.URL,
+		Tags:        c.Tags,
+		Fields:      c.Fields,
+		Favorite:    c.Favorite,
+		TOTPSecret:  c.TOTPSecret,
+		HOTPSecret:  c.HOTPSecret,
+		HOTPCounter: c.HOTPCounter,
+		SSHKey:      c.SSHKey,
+		Card:        c.Card,
+		Identity:    c.Identity,
+		Link:        c.Link,
+		CreatedAt:   optionalTime(c.CreatedAt),
+		UpdatedAt:   optionalTime(c.UpdatedAt),
+		ExpiresAt:   optionalTime(c.ExpiresAt),
+	}
+	if includeHistory {
+		for _, version := range c.History {
+			jc.History = append(jc.History, jsonVersion{
+				Credential: version.Credential.toJSON("", false),
+				ReplacedAt: version.ReplacedAt,
+			})
+		}
+		jc.GeneratedPasswords = c.GeneratedPasswords
+	}
+	return jc
+}
+
+// derefTime returns the time pointed to by `t`, or the zero time if `t` is
+// nil.
+func derefTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// credential converts `jc` back into a Credential.
+func (jc jsonCredential) credential() Credential {
+	c := Credential{
+		Kind:        jc.Kind,
+		Username:    jc.Username,
+		Password:    jc.Password,
+		Notes:       jc.Notes,
+		URL:         jc.URL,
+		Tags:        jc.Tags,
+		Fields:      jc.Fields,
+		Favorite:    jc.Favorite,
+		TOTPSecret:  jc.TOTPSecret,
+		HOTPSecret:  jc.HOTPSecret,
+		HOTPCounter: jc.HOTPCounter,
+		SSHKey:      jc.SSHKey,
+		Card:        jc.Card,
+		Identity:    jc.Identity,
+		Link:        jc.Link,
+		CreatedAt:   derefTime(jc.CreatedAt),
+		UpdatedAt:   derefTime(jc.UpdatedAt),
+		ExpiresAt:   derefTime(jc.ExpiresAt),
+	}
+	for _, version := range jc.History {
+		c.History = append(c.History, CredentialVersion{
+			Credential: version.Credential.credential(),
+			ReplacedAt: version.ReplacedAt,
+		})
+	}
+	c.GeneratedPasswords = jc.GeneratedPasswords
+	return c
+}
+
+// ExportJSON writes every credential in the vault to `w` as an unencrypted
+// JSON array, sorted by location. The output contains every password in the
+// vault in plaintext and should be handled accordingly.
+func (v *Vault) ExportJSON(w io.Writer, opts ExportOptions) error {
+	p, err := v.decrypt()
+	if err != nil {
+		return err
+	}
+
+	entries := []jsonCredential{}
+	for location, cred := range p.Credentials {
+		if strings.HasPrefix(location, opts.Prefix) {
+			entries = append(entries, cred.toJSON(location, opts.IncludeHistory))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Location < entries[j].Location
+	})
+
+	enc := json.NewEncoder(w)
+	if opts.Indent {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(entries)
+}
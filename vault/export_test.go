@@ -0,0 +1,69 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestExportJSON(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	err = v.ExportJSON(buf, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Fatalf("expected empty vault to export an empty array, got %q", buf.String())
+	}
+
+	err = v.Add("work/github", Credential{Username: "testuser", Password: "testpass", Tags: []string{"work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Edit("work/github", Credential{Username: "testuser", Password: "testpass2", Tags: []string{"work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("bank", Credential{Username: "testuser", Password: "testpass", Notes: "testnotes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	err = v.ExportJSON(buf, ExportOptions{IncludeHistory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []jsonCredential
+	if err = json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Location != "bank" || entries[1].Location != "work/github" {
+		t.Fatalf("unexpected exported entries %v", entries)
+	}
+	if entries[0].Notes != "testnotes" || entries[1].Password != "testpass2" || entries[1].Tags[0] != "work" {
+		t.Fatal("ExportJSON did not export credential data")
+	}
+	if entries[1].CreatedAt == nil || len(entries[1].History) != 1 || entries[1].History[0].Credential.Password != "testpass" {
+		t.Fatal("ExportJSON did not export metadata and history")
+	}
+
+	buf.Reset()
+	err = v.ExportJSON(buf, ExportOptions{Prefix: "work/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = nil
+	if err = json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Location != "work/github" || entries[0].History != nil {
+		t.Fatalf("ExportJSON did not respect export options, got %v", entries)
+	}
+}
@@ -0,0 +1,42 @@
+package vault
+
+import (
+	"sort"
+	"time"
+)
+
+// SetFavorite marks the credential at `location` as a favorite, or unmarks it
+// if `favorite` is false.
+func (v *Vault) SetFavorite(location string, favorite bool) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	location = p.resolve(location)
+	cred, err := p.credential(location)
+	if err != nil {
+		return err
+	}
+	cred.Favorite = favorite
+	cred.UpdatedAt = time.Now()
+
+	return v.encrypt(p)
+}
+
+// Favorites returns the locations of every favorite credential, sorted.
+func (v *Vault) Favorites() ([]string, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	var favorites []string
+	for location, cred := range p.Credentials {
+		if cred.Favorite {
+			favorites = append(favorites, location)
+		}
+	}
+	sort.Strings(favorites)
+	return favorites, nil
+}
@@ -0,0 +1,46 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFavorites(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, location := range []string{"github", "email", "bank"} {
+		if err = v.Add(location, Credential{Username: "testuser", Password: "testpass"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = v.SetFavorite("nonexistent", true); err != ErrNoSuchCredential {
+		t.Fatal("expected ErrNoSuchCredential favoriting a nonexistent location, got", err)
+	}
+	for _, location := range []string{"github", "email"} {
+		if err = v.SetFavorite(location, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	favorites, err := v.Favorites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(favorites, []string{"email", "github"}) {
+		t.Fatalf("unexpected favorites %v", favorites)
+	}
+
+	if err = v.SetFavorite("github", false); err != nil {
+		t.Fatal(err)
+	}
+	favorites, err = v.Favorites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(favorites, []string{"email"}) {
+		t.Fatalf("unexpected favorites after unfavoriting %v", favorites)
+	}
+}
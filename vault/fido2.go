@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// fido2RelyingParty is the relying party ID of the FIDO2 credentials
+	// created for key slots.
+	fido2RelyingParty = "masterkey"
+
+	// maxFIDO2CredentialSize bounds the size of a FIDO2 credential ID.
+	maxFIDO2CredentialSize = 1024
+)
+
+// ErrInvalidFIDO2Credential is returned if a FIDO2 authenticator returns a
+// malformed credential or hmac-secret.
+var ErrInvalidFIDO2Credential = errors.New("invalid FIDO2 credential")
+
+// FIDO2Authenticator is a FIDO2 security key supporting the hmac-secret
+// extension, which derives a secret from a credential on the key and a salt
+// without revealing the credential's key.
+type FIDO2Authenticator interface {
+	// MakeCredential creates a credential with the hmac-secret extension,
+	// returning its ID.
+	MakeCredential() ([]byte, error)
+	// HMACSecret returns the hmac-secret of the credential `id` for
+	// `salt`.
+	HMACSecret(id []byte, salt [32]byte) ([32]byte, error)
+}
+
+// FIDO2Device is a FIDO2 authenticator used through the fido2-cred and
+// fido2-assert tools of libfido2. Creating a credential or getting its
+// hmac-secret needs the device to be touched and its PIN to be entered,
+// which the tools prompt for.
+type FIDO2Device struct {
+	// Path is the path of the device, as listed by `fido2-token -L`,
+	// such as /dev/hidraw0.
+	Path string
+}
+
+// runFIDO2 runs the libfido2 tool `name` with `args`, writing each of `input`
+// to its standard input on a line of its own, and returns the lines of its
+// output.
+func runFIDO2(name string, input []string, args ...string) ([]string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(strings.Join(input, "\n") + "\n")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v: %v", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
+// randomBase64 returns `n` random bytes encoded as base64.
+func randomBase64(n int) string {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// MakeCredential creates a credential with the hmac-secret extension on the
+// device using fido2-cred, returning its ID.
+func (d FIDO2Device) MakeCredential() ([]byte, error) {
+	// The input is the client data hash, relying party, user name and user
+	// ID. The output is the client data hash, relying party, format,
+	// authenticator data, credential ID, signature and certificate.
+	input := []string{randomBase64(32), fido2RelyingParty, "masterkey", randomBase64(32)}
+	lines, err := runFIDO2("fido2-cred", input, "-M", "-h", "-v", d.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) < 5 {
+		return nil, ErrInvalidFIDO2Credential
+	}
+	id, err := base64.StdEncoding.DecodeString(lines[4])
+	if err != nil || len(id) == 0 {
+		return nil, ErrInvalidFIDO2Credential
+	}
+	return id, nil
+}
+
+// HMACSecret returns the hmac-secret of the credential `id` for `salt` using
+// fido2-assert.
+func (d FIDO2Device) HMACSecret(id []byte, salt [32]byte) ([32]byte, error) {
+	var secret [32]byte
+	// The input is the client data hash, relying party, credential ID and
+	// hmac-secret salt. The last line of output is the hmac-secret.
+	input := []string{
+		randomBase64(32),
+		fido2RelyingParty,
+		base64.StdEncoding.EncodeToString(id),
+		base64.StdEncoding.EncodeToString(salt[:]),
+	}
+	lines, err := runFIDO2("fido2-assert", input, "-G", "-h", "-v", d.Path)
+	if err != nil {
+		return secret, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+	if err != nil || len(decoded) != len(secret) {
+		return secret, ErrInvalidFIDO2Credential
+	}
+	copy(secret[:], decoded)
+	return secret, nil
+}
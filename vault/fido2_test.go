@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+var errNoCredential = errors.New("no such credential")
+
+// testAuthenticator is a FIDO2 authenticator holding its credentials' keys
+// in memory.
+type testAuthenticator struct {
+	name        string
+	credentials map[string][]byte
+}
+
+func (a *testAuthenticator) MakeCredential() ([]byte, error) {
+	id := fmt.Sprintf("%v-%v", a.name, len(a.credentials))
+	a.credentials[id] = []byte("key of " + id)
+	return []byte(id), nil
+}
+
+func (a *testAuthenticator) HMACSecret(id []byte, salt [32]byte) ([32]byte, error) {
+	var secret [32]byte
+	key, ok := a.credentials[string(id)]
+	if !ok {
+		return secret, errNoCredential
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(salt[:])
+	copy(secret[:], mac.Sum(nil))
+	return secret, nil
+}
+
+func TestFIDO2(t *testing.T) {
+	first := &testAuthenticator{name: "first", credentials: make(map[string][]byte)}
+	second := &testAuthenticator{name: "second", credentials: make(map[string][]byte)}
+	unenrolled := &testAuthenticator{name: "unenrolled", credentials: make(map[string][]byte)}
+
+	v, err := NewWithOptions("", Options{KDF: KDFParams{N: 1024}, FIDO2: first})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.AddKeySlotWithOptions("", KeySlotOptions{FIDO2: second}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.AddKeySlot("recoverypass", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if _, err = Open("pass.db", ""); err != ErrCouldNotDecrypt {
+		t.Fatal("vault was opened without a FIDO2 authenticator")
+	}
+	if _, err = OpenWithOptions("pass.db", "", OpenOptions{FIDO2: unenrolled}); err != errNoCredential {
+		t.Fatal("expected the authenticator's error, got", err)
+	}
+	for i, authenticator := range []*testAuthenticator{first, second} {
+		vopen, err := OpenWithOptions("pass.db", "", OpenOptions{FIDO2: authenticator})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slots := vopen.ListKeySlots(); !slots[i].Current || !slots[i].FIDO2 || slots[2].FIDO2 {
+			t.Fatal("unexpected key slots", slots)
+		}
+	}
+
+	vopen, err := OpenWithOptions("pass.db", "", OpenOptions{FIDO2: second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.ChangePassphrase("", "newpass"); err != nil {
+		t.Fatal(err)
+	}
+	if len(second.credentials) != 1 {
+		t.Fatal("changing the passphrase created another credential")
+	}
+	if err = vopen.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenWithOptions("pass.db", "newpass", OpenOptions{FIDO2: second}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "recoverypass"); err != nil {
+		t.Fatal(err)
+	}
+}
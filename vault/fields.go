@@ -0,0 +1,48 @@
+package vault
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoSuchField is returned from GetField if the credential does not have a
+// custom field with the requested key.
+var ErrNoSuchField = errors.New("credential does not have the specified field")
+
+// SetField sets the custom field `key` to `value` on the credential at
+// `location`, replacing any existing value.
+func (v *Vault) SetField(location string, key string, value string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	location = p.resolve(location)
+	cred, err := p.credential(location)
+	if err != nil {
+		return err
+	}
+
+	if cred.Fields == nil {
+		cred.Fields = make(map[string]string)
+	}
+	cred.Fields[key] = value
+	cred.UpdatedAt = time.Now()
+
+	return v.encrypt(p)
+}
+
+// GetField retrieves the value of the custom field `key` from the credential
+// at `location`.
+func (v *Vault) GetField(location string, key string) (string, error) {
+	cred, err := v.Get(location)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := cred.Fields[key]
+	if !ok {
+		return "", ErrNoSuchField
+	}
+	return value, nil
+}
@@ -0,0 +1,43 @@
+package vault
+
+import (
+	"testing"
+)
+
+func TestSetGetField(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.SetField("testlocation", "pin", "1234"); err != ErrNoSuchCredential {
+		t.Fatal("expected SetField on non-existant location to return ErrNoSuchCredential")
+	}
+
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = v.GetField("testlocation", "pin"); err != ErrNoSuchField {
+		t.Fatal("expected GetField on a missing field to return ErrNoSuchField")
+	}
+
+	err = v.SetField("testlocation", "pin", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.SetField("testlocation", "pin", "5678")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := v.GetField("testlocation", "pin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "5678" {
+		t.Fatalf("expected field value 5678, got %v", value)
+	}
+}
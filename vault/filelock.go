@@ -0,0 +1,171 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxLockAttempts bounds the attempts to lock a lock file that keeps being
+// removed by the processes releasing it.
+const maxLockAttempts = 8
+
+var (
+	// ErrVaultLocked is returned, as a *VaultLockedError, if the vault file
+	// is locked by another process.
+	ErrVaultLocked = errors.New("vault is in use by another process")
+
+	// errLockHeld is returned from lockFile if another process holds the
+	// lock.
+	errLockHeld = errors.New("lock is held by another process")
+)
+
+// VaultLockedError is returned from OpenWithOptions with Lock set, and from
+// LockFile, if another process holds the vault file's lock. It is
+// ErrVaultLocked according to errors.Is.
+type VaultLockedError struct {
+	// PID is the process ID of the process holding the lock, or zero if it
+	// is not known.
+	PID int
+}
+
+func (e *VaultLockedError) Error() string {
+	if e.PID == 0 {
+		return ErrVaultLocked.Error()
+	}
+	return fmt.Sprintf("vault is in use by process %d", e.PID)
+}
+
+// Is returns true if `target` is ErrVaultLocked.
+func (e *VaultLockedError) Is(target error) bool {
+	return target == ErrVaultLocked
+}
+
+// fileLock is an advisory lock on a vault file, held on a lock file next to
+// it which records the process ID of its holder. The vault file itself cannot
+// be locked, as Save replaces it.
+type fileLock struct {
+	path string
+	file *os.File
+}
+
+// lockPath returns the path of the lock file of the vault at `filename`.
+func lockPath(filename string) string {
+	return filename + ".lock"
+}
+
+// acquireLock locks the vault file at `filename`, returning a
+// *VaultLockedError if another process holds its lock. The lock file is
+// removed when the lock is released, so a lock acquired on a lock file that
+// was removed in the meantime is retried on the new one.
+func acquireLock(filename string) (*fileLock, error) {
+	path := lockPath(filename)
+	for i := 0; i < maxLockAttempts; i++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		if err = lockFile(f); err == errLockHeld {
+			contents, _ := ioutil.ReadAll(f)
+			f.Close()
+			pid, _ := strconv.Atoi(strings.TrimSpace(string(contents)))
+			return nil, &VaultLockedError{PID: pid}
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		opened, err := f.Stat()
+		if err != nil {
+			unlockFile(f)
+			f.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(path); err != nil || !os.SameFile(opened, current) {
+			unlockFile(f)
+			f.Close()
+			continue
+		}
+		if err = f.Truncate(0); err == nil {
+			_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+		}
+		if err != nil {
+			unlockFile(f)
+			f.Close()
+			return nil, err
+		}
+		return &fileLock{path: path, file: f}, nil
+	}
+	return nil, &VaultLockedError{}
+}
+
+// release removes the lock file and releases the lock. The file is removed
+// while still locked, so that no other process can lock it in between. Where
+// an open file cannot be removed, such as on Windows, it is left for the next
+// process to lock.
+func (l *fileLock) release() error {
+	os.Remove(l.path)
+	err := unlockFile(l.file)
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// storageLock is the lock of a Storage kept in a file, taken on a lock file
+// next to it as acquireLock does. Its zero value is unlocked.
+type storageLock struct {
+	lock *fileLock
+}
+
+// acquire locks the file at `filename`, which does nothing if l already holds
+// its lock.
+func (l *storageLock) acquire(filename string) error {
+	if l.lock != nil {
+		return nil
+	}
+	lock, err := acquireLock(filename)
+	if err != nil {
+		return err
+	}
+	l.lock = lock
+	return nil
+}
+
+// release releases the lock, if l holds it, removing the lock file.
+func (l *storageLock) release() error {
+	if l.lock == nil {
+		return nil
+	}
+	lock := l.lock
+	l.lock = nil
+	return lock.release()
+}
+
+// LockFile takes an advisory lock on the vault file at `filename`, held until
+// the vault is Closed, so that other masterkey processes cannot open it for
+// writing with OpenWithOptions with Lock set, or lock it, and then overwrite
+// the vault's changes with their own. It is used to lock the file of a vault
+// not opened with Lock set, such as a new one. A *VaultLockedError is
+// returned if another process holds the lock. Locks are advisory: programs
+// that do not lock the vault can still write it.
+func (v *Vault) LockFile(filename string) error {
+	if v.closed {
+		return ErrLocked
+	}
+	if s, ok := v.locked.(*FileStorage); ok && s.Path == filename {
+		return nil
+	}
+	s := &FileStorage{Path: filename}
+	if err := s.Lock(); err != nil {
+		return err
+	}
+	if v.locked != nil {
+		v.locked.Unlock()
+	}
+	v.locked = s
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package vault
+
+import (
+	"os"
+)
+
+// lockFile does nothing on platforms without file locking, where vault files
+// are not locked.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile does nothing on platforms without file locking.
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestFileLock(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	if err = v.LockFile("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenWithOptions("pass.db", "testpass", OpenOptions{Lock: true})
+	locked, ok := err.(*VaultLockedError)
+	if !ok || locked.PID != os.Getpid() || !errors.Is(err, ErrVaultLocked) {
+		t.Fatal("expected a VaultLockedError naming this process, got", err)
+	}
+	if _, err = OpenWithOptions("pass.db", "testpass", OpenOptions{Lock: true, ReadOnly: true}); err != nil {
+		t.Fatal("expected a read-only vault to be opened while locked, got", err)
+	}
+	if err = v.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(lockPath("pass.db")); !os.IsNotExist(err) {
+		t.Fatal("expected the lock file to be removed on Close, got", err)
+	}
+
+	if _, err = OpenWithOptions("pass.db", "wrongpass", OpenOptions{Lock: true}); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt, got", err)
+	}
+	vopen, err := OpenWithOptions("pass.db", "testpass", OpenOptions{Lock: true})
+	if err != nil {
+		t.Fatal("expected a failed open to release the lock, got", err)
+	}
+	defer vopen.Close()
+	if err = vopen.LockFile("pass.db"); err != nil {
+		t.Fatal("expected locking the vault's own file again to succeed, got", err)
+	}
+	if _, err = OpenWithOptions("pass.db", "testpass", OpenOptions{Lock: true}); !errors.Is(err, ErrVaultLocked) {
+		t.Fatal("expected ErrVaultLocked, got", err)
+	}
+}
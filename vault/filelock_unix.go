@@ -0,0 +1,24 @@
+//go:build unix
+
+package vault
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an exclusive flock on `f` without blocking, returning
+// errLockHeld if another process holds it.
+func lockFile(f *os.File) error {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		return errLockHeld
+	}
+	return err
+}
+
+// unlockFile releases the flock on `f`.
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,29 @@
+package vault
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockOffset is the offset of the byte of a lock file that is locked, beyond
+// the process ID it records, which would otherwise be unreadable by the
+// processes it is locked against.
+const lockOffset = 1 << 32
+
+// lockFile takes an exclusive lock on `f` using LockFileEx without blocking,
+// returning errLockHeld if another process holds it.
+func lockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockOffset >> 32}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return errLockHeld
+	}
+	return err
+}
+
+// unlockFile releases the lock on `f`.
+func unlockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockOffset >> 32}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
@@ -0,0 +1,207 @@
+package vault
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// FolderSeparator separates the folders in a location, e.g. "work/aws/prod".
+const FolderSeparator = "/"
+
+var (
+	// ErrNoSuchFolder is returned from MoveFolder and DeleteFolder if no
+	// credentials exist underneath the provided folder.
+	ErrNoSuchFolder = errors.New("no credentials exist in the specified folder")
+
+	// ErrInvalidFolder is returned from MoveFolder if the destination folder
+	// is the root of the vault.
+	ErrInvalidFolder = errors.New("cannot move a folder to the root of the vault")
+)
+
+// Node is a single node in the folder tree returned by List. A node may be a
+// folder containing other nodes, a credential, or both (a credential stored at
+// "work" alongside credentials at "work/aws").
+type Node struct {
+	// Name is the last segment of the node's path.
+	Name string
+	// Path is the full location of the node.
+	Path string
+	// Credential is true if a credential is stored at Path.
+	Credential bool
+	// Children are the nodes underneath this node, sorted by Name.
+	Children []*Node
+}
+
+// cleanFolder strips leading and trailing separators from `folder`.
+func cleanFolder(folder string) string {
+	return strings.Trim(folder, FolderSeparator)
+}
+
+// inFolder returns true if `location` is `folder` or lies underneath it. Every
+// location is in the root folder, "".
+func inFolder(location string, folder string) bool {
+	if folder == "" {
+		return true
+	}
+	return location == folder || strings.HasPrefix(location, folder+FolderSeparator)
+}
+
+// child returns the child of `n` named `name`, creating it if necessary.
+func (n *Node) child(name string) *Node {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	path := name
+	if n.Path != "" {
+		path = n.Path + FolderSeparator + name
+	}
+	c := &Node{Name: name, Path: path}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// sort recursively sorts the children of `n` by name.
+func (n *Node) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// List returns the tree of locations underneath the folder `prefix`. An empty
+// prefix lists the entire vault.
+func (v *Vault) List(prefix string) (*Node, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = cleanFolder(prefix)
+	root := &Node{Path: prefix}
+	if i := strings.LastIndex(prefix, FolderSeparator); i >= 0 {
+		root.Name = prefix[i+1:]
+	} else {
+		root.Name = prefix
+	}
+
+	for location := range p.Credentials {
+		if !inFolder(location, prefix) {
+			continue
+		}
+		if location == prefix {
+			root.Credential = true
+			continue
+		}
+
+		rel := location
+		if prefix != "" {
+			rel = location[len(prefix)+len(FolderSeparator):]
+		}
+		n := root
+		for _, name := range strings.Split(rel, FolderSeparator) {
+			n = n.child(name)
+		}
+		n.Credential = true
+	}
+
+	root.sort()
+	return root, nil
+}
+
+// MoveFolder moves every credential underneath the folder `oldPrefix` to the
+// folder `newPrefix`, preserving their relative paths. If any destination
+// location already exists, MoveFolder returns ErrCredentialExists and the
+// vault is left unmodified.
+func (v *Vault) MoveFolder(oldPrefix string, newPrefix string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	oldPrefix = cleanFolder(oldPrefix)
+	newPrefix = cleanFolder(newPrefix)
+	if newPrefix == "" {
+		return ErrInvalidFolder
+	}
+
+	moves := make(map[string]string)
+	if oldPrefix != "" {
+		for location := range p.Credentials {
+			if inFolder(location, oldPrefix) {
+				moves[location] = newPrefix + location[len(oldPrefix):]
+			}
+		}
+	}
+	if len(moves) == 0 {
+		return ErrNoSuchFolder
+	}
+
+	for _, newLocation := range moves {
+		if _, isAlias := p.Aliases[newLocation]; isAlias {
+			return ErrCredentialExists
+		}
+		if _, exists := p.Credentials[newLocation]; exists {
+			if _, moving := moves[newLocation]; !moving {
+				return ErrCredentialExists
+			}
+		}
+	}
+
+	// Links to the moved credentials are retargeted, so every credential
+	// is opened.
+	if err = p.openAll(); err != nil {
+		return err
+	}
+	moved := make(map[string]*Credential)
+	for oldLocation, newLocation := range moves {
+		moved[newLocation] = p.Credentials[oldLocation]
+		delete(p.Credentials, oldLocation)
+	}
+	for location, cred := range moved {
+		p.Credentials[location] = cred
+	}
+	for alias, target := range p.Aliases {
+		if newLocation, ok := moves[target]; ok {
+			p.Aliases[alias] = newLocation
+		}
+	}
+	for _, cred := range p.Credentials {
+		if newLocation, ok := moves[cred.Link]; ok && cred.kind() == KindLink {
+			cred.Link = newLocation
+		}
+	}
+
+	return v.encrypt(p)
+}
+
+// DeleteFolder removes every credential underneath the folder `prefix`.
+func (v *Vault) DeleteFolder(prefix string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	prefix = cleanFolder(prefix)
+	if prefix == "" {
+		return ErrNoSuchFolder
+	}
+
+	deleted := 0
+	for location := range p.Credentials {
+		if inFolder(location, prefix) {
+			delete(p.Credentials, location)
+			p.removeAliases(location)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return ErrNoSuchFolder
+	}
+
+	return v.encrypt(p)
+}
@@ -0,0 +1,99 @@
+package vault
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newFolderTestVault(t *testing.T) *Vault {
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locs := []string{"work/aws/prod", "work/aws/dev", "work/github", "personal/email", "work"}
+	for _, loc := range locs {
+		if err = v.Add(loc, Credential{Username: "testuser", Password: "testpass"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return v
+}
+
+func TestList(t *testing.T) {
+	relaxDefaults(t)
+	v := newFolderTestVault(t)
+
+	root, err := v.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Children) != 2 || root.Children[0].Name != "personal" || root.Children[1].Name != "work" {
+		t.Fatal("List did not return the expected top level folders")
+	}
+
+	work, err := v.List("work/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work.Name != "work" || work.Path != "work" || !work.Credential {
+		t.Fatal("expected work to be a folder and a credential")
+	}
+	if len(work.Children) != 2 {
+		t.Fatalf("expected 2 children of work, got %v", len(work.Children))
+	}
+	aws := work.Children[0]
+	if aws.Name != "aws" || aws.Credential || len(aws.Children) != 2 {
+		t.Fatal("List did not return the expected aws folder")
+	}
+	if aws.Children[0].Path != "work/aws/dev" || !aws.Children[0].Credential {
+		t.Fatal("List did not return the expected aws credentials")
+	}
+}
+
+func TestMoveFolder(t *testing.T) {
+	relaxDefaults(t)
+	v := newFolderTestVault(t)
+
+	if err := v.MoveFolder("nonexistent", "other"); err != ErrNoSuchFolder {
+		t.Fatal("expected MoveFolder on a non-existant folder to return ErrNoSuchFolder")
+	}
+	if err := v.MoveFolder("work/github", "personal/email"); err != ErrCredentialExists {
+		t.Fatal("expected MoveFolder onto existing locations to return ErrCredentialExists")
+	}
+
+	err := v.MoveFolder("work/aws", "aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locations, err := v.Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(locations)
+	expected := []string{"aws/dev", "aws/prod", "personal/email", "work", "work/github"}
+	if !reflect.DeepEqual(locations, expected) {
+		t.Fatalf("expected %v after MoveFolder, got %v", expected, locations)
+	}
+}
+
+func TestDeleteFolder(t *testing.T) {
+	relaxDefaults(t)
+	v := newFolderTestVault(t)
+
+	if err := v.DeleteFolder("nonexistent"); err != ErrNoSuchFolder {
+		t.Fatal("expected DeleteFolder on a non-existant folder to return ErrNoSuchFolder")
+	}
+
+	err := v.DeleteFolder("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locations, err := v.Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(locations, []string{"personal/email"}) {
+		t.Fatalf("expected only personal/email to remain after DeleteFolder, got %v", locations)
+	}
+}
@@ -0,0 +1,202 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	gcmSIVNonceSize = 12
+	gcmSIVTagSize   = 16
+)
+
+// errGCMSIVOpen is returned if AES-GCM-SIV authentication fails.
+var errGCMSIVOpen = errors.New("message authentication failed")
+
+// gcmSIV is AES-256-GCM-SIV, as specified by RFC 8452. Unlike AES-GCM, it
+// does not fail catastrophically if a nonce is ever repeated.
+type gcmSIV struct {
+	block cipher.Block
+}
+
+// newGCMSIV returns AES-256-GCM-SIV keyed with the 32 byte `key`.
+func newGCMSIV(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmSIV{block: block}, nil
+}
+
+func (g *gcmSIV) NonceSize() int { return gcmSIVNonceSize }
+func (g *gcmSIV) Overhead() int  { return gcmSIVTagSize }
+
+// deriveKeys derives the per-nonce POLYVAL authentication key and AES
+// encryption key.
+func (g *gcmSIV) deriveKeys(nonce []byte) (authKey [16]byte, encBlock cipher.Block) {
+	var in, out [16]byte
+	var encKey [32]byte
+	copy(in[4:], nonce)
+	for i := uint32(0); i < 6; i++ {
+		binary.LittleEndian.PutUint32(in[:4], i)
+		g.block.Encrypt(out[:], in[:])
+		if i < 2 {
+			copy(authKey[8*i:], out[:8])
+		} else {
+			copy(encKey[8*(i-2):], out[:8])
+		}
+	}
+	encBlock, err := aes.NewCipher(encKey[:])
+	if err != nil {
+		panic(err)
+	}
+	return authKey, encBlock
+}
+
+// tag computes the tag of `plaintext` and `additionalData`.
+func (g *gcmSIV) tag(authKey [16]byte, encBlock cipher.Block, nonce, plaintext, additionalData []byte) [16]byte {
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+
+	s := polyval(authKey, additionalData, plaintext, lengths[:])
+	for i := range nonce {
+		s[i] ^= nonce[i]
+	}
+	s[15] &= 0x7f
+	var tag [16]byte
+	encBlock.Encrypt(tag[:], s[:])
+	return tag
+}
+
+// ctr XORs `in` with the keystream starting at the counter block derived from
+// `tag`, writing the result to `out`.
+func ctr(encBlock cipher.Block, tag [16]byte, out, in []byte) {
+	counter := tag
+	counter[15] |= 0x80
+	var keystream [16]byte
+	for len(in) > 0 {
+		encBlock.Encrypt(keystream[:], counter[:])
+		binary.LittleEndian.PutUint32(counter[:4], binary.LittleEndian.Uint32(counter[:4])+1)
+		n := subtle.XORBytes(out, in, keystream[:])
+		out, in = out[n:], in[n:]
+	}
+}
+
+func (g *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("vault: incorrect nonce length given to AES-GCM-SIV")
+	}
+	authKey, encBlock := g.deriveKeys(nonce)
+	tag := g.tag(authKey, encBlock, nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmSIVTagSize)
+	ctr(encBlock, tag, out, plaintext)
+	copy(out[len(plaintext):], tag[:])
+	return ret
+}
+
+func (g *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("vault: incorrect nonce length given to AES-GCM-SIV")
+	}
+	if len(ciphertext) < gcmSIVTagSize {
+		return nil, errGCMSIVOpen
+	}
+	var tag [16]byte
+	copy(tag[:], ciphertext[len(ciphertext)-gcmSIVTagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-gcmSIVTagSize]
+
+	authKey, encBlock := g.deriveKeys(nonce)
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	ctr(encBlock, tag, out, ciphertext)
+
+	expected := g.tag(authKey, encBlock, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(expected[:], tag[:]) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errGCMSIVOpen
+	}
+	return ret, nil
+}
+
+// sliceForAppend extends `in` by `n` bytes, returning the whole slice and the
+// extension.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	return head, head[len(in):]
+}
+
+// polyval computes POLYVAL, the universal hash of RFC 8452, keyed with `h`
+// over each of `inputs` zero padded to a multiple of 16 bytes. It is computed
+// using GHASH multiplication on byte reversed blocks, as described in
+// appendix A of the RFC.
+func polyval(h [16]byte, inputs ...[]byte) [16]byte {
+	hi, lo := reversedBlock(h[:])
+	hi, lo = mulX(hi, lo)
+
+	var shi, slo uint64
+	var block [16]byte
+	for _, input := range inputs {
+		for len(input) > 0 {
+			block = [16]byte{}
+			n := copy(block[:], input)
+			input = input[n:]
+			xhi, xlo := reversedBlock(block[:])
+			shi, slo = ghashMul(shi^xhi, slo^xlo, hi, lo)
+		}
+	}
+
+	var s [16]byte
+	binary.BigEndian.PutUint64(s[:8], shi)
+	binary.BigEndian.PutUint64(s[8:], slo)
+	for i, j := 0, 15; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+	return s
+}
+
+// reversedBlock returns the byte reversal of the 16 byte `b` as two big
+// endian halves.
+func reversedBlock(b []byte) (hi, lo uint64) {
+	return binary.LittleEndian.Uint64(b[8:]), binary.LittleEndian.Uint64(b[:8])
+}
+
+// ghashMul multiplies the GHASH field elements `x` and `y`, each given as two
+// big endian halves.
+func ghashMul(xhi, xlo, yhi, ylo uint64) (uint64, uint64) {
+	var zhi, zlo uint64
+	vhi, vlo := yhi, ylo
+	for i := 0; i < 128; i++ {
+		var bit uint64
+		if i < 64 {
+			bit = xhi >> (63 - i) & 1
+		} else {
+			bit = xlo >> (127 - i) & 1
+		}
+		// Branch free, so the time taken does not depend on the key.
+		mask := -bit
+		zhi ^= vhi & mask
+		zlo ^= vlo & mask
+		vhi, vlo = mulX(vhi, vlo)
+	}
+	return zhi, zlo
+}
+
+// mulX multiplies the GHASH field element `hi`, `lo` by x, without branching
+// on its value.
+func mulX(hi, lo uint64) (uint64, uint64) {
+	mask := -(lo & 1)
+	lo = lo>>1 | hi<<63
+	hi = hi>>1 ^ (0xe1<<56)&mask
+	return hi, lo
+}
@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestPolyval(t *testing.T) {
+	// From appendix A of RFC 8452.
+	var h [16]byte
+	copy(h[:], decodeHex(t, "25629347589242761d31f826ba4b757b"))
+	s := polyval(h, decodeHex(t, "4f4f95668c83dfb6401762bb2d01a262"), decodeHex(t, "d1a24ddd2721d006bbe45f20d3c9f362"))
+	if !bytes.Equal(s[:], decodeHex(t, "f7a3b47b846119fae5b7866cf5e5b77e")) {
+		t.Fatalf("unexpected POLYVAL %x", s)
+	}
+}
+
+func TestGCMSIV(t *testing.T) {
+	const (
+		key   = "0100000000000000000000000000000000000000000000000000000000000000"
+		nonce = "030000000000000000000000"
+		zeros = "0000000000000000000000000000000000000000000000000000000000000000"
+	)
+	for _, test := range []struct{ key, nonce, plaintext, additionalData, result string }{
+		// From appendix C.2 of RFC 8452.
+		{key, nonce, "", "", "07f5f4169bbf55a8400cd47ea6fd400f"},
+		{key, nonce, "0100000000000000", "", "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28"},
+		{key, nonce, "010000000000000000000000", "", "9aab2aeb3faa0a34aea8e2b18ca50da9ae6559e48fd10f6e5c9ca17e"},
+		{key, nonce, "01000000000000000000000000000000", "", "85a01b63025ba19b7fd3ddfc033b3e76c9eac6fa700942702e90862383c6c366"},
+		{key, nonce, "0100000000000000000000000000000002000000000000000000000000000000", "", "4a6a9db4c8c6549201b9edb53006cba821ec9cf850948a7c86c68ac7539d027fe819e63abcd020b006a976397632eb5d"},
+		{key, nonce, "010000000000000000000000000000000200000000000000000000000000000003000000000000000000000000000000", "", "c00d121893a9fa603f48ccc1ca3c57ce7499245ea0046db16c53c7c66fe717e39cf6c748837b61f6ee3adcee17534ed5790bc96880a99ba804bd12c0e6a22cc4"},
+		{key, nonce, "01000000000000000000000000000000020000000000000000000000000000000300000000000000000000000000000004000000000000000000000000000000", "", "c2d5160a1f8683834910acdafc41fbb1632d4a353e8b905ec9a5499ac34f96c7e1049eb080883891a4db8caaa1f99dd004d80487540735234e3744512c6f90ce112864c269fc0d9d88c61fa47e39aa08"},
+		{key, nonce, "0200000000000000", "01", "1de22967237a813291213f267e3b452f02d01ae33e4ec854"},
+		{key, nonce, "020000000000000000000000", "01", "163d6f9cc1b346cd453a2e4cc1a4a19ae800941ccdc57cc8413c277f"},
+		{key, nonce, "02000000000000000000000000000000", "01", "c91545823cc24f17dbb0e9e807d5ec17b292d28ff61189e8e49f3875ef91aff7"},
+		{key, nonce, "0200000000000000000000000000000003000000000000000000000000000000", "01", "07dad364bfc2b9da89116d7bef6daaaf6f255510aa654f920ac81b94e8bad365aea1bad12702e1965604374aab96dbbc"},
+		{key, nonce, "020000000000000000000000000000000300000000000000000000000000000004000000000000000000000000000000", "01", "c67a1f0f567a5198aa1fcc8e3f21314336f7f51ca8b1af61feac35a86416fa47fbca3b5f749cdf564527f2314f42fe2503332742b228c647173616cfd44c54eb"},
+		{key, nonce, "02000000000000000000000000000000030000000000000000000000000000000400000000000000000000000000000005000000000000000000000000000000", "01", "67fd45e126bfb9a79930c43aad2d36967d3f0e4d217c1e551f59727870beefc98cb933a8fce9de887b1e40799988db1fc3f91880ed405b2dd298318858467c895bde0285037c5de81e5b570a049b62a0"},
+		{key, nonce, "02000000", "010000000000000000000000", "22b3f4cd1835e517741dfddccfa07fa4661b74cf"},
+		{key, nonce, "0300000000000000000000000000000004000000", "010000000000000000000000000000000200", "43dd0163cdb48f9fe3212bf61b201976067f342bb879ad976d8242acc188ab59cabfe307"},
+		{key, nonce, "030000000000000000000000000000000400", "0100000000000000000000000000000002000000", "462401724b5ce6588d5a54aae5375513a075cfcdf5042112aa29685c912fc2056543"},
+		// From appendix C.3 of RFC 8452, whose counters wrap around.
+		{zeros, "000000000000000000000000", "000000000000000000000000000000004db923dc793ee6497c76dcc03a98e108", "", "f3f80f2cf0cb2dd9c5984fcda908456cc537703b5ba70324a6793a7bf218d3eaffffffff000000000000000000000000"},
+		{zeros, "000000000000000000000000", "eb3640277c7ffd1303c7a542d02d3e4c0000000000000000", "", "18ce4f0b8cb4d0cac65fea8f79257b20888e53e72299e56dffffffff000000000000000000000000"},
+	} {
+		aead, err := newGCMSIV(decodeHex(t, test.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := decodeHex(t, test.nonce)
+		plaintext := decodeHex(t, test.plaintext)
+		additionalData := decodeHex(t, test.additionalData)
+		sealed := aead.Seal(nil, nonce, plaintext, additionalData)
+		if !bytes.Equal(sealed, decodeHex(t, test.result)) {
+			t.Fatalf("sealing %v with %v: expected %v, got %x", test.plaintext, test.additionalData, test.result, sealed)
+		}
+		opened, err := aead.Open(nil, nonce, sealed, additionalData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			t.Fatal("opening did not return the plaintext")
+		}
+
+		sealed[0] ^= 1
+		if _, err = aead.Open(nil, nonce, sealed, additionalData); err == nil {
+			t.Fatal("expected opening a modified ciphertext to fail")
+		}
+		sealed[0] ^= 1
+		sealed[len(sealed)-1] ^= 0x80
+		if _, err = aead.Open(nil, nonce, sealed, additionalData); err == nil {
+			t.Fatal("expected opening a ciphertext with a modified tag to fail")
+		}
+		sealed[len(sealed)-1] ^= 0x80
+		if _, err = aead.Open(nil, nonce, sealed, append(additionalData, 0)); err == nil {
+			t.Fatal("expected opening with different additional data to fail")
+		}
+	}
+}
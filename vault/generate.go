@@ -0,0 +1,383 @@
+package vault
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultGenerateLength is the length of passwords generated using
+	// GenerateOptions with no Length.
+	DefaultGenerateLength = 20
+
+	// maxGeneratedPasswords is the maximum number of generated passwords
+	// kept for each credential. The oldest are discarded first.
+	maxGeneratedPasswords = 10
+
+	// DefaultPassphraseWords is the number of words in passphrases generated
+	// using GenerateOptions with no Words.
+	DefaultPassphraseWords = 6
+
+	lowerCharacters  = "abcdefghijklmnopqrstuvwxyz"
+	upperCharacters  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitCharacters  = "0123456789"
+	symbolCharacters = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+	// ambiguousCharacters are easily confused with one another when read or
+	// typed by hand.
+	ambiguousCharacters = "Il1|O0o`'\""
+)
+
+// The parts of the syllables of pronounceable passwords, each made of an
+// onset, a vowel and an optional coda.
+var (
+	syllableOnsets = []string{
+		"b", "c", "d", "f", "g", "h", "j", "k", "l", "m", "n", "p", "r", "s", "t", "v", "w", "z",
+		"bl", "br", "ch", "cr", "dr", "fl", "fr", "gr", "pl", "pr", "sh", "sl", "st", "th", "tr",
+	}
+	syllableVowels = []string{"a", "e", "i", "o", "u", "ai", "ea", "ee", "oo", "ou"}
+	syllableCodas  = []string{"", "", "", "l", "m", "n", "r", "s", "t", "nd", "st"}
+)
+
+// ErrInvalidGenerateOptions is returned if GenerateOptions cannot be
+// satisfied, such as requiring more character classes than the length allows.
+var ErrInvalidGenerateOptions = errors.New("password cannot be generated using the provided options")
+
+// GeneratedPassword is a password generated for a credential, whether or not
+// it is still the credential's password.
+type GeneratedPassword struct {
+	Password    string    `json:"password"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// Wordlist is a list of distinct words used to generate passphrases.
+type Wordlist []string
+
+// GenerateMode selects the kind of password generated.
+type GenerateMode int
+
+const (
+	// ModeRandom generates a password of random characters.
+	ModeRandom GenerateMode = iota
+	// ModePassphrase generates a diceware passphrase of random words.
+	ModePassphrase
+	// ModePronounceable generates a password of random syllables, which is
+	// easier to read aloud or remember than random characters.
+	ModePronounceable
+)
+
+// CharClass is a set of character classes used to generate passwords.
+type CharClass uint8
+
+// Character classes, which may be combined using |.
+const (
+	ClassLower CharClass = 1 << iota
+	ClassUpper
+	ClassDigits
+	ClassSymbols
+
+	ClassAll = ClassLower | ClassUpper | ClassDigits | ClassSymbols
+)
+
+// GenerateOptions controls the passwords generated by GeneratePassword and
+// GenerateWithOptions. The zero value generates a password of
+// DefaultGenerateLength characters drawn from every character class.
+type GenerateOptions struct {
+	Mode GenerateMode
+
+	// Length, Classes, Required, ExcludeAmbiguous and NoSymbols apply to
+	// ModeRandom. ModePronounceable passwords are always made of lowercase
+	// syllables, so only Length, Required, ExcludeAmbiguous and NoSymbols
+	// apply to them.
+	Length int
+	// Classes are the character classes the password is drawn from. If
+	// zero, every class is used.
+	Classes CharClass
+	// Required are the character classes of which the password must contain
+	// at least one character. Required classes are always allowed.
+	Required CharClass
+	// ExcludeAmbiguous excludes characters which are easily confused, such
+	// as 0 and O or l and 1.
+	ExcludeAmbiguous bool
+	// NoSymbols excludes symbols, regardless of Classes and Required, for
+	// sites which reject them.
+	NoSymbols bool
+	// Symbols are the symbols ClassSymbols is made of. If empty, every ASCII
+	// punctuation character is used.
+	Symbols string
+
+	// Words, Separator and Wordlist apply to ModePassphrase, and are passed
+	// to GeneratePassphrase. If zero, DefaultPassphraseWords words separated
+	// by spaces are drawn from EFFLargeWordlist.
+	Words     int
+	Separator string
+	Wordlist  Wordlist
+
+	// Username, if set, is the style of the username GenerateWithOptions
+	// generates when it is given an empty username.
+	Username *UsernameStyle
+}
+
+// classCharacters returns the characters in `class`, excluding ambiguous
+// characters if requested.
+func (opts GenerateOptions) classCharacters(class CharClass) string {
+	var chars string
+	switch class {
+	case ClassLower:
+		chars = lowerCharacters
+	case ClassUpper:
+		chars = upperCharacters
+	case ClassDigits:
+		chars = digitCharacters
+	case ClassSymbols:
+		chars = symbolCharacters
+		if opts.Symbols != "" {
+			chars = opts.Symbols
+		}
+	}
+	if opts.ExcludeAmbiguous {
+		chars = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(ambiguousCharacters, r) {
+				return -1
+			}
+			return r
+		}, chars)
+	}
+	return chars
+}
+
+// randomIndex returns a uniformly random integer in [0, n).
+func randomIndex(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(i.Int64())
+}
+
+// GeneratePassword generates a random password according to `opts`.
+func GeneratePassword(opts GenerateOptions) (string, error) {
+	switch opts.Mode {
+	case ModeRandom:
+		return opts.generateRandom()
+	case ModePronounceable:
+		return opts.generatePronounceable()
+	case ModePassphrase:
+		words, separator := opts.Words, opts.Separator
+		if words == 0 {
+			words = DefaultPassphraseWords
+		}
+		if separator == "" {
+			separator = " "
+		}
+		return GeneratePassphrase(words, separator, opts.Wordlist)
+	default:
+		return "", ErrInvalidGenerateOptions
+	}
+}
+
+// GeneratePassphrase generates a diceware passphrase of `words` words drawn
+// uniformly at random from `wordlist`, joined by `separator`. If `wordlist`
+// is nil, EFFLargeWordlist is used, giving about 12.9 bits of entropy per
+// word.
+func GeneratePassphrase(words int, separator string, wordlist Wordlist) (string, error) {
+	if wordlist == nil {
+		wordlist = EFFLargeWordlist
+	}
+	if words < 1 || len(wordlist) < 2 {
+		return "", ErrInvalidGenerateOptions
+	}
+
+	phrase := make([]string, words)
+	for i := range phrase {
+		phrase[i] = wordlist[randomIndex(len(wordlist))]
+	}
+	return strings.Join(phrase, separator), nil
+}
+
+// generateRandom generates a password of random characters.
+func (opts GenerateOptions) generateRandom() (string, error) {
+	length := opts.Length
+	if length == 0 {
+		length = DefaultGenerateLength
+	}
+	classes, required := opts.Classes, opts.Required
+	if classes == 0 {
+		classes = ClassAll
+	}
+	classes |= required
+	if opts.NoSymbols {
+		classes &^= ClassSymbols
+		required &^= ClassSymbols
+	}
+
+	var alphabet string
+	var requiredSets []string
+	for class := ClassLower; class <= ClassSymbols; class <<= 1 {
+		if classes&class == 0 {
+			continue
+		}
+		chars := opts.classCharacters(class)
+		alphabet += chars
+		if required&class != 0 {
+			requiredSets = append(requiredSets, chars)
+		}
+	}
+	if length < 0 || length < len(requiredSets) || alphabet == "" {
+		return "", ErrInvalidGenerateOptions
+	}
+
+	// Passwords missing a required class are discarded rather than patched
+	// up, so that every acceptable password is equally likely.
+	password := make([]byte, length)
+	for {
+		for i := range password {
+			password[i] = alphabet[randomIndex(len(alphabet))]
+		}
+		satisfied := true
+		for _, chars := range requiredSets {
+			if !strings.ContainsAny(string(password), chars) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return string(password), nil
+		}
+	}
+}
+
+// pick returns a random element of `choices`, skipping those containing
+// ambiguous characters if requested.
+func (opts GenerateOptions) pick(choices []string) string {
+	for {
+		choice := choices[randomIndex(len(choices))]
+		if !opts.ExcludeAmbiguous || !strings.ContainsAny(choice, ambiguousCharacters) {
+			return choice
+		}
+	}
+}
+
+// generatePronounceable generates a password of random syllables. Required
+// uppercase letters are satisfied by capitalizing the start of a syllable,
+// and required digits and symbols by appending one of each.
+func (opts GenerateOptions) generatePronounceable() (string, error) {
+	length := opts.Length
+	if length == 0 {
+		length = DefaultGenerateLength
+	}
+	required := opts.Required
+	if opts.NoSymbols {
+		required &^= ClassSymbols
+	}
+
+	var suffix []byte
+	for _, class := range []CharClass{ClassDigits, ClassSymbols} {
+		if required&class != 0 {
+			chars := opts.classCharacters(class)
+			suffix = append(suffix, chars[randomIndex(len(chars))])
+		}
+	}
+	length -= len(suffix)
+	if length < 1 {
+		return "", ErrInvalidGenerateOptions
+	}
+
+	var syllables []string
+	var n int
+	for n < length {
+		syllable := opts.pick(syllableOnsets) + opts.pick(syllableVowels) + opts.pick(syllableCodas)
+		syllables = append(syllables, syllable)
+		n += len(syllable)
+	}
+	if required&ClassUpper != 0 {
+		i := randomIndex(len(syllables))
+		syllables[i] = strings.ToUpper(syllables[i][:1]) + syllables[i][1:]
+	}
+	return strings.Join(syllables, "")[:length] + string(suffix), nil
+}
+
+// GenerateWithOptions generates a password according to `opts` and Add()s it
+// to the vault at `location` along with `username`. If `location` has a
+// policy, the password conforms to it. If `username` is empty and
+// opts.Username is set, a username is generated too.
+func (v *Vault) GenerateWithOptions(location string, username string, opts GenerateOptions) error {
+	p, err := v.decrypt()
+	if err != nil {
+		return err
+	}
+	password, err := p.generate(location, opts)
+	if err != nil {
+		return err
+	}
+	if username == "" && opts.Username != nil {
+		if username, err = GenerateUsername(*opts.Username); err != nil {
+			return err
+		}
+	}
+	cred := Credential{Username: username, Password: password}
+	cred.recordGenerated(password)
+	return v.Add(location, cred)
+}
+
+// recordGenerated appends `password` to the credential's GeneratedPasswords.
+func (c *Credential) recordGenerated(password string) {
+	c.GeneratedPasswords = append(c.GeneratedPasswords, GeneratedPassword{
+		Password:    password,
+		GeneratedAt: time.Now(),
+	})
+	if len(c.GeneratedPasswords) > maxGeneratedPasswords {
+		c.GeneratedPasswords = c.GeneratedPasswords[len(c.GeneratedPasswords)-maxGeneratedPasswords:]
+	}
+}
+
+// PasswordHistory returns the passwords most recently generated for the
+// credential at `location` by Generate, GenerateWithOptions and Rotate, oldest
+// first. Unlike History, it is unaffected by other edits, so a generated
+// password a site silently failed to accept can be recovered.
+func (v *Vault) PasswordHistory(location string) ([]GeneratedPassword, error) {
+	cred, err := v.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	return cred.GeneratedPasswords, nil
+}
+
+// Rotate replaces the password of the login at `location` with one generated
+// according to `opts` and the location's policy, if any, returning the old
+// and new passwords. The previous version is kept in the credential's
+// History. ErrWrongKind is returned if the entry at `location` is not a
+// login.
+func (v *Vault) Rotate(location string, opts GenerateOptions) (string, string, error) {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return "", "", err
+	}
+
+	location = p.resolve(location)
+	cred, err := p.credential(location)
+	if err != nil {
+		return "", "", err
+	}
+	if cred.kind() != KindLogin {
+		return "", "", ErrWrongKind
+	}
+	password, err := p.generate(location, opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	old := cred.Password
+	cred.History = cred.archive()
+	cred.Password = password
+	cred.recordGenerated(password)
+	cred.UpdatedAt = time.Now()
+
+	if err = v.encrypt(p); err != nil {
+		return "", "", err
+	}
+	return old, password, nil
+}
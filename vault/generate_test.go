@@ -0,0 +1,216 @@
+package vault
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	password, err := GeneratePassword(GenerateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(password) != DefaultGenerateLength {
+		t.Fatalf("expected a password of length %v, got %q", DefaultGenerateLength, password)
+	}
+
+	for i := 0; i < 50; i++ {
+		password, err = GeneratePassword(GenerateOptions{
+			Length:           8,
+			Classes:          ClassLower,
+			Required:         ClassUpper | ClassDigits | ClassSymbols,
+			ExcludeAmbiguous: true,
+			NoSymbols:        true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != 8 {
+			t.Fatalf("expected a password of length 8, got %q", password)
+		}
+		if !strings.ContainsAny(password, upperCharacters) || !strings.ContainsAny(password, digitCharacters) {
+			t.Fatalf("password %q is missing a required class", password)
+		}
+		if strings.ContainsAny(password, symbolCharacters) {
+			t.Fatalf("password %q contains a symbol", password)
+		}
+		if strings.ContainsAny(password, ambiguousCharacters) {
+			t.Fatalf("password %q contains an ambiguous character", password)
+		}
+	}
+
+	if _, err = GeneratePassword(GenerateOptions{Length: 2, Required: ClassAll}); err != ErrInvalidGenerateOptions {
+		t.Fatal("expected ErrInvalidGenerateOptions when the length is too short, got", err)
+	}
+	if _, err = GeneratePassword(GenerateOptions{Classes: ClassSymbols, NoSymbols: true}); err != ErrInvalidGenerateOptions {
+		t.Fatal("expected ErrInvalidGenerateOptions with no characters, got", err)
+	}
+}
+
+func TestGenerateWithOptions(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.GenerateWithOptions("bank", "testuser", GenerateOptions{Length: 12, Classes: ClassDigits}); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || len(cred.Password) != 12 || strings.Trim(cred.Password, digitCharacters) != "" {
+		t.Fatalf("GenerateWithOptions did not generate a 12 digit password, got %q", cred.Password)
+	}
+	if err = v.GenerateWithOptions("bank", "testuser", GenerateOptions{}); err != ErrCredentialExists {
+		t.Fatal("expected ErrCredentialExists on generate with existing location, got", err)
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	if len(EFFLargeWordlist) != 7776 {
+		t.Fatalf("expected 7776 words in the EFF wordlist, got %v", len(EFFLargeWordlist))
+	}
+
+	phrase, err := GeneratePassphrase(5, "-", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := strings.Split(phrase, "-")
+	if len(words) != 5 {
+		t.Fatalf("expected 5 words, got %q", phrase)
+	}
+	for _, word := range words {
+		i := sort.SearchStrings(EFFLargeWordlist, word)
+		if i == len(EFFLargeWordlist) || EFFLargeWordlist[i] != word {
+			t.Fatalf("%q is not in the EFF wordlist", word)
+		}
+	}
+
+	phrase, err = GeneratePassphrase(3, ".", Wordlist{"correct", "horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Trim(strings.Replace(strings.Replace(phrase, "correct", "", -1), "horse", "", -1), ".") != "" {
+		t.Fatalf("passphrase %q was not drawn from the wordlist", phrase)
+	}
+	if _, err = GeneratePassphrase(0, " ", nil); err != ErrInvalidGenerateOptions {
+		t.Fatal("expected ErrInvalidGenerateOptions for zero words, got", err)
+	}
+
+	phrase, err = GeneratePassword(GenerateOptions{Mode: ModePassphrase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strings.Split(phrase, " ")) != DefaultPassphraseWords {
+		t.Fatalf("expected %v space separated words, got %q", DefaultPassphraseWords, phrase)
+	}
+}
+
+func TestGeneratePronounceable(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		password, err := GeneratePassword(GenerateOptions{
+			Mode:             ModePronounceable,
+			Length:           12,
+			Required:         ClassUpper | ClassDigits,
+			ExcludeAmbiguous: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != 12 {
+			t.Fatalf("expected a password of length 12, got %q", password)
+		}
+		if !strings.ContainsAny(password, upperCharacters) || !strings.ContainsAny(password[11:], digitCharacters) {
+			t.Fatalf("password %q is missing a required class", password)
+		}
+		if strings.ContainsAny(password, ambiguousCharacters+symbolCharacters) {
+			t.Fatalf("password %q contains an unexpected character", password)
+		}
+	}
+
+	if _, err := GeneratePassword(GenerateOptions{Mode: ModePronounceable, Length: 1, Required: ClassDigits}); err != ErrInvalidGenerateOptions {
+		t.Fatal("expected ErrInvalidGenerateOptions when the length is too short, got", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = v.Rotate("github", GenerateOptions{}); err != ErrNoSuchCredential {
+		t.Fatal("expected ErrNoSuchCredential rotating a nonexistent location, got", err)
+	}
+	if err = v.Add("github", Credential{Username: "testuser", Password: "oldpass"}); err != nil {
+		t.Fatal(err)
+	}
+
+	old, password, err := v.Rotate("github", GenerateOptions{Length: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old != "oldpass" || len(password) != 16 {
+		t.Fatalf("unexpected passwords from Rotate %q, %q", old, password)
+	}
+	cred, err := v.Get("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != password || cred.Username != "testuser" {
+		t.Fatal("Rotate did not replace the password")
+	}
+	if len(cred.History) != 1 || cred.History[0].Credential.Password != "oldpass" {
+		t.Fatal("Rotate did not archive the previous password")
+	}
+
+	if err = v.AddNote("wifi", "body"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = v.Rotate("wifi", GenerateOptions{}); err != ErrWrongKind {
+		t.Fatal("expected ErrWrongKind rotating a note, got", err)
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.GenerateWithOptions("github", "testuser", GenerateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := []string{cred.Password}
+	for i := 0; i < maxGeneratedPasswords; i++ {
+		_, password, err := v.Rotate("github", GenerateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		generated = append(generated, password)
+	}
+
+	if err = v.Edit("github", Credential{Username: "testuser", Password: "manual"}); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := v.PasswordHistory("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != maxGeneratedPasswords {
+		t.Fatalf("expected %v generated passwords, got %v", maxGeneratedPasswords, len(history))
+	}
+	for i, password := range history {
+		if password.Password != generated[i+1] {
+			t.Fatalf("generated password %v was %q, expected %q", i, password.Password, generated[i+1])
+		}
+	}
+}
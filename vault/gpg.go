@@ -0,0 +1,145 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// maxGPGRecipientSize bounds the size of the recipient of a GPG slot.
+	maxGPGRecipientSize = 1024
+
+	// maxGPGKeySize bounds the size of the encrypted master key of a GPG
+	// slot.
+	maxGPGKeySize = 16 * 1024
+)
+
+var (
+	// ErrNoPassphrase is returned from ChangePassphrase and UpgradeKDF if
+	// the vault was unlocked using a GPG, TPM, PKCS#11 or threshold slot,
+	// which has no passphrase.
+	ErrNoPassphrase = errors.New("key slot the vault was unlocked with has no passphrase")
+
+	// ErrNoGPGRecipients is returned from NewWithGPG if it is given no
+	// recipients.
+	ErrNoGPGRecipients = errors.New("no GPG recipients")
+)
+
+type (
+	// GPGEncryptFunc encrypts `plaintext` to the OpenPGP public key of
+	// `recipient`.
+	GPGEncryptFunc func(recipient string, plaintext []byte) ([]byte, error)
+
+	// GPGDecryptFunc decrypts the OpenPGP message `ciphertext`.
+	GPGDecryptFunc func(ciphertext []byte) ([]byte, error)
+)
+
+// runGPG runs gpg with `args`, writing `input` to its standard input, and
+// returns its output.
+func runGPG(input []byte, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("gpg", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gpg: %v: %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// GPGEncrypt encrypts `plaintext` to the OpenPGP public key of `recipient`,
+// such as an email address or fingerprint, by running gpg.
+func GPGEncrypt(recipient string, plaintext []byte) ([]byte, error) {
+	return runGPG(plaintext, "--quiet", "--batch", "--encrypt", "--recipient", recipient)
+}
+
+// GPGDecryptBytes decrypts `ciphertext` by running gpg, which uses the user's
+// gpg-agent to unlock their private key, including one held on a smartcard.
+func GPGDecryptBytes(ciphertext []byte) ([]byte, error) {
+	return runGPG(ciphertext, "--quiet", "--batch", "--decrypt")
+}
+
+// newGPGKeySlot returns a GPG slot encrypting the vault's master key to
+// `recipient` using `encrypt`.
+func (v *Vault) newGPGKeySlot(recipient string, encrypt GPGEncryptFunc) (keySlot, error) {
+	if recipient == "" || len(recipient) > maxGPGRecipientSize {
+		return keySlot{}, fmt.Errorf("invalid GPG recipient %q", recipient)
+	}
+	wrapped, err := encrypt(recipient, v.keys.master[:])
+	if err != nil {
+		return keySlot{}, err
+	}
+	if len(wrapped) == 0 || len(wrapped) > maxGPGKeySize {
+		return keySlot{}, fmt.Errorf("gpg returned %v bytes for %v", len(wrapped), recipient)
+	}
+	return keySlot{
+		kdf:          KDFParams{}.withDefaults(),
+		salt:         randomSalt(),
+		gpgRecipient: recipient,
+		wrapped:      wrapped,
+	}, nil
+}
+
+// NewWithGPG creates a new, empty, vault with a GPG slot for each of
+// `recipients`, which need no passphrase: the master key is encrypted to each
+// recipient's OpenPGP public key using `encrypt`, such as GPGEncrypt. Any of
+// the recipients can open the vault using OpenWithOptions with GPGDecrypt set,
+// so a vault can be shared without sharing a passphrase.
+func NewWithGPG(recipients []string, encrypt GPGEncryptFunc) (*Vault, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoGPGRecipients
+	}
+	if len(recipients) > maxKeySlots {
+		return nil, ErrTooManyKeySlots
+	}
+	keys, enclave := newVaultKeys()
+	v := &Vault{
+		keys:    keys,
+		enclave: enclave,
+		undo:    newUndoStack(),
+	}
+	for _, recipient := range recipients {
+		slot, err := v.newGPGKeySlot(recipient, encrypt)
+		if err != nil {
+			return nil, err
+		}
+		v.slots = append(v.slots, slot)
+	}
+
+	if err := v.encrypt(newPayload()); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// AddGPGKeySlot adds a GPG slot to the vault, encrypting the master key to
+// the OpenPGP public key of `recipient` using `encrypt`, such as GPGEncrypt,
+// and returns the number of the new slot. The slot is written by the next
+// Save.
+func (v *Vault) AddGPGKeySlot(recipient string, encrypt GPGEncryptFunc) (int, error) {
+	if v.closed {
+		return 0, ErrLocked
+	}
+	if v.readOnly {
+		return 0, ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return 0, ErrAgeVault
+	}
+	if v.outer != nil {
+		return 0, ErrHiddenVault
+	}
+	if len(v.slots) >= maxKeySlots {
+		return 0, ErrTooManyKeySlots
+	}
+	slot, err := v.newGPGKeySlot(recipient, encrypt)
+	if err != nil {
+		return 0, err
+	}
+	v.slots = append(v.slots, slot)
+	return len(v.slots) - 1, nil
+}
@@ -0,0 +1,86 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+var errNoSecretKey = errors.New("no secret key")
+
+// testGPGEncrypt "encrypts" to `recipient` by prefixing the recipient, so
+// that testGPGDecrypt can check whose key is needed.
+func testGPGEncrypt(recipient string, plaintext []byte) ([]byte, error) {
+	return append([]byte(recipient+":"), plaintext...), nil
+}
+
+// testGPGDecrypt returns a GPGDecryptFunc holding the private keys of
+// `recipient`.
+func testGPGDecrypt(recipient string) GPGDecryptFunc {
+	return func(ciphertext []byte) ([]byte, error) {
+		prefix := []byte(recipient + ":")
+		if !bytes.HasPrefix(ciphertext, prefix) {
+			return nil, errNoSecretKey
+		}
+		return ciphertext[len(prefix):], nil
+	}
+}
+
+func TestGPG(t *testing.T) {
+	if _, err := NewWithGPG(nil, testGPGEncrypt); err != ErrNoGPGRecipients {
+		t.Fatal("expected ErrNoGPGRecipients, got", err)
+	}
+	v, err := NewWithGPG([]string{"alice@example.com", "bob@example.com"}, testGPGEncrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if _, err = Open("pass.db", ""); err != ErrCouldNotDecrypt {
+		t.Fatal("GPG vault was opened without gpg")
+	}
+	if _, err = OpenWithOptions("pass.db", "", OpenOptions{GPGDecrypt: testGPGDecrypt("eve@example.com")}); err != errNoSecretKey {
+		t.Fatal("expected gpg's error, got", err)
+	}
+	vopen, err := OpenWithOptions("pass.db", "", OpenOptions{GPGDecrypt: testGPGDecrypt("bob@example.com")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots := vopen.ListKeySlots(); len(slots) != 2 || !slots[1].Current || slots[1].GPGRecipient != "bob@example.com" {
+		t.Fatal("unexpected key slots", slots)
+	}
+	if err = vopen.ChangePassphrase("", "newpass"); err != ErrNoPassphrase {
+		t.Fatal("expected ErrNoPassphrase, got", err)
+	}
+
+	// A passphrase slot can be added to a GPG vault too.
+	if _, err = vopen.AddGPGKeySlot("carol@example.com", testGPGEncrypt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = vopen.AddKeySlot("recoverypass", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []OpenOptions{{GPGDecrypt: testGPGDecrypt("carol@example.com")}, {}} {
+		vopen, err = OpenWithOptions("pass.db", "recoverypass", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cred, err := vopen.Get("testlocation")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cred.Password != "testpass" {
+			t.Fatal("GPG vault did not contain the credential")
+		}
+	}
+}
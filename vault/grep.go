@@ -0,0 +1,113 @@
+package vault
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Field identifies a searchable part of a credential.
+type Field int
+
+const (
+	// FieldUsername is the credential's Username.
+	FieldUsername Field = iota
+	// FieldNotes is the credential's Notes.
+	FieldNotes
+	// FieldURL is the credential's URL.
+	FieldURL
+	// FieldCustom is each of the credential's custom Fields.
+	FieldCustom
+	// FieldPassword is the credential's Password. It is never searched
+	// unless explicitly requested.
+	FieldPassword
+)
+
+// defaultGrepFields are the fields searched by Grep if none are provided.
+var defaultGrepFields = []Field{FieldUsername, FieldNotes, FieldURL, FieldCustom}
+
+// String returns the name of the field.
+func (f Field) String() string {
+	switch f {
+	case FieldUsername:
+		return "username"
+	case FieldNotes:
+		return "notes"
+	case FieldURL:
+		return "url"
+	case FieldCustom:
+		return "custom"
+	case FieldPassword:
+		return "password"
+	}
+	return "unknown"
+}
+
+// Result is a single match found by Grep. Results identify where a match was
+// found but not the matching data itself.
+type Result struct {
+	Location string
+	Field    Field
+	// Key is the name of the matching custom field if Field is
+	// FieldCustom.
+	Key string
+}
+
+// Grep searches the provided `fields` of every credential in the vault for
+// the regular expression `pattern` and returns a Result for each matching
+// field, ordered by location. If no fields are provided, usernames, notes,
+// URLs and custom fields are searched.
+func (v *Vault) Grep(pattern string, fields ...Field) ([]Result, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		fields = defaultGrepFields
+	}
+
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for location, cred := range p.Credentials {
+		for _, field := range fields {
+			switch field {
+			case FieldUsername:
+				if re.MatchString(cred.Username) {
+					results = append(results, Result{Location: location, Field: field})
+				}
+			case FieldNotes:
+				if re.MatchString(cred.Notes) {
+					results = append(results, Result{Location: location, Field: field})
+				}
+			case FieldURL:
+				if re.MatchString(cred.URL) {
+					results = append(results, Result{Location: location, Field: field})
+				}
+			case FieldPassword:
+				if re.MatchString(cred.Password) {
+					results = append(results, Result{Location: location, Field: field})
+				}
+			case FieldCustom:
+				for key, value := range cred.Fields {
+					if re.MatchString(value) {
+						results = append(results, Result{Location: location, Field: field, Key: key})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Location != results[j].Location {
+			return results[i].Location < results[j].Location
+		}
+		if results[i].Field != results[j].Field {
+			return results[i].Field < results[j].Field
+		}
+		return results[i].Key < results[j].Key
+	})
+	return results, nil
+}
@@ -0,0 +1,57 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Add("testlocation1", Credential{Username: "old@example.com", Password: "old@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation2", Credential{
+		Username: "testuser",
+		Password: "testpass",
+		Notes:    "recovery email: OLD@example.com",
+		Fields:   map[string]string{"backup": "old@example.com", "pin": "1234"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation3", Credential{Username: "new@example.com", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := v.Grep(`(?i)old@example\.com`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Result{
+		{Location: "testlocation1", Field: FieldUsername},
+		{Location: "testlocation2", Field: FieldNotes},
+		{Location: "testlocation2", Field: FieldCustom, Key: "backup"},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected results %v, got %v", expected, results)
+	}
+
+	results, err = v.Grep(`old@`, FieldPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Location != "testlocation1" || results[0].Field != FieldPassword {
+		t.Fatalf("expected Grep to search passwords when requested, got %v", results)
+	}
+
+	if _, err = v.Grep(`(`); err == nil {
+		t.Fatal("expected Grep to fail with an invalid pattern")
+	}
+}
@@ -0,0 +1,443 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// vaultVersion is the current version of the vault file header. Version 2
+// added the cipher, and a salt separate from the nonce. Version 3 replaced
+// the salt with key slots, version 4 added the FIDO2 credentials of key slots,
+// version 5 added GPG slots, version 6 sealed each credential separately in
+// an envelope, version 7 authenticated the header, version 8 added the
+// hidden section, version 9 added TPM slots, version 10 added PKCS#11 slots,
+// version 11 padded the envelope with random entries, version 12 added
+// threshold slots, version 13 added the compression of the envelope, version
+// 14 added the IDs of the envelope's entries, version 15 encoded the
+// envelope's entries as one gob stream, and version 16 sealed the entries,
+// trash and operation log with a record key of their own, keeping those that
+// did not change from one save to the next.
+const vaultVersion = 16
+
+// headerMACSize is the size of the MAC following the header from version 7.
+const headerMACSize = sha256.Size
+
+var (
+	// vaultMagic identifies a masterkey vault file with a header. Vaults
+	// written before the header was introduced start directly with the
+	// nonce, and are read as scrypt vaults.
+	vaultMagic = [8]byte{'M', 'K', 'V', 'A', 'U', 'L', 'T', 0}
+
+	// ErrUnsupportedVaultVersion is returned from Open, as an
+	// *UnsupportedVersionError, if the vault was written by a newer
+	// version of masterkey.
+	ErrUnsupportedVaultVersion = errors.New("vault version is not supported")
+)
+
+type (
+	// vaultHeader is the unencrypted header at the start of a vault file.
+	// Before version 3, it is followed by a kdfHeader and, from version
+	// 2, a cipherHeader. From version 3, it is followed by a keyHeader.
+	vaultHeader struct {
+		Magic   [8]byte
+		Version uint32
+	}
+
+	// kdfHeader records a key derivation function and its parameters.
+	kdfHeader struct {
+		KDF uint32
+		// Params are scrypt's N, r and p, or Argon2id's time, memory and
+		// threads.
+		Params [3]uint32
+	}
+
+	// cipherHeader records the cipher and the salt of a version 2 vault.
+	// Vaults written before version 2 are encrypted using
+	// XSalsa20-Poly1305, and their salt is the nonce following the header.
+	cipherHeader struct {
+		Cipher uint32
+		Salt   [24]byte
+	}
+
+	// keyHeader records the cipher of the vault and its number of key
+	// slots. Each slot is recorded by a slotHeader, followed from version
+	// 4 by the length and ID of its FIDO2 credential, if it has one, and
+	// then the master key wrapped by the slot's key. From version 5, a GPG
+	// slot is instead followed by the length and name of its recipient and
+	// the length and contents of the OpenPGP message holding the master
+	// key. From version 9, a TPM slot is followed by the length and
+	// contents of the sealed master key, and from version 10, a PKCS#11
+	// slot by the length and label of the token's key and the length and
+	// contents of the wrapped master key. From version 12, a threshold
+	// slot is followed by a thresholdHeader and its approvers, then the
+	// master key wrapped by the slot's key. The slots are followed by the
+	// data key wrapped by the master key. From version 13, the keyHeader
+	// is followed by the Compression of the envelope.
+	keyHeader struct {
+		Cipher uint32
+		Slots  uint32
+	}
+
+	// slotHeader records how the key of a key slot is derived.
+	slotHeader struct {
+		KDF   kdfHeader
+		Salt  [24]byte
+		Flags uint32
+	}
+
+	// fileHeader is everything needed to decrypt a vault other than its
+	// passphrase, as read from the vault's header. The slot of a vault
+	// written before version 3 wraps nothing: its key is the data key.
+	// The version of a vault without a header is 0.
+	fileHeader struct {
+		version     uint32
+		cipher      CipherID
+		compression Compression
+		slots       []keySlot
+		dataKey     []byte
+		// authenticated is the header as written, and mac its MAC, from
+		// version 7.
+		authenticated []byte
+		mac           []byte
+		// raw is the header followed by its MAC, and hidden the hidden
+		// section after it, from version 8.
+		raw    []byte
+		hidden []byte
+	}
+)
+
+// Flags of a slotHeader, set if the slot's key is derived from a keyfile or
+// the response to a challenge as well as a passphrase.
+const (
+	slotKeyfile = 1 << iota
+	slotChallengeResponse
+	slotFIDO2
+	slotGPG
+	slotTPM
+	slotPKCS11
+	slotThreshold
+)
+
+// readHeader reads the header at the start of `data`, returning it along with
+// the rest of `data`: the nonce followed by the encrypted contents.
+func readHeader(data []byte) (fileHeader, []byte, error) {
+	fh := fileHeader{cipher: CipherXSalsa20Poly1305}
+	legacy := keySlot{kdf: KDFParams{}.withDefaults()}
+	if bytes.HasPrefix(data, []byte(ageMagic)) {
+		return fileHeader{}, nil, ErrAgeVault
+	}
+	if bytes.HasPrefix(data, vaultMagic[:]) {
+		r := bytes.NewReader(data)
+		var h vaultHeader
+		if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+			return fileHeader{}, nil, ErrCouldNotDecrypt
+		}
+		if h.Version > vaultVersion {
+			return fileHeader{}, nil, &UnsupportedVersionError{Format: "header", Version: int(h.Version), MaxVersion: vaultVersion}
+		}
+		fh.version = h.Version
+		if h.Version >= 3 {
+			if err := fh.readKeySlots(r, h.Version); err != nil {
+				return fileHeader{}, nil, err
+			}
+			if h.Version >= 7 {
+				fh.authenticated = data[:len(data)-r.Len()]
+				fh.mac = make([]byte, headerMACSize)
+				if _, err := io.ReadFull(r, fh.mac); err != nil {
+					return fileHeader{}, nil, ErrCouldNotDecrypt
+				}
+			}
+			if h.Version >= 8 {
+				fh.raw = data[:len(data)-r.Len()]
+				var size uint32
+				if err := binary.Read(r, binary.BigEndian, &size); err != nil || size > maxHiddenSize {
+					return fileHeader{}, nil, ErrCouldNotDecrypt
+				}
+				fh.hidden = make([]byte, size)
+				if _, err := io.ReadFull(r, fh.hidden); err != nil {
+					return fileHeader{}, nil, ErrCouldNotDecrypt
+				}
+			}
+			return fh, data[len(data)-r.Len():], nil
+		}
+
+		var kh kdfHeader
+		if err := binary.Read(r, binary.BigEndian, &kh); err != nil {
+			return fileHeader{}, nil, ErrCouldNotDecrypt
+		}
+		kdf, err := kh.kdfParams()
+		if err != nil {
+			return fileHeader{}, nil, err
+		}
+		legacy.kdf = kdf
+
+		if h.Version >= 2 {
+			var ch cipherHeader
+			if err = binary.Read(r, binary.BigEndian, &ch); err != nil {
+				return fileHeader{}, nil, ErrCouldNotDecrypt
+			}
+			fh.cipher, legacy.salt = CipherID(ch.Cipher), ch.Salt
+			fh.slots = []keySlot{legacy}
+			return fh, data[len(data)-r.Len():], nil
+		}
+		data = data[len(data)-r.Len():]
+	}
+
+	if len(data) < len(legacy.salt) {
+		return fileHeader{}, nil, ErrCouldNotDecrypt
+	}
+	copy(legacy.salt[:], data)
+	fh.slots = []keySlot{legacy}
+	return fh, data, nil
+}
+
+// readKeySlots reads the key slots and wrapped data key of a header of version
+// `version`, from 3, from `r`.
+func (fh *fileHeader) readKeySlots(r io.Reader, version uint32) error {
+	var kh keyHeader
+	if err := binary.Read(r, binary.BigEndian, &kh); err != nil {
+		return ErrCouldNotDecrypt
+	}
+	if kh.Slots == 0 || kh.Slots > maxKeySlots {
+		return ErrCouldNotDecrypt
+	}
+	fh.cipher = CipherID(kh.Cipher)
+	size, err := wrappedKeySize(fh.cipher)
+	if err != nil {
+		return err
+	}
+	if version >= 13 {
+		if err = binary.Read(r, binary.BigEndian, &fh.compression); err != nil {
+			return ErrCouldNotDecrypt
+		}
+		if err = fh.compression.validate(); err != nil {
+			return err
+		}
+	}
+
+	for i := uint32(0); i < kh.Slots; i++ {
+		var sh slotHeader
+		if err = binary.Read(r, binary.BigEndian, &sh); err != nil {
+			return ErrCouldNotDecrypt
+		}
+		kdf, err := sh.KDF.kdfParams()
+		if err != nil {
+			return err
+		}
+		slot := keySlot{
+			kdf:               kdf,
+			salt:              sh.Salt,
+			keyfile:           sh.Flags&slotKeyfile != 0,
+			challengeResponse: sh.Flags&slotChallengeResponse != 0,
+			wrapped:           make([]byte, size),
+		}
+		if version >= 4 && sh.Flags&slotFIDO2 != 0 {
+			if slot.fido2Credential, err = readSized(r, maxFIDO2CredentialSize); err != nil {
+				return err
+			}
+		}
+		if version >= 12 && sh.Flags&slotThreshold != 0 {
+			if err = slot.readApprovers(r, fh.cipher); err != nil {
+				return err
+			}
+		}
+		if version >= 5 && sh.Flags&slotGPG != 0 {
+			recipient, err := readSized(r, maxGPGRecipientSize)
+			if err != nil {
+				return err
+			}
+			slot.gpgRecipient = string(recipient)
+			if slot.wrapped, err = readSized(r, maxGPGKeySize); err != nil {
+				return err
+			}
+		} else if version >= 9 && sh.Flags&slotTPM != 0 {
+			slot.tpm = true
+			if slot.wrapped, err = readSized(r, maxTPMBlobSize); err != nil {
+				return err
+			}
+		} else if version >= 10 && sh.Flags&slotPKCS11 != 0 {
+			label, err := readSized(r, maxPKCS11LabelSize)
+			if err != nil {
+				return err
+			}
+			slot.pkcs11Label = string(label)
+			if slot.wrapped, err = readSized(r, maxPKCS11KeySize); err != nil {
+				return err
+			}
+		} else if _, err = io.ReadFull(r, slot.wrapped); err != nil {
+			return ErrCouldNotDecrypt
+		}
+		fh.slots = append(fh.slots, slot)
+	}
+
+	fh.dataKey = make([]byte, size)
+	if _, err = io.ReadFull(r, fh.dataKey); err != nil {
+		return ErrCouldNotDecrypt
+	}
+	return nil
+}
+
+// headerMAC returns the MAC of `header` and the vault's encrypted data, keyed
+// by a key derived from the master key, which binds the header's version, KDF
+// parameters, cipher and key slots to the contents they were written with.
+func (v *Vault) headerMAC(header []byte, data []byte) []byte {
+	kdf := hmac.New(sha256.New, v.keys.master[:])
+	kdf.Write([]byte("masterkey header authentication"))
+	key := kdf.Sum(nil)
+	defer wipe(key)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// verifyHeader checks the MAC of `fh`, read along with `data`, using the
+// vault's master key. Headers before version 7 are not authenticated, but a
+// payload written since then, which is only ever saved with an authenticated
+// header, is rejected along with one, so the MAC cannot be stripped by
+// rewriting the version.
+func (v *Vault) verifyHeader(fh fileHeader, data []byte, p *payload) error {
+	if fh.version < 7 {
+		if p.Meta.Version >= 2 {
+			return ErrCouldNotDecrypt
+		}
+		return nil
+	}
+	if !hmac.Equal(fh.mac, v.headerMAC(fh.authenticated, data)) {
+		return ErrCouldNotDecrypt
+	}
+	return nil
+}
+
+// writeHeader writes the vault's header to `out`, wrapping the data key by
+// the master key, followed by its MAC.
+func (v *Vault) writeHeader(out io.Writer) error {
+	dataKey, err := wrapKey(v.cipher, &v.keys.master, v.keys.secret)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	w := &buf
+
+	h := vaultHeader{Magic: vaultMagic, Version: vaultVersion}
+	if err = binary.Write(w, binary.BigEndian, &h); err != nil {
+		return err
+	}
+	kh := keyHeader{Cipher: uint32(v.cipher), Slots: uint32(len(v.slots))}
+	if err = binary.Write(w, binary.BigEndian, &kh); err != nil {
+		return err
+	}
+	if err = binary.Write(w, binary.BigEndian, v.compression); err != nil {
+		return err
+	}
+	for _, slot := range v.slots {
+		sh := slotHeader{KDF: slot.kdf.header(), Salt: slot.salt}
+		if slot.keyfile {
+			sh.Flags |= slotKeyfile
+		}
+		if slot.challengeResponse {
+			sh.Flags |= slotChallengeResponse
+		}
+		if slot.fido2Credential != nil {
+			sh.Flags |= slotFIDO2
+		}
+		if slot.gpgRecipient != "" {
+			sh.Flags |= slotGPG
+		}
+		if slot.tpm {
+			sh.Flags |= slotTPM
+		}
+		if slot.pkcs11Label != "" {
+			sh.Flags |= slotPKCS11
+		}
+		if slot.threshold > 0 {
+			sh.Flags |= slotThreshold
+		}
+		if err = binary.Write(w, binary.BigEndian, &sh); err != nil {
+			return err
+		}
+		if slot.fido2Credential != nil {
+			if err = writeSized(w, slot.fido2Credential); err != nil {
+				return err
+			}
+		}
+		if slot.threshold > 0 {
+			if err = slot.writeApprovers(w); err != nil {
+				return err
+			}
+		}
+		if slot.gpgRecipient != "" {
+			if err = writeSized(w, []byte(slot.gpgRecipient)); err != nil {
+				return err
+			}
+			err = writeSized(w, slot.wrapped)
+		} else if slot.pkcs11Label != "" {
+			if err = writeSized(w, []byte(slot.pkcs11Label)); err != nil {
+				return err
+			}
+			err = writeSized(w, slot.wrapped)
+		} else if slot.tpm {
+			err = writeSized(w, slot.wrapped)
+		} else {
+			_, err = w.Write(slot.wrapped)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err = w.Write(dataKey); err != nil {
+		return err
+	}
+	_, err = out.Write(append(buf.Bytes(), v.headerMAC(buf.Bytes(), v.data)...))
+	return err
+}
+
+// writeFile writes the vault to `w`: its header, the hidden section and its
+// encrypted data. A hidden vault is written inside its outer vault.
+func (v *Vault) writeFile(w io.Writer) error {
+	if v.outer != nil {
+		return v.writeInOuter(w)
+	}
+	if err := v.writeHeader(w); err != nil {
+		return err
+	}
+	if v.hidden == nil {
+		v.hidden = hiddenFiller()
+	}
+	if err := writeHiddenSection(w, v.hidden); err != nil {
+		return err
+	}
+	_, err := w.Write(v.data)
+	return err
+}
+
+// readSized reads a 16-bit length followed by that many bytes from `r`,
+// returning ErrCouldNotDecrypt if the length is zero or more than `max`.
+func readSized(r io.Reader, max int) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, ErrCouldNotDecrypt
+	}
+	if length == 0 || int(length) > max {
+		return nil, ErrCouldNotDecrypt
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, ErrCouldNotDecrypt
+	}
+	return b, nil
+}
+
+// writeSized writes the 16-bit length of `b` followed by `b` to `w`.
+func writeSized(w io.Writer, b []byte) error {
+	if err := binary.Write(w, binary.BigEndian, uint16(len(b))); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
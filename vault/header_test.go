@@ -0,0 +1,223 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// payloadData returns the encoded payload of `v`, as the first payload
+// version, which was written before headers were authenticated.
+func payloadData(t *testing.T, v *Vault) []byte {
+	p, err := v.decrypt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Meta.Version = 1
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(p); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// legacyData returns the contents of `v` encrypted under `passphrase` as they
+// were before the cipher header was introduced: nonce||secretbox, with the
+// nonce as the salt.
+func legacyData(t *testing.T, v *Vault, passphrase string) []byte {
+	nonce := randomSalt()
+	key, err := v.KDFParams().derive(passphrase, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secretbox.Seal(nonce[:], payloadData(t, v), &nonce, &key)
+}
+
+func TestOpenWithoutHeader(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile("pass.db", legacyData(t, v, "testpass"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = vopen.Get("testlocation"); err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, vaultMagic[:]) {
+		t.Fatal("vault without a header was not upgraded on save")
+	}
+}
+
+func TestOpenVersion1Header(t *testing.T) {
+	relaxDefaults(t)
+	params := KDFParams{KDF: KDFArgon2id, Time: 1, Memory: 64, Threads: 1}
+	v, err := NewWithOptions("testpass", Options{KDF: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = binary.Write(&buf, binary.BigEndian, &vaultHeader{Magic: vaultMagic, Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	kh := params.header()
+	if err = binary.Write(&buf, binary.BigEndian, &kh); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(legacyData(t, v, "testpass"))
+	if err = ioutil.WriteFile("pass.db", buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vopen.KDFParams() != params || vopen.Cipher() != CipherXSalsa20Poly1305 {
+		t.Fatal("version 1 header was not read correctly")
+	}
+	if _, err = vopen.Get("testlocation"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenVersion2Header(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{Cipher: CipherAES256GCM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = binary.Write(&buf, binary.BigEndian, &vaultHeader{Magic: vaultMagic, Version: 2}); err != nil {
+		t.Fatal(err)
+	}
+	kh := v.KDFParams().header()
+	if err = binary.Write(&buf, binary.BigEndian, &kh); err != nil {
+		t.Fatal(err)
+	}
+	ch := cipherHeader{Cipher: uint32(CipherAES256GCM), Salt: randomSalt()}
+	if err = binary.Write(&buf, binary.BigEndian, &ch); err != nil {
+		t.Fatal(err)
+	}
+	key, err := v.KDFParams().derive("testpass", ch.Salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCipher(CipherAES256GCM, &key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, c.NonceSize())
+	buf.Write(c.Seal(nonce, nonce, payloadData(t, v), nil))
+	if err = ioutil.WriteFile("pass.db", buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if _, err = Open("pass.db", "wrongpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("version 2 vault was opened with the wrong passphrase")
+	}
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vopen.Cipher() != CipherAES256GCM {
+		t.Fatal("version 2 header was not read correctly")
+	}
+	if _, err = vopen.Get("testlocation"); err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "testpass"); err != nil {
+		t.Fatal("version 2 vault could not be opened after upgrading:", err)
+	}
+}
+
+func TestUnsupportedVaultVersion(t *testing.T) {
+	h := vaultHeader{Magic: vaultMagic, Version: vaultVersion + 1}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.BigEndian, &h); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := readHeader(buf.Bytes())
+	unsupported, ok := err.(*UnsupportedVersionError)
+	if !ok || !errors.Is(err, ErrUnsupportedVaultVersion) || unsupported.Version != vaultVersion+1 || unsupported.MaxVersion != vaultVersion {
+		t.Fatal("expected an UnsupportedVersionError, got", err)
+	}
+}
+
+func TestAuthenticatedHeader(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	data, err := ioutil.ReadFile("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh, _, err := readHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	macOffset := len(fh.authenticated)
+
+	tampered := append([]byte{}, data...)
+	tampered[macOffset] ^= 1
+	if err = ioutil.WriteFile("pass.db", tampered, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "testpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected a vault with a bad header MAC to fail to open, got", err)
+	}
+
+	// Rewriting the version to strip the MAC, along with the compression
+	// that version did not have, is detected too.
+	compressionOffset := binary.Size(vaultHeader{}) + binary.Size(keyHeader{})
+	downgraded := append([]byte{}, data[:compressionOffset]...)
+	downgraded = append(downgraded, data[compressionOffset+4:macOffset]...)
+	downgraded = append(downgraded, data[macOffset+headerMACSize:]...)
+	binary.BigEndian.PutUint32(downgraded[len(vaultMagic):], 6)
+	if err = ioutil.WriteFile("pass.db", downgraded, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "testpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected a vault with a stripped header MAC to fail to open, got", err)
+	}
+}
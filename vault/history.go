@@ -0,0 +1,76 @@
+package vault
+
+import (
+	"errors"
+	"time"
+)
+
+// maxHistory is the maximum number of previous versions kept for each
+// credential. The oldest versions are discarded first.
+const maxHistory = 20
+
+// ErrNoSuchVersion is returned from Restore if the requested version does not
+// exist in the credential's history.
+var ErrNoSuchVersion = errors.New("credential does not have the specified version")
+
+// CredentialVersion is a previous version of a credential, along with the
+// time at which it was replaced.
+type CredentialVersion struct {
+	Credential Credential
+	ReplacedAt time.Time
+}
+
+// archive returns the history of `c` with the current version of `c`
+// appended to it.
+func (c *Credential) archive() []CredentialVersion {
+	version := *c
+	version.History = nil
+	version.GeneratedPasswords = nil
+
+	history := append(c.History, CredentialVersion{
+		Credential: version,
+		ReplacedAt: time.Now(),
+	})
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	return history
+}
+
+// History returns the previous versions of the credential at `location`,
+// oldest first.
+func (v *Vault) History(location string) ([]CredentialVersion, error) {
+	cred, err := v.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	return cred.History, nil
+}
+
+// Restore replaces the credential at `location` with the version at
+// `versionIndex` in its History. The current version is kept in the history,
+// so a Restore can itself be undone.
+func (v *Vault) Restore(location string, versionIndex int) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	location = p.resolve(location)
+	cred, err := p.credential(location)
+	if err != nil {
+		return err
+	}
+	if versionIndex < 0 || versionIndex >= len(cred.History) {
+		return ErrNoSuchVersion
+	}
+
+	restored := cred.History[versionIndex].Credential
+	restored.History = cred.archive()
+	restored.GeneratedPasswords = cred.GeneratedPasswords
+	restored.CreatedAt = cred.CreatedAt
+	restored.UpdatedAt = time.Now()
+	p.Credentials[location] = &restored
+
+	return v.encrypt(p)
+}
@@ -0,0 +1,104 @@
+package vault
+
+import (
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = v.History("testlocation"); err != ErrNoSuchCredential {
+		t.Fatal("expected History on non-existant location to return ErrNoSuchCredential")
+	}
+
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Edit("testlocation", Credential{Username: "testuser", Password: "testpass2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Edit("testlocation", Credential{Username: "testuser", Password: "testpass3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := v.History("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 previous versions, got %v", len(history))
+	}
+	if history[0].Credential.Password != "testpass1" || history[1].Credential.Password != "testpass2" {
+		t.Fatal("History did not return previous versions oldest first")
+	}
+	if history[0].ReplacedAt.IsZero() {
+		t.Fatal("History did not record when the version was replaced")
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxHistory+5; i++ {
+		err = v.Edit("testlocation", Credential{Username: "testuser", Password: "testpass"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	history, err := v.History("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != maxHistory {
+		t.Fatalf("expected history to be limited to %v versions, got %v", maxHistory, len(history))
+	}
+}
+
+func TestRestore(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Edit("testlocation", Credential{Username: "testuser", Password: "testpass2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.Restore("testlocation", 1); err != ErrNoSuchVersion {
+		t.Fatal("expected Restore of a non-existant version to return ErrNoSuchVersion")
+	}
+
+	err = v.Restore("testlocation", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpass1" {
+		t.Fatal("Restore did not restore the previous password")
+	}
+	if len(cred.History) != 2 || cred.History[1].Credential.Password != "testpass2" {
+		t.Fatal("Restore did not keep the replaced version in the history")
+	}
+}
@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNoHOTP is returned from HOTP if the credential does not have an
+	// HOTP secret.
+	ErrNoHOTP = errors.New("credential does not have an hotp secret")
+	// ErrInvalidHOTP is returned from HOTP if the credential's HOTP secret
+	// cannot be parsed.
+	ErrInvalidHOTP = errors.New("invalid hotp secret")
+)
+
+// HOTP returns the next RFC 4226 counter-based one-time password for the
+// credential at `location`, and increments the credential's HOTPCounter so
+// that each code is only generated once. The vault must be saved to persist
+// the new counter. The credential's HOTPSecret may be a base32 encoded key,
+// or an otpauth:// URI specifying the algorithm, number of digits, and the
+// initial counter, which is used if it is ahead of HOTPCounter.
+func (v *Vault) HOTP(location string) (string, error) {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return "", err
+	}
+
+	location = p.resolve(location)
+	cred, err := p.credential(location)
+	if err != nil {
+		return "", err
+	}
+	if cred.HOTPSecret == "" {
+		return "", ErrNoHOTP
+	}
+	params, err := parseOTP(cred.HOTPSecret)
+	if err != nil {
+		return "", ErrInvalidHOTP
+	}
+
+	counter := cred.HOTPCounter
+	if params.counter > counter {
+		counter = params.counter
+	}
+	code := params.hotp(counter)
+	cred.HOTPCounter = counter + 1
+
+	if err = v.encrypt(p); err != nil {
+		return "", err
+	}
+	return code, nil
+}
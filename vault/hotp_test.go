@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"testing"
+)
+
+// RFC 4226 appendix D test values, for the key "12345678901234567890".
+var hotpCodes = []string{
+	"755224", "287082", "359152", "969429", "338314",
+	"254676", "287922", "162583", "399871", "520489",
+}
+
+func TestHOTP(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("server", Credential{Username: "testuser", HOTPSecret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range hotpCodes {
+		code, err := v.HOTP("server")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Fatalf("expected code %v for counter %v, got %v", want, i, code)
+		}
+	}
+	cred, err := v.Get("server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.HOTPCounter != uint64(len(hotpCodes)) {
+		t.Fatal("HOTP did not persist the counter")
+	}
+}
+
+func TestHOTPInitialCounter(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("server", Credential{HOTPSecret: "otpauth://hotp/test?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("bank", Credential{HOTPSecret: "not base32!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := v.HOTP("server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != hotpCodes[5] {
+		t.Fatal("HOTP did not start from the initial counter")
+	}
+	code, err = v.HOTP("server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != hotpCodes[6] {
+		t.Fatal("HOTP did not increment the counter past the initial counter")
+	}
+
+	if _, err = v.HOTP("bank"); err != ErrInvalidHOTP {
+		t.Fatal("expected ErrInvalidHOTP, got", err)
+	}
+	if err = v.Add("totp", Credential{TOTPSecret: "JBSWY3DPEHPK3PXP"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.HOTP("totp"); err != ErrNoHOTP {
+		t.Fatal("expected ErrNoHOTP, got", err)
+	}
+}
@@ -0,0 +1,232 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// hybridStanzaType is the type of the age stanzas written by a
+	// HybridRecipient. Standard age tools do not know it, so only
+	// masterkey can decrypt an age vault encrypted to one.
+	hybridStanzaType = "masterkey-mlkem768x25519"
+
+	// hybridLabel is the HKDF info of the key wrapping an age file key.
+	hybridLabel = "masterkey.mlkem768x25519"
+
+	// hybridRecipientPrefix and hybridIdentityPrefix start the encodings
+	// of a HybridRecipient and a HybridIdentity.
+	hybridRecipientPrefix = "masterkey-pq1"
+	hybridIdentityPrefix  = "MASTERKEY-PQ-SECRET-KEY-1"
+
+	// ageFileKeySize is the size of the file key age wraps to each
+	// recipient.
+	ageFileKeySize = 16
+)
+
+// ErrInvalidHybridKey is returned if a hybrid recipient or identity cannot be
+// parsed.
+var ErrInvalidHybridKey = errors.New("invalid post-quantum hybrid key")
+
+type (
+	// HybridRecipient is an age recipient that wraps the file key using
+	// both X25519 and ML-KEM-768, so that it stays confidential unless
+	// both are broken, including by a quantum computer built after the
+	// file was stored. It is used like an X25519 recipient, such as with
+	// NewAge.
+	HybridRecipient struct {
+		mlkem  *mlkem.EncapsulationKey768
+		x25519 *ecdh.PublicKey
+	}
+
+	// HybridIdentity is the secret key of a HybridRecipient, which
+	// unwraps the file keys wrapped to it.
+	HybridIdentity struct {
+		seed   []byte
+		mlkem  *mlkem.DecapsulationKey768
+		x25519 *ecdh.PrivateKey
+	}
+)
+
+// hybridWrappingKey derives the key wrapping a file key from the ML-KEM and
+// X25519 shared secrets, binding it to the ML-KEM ciphertext and both X25519
+// public keys so that neither half can be swapped out.
+func hybridWrappingKey(mlkemShared []byte, x25519Shared []byte, ciphertext []byte, ephemeral []byte, recipient []byte) ([]byte, error) {
+	ikm := append(append([]byte{}, mlkemShared...), x25519Shared...)
+	defer wipe(ikm)
+	salt := append(append(append([]byte{}, ciphertext...), ephemeral...), recipient...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte(hybridLabel)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GenerateHybridIdentity generates a new random HybridIdentity.
+func GenerateHybridIdentity() (*HybridIdentity, error) {
+	seed := make([]byte, mlkem.SeedSize+32)
+	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+		return nil, err
+	}
+	return newHybridIdentity(seed)
+}
+
+// newHybridIdentity returns the HybridIdentity whose ML-KEM seed and X25519
+// private key are `seed`.
+func newHybridIdentity(seed []byte) (*HybridIdentity, error) {
+	if len(seed) != mlkem.SeedSize+32 {
+		return nil, ErrInvalidHybridKey
+	}
+	dk, err := mlkem.NewDecapsulationKey768(seed[:mlkem.SeedSize])
+	if err != nil {
+		return nil, ErrInvalidHybridKey
+	}
+	sk, err := ecdh.X25519().NewPrivateKey(seed[mlkem.SeedSize:])
+	if err != nil {
+		return nil, ErrInvalidHybridKey
+	}
+	return &HybridIdentity{seed: seed, mlkem: dk, x25519: sk}, nil
+}
+
+// ParseHybridIdentity parses an identity encoded by HybridIdentity.String.
+func ParseHybridIdentity(s string) (*HybridIdentity, error) {
+	if !strings.HasPrefix(s, hybridIdentityPrefix) {
+		return nil, ErrInvalidHybridKey
+	}
+	seed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, hybridIdentityPrefix))
+	if err != nil {
+		return nil, ErrInvalidHybridKey
+	}
+	return newHybridIdentity(seed)
+}
+
+// String encodes the identity, which is secret.
+func (i *HybridIdentity) String() string {
+	return hybridIdentityPrefix + base64.RawURLEncoding.EncodeToString(i.seed)
+}
+
+// Recipient returns the recipient of the identity.
+func (i *HybridIdentity) Recipient() *HybridRecipient {
+	return &HybridRecipient{mlkem: i.mlkem.EncapsulationKey(), x25519: i.x25519.PublicKey()}
+}
+
+// Unwrap unwraps the file key of the first of `stanzas` wrapped to the
+// identity's recipient, returning age.ErrIncorrectIdentity if there is none.
+func (i *HybridIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	recipient := i.x25519.PublicKey().Bytes()
+	for _, stanza := range stanzas {
+		if stanza.Type != hybridStanzaType || len(stanza.Args) != 1 {
+			continue
+		}
+		ephemeral, err := base64.RawStdEncoding.DecodeString(stanza.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		if len(stanza.Body) != mlkem.CiphertextSize768+ageFileKeySize+chacha20poly1305.Overhead {
+			return nil, errors.New("invalid hybrid stanza")
+		}
+		ciphertext, wrapped := stanza.Body[:mlkem.CiphertextSize768], stanza.Body[mlkem.CiphertextSize768:]
+
+		mlkemShared, err := i.mlkem.Decapsulate(ciphertext)
+		if err != nil {
+			return nil, err
+		}
+		peer, err := ecdh.X25519().NewPublicKey(ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		x25519Shared, err := i.x25519.ECDH(peer)
+		if err != nil {
+			return nil, err
+		}
+		key, err := hybridWrappingKey(mlkemShared, x25519Shared, ciphertext, ephemeral, recipient)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := chacha20poly1305.New(key)
+		wipe(key)
+		if err != nil {
+			return nil, err
+		}
+		// ML-KEM decapsulation never fails, so a stanza wrapped to
+		// another recipient fails to decrypt instead.
+		fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+		if err != nil {
+			continue
+		}
+		return fileKey, nil
+	}
+	return nil, age.ErrIncorrectIdentity
+}
+
+// ParseHybridRecipient parses a recipient encoded by HybridRecipient.String.
+func ParseHybridRecipient(s string) (*HybridRecipient, error) {
+	if !strings.HasPrefix(s, hybridRecipientPrefix) {
+		return nil, ErrInvalidHybridKey
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, hybridRecipientPrefix))
+	if err != nil || len(b) != mlkem.EncapsulationKeySize768+32 {
+		return nil, ErrInvalidHybridKey
+	}
+	ek, err := mlkem.NewEncapsulationKey768(b[:mlkem.EncapsulationKeySize768])
+	if err != nil {
+		return nil, ErrInvalidHybridKey
+	}
+	pk, err := ecdh.X25519().NewPublicKey(b[mlkem.EncapsulationKeySize768:])
+	if err != nil {
+		return nil, ErrInvalidHybridKey
+	}
+	return &HybridRecipient{mlkem: ek, x25519: pk}, nil
+}
+
+// String encodes the recipient, which can be shared.
+func (r *HybridRecipient) String() string {
+	var b bytes.Buffer
+	b.Write(r.mlkem.Bytes())
+	b.Write(r.x25519.Bytes())
+	return hybridRecipientPrefix + base64.RawURLEncoding.EncodeToString(b.Bytes())
+}
+
+// Wrap wraps `fileKey` to the recipient, encapsulating a shared secret to its
+// ML-KEM key and agreeing another with its X25519 key using an ephemeral key.
+// The stanza holds the ephemeral X25519 public key, followed in its body by
+// the ML-KEM ciphertext and the wrapped file key.
+func (r *HybridRecipient) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	mlkemShared, ciphertext := r.mlkem.Encapsulate()
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	x25519Shared, err := ephemeral.ECDH(r.x25519)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hybridWrappingKey(mlkemShared, x25519Shared, ciphertext, ephemeral.PublicKey().Bytes(), r.x25519.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(key)
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	// Each wrapping key is used once, so the nonce can be fixed, as age
+	// does for X25519 recipients.
+	wrapped := aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+	return []*age.Stanza{{
+		Type: hybridStanzaType,
+		Args: []string{base64.RawStdEncoding.EncodeToString(ephemeral.PublicKey().Bytes())},
+		Body: append(ciphertext, wrapped...),
+	}}, nil
+}
@@ -0,0 +1,75 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestHybridRecipient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "masterkey-hybrid-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "vault.age")
+
+	identity, err := GenerateHybridIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedIdentity, err := ParseHybridIdentity(identity.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := ParseHybridRecipient(identity.Recipient().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipient.String() != parsedIdentity.Recipient().String() {
+		t.Fatal("parsed identity has a different recipient")
+	}
+	if _, err = ParseHybridRecipient("masterkey-pq1AAAA"); err != ErrInvalidHybridKey {
+		t.Fatal("expected ErrInvalidHybridKey, got", err)
+	}
+
+	// Hybrid recipients can be mixed with X25519 recipients.
+	classic, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewAge(recipient, classic.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := GenerateHybridIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenAge(filename, []age.Identity{other}, []age.Recipient{other.Recipient()}); err == nil {
+		t.Fatal("expected OpenAge to fail with the wrong identity")
+	}
+	for _, id := range []age.Identity{parsedIdentity, classic} {
+		vopen, err := OpenAge(filename, []age.Identity{id}, []age.Recipient{recipient})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cred, err := vopen.Get("testlocation")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cred.Password != "testpassword" {
+			t.Fatal("credential did not survive the hybrid round trip")
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KindIdentity is personal information used for filling in forms, stored in
+// Identity.
+const KindIdentity Kind = "identity"
+
+// Identity is personal information used for filling in forms.
+type Identity struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address,omitempty"`
+	Phone   string `json:"phone,omitempty"`
+	Email   string `json:"email,omitempty"`
+	// Documents are the numbers of identity documents, such as a passport
+	// or driving license, keyed by the name of the document.
+	Documents map[string]string `json:"documents,omitempty"`
+}
+
+// Display formats the identity for display, one field per line, followed by
+// its documents sorted by name. Unless `reveal` is true, the document numbers
+// are masked.
+func (identity Identity) Display(reveal bool) string {
+	var lines []string
+	add := func(name, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("%v: %v", name, value))
+		}
+	}
+	add("Name", identity.Name)
+	add("Address", identity.Address)
+	add("Phone", identity.Phone)
+	add("Email", identity.Email)
+
+	documents := make([]string, 0, len(identity.Documents))
+	for document := range identity.Documents {
+		documents = append(documents, document)
+	}
+	sort.Strings(documents)
+	for _, document := range documents {
+		number := identity.Documents[document]
+		if !reveal {
+			number = mask(number)
+		}
+		add(document, number)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// AddIdentity adds the identity `identity` at `location`.
+func (v *Vault) AddIdentity(location string, identity Identity) error {
+	return v.Add(location, Credential{Kind: KindIdentity, Identity: &identity})
+}
+
+// GetIdentity retrieves the identity at `location`. ErrWrongKind is returned
+// if the entry at `location` is not an identity.
+func (v *Vault) GetIdentity(location string) (*Identity, error) {
+	cred, err := v.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if cred.kind() != KindIdentity || cred.Identity == nil {
+		return nil, ErrWrongKind
+	}
+	return cred.Identity, nil
+}
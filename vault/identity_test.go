@@ -0,0 +1,47 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIdentities(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity := Identity{
+		Name:      "Test User",
+		Address:   "1 Main St, Springfield",
+		Phone:     "555-0100",
+		Email:     "test@example.com",
+		Documents: map[string]string{"passport": "X1234567"},
+	}
+	if err = v.AddIdentity("me", identity); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := v.GetIdentity("me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*stored, identity) {
+		t.Fatal("GetIdentity did not return the stored identity")
+	}
+
+	masked := stored.Display(false)
+	if masked != "Name: Test User\nAddress: 1 Main St, Springfield\nPhone: 555-0100\nEmail: test@example.com\npassport: ••••••••" {
+		t.Fatalf("unexpected masked display %q", masked)
+	}
+	if revealed := stored.Display(true); revealed != "Name: Test User\nAddress: 1 Main St, Springfield\nPhone: 555-0100\nEmail: test@example.com\npassport: X1234567" {
+		t.Fatalf("unexpected revealed display %q", revealed)
+	}
+
+	if err = v.AddNote("wifi", "body"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.GetIdentity("wifi"); err != ErrWrongKind {
+		t.Fatal("expected ErrWrongKind for a note, got", err)
+	}
+}
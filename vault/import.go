@@ -0,0 +1,72 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ImportReport describes the result of importing credentials into a vault.
+type ImportReport struct {
+	// Imported are the locations of the credentials that were added to
+	// the vault.
+	Imported []string
+	// Skipped are the locations that were not imported because they
+	// already exist in the vault or appear more than once in the import.
+	Skipped []string
+}
+
+// add adds `cred` at `location` as part of an import, recording the outcome
+// in `report`. Existing locations are skipped rather than overwritten.
+func (report *ImportReport) add(tx *Tx, location string, cred Credential) error {
+	err := tx.Add(location, cred)
+	if err == ErrCredentialExists {
+		report.Skipped = append(report.Skipped, location)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	report.Imported = append(report.Imported, location)
+	return nil
+}
+
+// importLocation returns the location of an imported credential titled
+// `title` in `folder`. Folder separators in the title are replaced so that it
+// remains a single segment, and untitled credentials are named after the host
+// of `url`.
+func importLocation(folder, title, url string) string {
+	title = strings.Replace(title, FolderSeparator, "-", -1)
+	if title == "" {
+		title = normalizeHost(url)
+	}
+	if title == "" {
+		title = "untitled"
+	}
+	if folder == "" {
+		return title
+	}
+	return folder + FolderSeparator + title
+}
+
+// importField sets the custom field `key` of an imported credential to
+// `value`, numbering the key if it is already in use. Empty values are
+// ignored.
+func (c *Credential) importField(key, value string) {
+	if value == "" {
+		return
+	}
+	if key == "" {
+		key = "field"
+	}
+	if c.Fields == nil {
+		c.Fields = make(map[string]string)
+	}
+	unique := key
+	for i := 2; ; i++ {
+		if _, exists := c.Fields[unique]; !exists {
+			break
+		}
+		unique = fmt.Sprintf("%v (%v)", key, i)
+	}
+	c.Fields[unique] = value
+}
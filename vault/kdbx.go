@@ -0,0 +1,849 @@
+package vault
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20/salsa"
+)
+
+const (
+	kdbxSignature1 = 0x9AA2D903
+	kdbxSignature2 = 0xB54BFB67
+
+	// outer header field ids
+	kdbxEndOfHeader         = 0
+	kdbxCipherID            = 2
+	kdbxCompressionFlags    = 3
+	kdbxMasterSeed          = 4
+	kdbxTransformSeed       = 5
+	kdbxTransformRounds     = 6
+	kdbxEncryptionIV        = 7
+	kdbxProtectedStreamKey  = 8
+	kdbxStreamStartBytes    = 9
+	kdbxInnerRandomStreamID = 10
+	kdbxKdfParameters       = 11
+
+	// inner header field ids (KDBX 4)
+	kdbxInnerEnd       = 0
+	kdbxInnerStreamID  = 1
+	kdbxInnerStreamKey = 2
+
+	// inner random stream ids
+	kdbxStreamSalsa20  = 2
+	kdbxStreamChaCha20 = 3
+)
+
+var (
+	kdbxCipherAES      = mustDecodeHex("31c1f2e6bf714350be5805216afc5aff")
+	kdbxCipherChaCha20 = mustDecodeHex("d6038a2b8b6f4cb5a524339a31dbb59a")
+
+	kdbxKdfAES      = mustDecodeHex("c9d9f39a628a4460bf740d08c18a4fea")
+	kdbxKdfAES4     = mustDecodeHex("7c02bb8279a74ac0927d114a00648238")
+	kdbxKdfArgon2id = mustDecodeHex("9e298b1956db4773b23dfc3ec6f0a1e6")
+
+	kdbxSalsa20Nonce = []byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A}
+
+	// ErrInvalidKDBX is returned from ImportKDBX if the input is not a
+	// KeePass 2.x database or is corrupt.
+	ErrInvalidKDBX = errors.New("input is not a valid KeePass 2.x database")
+
+	// ErrUnsupportedKDBX is returned from ImportKDBX if the database uses a
+	// cipher, key derivation function, or version that is not supported.
+	// Argon2d and Twofish databases are not supported; they can be
+	// converted to Argon2id or AES-KDF with AES-256 or ChaCha20 in KeePass.
+	ErrUnsupportedKDBX = errors.New("KeePass database uses an unsupported version, cipher, or key derivation function")
+)
+
+// mustDecodeHex decodes the hex string `s`, panicking on failure.
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// kdbxHeader holds the fields of a KDBX outer header needed for decryption.
+type kdbxHeader struct {
+	major uint16
+
+	cipherID    []byte
+	compressed  bool
+	masterSeed  []byte
+	iv          []byte
+	kdfParams   map[string][]byte
+	streamStart []byte
+
+	// KDBX 3.1 only
+	transformSeed   []byte
+	transformRounds uint64
+	streamKey       []byte
+	streamID        uint32
+
+	// raw is the header exactly as it appeared in the file, used to
+	// verify the KDBX 4 header hash and HMAC.
+	raw []byte
+}
+
+// readKDBXHeader reads the outer header of a KDBX database from `r`.
+func readKDBXHeader(r io.Reader) (*kdbxHeader, error) {
+	var raw bytes.Buffer
+	r = io.TeeReader(r, &raw)
+
+	var preamble struct {
+		Sig1, Sig2   uint32
+		Minor, Major uint16
+	}
+	if err := binary.Read(r, binary.LittleEndian, &preamble); err != nil {
+		return nil, ErrInvalidKDBX
+	}
+	if preamble.Sig1 != kdbxSignature1 || preamble.Sig2 != kdbxSignature2 {
+		return nil, ErrInvalidKDBX
+	}
+	if preamble.Major != 3 && preamble.Major != 4 {
+		return nil, ErrUnsupportedKDBX
+	}
+
+	h := &kdbxHeader{major: preamble.Major}
+	for {
+		var id uint8
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return nil, ErrInvalidKDBX
+		}
+		if h.major == 3 {
+			var size16 uint16
+			if err := binary.Read(r, binary.LittleEndian, &size16); err != nil {
+				return nil, ErrInvalidKDBX
+			}
+			size = uint32(size16)
+		} else if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, ErrInvalidKDBX
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, ErrInvalidKDBX
+		}
+
+		switch id {
+		case kdbxEndOfHeader:
+			h.raw = raw.Bytes()
+			return h, nil
+		case kdbxCipherID:
+			h.cipherID = data
+		case kdbxCompressionFlags:
+			h.compressed = len(data) == 4 && binary.LittleEndian.Uint32(data) == 1
+		case kdbxMasterSeed:
+			h.masterSeed = data
+		case kdbxTransformSeed:
+			h.transformSeed = data
+		case kdbxTransformRounds:
+			if len(data) != 8 {
+				return nil, ErrInvalidKDBX
+			}
+			h.transformRounds = binary.LittleEndian.Uint64(data)
+		case kdbxEncryptionIV:
+			h.iv = data
+		case kdbxProtectedStreamKey:
+			h.streamKey = data
+		case kdbxStreamStartBytes:
+			h.streamStart = data
+		case kdbxInnerRandomStreamID:
+			if len(data) != 4 {
+				return nil, ErrInvalidKDBX
+			}
+			h.streamID = binary.LittleEndian.Uint32(data)
+		case kdbxKdfParameters:
+			params, err := readVariantDictionary(data)
+			if err != nil {
+				return nil, err
+			}
+			h.kdfParams = params
+		}
+	}
+}
+
+// readVariantDictionary decodes a KDBX 4 VariantDictionary into a map of
+// keys to raw little-endian values.
+func readVariantDictionary(data []byte) (map[string][]byte, error) {
+	r := bytes.NewReader(data)
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil || version>>8 != 1 {
+		return nil, ErrInvalidKDBX
+	}
+
+	dict := make(map[string][]byte)
+	for {
+		var typ uint8
+		if err := binary.Read(r, binary.LittleEndian, &typ); err != nil {
+			return nil, ErrInvalidKDBX
+		}
+		if typ == 0 {
+			return dict, nil
+		}
+
+		var keyLen, valueLen uint32
+		if err := binary.Read(r, binary.LittleEndian, &keyLen); err != nil || int(keyLen) > r.Len() {
+			return nil, ErrInvalidKDBX
+		}
+		key := make([]byte, keyLen)
+		io.ReadFull(r, key)
+		if err := binary.Read(r, binary.LittleEndian, &valueLen); err != nil || int(valueLen) > r.Len() {
+			return nil, ErrInvalidKDBX
+		}
+		value := make([]byte, valueLen)
+		io.ReadFull(r, value)
+		dict[string(key)] = value
+	}
+}
+
+// kdbxUint reads a little-endian unsigned integer of 4 or 8 bytes.
+func kdbxUint(b []byte) (uint64, error) {
+	switch len(b) {
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b)), nil
+	case 8:
+		return binary.LittleEndian.Uint64(b), nil
+	}
+	return 0, ErrInvalidKDBX
+}
+
+// aesKDF transforms `key` by encrypting it `rounds` times with AES-256 in
+// ECB mode under `seed`, as used by KDBX 3.1 and the KDBX 4 AES-KDF.
+func aesKDF(key []byte, seed []byte, rounds uint64) ([]byte, error) {
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, ErrInvalidKDBX
+	}
+	transformed := append([]byte(nil), key...)
+	for i := uint64(0); i < rounds; i++ {
+		block.Encrypt(transformed[:16], transformed[:16])
+		block.Encrypt(transformed[16:], transformed[16:])
+	}
+	sum := sha256.Sum256(transformed)
+	return sum[:], nil
+}
+
+// transformKey applies the database's key derivation function to the
+// composite key.
+func (h *kdbxHeader) transformKey(compositeKey []byte) ([]byte, error) {
+	if h.major == 3 {
+		return aesKDF(compositeKey, h.transformSeed, h.transformRounds)
+	}
+
+	uuid := h.kdfParams["$UUID"]
+	switch {
+	case bytes.Equal(uuid, kdbxKdfAES), bytes.Equal(uuid, kdbxKdfAES4):
+		rounds, err := kdbxUint(h.kdfParams["R"])
+		if err != nil {
+			return nil, err
+		}
+		return aesKDF(compositeKey, h.kdfParams["S"], rounds)
+	case bytes.Equal(uuid, kdbxKdfArgon2id):
+		iterations, err := kdbxUint(h.kdfParams["I"])
+		if err != nil {
+			return nil, err
+		}
+		memory, err := kdbxUint(h.kdfParams["M"])
+		if err != nil {
+			return nil, err
+		}
+		parallelism, err := kdbxUint(h.kdfParams["P"])
+		if err != nil {
+			return nil, err
+		}
+		return argon2.IDKey(compositeKey, h.kdfParams["S"], uint32(iterations), uint32(memory/1024), uint8(parallelism), 32), nil
+	}
+	return nil, ErrUnsupportedKDBX
+}
+
+// decryptPayload decrypts `ciphertext` using the cipher identified in the
+// header.
+func (h *kdbxHeader) decryptPayload(key []byte, ciphertext []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(h.cipherID, kdbxCipherAES):
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(h.iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+			return nil, ErrInvalidKDBX
+		}
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCBCDecrypter(block, h.iv).CryptBlocks(plaintext, ciphertext)
+
+		padding := int(plaintext[len(plaintext)-1])
+		if padding == 0 || padding > aes.BlockSize {
+			return nil, ErrCouldNotDecrypt
+		}
+		return plaintext[:len(plaintext)-padding], nil
+	case bytes.Equal(h.cipherID, kdbxCipherChaCha20):
+		stream, err := chacha20.NewUnauthenticatedCipher(key, h.iv)
+		if err != nil {
+			return nil, ErrInvalidKDBX
+		}
+		plaintext := make([]byte, len(ciphertext))
+		stream.XORKeyStream(plaintext, ciphertext)
+		return plaintext, nil
+	}
+	return nil, ErrUnsupportedKDBX
+}
+
+// readHashedBlocks reads a KDBX 3.1 hashed block stream.
+func readHashedBlocks(r io.Reader) ([]byte, error) {
+	var out bytes.Buffer
+	for {
+		var block struct {
+			Index uint32
+			Hash  [32]byte
+			Size  uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &block); err != nil {
+			return nil, ErrInvalidKDBX
+		}
+		if block.Size == 0 {
+			return out.Bytes(), nil
+		}
+		data := make([]byte, block.Size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, ErrInvalidKDBX
+		}
+		if sha256.Sum256(data) != block.Hash {
+			return nil, ErrInvalidKDBX
+		}
+		out.Write(data)
+	}
+}
+
+// kdbxBlockKey returns the HMAC key for the KDBX 4 block at `index`.
+func kdbxBlockKey(hmacKey []byte, index uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], index)
+	sum := sha512.Sum512(append(buf[:], hmacKey...))
+	return sum[:]
+}
+
+// readHMACBlocks reads and authenticates a KDBX 4 HMAC block stream.
+func readHMACBlocks(r io.Reader, hmacKey []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for index := uint64(0); ; index++ {
+		var block struct {
+			MAC  [32]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &block); err != nil {
+			return nil, ErrInvalidKDBX
+		}
+		data := make([]byte, block.Size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, ErrInvalidKDBX
+		}
+
+		mac := hmac.New(sha256.New, kdbxBlockKey(hmacKey, index))
+		binary.Write(mac, binary.LittleEndian, index)
+		binary.Write(mac, binary.LittleEndian, block.Size)
+		mac.Write(data)
+		if !hmac.Equal(mac.Sum(nil), block.MAC[:]) {
+			return nil, ErrInvalidKDBX
+		}
+
+		if block.Size == 0 {
+			return out.Bytes(), nil
+		}
+		out.Write(data)
+	}
+}
+
+// salsa20Stream is a cipher.Stream generating a continuous Salsa20
+// keystream, used to protect values in KDBX 3.1 databases.
+type salsa20Stream struct {
+	key     [32]byte
+	counter [16]byte
+	block   [64]byte
+	used    int
+}
+
+// newSalsa20Stream returns a Salsa20 keystream for `key` and the 8 byte
+// `nonce`.
+func newSalsa20Stream(key [32]byte, nonce []byte) *salsa20Stream {
+	s := &salsa20Stream{key: key, used: 64}
+	copy(s.counter[:8], nonce)
+	return s
+}
+
+// XORKeyStream implements cipher.Stream.
+func (s *salsa20Stream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == len(s.block) {
+			var zero [64]byte
+			salsa.XORKeyStream(s.block[:], zero[:], &s.counter, &s.key)
+			binary.LittleEndian.PutUint64(s.counter[8:], binary.LittleEndian.Uint64(s.counter[8:])+1)
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.block[s.used]
+		s.used++
+	}
+}
+
+// newInnerStream returns the stream used to protect values in the database
+// XML.
+func newInnerStream(id uint32, key []byte) (cipher.Stream, error) {
+	switch id {
+	case kdbxStreamSalsa20:
+		return newSalsa20Stream(sha256.Sum256(key), kdbxSalsa20Nonce), nil
+	case kdbxStreamChaCha20:
+		sum := sha512.Sum512(key)
+		return chacha20.NewUnauthenticatedCipher(sum[:32], sum[32:44])
+	}
+	return nil, ErrUnsupportedKDBX
+}
+
+// readKDBXInnerHeader reads the KDBX 4 inner header from `r`, returning the
+// inner stream id and key.
+func readKDBXInnerHeader(r io.Reader) (uint32, []byte, error) {
+	var streamID uint32
+	var streamKey []byte
+	for {
+		var field struct {
+			ID   uint8
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &field); err != nil {
+			return 0, nil, ErrInvalidKDBX
+		}
+		data := make([]byte, field.Size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return 0, nil, ErrInvalidKDBX
+		}
+		switch field.ID {
+		case kdbxInnerEnd:
+			return streamID, streamKey, nil
+		case kdbxInnerStreamID:
+			if len(data) != 4 {
+				return 0, nil, ErrInvalidKDBX
+			}
+			streamID = binary.LittleEndian.Uint32(data)
+		case kdbxInnerStreamKey:
+			streamKey = data
+		}
+	}
+}
+
+// decryptKDBX decrypts the KDBX database read from `r` and returns its XML
+// document along with the stream protecting values inside it.
+func decryptKDBX(r io.Reader, passphrase string) ([]byte, cipher.Stream, error) {
+	h, err := readKDBXHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	passwordHash := sha256.Sum256([]byte(passphrase))
+	compositeKey := sha256.Sum256(passwordHash[:])
+	transformed, err := h.transformKey(compositeKey[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	keyHash := sha256.Sum256(append(append([]byte(nil), h.masterSeed...), transformed...))
+	key := keyHash[:]
+
+	var payload []byte
+	if h.major == 3 {
+		ciphertext, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		plaintext, err := h.decryptPayload(key, ciphertext)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(plaintext) < len(h.streamStart) || !bytes.Equal(plaintext[:len(h.streamStart)], h.streamStart) {
+			return nil, nil, ErrCouldNotDecrypt
+		}
+		payload, err = readHashedBlocks(bytes.NewReader(plaintext[len(h.streamStart):]))
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		var check struct {
+			Hash [32]byte
+			MAC  [32]byte
+		}
+		if err = binary.Read(r, binary.LittleEndian, &check); err != nil {
+			return nil, nil, ErrInvalidKDBX
+		}
+		if sha256.Sum256(h.raw) != check.Hash {
+			return nil, nil, ErrInvalidKDBX
+		}
+		hmacKeyHash := sha512.Sum512(append(append(append([]byte(nil), h.masterSeed...), transformed...), 1))
+		mac := hmac.New(sha256.New, kdbxBlockKey(hmacKeyHash[:], ^uint64(0)))
+		mac.Write(h.raw)
+		if !hmac.Equal(mac.Sum(nil), check.MAC[:]) {
+			return nil, nil, ErrCouldNotDecrypt
+		}
+
+		ciphertext, err := readHMACBlocks(r, hmacKeyHash[:])
+		if err != nil {
+			return nil, nil, err
+		}
+		payload, err = h.decryptPayload(key, ciphertext)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if h.compressed {
+		gz, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, nil, ErrInvalidKDBX
+		}
+		if payload, err = ioutil.ReadAll(gz); err != nil {
+			return nil, nil, ErrInvalidKDBX
+		}
+	}
+
+	streamID, streamKey := h.streamID, h.streamKey
+	xmlReader := bytes.NewReader(payload)
+	if h.major == 4 {
+		if streamID, streamKey, err = readKDBXInnerHeader(xmlReader); err != nil {
+			return nil, nil, err
+		}
+	}
+	stream, err := newInnerStream(streamID, streamKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	document, err := ioutil.ReadAll(xmlReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return document, stream, nil
+}
+
+// kdbxEntry is an entry parsed from the database XML.
+type kdbxEntry struct {
+	strings  map[string]string
+	tags     string
+	created  time.Time
+	modified time.Time
+	expires  bool
+	expiry   time.Time
+	history  []*kdbxEntry
+}
+
+// kdbxTime parses a KeePass timestamp, which is ISO 8601 in KDBX 3.1 and the
+// base64 encoded number of seconds since 0001-01-01 in KDBX 4.
+func kdbxTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != 8 {
+		return time.Time{}
+	}
+	seconds := int64(binary.LittleEndian.Uint64(b))
+	const unixOffset = 62135596800 // seconds from 0001-01-01 to 1970-01-01
+	return time.Unix(seconds-unixOffset, 0).UTC()
+}
+
+// credential converts the entry to a Credential. Standard KeePass fields map
+// to their Credential equivalents; all other strings become custom fields.
+func (e *kdbxEntry) credential() Credential {
+	c := Credential{
+		CreatedAt: e.created,
+		UpdatedAt: e.modified,
+	}
+	if e.expires {
+		c.ExpiresAt = e.expiry
+	}
+	for key, value := range e.strings {
+		switch key {
+		case "Title":
+		case "UserName":
+			c.Username = value
+		case "Password":
+			c.Password = value
+		case "URL":
+			c.URL = value
+		case "Notes":
+			c.Notes = value
+		case "otp":
+			c.TOTPSecret = value
+		default:
+			if c.Fields == nil {
+				c.Fields = make(map[string]string)
+			}
+			c.Fields[key] = value
+		}
+	}
+	for _, tag := range strings.FieldsFunc(e.tags, func(r rune) bool { return r == ';' || r == ',' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			c.Tags = append(c.Tags, tag)
+		}
+	}
+	for i, version := range e.history {
+		replacedAt := e.modified
+		if i+1 < len(e.history) {
+			replacedAt = e.history[i+1].modified
+		}
+		c.History = append(c.History, CredentialVersion{Credential: version.credential(), ReplacedAt: replacedAt})
+	}
+	return c
+}
+
+// kdbxParser parses the XML document of a KeePass database, unprotecting
+// values in document order as required by the inner random stream.
+type kdbxParser struct {
+	decoder *xml.Decoder
+	stream  cipher.Stream
+
+	recycleBin string
+}
+
+// text reads the character data of the element started by `start`,
+// unprotecting it if it is marked as protected.
+func (p *kdbxParser) text(start xml.StartElement) (string, error) {
+	var value string
+	if err := p.decoder.DecodeElement(&value, &start); err != nil {
+		return "", ErrInvalidKDBX
+	}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "Protected" && strings.EqualFold(attr.Value, "true") {
+			ciphertext, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return "", ErrInvalidKDBX
+			}
+			plaintext := make([]byte, len(ciphertext))
+			p.stream.XORKeyStream(plaintext, ciphertext)
+			return string(plaintext), nil
+		}
+	}
+	return value, nil
+}
+
+// walk parses elements until the end of the current element, calling `fn`
+// for each child element. `fn` must consume the element it is passed.
+func (p *kdbxParser) walk(fn func(start xml.StartElement) error) error {
+	for {
+		tok, err := p.decoder.Token()
+		if err != nil {
+			return ErrInvalidKDBX
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err = fn(t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// skip consumes the element started by `start`, unprotecting any protected
+// values inside it so the inner stream stays in sync.
+func (p *kdbxParser) skip(start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "Protected" {
+			_, err := p.text(start)
+			return err
+		}
+	}
+	return p.walk(p.skip)
+}
+
+// entry parses an Entry element.
+func (p *kdbxParser) entry() (*kdbxEntry, error) {
+	e := &kdbxEntry{strings: make(map[string]string)}
+	err := p.walk(func(start xml.StartElement) error {
+		switch start.Name.Local {
+		case "String":
+			var key, value string
+			err := p.walk(func(start xml.StartElement) error {
+				var err error
+				switch start.Name.Local {
+				case "Key":
+					key, err = p.text(start)
+				case "Value":
+					value, err = p.text(start)
+				default:
+					err = p.skip(start)
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if value != "" {
+				e.strings[key] = value
+			}
+			return nil
+		case "Tags":
+			var err error
+			e.tags, err = p.text(start)
+			return err
+		case "Times":
+			return p.walk(func(start xml.StartElement) error {
+				if start.Name.Local != "CreationTime" && start.Name.Local != "LastModificationTime" &&
+					start.Name.Local != "ExpiryTime" && start.Name.Local != "Expires" {
+					return p.skip(start)
+				}
+				value, err := p.text(start)
+				if err != nil {
+					return err
+				}
+				switch start.Name.Local {
+				case "CreationTime":
+					e.created = kdbxTime(value)
+				case "LastModificationTime":
+					e.modified = kdbxTime(value)
+				case "ExpiryTime":
+					e.expiry = kdbxTime(value)
+				case "Expires":
+					e.expires = strings.EqualFold(value, "true")
+				}
+				return nil
+			})
+		case "History":
+			return p.walk(func(start xml.StartElement) error {
+				if start.Name.Local != "Entry" {
+					return p.skip(start)
+				}
+				version, err := p.entry()
+				if err != nil {
+					return err
+				}
+				e.history = append(e.history, version)
+				return nil
+			})
+		}
+		return p.skip(start)
+	})
+	return e, err
+}
+
+// group parses a Group element, calling `fn` with each entry and the folder
+// it belongs to. The root group's name is not included in folders.
+func (p *kdbxParser) group(folder string, root bool, fn func(folder string, e *kdbxEntry) error) error {
+	var name, uuid string
+	return p.walk(func(start xml.StartElement) error {
+		switch start.Name.Local {
+		case "UUID":
+			var err error
+			uuid, err = p.text(start)
+			return err
+		case "Name":
+			var err error
+			name, err = p.text(start)
+			if err != nil {
+				return err
+			}
+			if !root {
+				name = strings.Replace(name, FolderSeparator, "-", -1)
+				if folder != "" {
+					folder += FolderSeparator
+				}
+				folder += name
+			}
+			return nil
+		case "Entry":
+			e, err := p.entry()
+			if err != nil {
+				return err
+			}
+			if uuid != "" && uuid == p.recycleBin {
+				return nil
+			}
+			return fn(folder, e)
+		case "Group":
+			if uuid != "" && uuid == p.recycleBin {
+				return p.skip(start)
+			}
+			return p.group(folder, false, fn)
+		}
+		return p.skip(start)
+	})
+}
+
+// parse parses the KeePassFile document, calling `fn` for every entry outside
+// of the recycle bin.
+func (p *kdbxParser) parse(fn func(folder string, e *kdbxEntry) error) error {
+	for {
+		tok, err := p.decoder.Token()
+		if err != nil {
+			return ErrInvalidKDBX
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "KeePassFile" {
+			break
+		}
+	}
+	return p.walk(func(start xml.StartElement) error {
+		switch start.Name.Local {
+		case "Meta":
+			return p.walk(func(start xml.StartElement) error {
+				if start.Name.Local != "RecycleBinUUID" {
+					return p.skip(start)
+				}
+				var err error
+				p.recycleBin, err = p.text(start)
+				return err
+			})
+		case "Root":
+			return p.walk(func(start xml.StartElement) error {
+				if start.Name.Local != "Group" {
+					return p.skip(start)
+				}
+				return p.group("", true, fn)
+			})
+		}
+		return p.skip(start)
+	})
+}
+
+// ImportKDBX imports the entries of the KeePass 2.x (KDBX 3.1 or 4) database
+// read from `r`, unlocked using `passphrase`. Groups become folder prefixes
+// of each entry's location, and an entry's title is the last segment of its
+// location. Notes, URLs, tags, timestamps, history, and custom string fields
+// are preserved. Entries in the recycle bin are not imported, and existing
+// locations are skipped. Key files are not supported.
+func (v *Vault) ImportKDBX(r io.Reader, passphrase string) (ImportReport, error) {
+	var report ImportReport
+
+	document, stream, err := decryptKDBX(r, passphrase)
+	if err != nil {
+		return report, err
+	}
+
+	parser := &kdbxParser{
+		decoder: xml.NewDecoder(bytes.NewReader(document)),
+		stream:  stream,
+	}
+	err = v.Batch(func(tx *Tx) error {
+		return parser.parse(func(folder string, e *kdbxEntry) error {
+			location := importLocation(folder, e.strings["Title"], e.strings["URL"])
+			return report.add(tx, location, e.credential())
+		})
+	})
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	return report, nil
+}
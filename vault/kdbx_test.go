@@ -0,0 +1,420 @@
+package vault
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20"
+)
+
+// kdbxFixture describes a KeePass database written by writeKDBX.
+type kdbxFixture struct {
+	major    uint16
+	cipherID []byte
+	kdf      []byte
+	streamID uint32
+	compress bool
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// writeKDBXField writes a header field with a 2 (KDBX 3.1) or 4 (KDBX 4) byte
+// size.
+func writeKDBXField(buf *bytes.Buffer, major uint16, id uint8, data []byte) {
+	buf.WriteByte(id)
+	if major == 3 {
+		binary.Write(buf, binary.LittleEndian, uint16(len(data)))
+	} else {
+		binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	}
+	buf.Write(data)
+}
+
+func writeVariantItem(buf *bytes.Buffer, typ uint8, key string, value []byte) {
+	buf.WriteByte(typ)
+	binary.Write(buf, binary.LittleEndian, uint32(len(key)))
+	buf.WriteString(key)
+	binary.Write(buf, binary.LittleEndian, uint32(len(value)))
+	buf.Write(value)
+}
+
+func uint32Bytes(n uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, n)
+	return b
+}
+
+func uint64Bytes(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, n)
+	return b
+}
+
+func encryptKDBXPayload(t *testing.T, cipherID []byte, key []byte, iv []byte, plaintext []byte) []byte {
+	if bytes.Equal(cipherID, kdbxCipherChaCha20) {
+		stream, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext := make([]byte, len(plaintext))
+		stream.XORKeyStream(ciphertext, plaintext)
+		return ciphertext
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	plaintext = append(plaintext, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+	return ciphertext
+}
+
+// writeKDBX builds a KeePass database protected by `passphrase` containing
+// the XML returned by `document`, which must call `protect` on each
+// protected value in document order.
+func writeKDBX(t *testing.T, f kdbxFixture, passphrase string, document func(protect func(string) string) string) []byte {
+	masterSeed := randomBytes(t, 32)
+	seed := randomBytes(t, 32)
+	streamKey := randomBytes(t, 32)
+	streamStart := randomBytes(t, 32)
+	iv := randomBytes(t, 16)
+	if bytes.Equal(f.cipherID, kdbxCipherChaCha20) {
+		iv = randomBytes(t, 12)
+	}
+	const rounds = 100
+
+	passwordHash := sha256.Sum256([]byte(passphrase))
+	compositeKey := sha256.Sum256(passwordHash[:])
+	var transformed []byte
+	if bytes.Equal(f.kdf, kdbxKdfArgon2id) {
+		transformed = argon2.IDKey(compositeKey[:], seed, 1, 1024, 1, 32)
+	} else {
+		var err error
+		if transformed, err = aesKDF(compositeKey[:], seed, rounds); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keyHash := sha256.Sum256(append(append([]byte(nil), masterSeed...), transformed...))
+
+	stream, err := newInnerStream(f.streamID, streamKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := []byte(document(func(value string) string {
+		ciphertext := make([]byte, len(value))
+		stream.XORKeyStream(ciphertext, []byte(value))
+		return base64.StdEncoding.EncodeToString(ciphertext)
+	}))
+
+	var header bytes.Buffer
+	binary.Write(&header, binary.LittleEndian, []uint32{kdbxSignature1, kdbxSignature2})
+	binary.Write(&header, binary.LittleEndian, []uint16{1, f.major})
+	writeKDBXField(&header, f.major, kdbxCipherID, f.cipherID)
+	compression := uint32(0)
+	if f.compress {
+		compression = 1
+	}
+	writeKDBXField(&header, f.major, kdbxCompressionFlags, uint32Bytes(compression))
+	writeKDBXField(&header, f.major, kdbxMasterSeed, masterSeed)
+	writeKDBXField(&header, f.major, kdbxEncryptionIV, iv)
+
+	compress := func(data []byte) []byte {
+		if !f.compress {
+			return data
+		}
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(data)
+		gz.Close()
+		return buf.Bytes()
+	}
+
+	if f.major == 3 {
+		writeKDBXField(&header, f.major, kdbxTransformSeed, seed)
+		writeKDBXField(&header, f.major, kdbxTransformRounds, uint64Bytes(rounds))
+		writeKDBXField(&header, f.major, kdbxProtectedStreamKey, streamKey)
+		writeKDBXField(&header, f.major, kdbxStreamStartBytes, streamStart)
+		writeKDBXField(&header, f.major, kdbxInnerRandomStreamID, uint32Bytes(f.streamID))
+		writeKDBXField(&header, f.major, kdbxEndOfHeader, []byte("\r\n\r\n"))
+
+		data := compress(doc)
+		hash := sha256.Sum256(data)
+		var blocks bytes.Buffer
+		blocks.Write(streamStart)
+		binary.Write(&blocks, binary.LittleEndian, uint32(0))
+		blocks.Write(hash[:])
+		binary.Write(&blocks, binary.LittleEndian, uint32(len(data)))
+		blocks.Write(data)
+		binary.Write(&blocks, binary.LittleEndian, uint32(1))
+		blocks.Write(make([]byte, 32))
+		binary.Write(&blocks, binary.LittleEndian, uint32(0))
+
+		return append(header.Bytes(), encryptKDBXPayload(t, f.cipherID, keyHash[:], iv, blocks.Bytes())...)
+	}
+
+	var params bytes.Buffer
+	binary.Write(&params, binary.LittleEndian, uint16(0x0100))
+	writeVariantItem(&params, 0x42, "$UUID", f.kdf)
+	writeVariantItem(&params, 0x42, "S", seed)
+	if bytes.Equal(f.kdf, kdbxKdfArgon2id) {
+		writeVariantItem(&params, 0x04, "P", uint32Bytes(1))
+		writeVariantItem(&params, 0x05, "M", uint64Bytes(1024*1024))
+		writeVariantItem(&params, 0x05, "I", uint64Bytes(1))
+		writeVariantItem(&params, 0x04, "V", uint32Bytes(0x13))
+	} else {
+		writeVariantItem(&params, 0x05, "R", uint64Bytes(rounds))
+	}
+	params.WriteByte(0)
+	writeKDBXField(&header, f.major, kdbxKdfParameters, params.Bytes())
+	writeKDBXField(&header, f.major, kdbxEndOfHeader, []byte("\r\n\r\n"))
+
+	hmacKeyHash := sha512.Sum512(append(append(append([]byte(nil), masterSeed...), transformed...), 1))
+	headerHash := sha256.Sum256(header.Bytes())
+	headerMAC := hmac.New(sha256.New, kdbxBlockKey(hmacKeyHash[:], ^uint64(0)))
+	headerMAC.Write(header.Bytes())
+
+	var inner bytes.Buffer
+	writeKDBXField(&inner, f.major, kdbxInnerStreamID, uint32Bytes(f.streamID))
+	writeKDBXField(&inner, f.major, kdbxInnerStreamKey, streamKey)
+	writeKDBXField(&inner, f.major, kdbxInnerEnd, nil)
+	inner.Write(doc)
+	ciphertext := encryptKDBXPayload(t, f.cipherID, keyHash[:], iv, compress(inner.Bytes()))
+
+	out := bytes.NewBuffer(header.Bytes())
+	out.Write(headerHash[:])
+	out.Write(headerMAC.Sum(nil))
+	for index, data := range [][]byte{ciphertext, nil} {
+		mac := hmac.New(sha256.New, kdbxBlockKey(hmacKeyHash[:], uint64(index)))
+		binary.Write(mac, binary.LittleEndian, uint64(index))
+		binary.Write(mac, binary.LittleEndian, uint32(len(data)))
+		mac.Write(data)
+		out.Write(mac.Sum(nil))
+		binary.Write(out, binary.LittleEndian, uint32(len(data)))
+		out.Write(data)
+	}
+	return out.Bytes()
+}
+
+// testKDBXDocument is the database used by the KDBX tests. Times are written
+// in the KDBX 4 format if `v4` is true.
+func testKDBXDocument(v4 bool) func(protect func(string) string) string {
+	timestamp := func(t time.Time) string {
+		if !v4 {
+			return t.Format(time.RFC3339)
+		}
+		return base64.StdEncoding.EncodeToString(uint64Bytes(uint64(t.Unix() + 62135596800)))
+	}
+	created := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	modified := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	return func(protect func(string) string) string {
+		return `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<Generator>masterkey test</Generator>
+		<RecycleBinUUID>cmVjeWNsZWJpbnV1aWQ=</RecycleBinUUID>
+	</Meta>
+	<Root>
+		<Group>
+			<UUID>cm9vdHJvb3Ryb290cm9vdA==</UUID>
+			<Name>Database</Name>
+			<Entry>
+				<UUID>ZW50cnkxZW50cnkxZW50cg==</UUID>
+				<Times>
+					<CreationTime>` + timestamp(created) + `</CreationTime>
+					<LastModificationTime>` + timestamp(modified) + `</LastModificationTime>
+					<ExpiryTime>` + timestamp(expiry) + `</ExpiryTime>
+					<Expires>True</Expires>
+				</Times>
+				<String><Key>Title</Key><Value>bank</Value></String>
+				<String><Key>UserName</Key><Value>testuser</Value></String>
+				<String><Key>Password</Key><Value Protected="True">` + protect("testpass") + `</Value></String>
+				<String><Key>PIN</Key><Value Protected="True">` + protect("1234") + `</Value></String>
+				<String><Key>Notes</Key><Value>line one
+line two</Value></String>
+				<Tags>finance;personal</Tags>
+				<History>
+					<Entry>
+						<Times>
+							<LastModificationTime>` + timestamp(created) + `</LastModificationTime>
+						</Times>
+						<String><Key>Title</Key><Value>bank</Value></String>
+						<String><Key>Password</Key><Value Protected="True">` + protect("oldpass") + `</Value></String>
+					</Entry>
+				</History>
+			</Entry>
+			<Group>
+				<UUID>d29ya3dvcmt3b3Jrd29yaw==</UUID>
+				<Name>Work</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>github</Value></String>
+					<String><Key>Password</Key><Value Protected="True">` + protect("ghpass") + `</Value></String>
+					<String><Key>URL</Key><Value>https://github.com</Value></String>
+				</Entry>
+				<Group>
+					<UUID>YXdzYXdzYXdzYXdzYXdzYQ==</UUID>
+					<Name>AWS</Name>
+					<Entry>
+						<String><Key>Title</Key><Value>prod</Value></String>
+						<String><Key>Password</Key><Value Protected="True">` + protect("awspass") + `</Value></String>
+					</Entry>
+				</Group>
+			</Group>
+			<Group>
+				<UUID>cmVjeWNsZWJpbnV1aWQ=</UUID>
+				<Name>Recycle Bin</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>deleted</Value></String>
+					<String><Key>Password</Key><Value Protected="True">` + protect("deletedpass") + `</Value></String>
+				</Entry>
+			</Group>
+			<Group>
+				<UUID>bGFzdGxhc3RsYXN0bGFzdA==</UUID>
+				<Name>Personal</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>email</Value></String>
+					<String><Key>Password</Key><Value Protected="True">` + protect("emailpass") + `</Value></String>
+				</Entry>
+			</Group>
+		</Group>
+		<DeletedObjects/>
+	</Root>
+</KeePassFile>`
+	}
+}
+
+func checkKDBXImport(t *testing.T, v *Vault, report ImportReport) {
+	sort.Strings(report.Imported)
+	expected := []string{"Personal/email", "Work/AWS/prod", "Work/github", "bank"}
+	if !reflect.DeepEqual(report.Imported, expected) {
+		t.Fatalf("expected imported locations %v, got %v", expected, report.Imported)
+	}
+
+	cred, err := v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" || cred.Notes != "line one\nline two" {
+		t.Fatalf("ImportKDBX did not import the entry's standard fields: %v", cred)
+	}
+	if cred.Fields["PIN"] != "1234" {
+		t.Fatal("ImportKDBX did not import custom fields")
+	}
+	if !reflect.DeepEqual(cred.Tags, []string{"finance", "personal"}) {
+		t.Fatalf("ImportKDBX did not import tags, got %v", cred.Tags)
+	}
+	if !cred.CreatedAt.Equal(time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)) || !cred.ExpiresAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ImportKDBX did not import times, got created %v expires %v", cred.CreatedAt, cred.ExpiresAt)
+	}
+	if len(cred.History) != 1 || cred.History[0].Credential.Password != "oldpass" {
+		t.Fatal("ImportKDBX did not import history")
+	}
+
+	for location, password := range map[string]string{"Work/github": "ghpass", "Work/AWS/prod": "awspass", "Personal/email": "emailpass"} {
+		cred, err = v.Get(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cred.Password != password {
+			t.Fatalf("expected password %v at %v, got %v", password, location, cred.Password)
+		}
+	}
+}
+
+func TestImportKDBX3(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := writeKDBX(t, kdbxFixture{major: 3, cipherID: kdbxCipherAES, streamID: kdbxStreamSalsa20, compress: true}, "kdbxpass", testKDBXDocument(false))
+
+	if _, err = v.ImportKDBX(bytes.NewReader(db), "wrongpass"); err != ErrCouldNotDecrypt {
+		t.Fatalf("expected ImportKDBX with the wrong passphrase to return ErrCouldNotDecrypt, got %v", err)
+	}
+
+	report, err := v.ImportKDBX(bytes.NewReader(db), "kdbxpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKDBXImport(t, v, report)
+}
+
+func TestImportKDBX4(t *testing.T) {
+	relaxDefaults(t)
+	fixtures := []kdbxFixture{
+		{major: 4, cipherID: kdbxCipherChaCha20, kdf: kdbxKdfArgon2id, streamID: kdbxStreamChaCha20},
+		{major: 4, cipherID: kdbxCipherAES, kdf: kdbxKdfAES, streamID: kdbxStreamChaCha20, compress: true},
+	}
+	for _, fixture := range fixtures {
+		v, err := New("testpass")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db := writeKDBX(t, fixture, "kdbxpass", testKDBXDocument(true))
+		if _, err = v.ImportKDBX(bytes.NewReader(db), "wrongpass"); err != ErrCouldNotDecrypt {
+			t.Fatalf("expected ImportKDBX with the wrong passphrase to return ErrCouldNotDecrypt, got %v", err)
+		}
+
+		report, err := v.ImportKDBX(bytes.NewReader(db), "kdbxpass")
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkKDBXImport(t, v, report)
+	}
+}
+
+func TestImportKDBXInvalid(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.ImportKDBX(bytes.NewReader([]byte("not a keepass database")), "testpass"); err != ErrInvalidKDBX {
+		t.Fatal("expected ImportKDBX on invalid input to return ErrInvalidKDBX")
+	}
+}
+
+func TestSalsa20Stream(t *testing.T) {
+	var key [32]byte
+	copy(key[:], randomBytes(t, 32))
+	plaintext := randomBytes(t, 200)
+
+	expected := make([]byte, len(plaintext))
+	salsa20.XORKeyStream(expected, plaintext, kdbxSalsa20Nonce, &key)
+
+	stream := newSalsa20Stream(key, kdbxSalsa20Nonce)
+	actual := make([]byte, len(plaintext))
+	for _, chunk := range [][2]int{{0, 7}, {7, 64}, {64, 65}, {65, 200}} {
+		stream.XORKeyStream(actual[chunk[0]:chunk[1]], plaintext[chunk[0]:chunk[1]])
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Fatal("salsa20Stream did not produce a continuous Salsa20 keystream")
+	}
+}
@@ -0,0 +1,270 @@
+package vault
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF is a key derivation function used to derive a vault's key from its
+// passphrase.
+type KDF uint32
+
+const (
+	// KDFScrypt derives keys using scrypt. It is the default.
+	KDFScrypt KDF = iota
+	// KDFArgon2id derives keys using Argon2id, as recommended by RFC 9106.
+	KDFArgon2id
+)
+
+const (
+	// DefaultScryptN, DefaultScryptR and DefaultScryptP are the scrypt
+	// parameters used unless others are given, and by vaults written before
+	// the parameters were recorded.
+	DefaultScryptN = 16384
+	DefaultScryptR = 8
+	DefaultScryptP = 1
+
+	// maxScryptMemory bounds the memory, in bytes, a vault header may ask
+	// scrypt to use.
+	maxScryptMemory = 4 << 30
+
+	// DefaultArgon2Time, DefaultArgon2Memory and DefaultArgon2Threads are
+	// the Argon2id parameters used unless others are given: the second
+	// recommended option of RFC 9106.
+	DefaultArgon2Time    = 3
+	DefaultArgon2Memory  = 64 * 1024
+	DefaultArgon2Threads = 4
+
+	// maxArgon2Memory bounds the memory, in KiB, a vault header may ask
+	// Argon2id to use.
+	maxArgon2Memory = 4 * 1024 * 1024
+
+	// maxCalibratedScryptN and maxCalibratedArgon2Memory bound the memory
+	// chosen by calibration, 256 MiB, so that a vault calibrated on a fast
+	// machine can still be opened on a smaller one. Argon2id is calibrated
+	// further by its number of passes.
+	maxCalibratedScryptN      = 1 << 18
+	maxCalibratedArgon2Memory = 256 * 1024
+)
+
+// KDFTargetDuration is how long deriving the key of a new vault should take
+// on this machine when NewWithOptions is not given KDF parameters, which are
+// then chosen by calibrating the KDF. Zero uses the defaults without
+// calibrating.
+var KDFTargetDuration = time.Second
+
+// ErrInvalidKDFParams is returned if KDFParams name an unknown key derivation
+// function or parameters outside its limits.
+var ErrInvalidKDFParams = errors.New("invalid key derivation parameters")
+
+// KDFParams selects the key derivation function of a vault and its
+// parameters. The zero value selects scrypt.
+type KDFParams struct {
+	KDF KDF
+	// N, R and P apply to KDFScrypt, and are the CPU/memory cost, which
+	// must be a power of two, the block size, and the parallelization.
+	// Zero values are replaced by the defaults.
+	N int
+	R int
+	P int
+	// Time, Memory and Threads apply to KDFArgon2id, and are the
+	// number of passes, the memory used in KiB, and the degree of
+	// parallelism. Zero values are replaced by the defaults.
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// withDefaults returns `params` with any unset parameters replaced by their
+// defaults.
+func (params KDFParams) withDefaults() KDFParams {
+	if params.KDF == KDFScrypt {
+		if params.N == 0 {
+			params.N = DefaultScryptN
+		}
+		if params.R == 0 {
+			params.R = DefaultScryptR
+		}
+		if params.P == 0 {
+			params.P = DefaultScryptP
+		}
+	}
+	if params.KDF == KDFArgon2id {
+		if params.Time == 0 {
+			params.Time = DefaultArgon2Time
+		}
+		if params.Memory == 0 {
+			params.Memory = DefaultArgon2Memory
+		}
+		if params.Threads == 0 {
+			params.Threads = DefaultArgon2Threads
+		}
+	}
+	return params
+}
+
+// validate returns ErrInvalidKDFParams if `params` cannot be used.
+func (params KDFParams) validate() error {
+	switch params.KDF {
+	case KDFScrypt:
+		if params.N <= 1 || params.N&(params.N-1) != 0 || params.R <= 0 || params.P <= 0 {
+			return ErrInvalidKDFParams
+		}
+		if uint64(params.R)*uint64(params.P) >= 1<<30 || 128*uint64(params.N)*uint64(params.R) > maxScryptMemory {
+			return ErrInvalidKDFParams
+		}
+		return nil
+	case KDFArgon2id:
+		if params.Time == 0 || params.Threads == 0 || params.Memory < 8*uint32(params.Threads) || params.Memory > maxArgon2Memory {
+			return ErrInvalidKDFParams
+		}
+		return nil
+	default:
+		return ErrInvalidKDFParams
+	}
+}
+
+// derive derives the key for `passphrase` with the salt `salt`.
+func (params KDFParams) derive(passphrase string, salt [24]byte) ([32]byte, error) {
+	var secret [32]byte
+	switch params.KDF {
+	case KDFArgon2id:
+		key := argon2.IDKey([]byte(passphrase), salt[:], params.Time, params.Memory, params.Threads, keyLen)
+		copy(secret[:], key)
+	default:
+		key, err := scrypt.Key([]byte(passphrase), salt[:], params.N, params.R, params.P, keyLen)
+		if err != nil {
+			return secret, err
+		}
+		copy(secret[:], key)
+	}
+	return secret, nil
+}
+
+// CalibrateKDF benchmarks scrypt on this machine and returns the parameters
+// that derive a key in about `target`, so that vaults get stronger as
+// hardware gets faster. The parameters are never weaker than the defaults,
+// nor use more than 256 MiB of memory.
+func CalibrateKDF(target time.Duration) KDFParams {
+	return KDFParams{}.calibrate(target)
+}
+
+// calibrate returns `params`, with unset parameters replaced by the defaults,
+// with the cost of its KDF raised until deriving a key on this machine takes
+// about `target`. The time taken using the defaults is measured once, and
+// the time taken using higher costs extrapolated from it, as both KDFs take
+// time proportional to their cost.
+func (params KDFParams) calibrate(target time.Duration) KDFParams {
+	params = params.withDefaults()
+	if target <= 0 {
+		return params
+	}
+	start := time.Now()
+	if _, err := params.derive("calibration", [24]byte{}); err != nil {
+		return params
+	}
+	elapsed := time.Since(start)
+	for elapsed*2 <= target {
+		switch {
+		case params.KDF == KDFScrypt && params.N < maxCalibratedScryptN:
+			params.N *= 2
+		case params.KDF == KDFArgon2id && params.Memory < maxCalibratedArgon2Memory:
+			params.Memory *= 2
+		case params.KDF == KDFArgon2id:
+			params.Time++
+			elapsed += elapsed / time.Duration(params.Time-1)
+			continue
+		default:
+			return params
+		}
+		elapsed *= 2
+	}
+	return params
+}
+
+// header returns the file header recording `params`.
+func (params KDFParams) header() kdfHeader {
+	h := kdfHeader{KDF: uint32(params.KDF)}
+	switch params.KDF {
+	case KDFArgon2id:
+		h.Params = [3]uint32{params.Time, params.Memory, uint32(params.Threads)}
+	default:
+		h.Params = [3]uint32{uint32(params.N), uint32(params.R), uint32(params.P)}
+	}
+	return h
+}
+
+// kdfParams returns the KDF parameters recorded in the header.
+func (h kdfHeader) kdfParams() (KDFParams, error) {
+	params := KDFParams{KDF: KDF(h.KDF)}
+	switch params.KDF {
+	case KDFScrypt:
+		if h.Params[0] > 1<<30 || h.Params[1] > 1<<30 || h.Params[2] > 1<<30 {
+			return KDFParams{}, ErrInvalidKDFParams
+		}
+		params.N, params.R, params.P = int(h.Params[0]), int(h.Params[1]), int(h.Params[2])
+	case KDFArgon2id:
+		if h.Params[2] > 255 {
+			return KDFParams{}, ErrInvalidKDFParams
+		}
+		params.Time, params.Memory, params.Threads = h.Params[0], h.Params[1], uint8(h.Params[2])
+	}
+	if err := params.validate(); err != nil {
+		return KDFParams{}, err
+	}
+	return params, nil
+}
+
+// cost returns the relative cost of deriving a key using `params`, which is
+// comparable between parameters of the same KDF.
+func (params KDFParams) cost() uint64 {
+	if params.KDF == KDFArgon2id {
+		return uint64(params.Time) * uint64(params.Memory)
+	}
+	return uint64(params.N) * uint64(params.R) * uint64(params.P)
+}
+
+// Weaker returns true if keys derived using `params` are cheaper to guess
+// than those derived using `target`. Argon2id is considered stronger than
+// scrypt regardless of their parameters. Unset parameters of `target` are
+// replaced by the defaults.
+func (params KDFParams) Weaker(target KDFParams) bool {
+	target = target.withDefaults()
+	if params.KDF != target.KDF {
+		return target.KDF == KDFArgon2id
+	}
+	return params.cost() < target.cost()
+}
+
+// UpgradeKDF verifies that `passphrase` unlocks the vault, then re-derives the
+// key of the key slot the vault was unlocked with using `target`, so that the
+// next Save writes the vault using the stronger KDF without an export and
+// import. Only the slot is rewrapped: the master key is kept, so shares made
+// by SplitKey and cached unlock keys still unlock the vault. The passphrase
+// is needed because the vault never retains it. Unset parameters of `target`
+// are replaced by the defaults, and ErrInvalidKDFParams is returned if
+// `target` cannot be used.
+func (v *Vault) UpgradeKDF(passphrase string, target KDFParams) error {
+	target = target.withDefaults()
+	if err := target.validate(); err != nil {
+		return err
+	}
+	return v.rekey(passphrase, passphrase, target, false)
+}
+
+// KDFParams returns the key derivation function and parameters of the key
+// slot the vault was unlocked with. Vaults saved as age files have no key
+// slots, so the zero KDFParams is returned for them, and hidden vaults, whose
+// key is always derived in the same way, have none either.
+func (v *Vault) KDFParams() KDFParams {
+	if v.ageRecipients != nil {
+		return KDFParams{}
+	}
+	if v.outer != nil {
+		return hiddenKDF
+	}
+	return v.slots[v.slot].kdf
+}
@@ -0,0 +1,165 @@
+package vault
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestArgon2id(t *testing.T) {
+	relaxDefaults(t)
+	params := KDFParams{KDF: KDFArgon2id, Time: 1, Memory: 64, Threads: 1}
+	v, err := NewWithOptions("testpass", Options{KDF: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if _, err = Open("pass.db", "wrongpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt with the wrong passphrase, got", err)
+	}
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vopen.KDFParams() != params {
+		t.Fatalf("opened vault used %v rather than the KDF it was created with", vopen.KDFParams())
+	}
+	if _, err = vopen.Get("testlocation"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewWithOptions("testpass", Options{KDF: KDFParams{KDF: 42}}); err != ErrInvalidKDFParams {
+		t.Fatal("expected ErrInvalidKDFParams with an unknown KDF, got", err)
+	}
+	v, err = NewWithOptions("testpass", Options{KDF: KDFParams{KDF: KDFArgon2id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params := v.KDFParams(); params.Time != DefaultArgon2Time || params.Memory != DefaultArgon2Memory || params.Threads != DefaultArgon2Threads {
+		t.Fatalf("expected default Argon2id parameters, got %v", params)
+	}
+}
+
+func TestScryptParams(t *testing.T) {
+	relaxDefaults(t)
+	params := KDFParams{KDF: KDFScrypt, N: 1024, R: 4, P: 2}
+	v, err := NewWithOptions("testpass", Options{KDF: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vopen.KDFParams() != params {
+		t.Fatalf("opened vault used %v rather than the scrypt parameters it was created with", vopen.KDFParams())
+	}
+
+	v, err = New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params := v.KDFParams(); params.N != DefaultScryptN || params.R != DefaultScryptR || params.P != DefaultScryptP {
+		t.Fatalf("expected default scrypt parameters, got %v", params)
+	}
+	for _, params := range []KDFParams{{N: 1000}, {N: 1 << 30}, {R: -1}} {
+		if _, err = NewWithOptions("testpass", Options{KDF: params}); err != ErrInvalidKDFParams {
+			t.Fatalf("expected ErrInvalidKDFParams using %v, got %v", params, err)
+		}
+	}
+}
+
+func TestUpgradeKDF(t *testing.T) {
+	relaxDefaults(t)
+	weak := KDFParams{KDF: KDFScrypt, N: 1024, R: 8, P: 1}
+	v, err := NewWithOptions("testpass", Options{KDF: weak})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if !weak.Weaker(KDFParams{}) {
+		t.Fatal("expected N=1024 to be weaker than the default scrypt parameters")
+	}
+	if weak.Weaker(weak) {
+		t.Fatal("parameters should not be weaker than themselves")
+	}
+	if !weak.Weaker(KDFParams{KDF: KDFArgon2id}) {
+		t.Fatal("expected scrypt to be weaker than Argon2id")
+	}
+
+	target := KDFParams{KDF: KDFArgon2id, Time: 1, Memory: 64, Threads: 1}
+	if err = v.UpgradeKDF("wrongpass", target); err != ErrIncorrectPassphrase {
+		t.Fatal("expected ErrIncorrectPassphrase upgrading with the wrong passphrase, got", err)
+	}
+	master := v.keys.master
+	if err = v.UpgradeKDF("testpass", target); err != nil {
+		t.Fatal(err)
+	}
+	if v.keys.master != master {
+		t.Fatal("expected UpgradeKDF to keep the master key")
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vopen.KDFParams() != target {
+		t.Fatalf("expected upgraded parameters %v to be saved, got %v", target, vopen.KDFParams())
+	}
+	if _, err = vopen.Get("testlocation"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCalibrateKDF(t *testing.T) {
+	relaxDefaults(t)
+	defaults := KDFParams{}.withDefaults()
+	if params := CalibrateKDF(0); params != defaults {
+		t.Fatal("expected the defaults without a target duration, got", params)
+	}
+	if params := CalibrateKDF(time.Nanosecond); params != defaults {
+		t.Fatal("expected the defaults for a short target duration, got", params)
+	}
+	if params := CalibrateKDF(time.Hour); params.N != maxCalibratedScryptN || params.R != DefaultScryptR || params.P != DefaultScryptP {
+		t.Fatal("expected the memory of calibrated parameters to be bounded, got", params)
+	}
+
+	defer func(target time.Duration) { KDFTargetDuration = target }(KDFTargetDuration)
+	KDFTargetDuration = time.Nanosecond
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{KDF: KDFArgon2id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params := v.KDFParams(); params != (KDFParams{KDF: KDFArgon2id}).withDefaults() {
+		t.Fatal("expected calibrated Argon2id parameters to be no weaker than the defaults, got", params)
+	}
+}
+
+// TestNewCalibratesKDF runs with the default KDF target duration.
+func TestNewCalibratesKDF(t *testing.T) {
+	v, err := New("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params := v.KDFParams(); params.Weaker(KDFParams{}) {
+		t.Fatal("expected calibrated parameters to be no weaker than the defaults, got", params)
+	}
+}
@@ -0,0 +1,260 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// keepassTimeFormat is the format of times in KeePass 2 XML.
+const keepassTimeFormat = "2006-01-02T15:04:05Z"
+
+// keepassStandardFields are the string fields KeePass (and KeePassXC, for
+// "otp") gives special meaning to, and those used to export SSH keys, cards
+// and identities. Custom fields with these names are renamed on export.
+var keepassStandardFields = map[string]bool{
+	"Title":    true,
+	"UserName": true,
+	"Password": true,
+	"URL":      true,
+	"Notes":    true,
+	"otp":      true,
+
+	"SSH Private Key":    true,
+	"SSH Key Passphrase": true,
+	"SSH Public Key":     true,
+	"Card Number":        true,
+	"Card Holder":        true,
+	"Card Expiry":        true,
+	"Card CVV":           true,
+	"Card PIN":           true,
+	"Name":               true,
+	"Address":            true,
+	"Phone":              true,
+	"Email":              true,
+}
+
+type (
+	// keepassFile is the root element of a KeePass 2 XML document.
+	keepassFile struct {
+		XMLName xml.Name `xml:"KeePassFile"`
+		Meta    struct {
+			Generator    string
+			DatabaseName string
+		}
+		Root struct {
+			Group *keepassGroup
+		}
+	}
+
+	// keepassGroup is a KeePass group, corresponding to a folder.
+	keepassGroup struct {
+		UUID    string
+		Name    string
+		Entries []*keepassEntry `xml:"Entry"`
+		Groups  []*keepassGroup `xml:"Group"`
+	}
+
+	// keepassEntry is a KeePass entry, corresponding to a credential.
+	keepassEntry struct {
+		UUID  string
+		Tags  string `xml:",omitempty"`
+		Times struct {
+			CreationTime         string
+			LastModificationTime string
+			ExpiryTime           string
+			Expires              string
+		}
+		Strings []keepassString `xml:"String"`
+		History *keepassHistory `xml:",omitempty"`
+	}
+
+	// keepassHistory holds the previous versions of a KeePass entry.
+	keepassHistory struct {
+		Entries []*keepassEntry `xml:"Entry"`
+	}
+
+	// keepassString is a string field of a KeePass entry.
+	keepassString struct {
+		Key   string
+		Value struct {
+			ProtectInMemory string `xml:",attr,omitempty"`
+			Text            string `xml:",chardata"`
+		}
+	}
+)
+
+// keepassUUID returns a random KeePass UUID.
+func keepassUUID() string {
+	var uuid [16]byte
+	if _, err := io.ReadFull(rand.Reader, uuid[:]); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(uuid[:])
+}
+
+// keepassTime formats `t` for KeePass, using `fallback` if `t` is zero.
+func keepassTime(t, fallback time.Time) string {
+	if t.IsZero() {
+		t = fallback
+	}
+	return t.UTC().Format(keepassTimeFormat)
+}
+
+// setString adds the string field `key` to the entry, marking it protected
+// if `protect` is true. Empty values are omitted.
+func (e *keepassEntry) setString(key, value string, protect bool) {
+	if value == "" {
+		return
+	}
+	s := keepassString{Key: key}
+	s.Value.Text = value
+	if protect {
+		s.Value.ProtectInMemory = "True"
+	}
+	e.Strings = append(e.Strings, s)
+}
+
+// keepassEntry converts `c`, titled `title`, to a KeePass entry.
+func (c *Credential) keepassEntry(title string, now time.Time) *keepassEntry {
+	e := &keepassEntry{
+		UUID: keepassUUID(),
+		Tags: strings.Join(c.Tags, ";"),
+	}
+	e.Times.CreationTime = keepassTime(c.CreatedAt, now)
+	e.Times.LastModificationTime = keepassTime(c.UpdatedAt, now)
+	e.Times.ExpiryTime = keepassTime(c.ExpiresAt, now)
+	e.Times.Expires = "False"
+	if !c.ExpiresAt.IsZero() {
+		e.Times.Expires = "True"
+	}
+
+	e.setString("Title", title, false)
+	e.setString("UserName", c.Username, false)
+	e.setString("Password", c.Password, true)
+	e.setString("URL", c.URL, false)
+	e.setString("Notes", c.Notes, false)
+	e.setString("otp", c.TOTPSecret, true)
+	if c.SSHKey != nil {
+		e.setString("SSH Private Key", c.SSHKey.PrivateKey, true)
+		e.setString("SSH Key Passphrase", c.SSHKey.Passphrase, true)
+		e.setString("SSH Public Key", c.SSHKey.PublicKey, false)
+	}
+	if c.Card != nil {
+		e.setString("Card Number", c.Card.Number, true)
+		e.setString("Card Holder", c.Card.Holder, false)
+		e.setString("Card Expiry", c.Card.Expiry, false)
+		e.setString("Card CVV", c.Card.CVV, true)
+		e.setString("Card PIN", c.Card.PIN, true)
+	}
+	if c.Identity != nil {
+		e.setString("Name", c.Identity.Name, false)
+		e.setString("Address", c.Identity.Address, false)
+		e.setString("Phone", c.Identity.Phone, false)
+		e.setString("Email", c.Identity.Email, false)
+		documents := make([]string, 0, len(c.Identity.Documents))
+		for document := range c.Identity.Documents {
+			documents = append(documents, document)
+		}
+		sort.Strings(documents)
+		for _, document := range documents {
+			e.setString(document, c.Identity.Documents[document], true)
+		}
+	}
+
+	keys := make([]string, 0, len(c.Fields))
+	for key := range c.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := key
+		for i := 2; keepassStandardFields[name]; i++ {
+			name = fmt.Sprintf("%v (%v)", key, i)
+		}
+		e.setString(name, c.Fields[key], false)
+	}
+	return e
+}
+
+// ExportKeePassXML writes every credential in the vault to `w` as an
+// unencrypted KeePass 2 XML document, which KeePass and compatible password
+// managers can import. Folders become groups, and each credential's history,
+// tags and custom fields are preserved. The output contains every password
+// in the vault in plaintext and should be handled accordingly.
+func (v *Vault) ExportKeePassXML(w io.Writer) error {
+	p, err := v.decrypt()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	root := &keepassGroup{UUID: keepassUUID(), Name: "masterkey"}
+	groups := map[string]*keepassGroup{"": root}
+	var group func(folder string) *keepassGroup
+	group = func(folder string) *keepassGroup {
+		if g, ok := groups[folder]; ok {
+			return g
+		}
+		parent, name := "", folder
+		if i := strings.LastIndex(folder, FolderSeparator); i >= 0 {
+			parent, name = folder[:i], folder[i+len(FolderSeparator):]
+		}
+		g := &keepassGroup{UUID: keepassUUID(), Name: name}
+		parentGroup := group(parent)
+		parentGroup.Groups = append(parentGroup.Groups, g)
+		groups[folder] = g
+		return g
+	}
+
+	locations := make([]string, 0, len(p.Credentials))
+	for location := range p.Credentials {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	for _, location := range locations {
+		cred := p.Credentials[location]
+		folder, title := "", location
+		if i := strings.LastIndex(location, FolderSeparator); i >= 0 {
+			folder, title = location[:i], location[i+len(FolderSeparator):]
+		}
+
+		e := cred.keepassEntry(title, now)
+		if len(cred.History) > 0 {
+			e.History = &keepassHistory{}
+			for _, version := range cred.History {
+				previous := version.Credential
+				if previous.UpdatedAt.IsZero() {
+					previous.UpdatedAt = version.ReplacedAt
+				}
+				old := previous.keepassEntry(title, now)
+				old.UUID = e.UUID
+				e.History.Entries = append(e.History.Entries, old)
+			}
+		}
+		g := group(folder)
+		g.Entries = append(g.Entries, e)
+	}
+
+	doc := keepassFile{}
+	doc.Meta.Generator = "masterkey"
+	doc.Meta.DatabaseName = "masterkey"
+	doc.Root.Group = root
+
+	if _, err := io.WriteString(w, `<?xml version="1.0" encoding="utf-8" standalone="yes"?>`+"\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
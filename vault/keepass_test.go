@@ -0,0 +1,85 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportKeePassXML(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("work/email/gmail", Credential{
+		Username: "testuser",
+		Password: "test<pass>&",
+		URL:      "https://mail.google.com",
+		Notes:    "some notes",
+		Tags:     []string{"mail", "work"},
+		Fields:   map[string]string{"pin": "1234", "Title": "custom title"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Edit("work/email/gmail", Credential{Username: "testuser", Password: "newpass"}); err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err = v.Add("bank", Credential{Username: "testuser2", Password: "testpass2", ExpiresAt: expiry}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = v.ExportKeePassXML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<Value ProtectInMemory="True">newpass</Value>`) {
+		t.Fatal("ExportKeePassXML did not mark the password as protected")
+	}
+
+	// parse the export using the KDBX importer's XML parser.
+	entries := make(map[string]*kdbxEntry)
+	parser := &kdbxParser{decoder: xml.NewDecoder(&buf)}
+	err = parser.parse(func(folder string, e *kdbxEntry) error {
+		entries[importLocation(folder, e.strings["Title"], "")] = e
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v", len(entries))
+	}
+
+	gmail, ok := entries["work/email/gmail"]
+	if !ok {
+		t.Fatal("ExportKeePassXML did not export folders as groups")
+	}
+	cred := gmail.credential()
+	if cred.Username != "testuser" || cred.Password != "newpass" {
+		t.Fatal("ExportKeePassXML did not export the credential")
+	}
+	if len(cred.History) != 1 {
+		t.Fatal("ExportKeePassXML did not export history")
+	}
+	old := cred.History[0].Credential
+	if old.Password != "test<pass>&" || old.Notes != "some notes" || old.URL != "https://mail.google.com" {
+		t.Fatal("ExportKeePassXML did not export the previous version")
+	}
+	if !reflect.DeepEqual(old.Tags, []string{"mail", "work"}) {
+		t.Fatal("ExportKeePassXML did not export tags", old.Tags)
+	}
+	if !reflect.DeepEqual(old.Fields, map[string]string{"pin": "1234", "Title (2)": "custom title"}) {
+		t.Fatal("ExportKeePassXML did not export custom fields", old.Fields)
+	}
+
+	cred = entries["bank"].credential()
+	if cred.Password != "testpass2" || !cred.ExpiresAt.Equal(expiry) {
+		t.Fatal("ExportKeePassXML did not export the expiry")
+	}
+}
@@ -0,0 +1,367 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// keychainService is the service name the keys of vaults are cached under.
+const keychainService = "masterkey"
+
+// ErrNotCached is returned from OpenCached if no unexpired key is cached for
+// the vault.
+var ErrNotCached = errors.New("no key is cached for the vault")
+
+type (
+	// Keychain stores secrets in the operating system's credential store,
+	// keyed by account.
+	Keychain interface {
+		// Store stores `secret` for `account`, replacing any secret
+		// already stored for it.
+		Store(account string, secret string) error
+		// Load returns the secret stored for `account`, or ErrNotCached
+		// if there is none.
+		Load(account string) (string, error)
+		// Delete deletes the secret stored for `account`, if any.
+		Delete(account string) error
+	}
+
+	// SecretServiceKeychain is the freedesktop.org Secret Service, such as
+	// GNOME Keyring or KWallet, used through libsecret's secret-tool.
+	SecretServiceKeychain struct{}
+
+	// MacKeychain is the macOS login keychain, used through security(1).
+	MacKeychain struct{}
+
+	// DPAPIKeychain stores secrets in files in Dir, encrypted using the
+	// Windows Data Protection API so that only the current user can
+	// decrypt them.
+	DPAPIKeychain struct {
+		Dir string
+	}
+)
+
+// DefaultKeychain returns the credential store of the current operating
+// system.
+func DefaultKeychain() Keychain {
+	switch runtime.GOOS {
+	case "darwin":
+		return MacKeychain{}
+	case "windows":
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			dir = os.TempDir()
+		}
+		return DPAPIKeychain{Dir: filepath.Join(dir, keychainService)}
+	default:
+		return SecretServiceKeychain{}
+	}
+}
+
+// runKeychainTool runs `name` with `args`, writing `input` to its standard
+// input, and returns its output.
+func runKeychainTool(input string, name string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%v: %v: %v", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Store implements Keychain.
+func (SecretServiceKeychain) Store(account string, secret string) error {
+	_, err := runKeychainTool(secret, "secret-tool", "store", "--label", keychainService+" "+account, "service", keychainService, "account", account)
+	return err
+}
+
+// Load implements Keychain. secret-tool exits with an error both when the
+// secret does not exist and when it cannot be read, so either is reported as
+// ErrNotCached.
+func (SecretServiceKeychain) Load(account string) (string, error) {
+	secret, err := runKeychainTool("", "secret-tool", "lookup", "service", keychainService, "account", account)
+	if err != nil || secret == "" {
+		return "", ErrNotCached
+	}
+	return secret, nil
+}
+
+// Delete implements Keychain.
+func (SecretServiceKeychain) Delete(account string) error {
+	_, err := runKeychainTool("", "secret-tool", "clear", "service", keychainService, "account", account)
+	return err
+}
+
+// Store implements Keychain. security(1) only accepts the secret as an
+// argument, so the command is given to its interactive mode on its standard
+// input, rather than on its command line, where other processes could see
+// the secret.
+func (MacKeychain) Store(account string, secret string) error {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	command := fmt.Sprintf("add-generic-password -U -s %v -a \"%v\" -w \"%v\"\n", keychainService, quote.Replace(account), quote.Replace(secret))
+	_, err := runKeychainTool(command, "security", "-i")
+	return err
+}
+
+// Load implements Keychain.
+func (MacKeychain) Load(account string) (string, error) {
+	secret, err := runKeychainTool("", "security", "find-generic-password", "-s", keychainService, "-a", account, "-w")
+	if err != nil || secret == "" {
+		return "", ErrNotCached
+	}
+	return secret, nil
+}
+
+// Delete implements Keychain.
+func (MacKeychain) Delete(account string) error {
+	_, err := runKeychainTool("", "security", "delete-generic-password", "-s", keychainService, "-a", account)
+	return err
+}
+
+// dpapiScript is run by PowerShell to protect or unprotect the base64 encoded
+// standard input using the Data Protection API, writing the result in base64.
+const dpapiScript = `Add-Type -AssemblyName System.Security; ` +
+	`$in = [Convert]::FromBase64String([Console]::In.ReadToEnd().Trim()); ` +
+	`[Convert]::ToBase64String([Security.Cryptography.ProtectedData]::%v($in, $null, 'CurrentUser'))`
+
+// dpapi runs the Data Protection API function `function`, Protect or
+// Unprotect, on `data`.
+func dpapi(function string, data []byte) ([]byte, error) {
+	out, err := runKeychainTool(base64.StdEncoding.EncodeToString(data), "powershell", "-NoProfile", "-NonInteractive", "-Command", fmt.Sprintf(dpapiScript, function))
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out)
+}
+
+// path returns the file the secret of `account` is stored in.
+func (k DPAPIKeychain) path(account string) string {
+	sum := sha256.Sum256([]byte(account))
+	return filepath.Join(k.Dir, hex.EncodeToString(sum[:]))
+}
+
+// Store implements Keychain.
+func (k DPAPIKeychain) Store(account string, secret string) error {
+	protected, err := dpapi("Protect", []byte(secret))
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(k.Dir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(k.path(account), protected, 0600)
+}
+
+// Load implements Keychain.
+func (k DPAPIKeychain) Load(account string) (string, error) {
+	protected, err := ioutil.ReadFile(k.path(account))
+	if err != nil {
+		return "", ErrNotCached
+	}
+	secret, err := dpapi("Unprotect", protected)
+	if err != nil {
+		return "", ErrNotCached
+	}
+	return string(secret), nil
+}
+
+// Delete implements Keychain.
+func (k DPAPIKeychain) Delete(account string) error {
+	if err := os.Remove(k.path(account)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// cachedKey is the key of a vault cached by CacheKey: the vault's master key,
+// the key slot it was unlocked with, when the cached key expires, and the
+// vault's KeyCacheEpoch when it was cached. It is kept sealed by a random
+// session key in a file next to the vault, and only the session key is stored
+// in the keychain, so that neither reveals the master key on its own.
+type cachedKey struct {
+	Expires int64
+	Slot    uint32
+	Master  [32]byte
+	Epoch   [32]byte
+}
+
+// keychainAccount returns the account the key of the vault at `filename` is
+// cached under: its absolute path.
+func keychainAccount(filename string) (string, error) {
+	return filepath.Abs(filename)
+}
+
+// sessionPath returns the path of the file the cached key of the vault at
+// `filename` is sealed in.
+func sessionPath(filename string) string {
+	return filename + ".session"
+}
+
+// CacheKey caches the vault's master key for `ttl`, so that the vault saved
+// at `filename` can be opened using OpenCached without its passphrase or
+// other factors until then. A copy of the master key is sealed by a new
+// random session key in a file next to the vault, and the session key is
+// stored in `keychain`. The cached key no longer opens the vault once the
+// vault is saved after its master key is replaced, its data key rotated or
+// its passphrase changed.
+func (v *Vault) CacheKey(filename string, keychain Keychain, ttl time.Duration) error {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.ageRecipients != nil {
+		return ErrAgeVault
+	}
+	if v.outer != nil {
+		return ErrHiddenVault
+	}
+	account, err := keychainAccount(filename)
+	if err != nil {
+		return err
+	}
+	p, err := v.decrypt()
+	if err != nil {
+		return err
+	}
+	key := cachedKey{Expires: time.Now().Add(ttl).Unix(), Slot: uint32(v.slot), Master: v.keys.master, Epoch: p.KeyCacheEpoch}
+	var buf bytes.Buffer
+	err = binary.Write(&buf, binary.BigEndian, &key)
+	wipe(key.Master[:])
+	lockBuffer(buf.Bytes())
+	defer wipe(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	sessionKey := randomKey()
+	defer wipe(sessionKey[:])
+	var nonce [24]byte
+	if _, err = io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		panic(err)
+	}
+	sealed := secretbox.Seal(nonce[:], buf.Bytes(), &nonce, &sessionKey)
+	if err = ioutil.WriteFile(sessionPath(filename), sealed, 0600); err != nil {
+		return err
+	}
+	return keychain.Store(account, hex.EncodeToString(sessionKey[:]))
+}
+
+// ForgetKey deletes the key of the vault at `filename` cached by CacheKey.
+func ForgetKey(filename string, keychain Keychain) error {
+	account, err := keychainAccount(filename)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(sessionPath(filename)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return keychain.Delete(account)
+}
+
+// renewKeyCacheEpoch replaces the vault's KeyCacheEpoch, so that keys cached
+// before then no longer open the vault once it is saved.
+func (v *Vault) renewKeyCacheEpoch() error {
+	p, err := v.decryptIndex()
+	if err != nil {
+		return err
+	}
+	p.KeyCacheEpoch = randomKey()
+	return v.seal(p)
+}
+
+// loadCachedKey returns the key of the vault at `filename` cached by CacheKey
+// under `account`, deleting it and returning ErrNotCached if it is missing,
+// cannot be unsealed or has expired.
+func loadCachedKey(filename string, account string, keychain Keychain) (cachedKey, error) {
+	secret, err := keychain.Load(account)
+	if err != nil {
+		return cachedKey{}, err
+	}
+	var key cachedKey
+	sessionKey, err := hex.DecodeString(secret)
+	defer wipe(sessionKey)
+	sealed, rerr := ioutil.ReadFile(sessionPath(filename))
+	if err != nil || rerr != nil || len(sessionKey) != 32 || len(sealed) < 24 {
+		ForgetKey(filename, keychain)
+		return cachedKey{}, ErrNotCached
+	}
+	var nonce [24]byte
+	var k [32]byte
+	copy(nonce[:], sealed)
+	copy(k[:], sessionKey)
+	plaintext, ok := secretbox.Open(nil, sealed[24:], &nonce, &k)
+	wipe(k[:])
+	if ok {
+		lockBuffer(plaintext)
+		err = binary.Read(bytes.NewReader(plaintext), binary.BigEndian, &key)
+		wipe(plaintext)
+	}
+	if !ok || err != nil || time.Now().Unix() >= key.Expires {
+		wipe(key.Master[:])
+		ForgetKey(filename, keychain)
+		return cachedKey{}, ErrNotCached
+	}
+	return key, nil
+}
+
+// OpenCached reads a vault from `filename` and decrypts it using the key
+// cached by CacheKey, whose session key is stored in `keychain`.
+// ErrNotCached is returned if there is no cached key, or if it has expired or
+// no longer opens the vault, in which case it is deleted. A vault opened
+// using a cached key has no passphrase at hand, so ChangePassphrase and
+// UpgradeKDF need the key slot's factors to be given again by opening the
+// vault normally.
+func OpenCached(filename string, keychain Keychain, readOnly bool) (*Vault, error) {
+	account, err := keychainAccount(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := loadCachedKey(filename, account, keychain)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(key.Master[:])
+
+	header, data, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	v, p, err := openWithKeys(header, data, &key.Master, int(key.Slot))
+	if err == nil && p.KeyCacheEpoch != key.Epoch {
+		v.Close()
+		err = ErrCouldNotDecrypt
+	}
+	if err == ErrCouldNotDecrypt {
+		ForgetKey(filename, keychain)
+		return nil, ErrNotCached
+	}
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		v.readOnly = true
+		return v, nil
+	}
+	if err = v.startSession(p); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,186 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryKeychain is a Keychain that stores secrets in memory.
+type memoryKeychain map[string]string
+
+func (k memoryKeychain) Store(account string, secret string) error {
+	k[account] = secret
+	return nil
+}
+
+func (k memoryKeychain) Load(account string) (string, error) {
+	secret, ok := k[account]
+	if !ok {
+		return "", ErrNotCached
+	}
+	return secret, nil
+}
+
+func (k memoryKeychain) Delete(account string) error {
+	delete(k, account)
+	return nil
+}
+
+// fakeTool puts a shell script named `name` running `script` first on the
+// PATH for the rest of the test `t`, returning the directory it is in, where
+// the script is run.
+func fakeTool(t *testing.T, name string, script string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	dir := t.TempDir()
+	script = "#!/bin/sh\ncd " + dir + "\n" + script
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestCacheKey(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	defer os.Remove("pass.db.session")
+
+	keychain := memoryKeychain{}
+	if _, err = OpenCached("pass.db", keychain, false); err != ErrNotCached {
+		t.Fatal("expected ErrNotCached before caching the key, got", err)
+	}
+	if err = v.CacheKey("pass.db", keychain, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// Only the session key is kept in the keychain, and the master key
+	// only sealed by it.
+	for _, secret := range keychain {
+		if sessionKey, err := hex.DecodeString(secret); err != nil || len(sessionKey) != 32 {
+			t.Fatal("expected the keychain to hold a session key, got", secret)
+		}
+	}
+	sealed, err := ioutil.ReadFile("pass.db.session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, v.keys.master[:]) {
+		t.Fatal("the session file holds the master key in the clear")
+	}
+	cached, err := OpenCached("pass.db", keychain, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred, err := cached.Get("testlocation"); err != nil || cred.Password != "testpassword" {
+		t.Fatal("could not read the vault opened using the cached key:", err)
+	}
+	if err = cached.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "testpass"); err != nil {
+		t.Fatal("vault saved after opening it using the cached key could not be opened:", err)
+	}
+
+	// A new master key invalidates the cached key.
+	if err = v.ChangePassphrase("testpass", "newpass"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenCached("pass.db", keychain, false); err != ErrNotCached {
+		t.Fatal("expected ErrNotCached after changing the master key, got", err)
+	}
+	if len(keychain) != 0 {
+		t.Fatal("stale cached key was not deleted")
+	}
+
+	// Rotating the data key, and changing the passphrase of a vault with
+	// several key slots, which keeps its master key, invalidate the cached
+	// key too.
+	if _, err = v.AddKeySlot("otherpass", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range []func() error{
+		v.RotateDataKey,
+		func() error { return v.ChangePassphrase("newpass", "testpass") },
+	} {
+		if err = v.CacheKey("pass.db", keychain, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err = change(); err != nil {
+			t.Fatal(err)
+		}
+		if err = v.Save("pass.db"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = OpenCached("pass.db", keychain, false); err != ErrNotCached {
+			t.Fatal("expected ErrNotCached after the change, got", err)
+		}
+		if _, err = os.Stat("pass.db.session"); !os.IsNotExist(err) {
+			t.Fatal("stale session file was not deleted")
+		}
+	}
+
+	if err = v.CacheKey("pass.db", keychain, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	if _, err = OpenCached("pass.db", keychain, false); err != ErrNotCached {
+		t.Fatal("expected ErrNotCached after the cached key expired, got", err)
+	}
+
+	if err = v.CacheKey("pass.db", keychain, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err = ForgetKey("pass.db", keychain); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenCached("pass.db", keychain, false); err != ErrNotCached {
+		t.Fatal("expected ErrNotCached after forgetting the key, got", err)
+	}
+	if _, err = os.Stat("pass.db.session"); !os.IsNotExist(err) {
+		t.Fatal("forgotten session file was not deleted")
+	}
+}
+
+func TestMacKeychainStore(t *testing.T) {
+	dir := fakeTool(t, "security", `printf '%s\n' "$@" > args
+cat > stdin
+`)
+	if err := (MacKeychain{}).Store("/path/to/my \"vault\"", "0123abcd"); err != nil {
+		t.Fatal(err)
+	}
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(args)) != "-i" {
+		t.Fatal("expected only -i on the command line, got", string(args))
+	}
+	stdin, err := ioutil.ReadFile(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stdin) != `add-generic-password -U -s masterkey -a "/path/to/my \"vault\"" -w "0123abcd"`+"\n" {
+		t.Fatal("unexpected command", string(stdin))
+	}
+}
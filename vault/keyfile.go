@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// keyfileSize is the number of random bytes written by GenerateKeyfile.
+const keyfileSize = 64
+
+// ErrEmptyKeyfile is returned if a keyfile is empty, and so adds nothing to
+// the passphrase.
+var ErrEmptyKeyfile = errors.New("keyfile is empty")
+
+// readKeyfile returns the SHA-256 digest of the contents of the keyfile at
+// `path`. Any file can be used as a keyfile.
+func readKeyfile(path string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) == 0 {
+		return nil, ErrEmptyKeyfile
+	}
+	digest := sha256.Sum256(contents)
+	return digest[:], nil
+}
+
+// compositeKey returns the input to the KDF for `passphrase` and the digests
+// of any other `factors`, such as a keyfile, which are nil if unused. As in
+// KeePass, the digests of the passphrase and factors are concatenated and
+// hashed, so that none alone reveals anything about the key. Without other
+// factors, the passphrase is used as is.
+func compositeKey(passphrase string, factors ...[]byte) string {
+	passphraseDigest := sha256.Sum256([]byte(passphrase))
+	composite := passphraseDigest[:]
+	for _, factor := range factors {
+		composite = append(composite, factor...)
+	}
+	if len(composite) == len(passphraseDigest) {
+		return passphrase
+	}
+	digest := sha256.Sum256(composite)
+	return string(digest[:])
+}
+
+// GenerateKeyfile writes a new keyfile of random bytes to `path`, which must
+// not already exist. Store the keyfile separately from the vault, such as on
+// a USB stick: both it and the passphrase are needed to open the vault.
+func GenerateKeyfile(path string) error {
+	contents := make([]byte, keyfileSize)
+	if _, err := io.ReadFull(rand.Reader, contents); err != nil {
+		panic(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(contents); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// NewWithKeyfile creates a new, empty, vault which can only be opened using
+// both `passphrase` and the keyfile at `keyfilePath`, using
+// OpenWithKeyfile.
+func NewWithKeyfile(passphrase string, keyfilePath string) (*Vault, error) {
+	return NewWithOptions(passphrase, Options{Keyfile: keyfilePath})
+}
+
+// OpenWithKeyfile is Open for a vault created with a keyfile, decrypting it
+// using both `passphrase` and the keyfile at `keyfilePath`.
+func OpenWithKeyfile(filename string, passphrase string, keyfilePath string) (*Vault, error) {
+	return OpenWithOptions(filename, passphrase, OpenOptions{Keyfile: keyfilePath})
+}
+
+// OpenReadOnlyWithKeyfile is OpenReadOnly for a vault created with a
+// keyfile.
+func OpenReadOnlyWithKeyfile(filename string, passphrase string, keyfilePath string) (*Vault, error) {
+	return OpenWithOptions(filename, passphrase, OpenOptions{Keyfile: keyfilePath, ReadOnly: true})
+}
@@ -0,0 +1,78 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestKeyfile(t *testing.T) {
+	if err := GenerateKeyfile("test.key"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("test.key")
+	if err := GenerateKeyfile("test.key"); err == nil {
+		t.Fatal("GenerateKeyfile overwrote an existing keyfile")
+	}
+	if err := ioutil.WriteFile("other.key", []byte("some other keyfile"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("other.key")
+
+	v, err := NewWithKeyfile("testpass", "test.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if _, err = Open("pass.db", "testpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("vault with a keyfile was opened without it")
+	}
+	if _, err = OpenWithKeyfile("pass.db", "testpass", "other.key"); err != ErrCouldNotDecrypt {
+		t.Fatal("vault was opened with the wrong keyfile")
+	}
+	if _, err = OpenWithKeyfile("pass.db", "wrongpass", "test.key"); err != ErrCouldNotDecrypt {
+		t.Fatal("vault was opened with the keyfile and the wrong passphrase")
+	}
+
+	v, err = OpenWithKeyfile("pass.db", "testpass", "test.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.ChangePassphrase("testpass", "newpass"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "newpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("changing the passphrase dropped the keyfile")
+	}
+	v, err = OpenReadOnlyWithKeyfile("pass.db", "newpass", "test.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpass" {
+		t.Fatal("credential did not survive reopening with the keyfile")
+	}
+}
+
+func TestEmptyKeyfile(t *testing.T) {
+	if err := ioutil.WriteFile("empty.key", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("empty.key")
+	if _, err := NewWithKeyfile("testpass", "empty.key"); err != ErrEmptyKeyfile {
+		t.Fatal("an empty keyfile was accepted")
+	}
+}
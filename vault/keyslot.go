@@ -0,0 +1,452 @@
+package vault
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+// maxKeySlots is the maximum number of key slots a vault may have.
+const maxKeySlots = 8
+
+var (
+	// ErrNoSuchKeySlot is returned from RemoveKeySlot if the vault has no
+	// key slot with the given number.
+	ErrNoSuchKeySlot = errors.New("key slot does not exist")
+
+	// ErrTooManyKeySlots is returned from AddKeySlot if the vault already
+	// has the maximum number of key slots.
+	ErrTooManyKeySlots = errors.New("vault has the maximum number of key slots")
+
+	// ErrCurrentKeySlot is returned from RemoveKeySlot if the slot is the
+	// one the vault was unlocked with. Unlock the vault using another slot
+	// to remove it.
+	ErrCurrentKeySlot = errors.New("cannot remove the key slot the vault was unlocked with")
+)
+
+type (
+	// keySlot is one of the secrets that unlocks a vault: the master key
+	// wrapped by a key derived from a passphrase, and optionally a keyfile,
+	// the response to a challenge, and the hmac-secret of a FIDO2
+	// credential, or else the master key encrypted to an OpenPGP key,
+	// sealed by a TPM or wrapped by a PKCS#11 token.
+	keySlot struct {
+		kdf               KDFParams
+		salt              [24]byte
+		keyfile           bool
+		challengeResponse bool
+		// fido2Credential is the ID of the slot's FIDO2 credential, or
+		// nil if it has none.
+		fido2Credential []byte
+		// gpgRecipient is the OpenPGP key the master key is encrypted
+		// to, or empty if the slot is not a GPG slot.
+		gpgRecipient string
+		// tpm is true if the master key is sealed by a TPM.
+		tpm bool
+		// pkcs11Label is the label of the PKCS#11 token's key the
+		// master key is wrapped by, or empty if the slot is not a
+		// PKCS#11 slot.
+		pkcs11Label string
+		// threshold is the number of approvers needed to recover the
+		// key of a threshold slot, or zero if the slot is not one.
+		threshold int
+		approvers []approver
+		wrapped   []byte
+	}
+
+	// unlockFactors are the secrets other than a passphrase that a key
+	// slot may need.
+	unlockFactors struct {
+		// keyfile is the digest of a keyfile, or nil.
+		keyfile []byte
+		// respond answers the slot's challenge, or is nil.
+		respond ChallengeResponseFunc
+		// fido2 holds the slot's FIDO2 credential, or is nil.
+		fido2 FIDO2Authenticator
+		// gpgDecrypt decrypts the master key of GPG slots, or is nil.
+		gpgDecrypt GPGDecryptFunc
+		// tpm unseals the master key of TPM slots, or is nil.
+		tpm TPM
+		// pkcs11 unwraps the master key of PKCS#11 slots, or is nil.
+		pkcs11 PKCS11Token
+		// approve asks the approvers of threshold slots for their
+		// passphrases, or is nil.
+		approve ApproveFunc
+	}
+
+	// KeySlot describes a key slot of a vault without revealing its secret.
+	KeySlot struct {
+		Slot int
+		KDF  KDFParams
+		// Keyfile is true if the slot needs a keyfile as well as a
+		// passphrase.
+		Keyfile bool
+		// ChallengeResponse is true if the slot needs the response to a
+		// challenge, such as from a YubiKey, as well as a passphrase.
+		ChallengeResponse bool
+		// FIDO2 is true if the slot needs a FIDO2 authenticator.
+		FIDO2 bool
+		// GPGRecipient is the OpenPGP key of a GPG slot, which needs
+		// no passphrase.
+		GPGRecipient string
+		// TPM is true if the slot is sealed by a TPM, and needs no
+		// passphrase.
+		TPM bool
+		// PKCS11Label is the label of the key of a PKCS#11 slot, which
+		// needs no passphrase.
+		PKCS11Label string
+		// Approvers are the names of the approvers of a threshold
+		// slot, Threshold of whom are needed to unlock it.
+		Approvers []string
+		Threshold int
+		// Current is true for the slot the vault was unlocked with.
+		Current bool
+	}
+
+	// KeySlotOptions configures a key slot added using
+	// AddKeySlotWithOptions. The zero value adds a slot needing only a
+	// passphrase.
+	KeySlotOptions struct {
+		// Keyfile, if set, is the path of a keyfile the slot needs.
+		Keyfile string
+		// ChallengeResponse, if set, answers the challenge whose
+		// response the slot needs.
+		ChallengeResponse ChallengeResponseFunc
+		// FIDO2, if set, is the authenticator on which a credential is
+		// created whose hmac-secret the slot needs.
+		FIDO2 FIDO2Authenticator
+	}
+)
+
+// newUnlockFactors returns the factors for the keyfile at `keyfilePath`, the
+// challenge-response `respond` and the FIDO2 authenticator `fido2`, any of
+// which may be unset.
+func newUnlockFactors(keyfilePath string, respond ChallengeResponseFunc, fido2 FIDO2Authenticator) (unlockFactors, error) {
+	factors := unlockFactors{respond: respond, fido2: fido2}
+	if keyfilePath != "" {
+		var err error
+		if factors.keyfile, err = readKeyfile(keyfilePath); err != nil {
+			return unlockFactors{}, err
+		}
+	}
+	return factors, nil
+}
+
+// derive derives the key of `slot` from `passphrase` and `factors`. The
+// slot's salt is also the challenge, and the digest of the salt the FIDO2
+// hmac-secret salt, so every slot has its own response.
+func (factors unlockFactors) derive(passphrase string, slot keySlot) ([32]byte, error) {
+	var response, hmacSecret []byte
+	if factors.respond != nil {
+		r, err := factors.respond(slot.salt[:])
+		if err != nil {
+			return [32]byte{}, err
+		}
+		digest := sha256.Sum256(r)
+		response = digest[:]
+	}
+	if factors.fido2 != nil {
+		secret, err := factors.fido2.HMACSecret(slot.fido2Credential, sha256.Sum256(slot.salt[:]))
+		if err != nil {
+			return [32]byte{}, err
+		}
+		digest := sha256.Sum256(secret[:])
+		hmacSecret = digest[:]
+	}
+	return slot.kdf.derive(compositeKey(passphrase, factors.keyfile, response, hmacSecret), slot.salt)
+}
+
+// fits returns true if `factors` are those needed by `slot`.
+func (factors unlockFactors) fits(slot keySlot) bool {
+	if slot.gpgRecipient != "" {
+		return factors.gpgDecrypt != nil
+	}
+	if slot.tpm {
+		return factors.tpm != nil
+	}
+	if slot.pkcs11Label != "" {
+		return factors.pkcs11 != nil
+	}
+	if slot.threshold > 0 {
+		return factors.approve != nil
+	}
+	return factors.gpgDecrypt == nil && factors.tpm == nil && factors.pkcs11 == nil && factors.approve == nil &&
+		slot.keyfile == (factors.keyfile != nil) &&
+		slot.challengeResponse == (factors.respond != nil) &&
+		(slot.fido2Credential != nil) == (factors.fido2 != nil)
+}
+
+// unwrap recovers the master key wrapped by `slot` using `passphrase` and
+// `factors`, returning ErrCouldNotDecrypt if they do not unlock it.
+func (factors unlockFactors) unwrap(passphrase string, slot keySlot, cipher CipherID) ([32]byte, error) {
+	var master [32]byte
+	if slot.gpgRecipient != "" {
+		key, err := factors.gpgDecrypt(slot.wrapped)
+		if err != nil {
+			return master, err
+		}
+		if len(key) != keyLen {
+			return master, ErrCouldNotDecrypt
+		}
+		copy(master[:], key)
+		return master, nil
+	}
+	if slot.tpm || slot.pkcs11Label != "" {
+		var key []byte
+		var err error
+		if slot.tpm {
+			key, err = factors.tpm.Unseal(slot.wrapped)
+		} else {
+			key, err = factors.pkcs11.Unwrap(slot.pkcs11Label, slot.wrapped)
+		}
+		if err != nil {
+			return master, err
+		}
+		defer wipe(key)
+		if len(key) != keyLen {
+			return master, ErrCouldNotDecrypt
+		}
+		copy(master[:], key)
+		return master, nil
+	}
+
+	var kek [32]byte
+	var err error
+	if slot.threshold > 0 {
+		kek, err = factors.unwrapThreshold(slot, cipher)
+	} else {
+		kek, err = factors.derive(passphrase, slot)
+	}
+	if err != nil {
+		return master, err
+	}
+	defer wipe(kek[:])
+	return unwrapKey(cipher, &kek, slot.wrapped)
+}
+
+// randomKey returns a new random key.
+func randomKey() [32]byte {
+	var key [32]byte
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// wrappedKeySize returns the size of a key wrapped using the cipher `id`.
+func wrappedKeySize(id CipherID) (int, error) {
+	c, err := newCipher(id, &[32]byte{})
+	if err != nil {
+		return 0, err
+	}
+	return c.NonceSize() + keyLen + c.Overhead(), nil
+}
+
+// wrapKey encrypts `key` using the cipher `id` keyed with `kek`, returning the
+// nonce followed by the encrypted key.
+func wrapKey(id CipherID, kek *[32]byte, key [32]byte) ([]byte, error) {
+	c, err := newCipher(id, kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, c.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(err)
+	}
+	return c.Seal(nonce, nonce, key[:], nil), nil
+}
+
+// unwrapKey decrypts a key wrapped by wrapKey, returning ErrCouldNotDecrypt if
+// `kek` is not the key it was wrapped with.
+func unwrapKey(id CipherID, kek *[32]byte, wrapped []byte) ([32]byte, error) {
+	var key [32]byte
+	c, err := newCipher(id, kek)
+	if err != nil {
+		return key, err
+	}
+	if len(wrapped) != c.NonceSize()+keyLen+c.Overhead() {
+		return key, ErrCouldNotDecrypt
+	}
+	unwrapped, err := c.Open(nil, wrapped[:c.NonceSize()], wrapped[c.NonceSize():], nil)
+	if err != nil {
+		return key, ErrCouldNotDecrypt
+	}
+	copy(key[:], unwrapped)
+	return key, nil
+}
+
+// newKeySlot returns a key slot wrapping the vault's master key by a key
+// derived from `passphrase` and `factors` using `kdf`. If `factors` include a
+// FIDO2 authenticator, the slot uses the credential `fido2Credential`, or if
+// it is nil, a credential created on the authenticator for the slot.
+func (v *Vault) newKeySlot(passphrase string, factors unlockFactors, kdf KDFParams, fido2Credential []byte) (keySlot, error) {
+	slot := keySlot{
+		kdf:               kdf,
+		salt:              randomSalt(),
+		keyfile:           factors.keyfile != nil,
+		challengeResponse: factors.respond != nil,
+		fido2Credential:   fido2Credential,
+	}
+	if factors.fido2 != nil && slot.fido2Credential == nil {
+		id, err := factors.fido2.MakeCredential()
+		if err != nil {
+			return keySlot{}, err
+		}
+		if len(id) == 0 || len(id) > maxFIDO2CredentialSize {
+			return keySlot{}, ErrInvalidFIDO2Credential
+		}
+		slot.fido2Credential = id
+	}
+	kek, err := factors.derive(passphrase, slot)
+	if err != nil {
+		return keySlot{}, err
+	}
+	if slot.wrapped, err = wrapKey(v.cipher, &kek, v.keys.master); err != nil {
+		return keySlot{}, err
+	}
+	return slot, nil
+}
+
+// unlock finds the key slot unlocked by `passphrase` and the vault's factors,
+// and recovers the master key, then the data key from `dataKey`. Only slots
+// needing exactly the vault's factors are tried. If no slot is unlocked, the
+// first error from a factor is returned, such as a missing FIDO2
+// authenticator, or else ErrCouldNotDecrypt. The key of a vault written
+// before key slots were introduced is its data key, and it is given a master
+// key so that the next Save writes it with a key slot.
+func (v *Vault) unlock(passphrase string, dataKey []byte) error {
+	var factorErr error
+	for i := range v.slots {
+		slot := &v.slots[i]
+		if slot.wrapped == nil {
+			kek, err := v.factors.derive(passphrase, *slot)
+			if err != nil {
+				return err
+			}
+			v.keys.secret, v.keys.master, v.slot = kek, randomKey(), i
+			slot.keyfile = v.factors.keyfile != nil
+			slot.challengeResponse = v.factors.respond != nil
+			slot.wrapped, err = wrapKey(v.cipher, &kek, v.keys.master)
+			return err
+		}
+		if !v.factors.fits(*slot) {
+			continue
+		}
+
+		// Another slot may be unlocked if this one's factor fails, such
+		// as when a different FIDO2 authenticator was enrolled in it.
+		master, err := v.factors.unwrap(passphrase, *slot, v.cipher)
+		if err == ErrCouldNotDecrypt {
+			continue
+		}
+		if err != nil {
+			if factorErr == nil {
+				factorErr = err
+			}
+			continue
+		}
+		secret, err := unwrapKey(v.cipher, &master, dataKey)
+		if err != nil {
+			return ErrCouldNotDecrypt
+		}
+		v.keys.secret, v.keys.master, v.slot = secret, master, i
+		return nil
+	}
+	if factorErr != nil {
+		return factorErr
+	}
+	return ErrCouldNotDecrypt
+}
+
+// AddKeySlot adds a key slot to the vault, so that it can also be unlocked
+// using `passphrase` and, unless `keyfilePath` is empty, the keyfile at
+// `keyfilePath`, returning the number of the new slot. The key of the new
+// slot is derived using the KDF of the slot the vault was unlocked with. The
+// slot is written by the next Save.
+func (v *Vault) AddKeySlot(passphrase string, keyfilePath string) (int, error) {
+	return v.AddKeySlotWithOptions(passphrase, KeySlotOptions{Keyfile: keyfilePath})
+}
+
+// AddKeySlotWithOptions is AddKeySlot for a slot configured by `opts`, such
+// as one enrolling a YubiKey.
+func (v *Vault) AddKeySlotWithOptions(passphrase string, opts KeySlotOptions) (int, error) {
+	if v.closed {
+		return 0, ErrLocked
+	}
+	if v.readOnly {
+		return 0, ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return 0, ErrAgeVault
+	}
+	if v.outer != nil {
+		return 0, ErrHiddenVault
+	}
+	if len(v.slots) >= maxKeySlots {
+		return 0, ErrTooManyKeySlots
+	}
+	factors, err := newUnlockFactors(opts.Keyfile, opts.ChallengeResponse, opts.FIDO2)
+	if err != nil {
+		return 0, err
+	}
+
+	slot, err := v.newKeySlot(passphrase, factors, v.KDFParams(), nil)
+	if err != nil {
+		return 0, err
+	}
+	v.slots = append(v.slots, slot)
+	return len(v.slots) - 1, nil
+}
+
+// RemoveKeySlot removes the key slot numbered `slot`, renumbering the slots
+// after it, so that the next Save writes the vault without it.
+// ErrCurrentKeySlot is returned if `slot` is the slot the vault was unlocked
+// with, so a vault always keeps a slot that is known to work. The master key
+// is unchanged, so anyone with the removed slot's secret and a copy of the
+// vault saved before its removal can still decrypt later saves. The next Save
+// removes the vault file's backups, which the removed slot still unlocks.
+func (v *Vault) RemoveKeySlot(slot int) error {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	if slot < 0 || slot >= len(v.slots) {
+		return ErrNoSuchKeySlot
+	}
+	if slot == v.slot {
+		return ErrCurrentKeySlot
+	}
+
+	v.slots = append(v.slots[:slot], v.slots[slot+1:]...)
+	if slot < v.slot {
+		v.slot--
+	}
+	v.pruneBackups = true
+	return nil
+}
+
+// ListKeySlots returns the vault's key slots, in order.
+func (v *Vault) ListKeySlots() []KeySlot {
+	slots := make([]KeySlot, len(v.slots))
+	for i, slot := range v.slots {
+		slots[i] = KeySlot{
+			Slot:              i,
+			KDF:               slot.kdf,
+			Keyfile:           slot.keyfile,
+			ChallengeResponse: slot.challengeResponse,
+			FIDO2:             slot.fido2Credential != nil,
+			GPGRecipient:      slot.gpgRecipient,
+			TPM:               slot.tpm,
+			PKCS11Label:       slot.pkcs11Label,
+			Threshold:         slot.threshold,
+			Current:           i == v.slot,
+		}
+		for _, a := range slot.approvers {
+			slots[i].Approvers = append(slots[i].Approvers, a.name)
+		}
+	}
+	return slots
+}
@@ -0,0 +1,104 @@
+package vault
+
+import (
+	"os"
+	"testing"
+)
+
+func TestKeySlots(t *testing.T) {
+	relaxDefaults(t)
+	if err := GenerateKeyfile("test.key"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("test.key")
+
+	v, err := NewWithOptions("alicepass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if slot, err := v.AddKeySlot("bobpass", ""); err != nil || slot != 1 {
+		t.Fatal("could not add a passphrase key slot:", err)
+	}
+	if slot, err := v.AddKeySlot("", "test.key"); err != nil || slot != 2 {
+		t.Fatal("could not add a keyfile key slot:", err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if _, err = Open("pass.db", "wrongpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("vault was opened with the wrong passphrase")
+	}
+	if _, err = Open("pass.db", ""); err != ErrCouldNotDecrypt {
+		t.Fatal("keyfile slot was unlocked without the keyfile")
+	}
+	for i, unlock := range []func() (*Vault, error){
+		func() (*Vault, error) { return Open("pass.db", "alicepass") },
+		func() (*Vault, error) { return Open("pass.db", "bobpass") },
+		func() (*Vault, error) { return OpenWithKeyfile("pass.db", "", "test.key") },
+	} {
+		vopen, err := unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = vopen.Get("testlocation"); err != nil {
+			t.Fatal(err)
+		}
+		slots := vopen.ListKeySlots()
+		if len(slots) != 3 || !slots[i].Current || slots[i].KDF != v.KDFParams() || !slots[2].Keyfile || slots[0].Keyfile {
+			t.Fatal("unexpected key slots", slots)
+		}
+	}
+
+	vopen, err := Open("pass.db", "bobpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.RemoveKeySlot(1); err != ErrCurrentKeySlot {
+		t.Fatal("expected ErrCurrentKeySlot, got", err)
+	}
+	if err = vopen.RemoveKeySlot(3); err != ErrNoSuchKeySlot {
+		t.Fatal("expected ErrNoSuchKeySlot, got", err)
+	}
+	if err = vopen.RemoveKeySlot(0); err != nil {
+		t.Fatal(err)
+	}
+	if slots := vopen.ListKeySlots(); len(slots) != 2 || !slots[0].Current {
+		t.Fatal("removing a key slot did not renumber the slots", slots)
+	}
+	if err = vopen.ChangePassphrase("bobpass", "newbobpass"); err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "alicepass"); err != ErrCouldNotDecrypt {
+		t.Fatal("removed key slot still unlocked the vault")
+	}
+	if _, err = Open("pass.db", "newbobpass"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenWithKeyfile("pass.db", "", "test.key"); err != nil {
+		t.Fatal("changing one slot's passphrase broke another slot:", err)
+	}
+}
+
+func TestTooManyKeySlots(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < maxKeySlots; i++ {
+		if _, err = v.AddKeySlot("testpass", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = v.AddKeySlot("testpass", ""); err != ErrTooManyKeySlots {
+		t.Fatal("expected ErrTooManyKeySlots, got", err)
+	}
+}
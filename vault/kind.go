@@ -0,0 +1,47 @@
+package vault
+
+import (
+	"errors"
+	"sort"
+)
+
+// Kind is the kind of entry a Credential holds.
+type Kind string
+
+const (
+	// KindLogin is a username and password for a site or service. A
+	// Credential with an empty Kind is a login.
+	KindLogin Kind = "login"
+	// KindNote is a secure note, whose body is stored in Notes.
+	KindNote Kind = "note"
+)
+
+// ErrWrongKind is returned when retrieving an entry as a kind it is not,
+// for example a login using GetNote.
+var ErrWrongKind = errors.New("entry is not of the requested kind")
+
+// kind returns the kind of `c`, treating an empty Kind as a login.
+func (c *Credential) kind() Kind {
+	if c.Kind == "" {
+		return KindLogin
+	}
+	return c.Kind
+}
+
+// LocationsOfKind retrieves the locations of the entries of kind `kind`,
+// sorted by location.
+func (v *Vault) LocationsOfKind(kind Kind) ([]string, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	locations := []string{}
+	for location, cred := range p.Credentials {
+		if cred.kind() == kind {
+			locations = append(locations, location)
+		}
+	}
+	sort.Strings(locations)
+	return locations, nil
+}
@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// lastPassNoteURL is the URL LastPass exports for secure notes.
+const lastPassNoteURL = "http://sn"
+
+// lastPassColumns are the columns required in a LastPass CSV export.
+var lastPassColumns = []string{"url", "username", "password", "extra", "name", "grouping", "fav"}
+
+// ImportLastPass imports credentials from a LastPass CSV export read from
+// `r`. Each entry's grouping becomes the folder prefix of its location, with
+// LastPass's "\" separated subfolders mapped to vault folders, and its name
+// the last segment. The extra column is imported as notes, and entries
+// marked as favorites in LastPass are marked as Favorite. Secure notes are
+// imported as notes. Existing locations are skipped. The import is atomic: if
+// any row is malformed, no credentials are imported.
+func (v *Vault) ImportLastPass(r io.Reader) (ImportReport, error) {
+	var report ImportReport
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return report, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range lastPassColumns {
+		if _, ok := columns[name]; !ok {
+			return report, fmt.Errorf("%v: %q", ErrMissingColumn, name)
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return report, err
+	}
+
+	value := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	err = v.Batch(func(tx *Tx) error {
+		for row, record := range records {
+			cred := Credential{
+				Username:   value(record, "username"),
+				Password:   value(record, "password"),
+				Notes:      value(record, "extra"),
+				URL:        value(record, "url"),
+				Favorite:   value(record, "fav") == "1",
+				TOTPSecret: value(record, "totp"),
+			}
+			if cred.URL == lastPassNoteURL {
+				cred.URL = ""
+				cred.Kind = KindNote
+			}
+
+			var segments []string
+			for _, segment := range strings.Split(value(record, "grouping"), `\`) {
+				if segment = strings.TrimSpace(segment); segment != "" {
+					segments = append(segments, strings.Replace(segment, FolderSeparator, "-", -1))
+				}
+			}
+			folder := strings.Join(segments, FolderSeparator)
+
+			name := value(record, "name")
+			if name == "" && cred.URL == "" {
+				// rows are numbered from 1, after the header.
+				return fmt.Errorf("lastpass row %v has no name or url", row+1)
+			}
+
+			if err := report.add(tx, importLocation(folder, name, cred.URL), cred); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	return report, nil
+}
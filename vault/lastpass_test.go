@@ -0,0 +1,73 @@
+package vault
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestImportLastPass(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `url,username,password,totp,extra,name,grouping,fav
+https://github.com/login,testuser,testpass,,some notes,github,Work\Code,1
+https://mail.google.com,testuser2,testpass2,JBSWY3DPEHPK3PXP,,gmail,,0
+http://sn,,,,"NoteType:Server
+Hostname:example.com",server,Work,0
+`
+	report, err := v.ImportLastPass(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(report.Imported)
+	if !reflect.DeepEqual(report.Imported, []string{"Work/Code/github", "Work/server", "gmail"}) {
+		t.Fatalf("unexpected imported locations %v", report.Imported)
+	}
+
+	cred, err := v.Get("Work/Code/github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" || cred.Notes != "some notes" || cred.URL != "https://github.com/login" {
+		t.Fatal("ImportLastPass did not import credential data")
+	}
+	if !cred.Favorite {
+		t.Fatal("ImportLastPass did not import the favorite flag")
+	}
+
+	cred, err = v.Get("gmail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Favorite {
+		t.Fatal("ImportLastPass marked a credential as a favorite")
+	}
+	if cred.TOTPSecret != "JBSWY3DPEHPK3PXP" {
+		t.Fatal("ImportLastPass did not import the totp secret")
+	}
+
+	cred, err = v.Get("Work/server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.URL != "" || cred.Notes != "NoteType:Server\nHostname:example.com" || cred.Kind != KindNote {
+		t.Fatal("ImportLastPass did not import the secure note")
+	}
+}
+
+func TestImportLastPassMissingColumn(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.ImportLastPass(strings.NewReader("url,username,password\nhttps://github.com,testuser,testpass\n"))
+	if err == nil || !strings.Contains(err.Error(), ErrMissingColumn.Error()) {
+		t.Fatal("expected ImportLastPass to fail with a missing column, got", err)
+	}
+}
@@ -0,0 +1,63 @@
+package vault
+
+import "errors"
+
+// KindLink is a login whose password is that of another credential, stored
+// in Link. Links share their target's password, so rotating the target
+// updates every link to it.
+const KindLink Kind = "link"
+
+// ErrBrokenLink is returned when retrieving a link whose target no longer
+// exists, or which links back to itself.
+var ErrBrokenLink = errors.New("linked credential does not exist")
+
+// AddLink adds a link at `location` with the username `username`, whose
+// password is the password of the credential at `target`. AddLink returns
+// ErrNoSuchCredential if `target` does not exist.
+func (v *Vault) AddLink(location string, target string, username string) error {
+	return v.Batch(func(tx *Tx) error {
+		target = tx.p.resolve(target)
+		if _, ok := tx.p.Credentials[target]; !ok {
+			return ErrNoSuchCredential
+		}
+		return tx.Add(location, Credential{Kind: KindLink, Username: username, Link: target})
+	})
+}
+
+// linked returns a copy of the link `cred` with the password of the
+// credential it links to. Links to links are followed.
+func (p *payload) linked(cred *Credential) (*Credential, error) {
+	seen := make(map[string]bool)
+	target := cred
+	for target.kind() == KindLink {
+		location := p.resolve(target.Link)
+		if seen[location] {
+			return nil, ErrBrokenLink
+		}
+		seen[location] = true
+
+		var err error
+		if target, err = p.credential(location); err == ErrNoSuchCredential {
+			return nil, ErrBrokenLink
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	resolved := cred.clone()
+	resolved.Password = target.Password
+	return &resolved, nil
+}
+
+// retargetLinks points every link to `oldLocation` at `newLocation`.
+func (p *payload) retargetLinks(oldLocation string, newLocation string) error {
+	if err := p.openAll(); err != nil {
+		return err
+	}
+	for _, cred := range p.Credentials {
+		if cred.kind() == KindLink && cred.Link == oldLocation {
+			cred.Link = newLocation
+		}
+	}
+	return nil
+}
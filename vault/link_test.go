@@ -0,0 +1,58 @@
+package vault
+
+import "testing"
+
+func TestLink(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("ldap", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.AddLink("wiki", "nonexistent", "testuser"); err != ErrNoSuchCredential {
+		t.Fatal("expected ErrNoSuchCredential linking to a nonexistent location, got", err)
+	}
+	if err = v.AddLink("wiki", "ldap", "wikiuser"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.AddLink("jira", "wiki", "jirauser"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, password, err := v.Rotate("ldap", GenerateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, location := range []string{"wiki", "jira"} {
+		cred, err := v.Get(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cred.Password != password {
+			t.Fatalf("%v does not reflect the rotated password", location)
+		}
+	}
+	cred, err := v.Get("wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "wikiuser" {
+		t.Fatal("link did not keep its own username")
+	}
+
+	if err = v.Rename("ldap", "directory"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("wiki"); err != nil {
+		t.Fatal("link did not follow renamed credential:", err)
+	}
+	if err = v.Delete("directory"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("jira"); err != ErrBrokenLink {
+		t.Fatal("expected ErrBrokenLink after deleting the target, got", err)
+	}
+}
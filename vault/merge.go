@@ -0,0 +1,112 @@
+package vault
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+type (
+	// MergeStrategy resolves a conflict encountered by Merge, where `mine`
+	// and `theirs` are the differing credentials stored at `location` in
+	// the receiving vault and the other vault respectively. The returned
+	// credential is stored at `location`. Returning an error aborts the
+	// merge, leaving the receiving vault unmodified.
+	MergeStrategy func(location string, mine *Credential, theirs *Credential) (*Credential, error)
+
+	// MergeReport describes the changes made to a vault by Merge.
+	MergeReport struct {
+		// Added are the locations that only existed in the other vault.
+		Added []string
+		// Updated are the conflicting locations that were replaced with
+		// the other vault's credential.
+		Updated []string
+		// Kept are the conflicting locations where the receiving vault's
+		// credential was kept.
+		Kept []string
+	}
+)
+
+// KeepMine is a MergeStrategy that always keeps the receiving vault's
+// credential.
+func KeepMine(location string, mine *Credential, theirs *Credential) (*Credential, error) {
+	return mine, nil
+}
+
+// KeepNewer is a MergeStrategy that keeps whichever credential was updated
+// most recently, preferring the receiving vault's credential on a tie.
+func KeepNewer(location string, mine *Credential, theirs *Credential) (*Credential, error) {
+	if theirs.UpdatedAt.After(mine.UpdatedAt) {
+		return theirs, nil
+	}
+	return mine, nil
+}
+
+// sameContent returns true if `c` and `other` hold the same data, ignoring
+// the history and timestamps maintained by the vault.
+func (c *Credential) sameContent(other *Credential) bool {
+	a, b := *c, *other
+	a.History, b.History = nil, nil
+	a.CreatedAt, b.CreatedAt = time.Time{}, time.Time{}
+	a.UpdatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	a.ExpiresAt, b.ExpiresAt = time.Time{}, time.Time{}
+	return reflect.DeepEqual(a, b) && c.ExpiresAt.Equal(other.ExpiresAt)
+}
+
+// Merge merges the credentials in `other` into the vault. Locations that only
+// exist in `other` are added, and locations whose credentials differ are
+// resolved using `strategy`. When the other vault's credential wins, the
+// replaced credential is kept in its History. Merge is atomic: if `strategy`
+// returns an error, the vault is left unmodified.
+func (v *Vault) Merge(other *Vault, strategy MergeStrategy) (MergeReport, error) {
+	var report MergeReport
+
+	theirs, err := other.decrypt()
+	if err != nil {
+		return report, err
+	}
+
+	var locations []string
+	for location := range theirs.Credentials {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	err = v.Batch(func(tx *Tx) error {
+		for _, location := range locations {
+			theirCred := theirs.Credentials[location]
+
+			myCred, err := tx.p.credential(location)
+			if err == ErrNoSuchCredential {
+				tx.p.Credentials[location] = theirCred
+				report.Added = append(report.Added, location)
+				continue
+			} else if err != nil {
+				return err
+			}
+			if myCred.sameContent(theirCred) {
+				continue
+			}
+
+			resolved, err := strategy(location, myCred, theirCred)
+			if err != nil {
+				return err
+			}
+			if resolved == myCred {
+				report.Kept = append(report.Kept, location)
+				continue
+			}
+
+			merged := *resolved
+			merged.History = myCred.archive()
+			tx.p.Credentials[location] = &merged
+			report.Updated = append(report.Updated, location)
+		}
+		return nil
+	})
+	if err != nil {
+		return MergeReport{}, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,142 @@
+package vault
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newMergeTestVaults(t *testing.T) (*Vault, *Vault) {
+	mine, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := New("otherpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	err = mine.Add("same", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = theirs.Add("same", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mine.Add("mineonly", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = theirs.Add("theirsonly", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mine.Add("theirsnewer", Credential{Username: "testuser", Password: "mine", UpdatedAt: now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = theirs.Add("theirsnewer", Credential{Username: "testuser", Password: "theirs", UpdatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mine.Add("minenewer", Credential{Username: "testuser", Password: "mine", UpdatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = theirs.Add("minenewer", Credential{Username: "testuser", Password: "theirs", UpdatedAt: now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mine, theirs
+}
+
+func TestMergeKeepNewer(t *testing.T) {
+	relaxDefaults(t)
+	mine, theirs := newMergeTestVaults(t)
+
+	report, err := mine.Merge(theirs, KeepNewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := MergeReport{
+		Added:   []string{"theirsonly"},
+		Updated: []string{"theirsnewer"},
+		Kept:    []string{"minenewer"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("expected merge report %v, got %v", expected, report)
+	}
+
+	cred, err := mine.Get("theirsnewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "theirs" {
+		t.Fatal("Merge did not take the newer credential")
+	}
+	if len(cred.History) != 1 || cred.History[0].Credential.Password != "mine" {
+		t.Fatal("Merge did not keep the replaced credential in the history")
+	}
+	cred, err = mine.Get("minenewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "mine" {
+		t.Fatal("Merge replaced a newer credential")
+	}
+	if _, err = mine.Get("theirsonly"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeKeepMine(t *testing.T) {
+	relaxDefaults(t)
+	mine, theirs := newMergeTestVaults(t)
+
+	report, err := mine.Merge(theirs, KeepMine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Updated) != 0 || len(report.Kept) != 2 || len(report.Added) != 1 {
+		t.Fatalf("unexpected merge report %v", report)
+	}
+	cred, err := mine.Get("theirsnewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "mine" {
+		t.Fatal("KeepMine replaced a credential")
+	}
+}
+
+func TestMergeCallback(t *testing.T) {
+	relaxDefaults(t)
+	mine, theirs := newMergeTestVaults(t)
+
+	var conflicts []string
+	_, err := mine.Merge(theirs, func(location string, m *Credential, th *Credential) (*Credential, error) {
+		conflicts = append(conflicts, location)
+		return th, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(conflicts, []string{"minenewer", "theirsnewer"}) {
+		t.Fatalf("strategy was not called for each conflict, got %v", conflicts)
+	}
+
+	mine, theirs = newMergeTestVaults(t)
+	testerr := errors.New("testerr")
+	_, err = mine.Merge(theirs, func(location string, m *Credential, th *Credential) (*Credential, error) {
+		return nil, testerr
+	})
+	if err != testerr {
+		t.Fatal("expected Merge to return the strategy's error")
+	}
+	if _, err = mine.Get("theirsonly"); err != ErrNoSuchCredential {
+		t.Fatal("expected aborted Merge to leave the vault unmodified")
+	}
+}
@@ -0,0 +1,49 @@
+package vault
+
+import (
+	"time"
+)
+
+// payloadVersion is the current version of the vault's payload format,
+// recorded in its Meta. Payloads from version 2 are only saved in vaults with
+// an authenticated header, and payloads from version 3 only in vaults from
+// version 16.
+const payloadVersion = 3
+
+// Meta describes a vault as a whole, and is useful for telling several vault
+// files apart. Name and Description are set using SetMeta, and the remaining
+// fields are maintained by the vault.
+type Meta struct {
+	Name        string
+	Description string
+	// CreatedAt is the time the vault was created. It is zero for vaults
+	// created before metadata was recorded.
+	CreatedAt time.Time
+	// ModifiedAt is the time the vault's contents were last changed.
+	ModifiedAt time.Time
+	// Version is the version of the format the vault was written in.
+	Version int
+}
+
+// Meta returns the vault's metadata.
+func (v *Vault) Meta() (Meta, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return Meta{}, err
+	}
+	return p.Meta, nil
+}
+
+// SetMeta sets the name and description of the vault from `meta`. The other
+// fields of `meta` are maintained by the vault and ignored.
+func (v *Vault) SetMeta(meta Meta) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	p.Meta.Name = meta.Name
+	p.Meta.Description = meta.Description
+
+	return v.encrypt(p)
+}
@@ -0,0 +1,57 @@
+package vault
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMeta(t *testing.T) {
+	relaxDefaults(t)
+	start := time.Now()
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := v.Meta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.CreatedAt.Before(start) || meta.ModifiedAt.Before(meta.CreatedAt) || meta.Version != payloadVersion {
+		t.Fatalf("unexpected metadata for a new vault %+v", meta)
+	}
+
+	err = v.SetMeta(Meta{Name: "personal", Description: "home accounts", Version: 99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	saved, err := v.Meta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.ModifiedAt.After(meta.ModifiedAt) {
+		t.Fatal("adding a credential did not update ModifiedAt")
+	}
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := vopen.Meta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Name != "personal" || opened.Description != "home accounts" || opened.Version != payloadVersion {
+		t.Fatalf("unexpected metadata after SetMeta %+v", opened)
+	}
+	if !opened.CreatedAt.Equal(meta.CreatedAt) || !opened.ModifiedAt.Equal(saved.ModifiedAt) {
+		t.Fatal("opening the vault changed its timestamps")
+	}
+}
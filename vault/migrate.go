@@ -0,0 +1,92 @@
+package vault
+
+import (
+	"fmt"
+)
+
+// migration upgrades a payload from the version it is registered under to the
+// next.
+type migration func(p *payload) error
+
+// migrations upgrade payloads written in older versions of the format, keyed
+// by the version each upgrades from. A change to the payload that needs
+// existing vaults to be upgraded, such as a new field whose zero value is
+// wrong for them, increments payloadVersion and registers a migration from
+// the previous version. Payloads are migrated when they are decrypted, and
+// written in the current version by the next Save. Every version before the
+// current one must have a migration, even one changing nothing, so that the
+// chain from any version is complete.
+var migrations = map[int]migration{
+	// Version 0 payloads did not record their version. Sections missing
+	// from them are allocated when they are decoded.
+	0: func(p *payload) error { return nil },
+	// Version 1 payloads may have been saved with an unauthenticated
+	// header, which version 2 payloads never are. The payload itself is
+	// unchanged.
+	1: func(p *payload) error { return nil },
+	// Version 3 payloads record the hash of their operation log's last
+	// operation, and its length, so that operations can be logged without
+	// opening the log, which is sealed apart from the rest of the payload.
+	// They also record the digest of their credentials, which is no longer
+	// the hash of them all, so the log is continued by an operation making
+	// no changes that records the new digest.
+	2: func(p *payload) error {
+		p.LogHead = p.LogBase
+		if len(p.Log) > 0 {
+			p.LogHead = p.Log[len(p.Log)-1].Hash
+		}
+		p.LogLength = len(p.Log)
+		digest, err := credentialsDigest(p)
+		if err != nil {
+			return err
+		}
+		p.Digest = digest
+		if len(p.Log) > 0 {
+			p.logOperation(nil)
+		}
+		return nil
+	},
+}
+
+// UnsupportedVersionError is returned from Open, and the other functions
+// opening a vault, if the vault was written in a newer version of the format
+// than this version of masterkey supports, which it cannot read without
+// losing what it does not understand. It is ErrUnsupportedVaultVersion
+// according to errors.Is.
+type UnsupportedVersionError struct {
+	// Format is the part of the vault written in a newer version: "header"
+	// or "payload".
+	Format string
+	// Version is the version the vault was written in, and MaxVersion the
+	// newest version supported.
+	Version    int
+	MaxVersion int
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("vault %v version %d is newer than the newest supported, %d: upgrade masterkey to open it", e.Format, e.Version, e.MaxVersion)
+}
+
+// Is returns true if `target` is ErrUnsupportedVaultVersion.
+func (e *UnsupportedVersionError) Is(target error) bool {
+	return target == ErrUnsupportedVaultVersion
+}
+
+// migratePayload upgrades `p` to the current payload version by applying the
+// migrations from its version in turn, returning an *UnsupportedVersionError
+// if it is newer than the current version.
+func migratePayload(p *payload) error {
+	if p.Meta.Version > payloadVersion {
+		return &UnsupportedVersionError{Format: "payload", Version: p.Meta.Version, MaxVersion: payloadVersion}
+	}
+	for version := p.Meta.Version; version < payloadVersion; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return fmt.Errorf("no migration from payload version %d", version)
+		}
+		if err := migrate(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
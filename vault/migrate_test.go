@@ -0,0 +1,66 @@
+package vault
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	for version := 0; version < payloadVersion; version++ {
+		if _, ok := migrations[version]; !ok {
+			t.Fatal("no migration from payload version", version)
+		}
+	}
+
+	defer func(migrate migration) { migrations[1] = migrate }(migrations[1])
+	migrations[1] = func(p *payload) error {
+		p.Meta.Description = "migrated"
+		return nil
+	}
+	p := newPayload()
+	p.Meta.Version = 1
+	if err := migratePayload(p); err != nil || p.Meta.Description != "migrated" {
+		t.Fatal("expected the payload to be migrated, got", p.Meta, err)
+	}
+	p = newPayload()
+	p.Meta.Version = payloadVersion
+	if err := migratePayload(p); err != nil || p.Meta.Description != "" {
+		t.Fatal("expected a current payload not to be migrated, got", p.Meta, err)
+	}
+}
+
+func TestNewerPayloadVersion(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := v.decrypt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCipher(v.cipher, &v.keys.secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := newCipher(v.cipher, &p.RecordKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Meta.Version = payloadVersion + 1
+	if v.data, err = sealEnvelope(c, records, p, p.Padding, CompressionNone); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeAtomic("pass.db", func(w io.Writer) error { return v.writeFile(w) }); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	_, err = Open("pass.db", "testpass")
+	unsupported, ok := err.(*UnsupportedVersionError)
+	if !ok || !errors.Is(err, ErrUnsupportedVaultVersion) || unsupported.Format != "payload" || unsupported.Version != payloadVersion+1 {
+		t.Fatal("expected an UnsupportedVersionError for the payload, got", err)
+	}
+}
@@ -0,0 +1,35 @@
+package vault
+
+import (
+	"errors"
+)
+
+// errMlockUnsupported is returned by mlock on platforms where memory cannot
+// be locked.
+var errMlockUnsupported = errors.New("memory locking is not supported on this platform")
+
+// MemoryLocked returns true if the vault's keys are locked into memory, so
+// that they cannot be swapped to disk. On Linux and macOS, locking fails if
+// the RLIMIT_MEMLOCK resource limit is too low, which can be raised using
+// `ulimit -l`; on Windows, if the process's minimum working set is too small.
+// Without it, keys and decrypted contents may be written to swap, which
+// should then be encrypted or disabled.
+func (v *Vault) MemoryLocked() bool {
+	return v.enclave != nil && v.enclave.locked
+}
+
+// lockBuffer tries to lock `b`, a buffer of decrypted contents, into memory.
+// The pages are never unlocked, as they may be shared with other locked
+// buffers.
+func lockBuffer(b []byte) {
+	if len(b) > 0 {
+		mlock(b)
+	}
+}
+
+// wipe overwrites `b` with zeros.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package vault
+
+// mlock reports that memory cannot be locked on this platform.
+func mlock(b []byte) error {
+	return errMlockUnsupported
+}
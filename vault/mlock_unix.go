@@ -0,0 +1,12 @@
+//go:build unix
+
+package vault
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// mlock locks the pages holding `b` into memory.
+func mlock(b []byte) error {
+	return unix.Mlock(b)
+}
@@ -0,0 +1,12 @@
+package vault
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// mlock locks the pages holding `b` into the process's working set.
+func mlock(b []byte) error {
+	return windows.VirtualLock(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)))
+}
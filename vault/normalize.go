@@ -0,0 +1,137 @@
+package vault
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrLocationCollision is returned if a location differs from one already in
+// use only in case or Unicode normalization, while location normalization is
+// enabled.
+var ErrLocationCollision = errors.New("location collides with an existing location")
+
+// compositions maps a base letter followed by a combining mark to the
+// precomposed letter, for the accented Latin letters most often seen in
+// locations.
+var compositions = make(map[[2]rune]rune)
+
+func init() {
+	for mark, letters := range map[rune][2]string{
+		'\u0300': {"aeiouAEIOU", "àèìòùÀÈÌÒÙ"},
+		'\u0301': {"aeiouyAEIOUY", "áéíóúýÁÉÍÓÚÝ"},
+		'\u0302': {"aeiouAEIOU", "âêîôûÂÊÎÔÛ"},
+		'\u0303': {"anoANO", "ãñõÃÑÕ"},
+		'\u0308': {"aeiouyAEIOU", "äëïöüÿÄËÏÖÜ"},
+		'\u030a': {"aA", "åÅ"},
+		'\u0327': {"cC", "çÇ"},
+	} {
+		bases, composed := []rune(letters[0]), []rune(letters[1])
+		for i, base := range bases {
+			compositions[[2]rune{base, mark}] = composed[i]
+		}
+	}
+}
+
+// normalizeLocation returns the form of `location` compared when location
+// normalization is enabled: letters followed by combining accents are
+// composed, fullwidth characters are replaced by their ASCII equivalents, and
+// the result is case folded.
+func normalizeLocation(location string) string {
+	var normalized []rune
+	for _, r := range location {
+		if r >= '\uff01' && r <= '\uff5e' {
+			r -= '\uff01' - '!'
+		}
+		if n := len(normalized); n > 0 && unicode.Is(unicode.Mn, r) {
+			if composed, ok := compositions[[2]rune{normalized[n-1], r}]; ok {
+				normalized[n-1] = composed
+				continue
+			}
+		}
+		normalized = append(normalized, r)
+	}
+	return strings.ToLower(strings.ToUpper(string(normalized)))
+}
+
+// canonical returns the location in use that `location` refers to when
+// location normalization is enabled, or `location` itself if it is in use
+// as is, normalization is disabled, or no location matches.
+func (p *payload) canonical(location string) string {
+	if !p.NormalizeLocations || p.inUse(location) {
+		return location
+	}
+	normalized := normalizeLocation(location)
+	for existing := range p.Credentials {
+		if normalizeLocation(existing) == normalized {
+			return existing
+		}
+	}
+	for existing := range p.Aliases {
+		if normalizeLocation(existing) == normalized {
+			return existing
+		}
+	}
+	return location
+}
+
+// available returns ErrCredentialExists if `location` is in use, or
+// ErrLocationCollision if a location differing only in its normalized form
+// is.
+func (p *payload) available(location string) error {
+	if p.inUse(location) {
+		return ErrCredentialExists
+	}
+	if p.canonical(location) != location {
+		return ErrLocationCollision
+	}
+	return nil
+}
+
+// SetNormalizeLocations enables or disables location normalization. While it
+// is enabled, locations are compared ignoring case and Unicode normalization,
+// so that Get, Edit, Delete and the other methods that take a location find
+// `Github.com` when given `github.com`, while the location is still stored
+// and listed as it was added. Adding a location that collides with an
+// existing one returns ErrLocationCollision. Enabling normalization returns
+// ErrLocationCollision if locations already in the vault collide.
+func (v *Vault) SetNormalizeLocations(enabled bool) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		seen := make(map[string]bool)
+		check := func(location string) error {
+			normalized := normalizeLocation(location)
+			if seen[normalized] {
+				return ErrLocationCollision
+			}
+			seen[normalized] = true
+			return nil
+		}
+		for location := range p.Credentials {
+			if err = check(location); err != nil {
+				return err
+			}
+		}
+		for alias := range p.Aliases {
+			if err = check(alias); err != nil {
+				return err
+			}
+		}
+	}
+	p.NormalizeLocations = enabled
+
+	return v.encrypt(p)
+}
+
+// NormalizesLocations returns true if location normalization is enabled.
+func (v *Vault) NormalizesLocations() (bool, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return false, err
+	}
+	return p.NormalizeLocations, nil
+}
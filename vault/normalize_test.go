@@ -0,0 +1,75 @@
+package vault
+
+import "testing"
+
+func TestNormalizeLocation(t *testing.T) {
+	for _, test := range []struct{ a, b string }{
+		{"Github.com", "github.com"},
+		{"café", "CAFÉ"},
+		{"ＧｉｔＨｕｂ", "github"},
+	} {
+		if normalizeLocation(test.a) != normalizeLocation(test.b) {
+			t.Fatalf("expected %q and %q to normalize to the same location", test.a, test.b)
+		}
+	}
+	if normalizeLocation("cafe") == normalizeLocation("café") {
+		t.Fatal("accents should not be removed")
+	}
+}
+
+func TestNormalizeLocations(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("Github.com", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("github.com"); err != ErrNoSuchCredential {
+		t.Fatal("expected case sensitive lookup with normalization disabled, got", err)
+	}
+
+	if err = v.SetNormalizeLocations(true); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("GITHUB.COM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" {
+		t.Fatal("normalized lookup returned the wrong credential")
+	}
+	if err = v.Add("github.com", Credential{Username: "other"}); err != ErrLocationCollision {
+		t.Fatal("expected ErrLocationCollision adding a colliding location, got", err)
+	}
+	locations, err := v.Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 1 || locations[0] != "Github.com" {
+		t.Fatalf("expected the display form to be preserved, got %v", locations)
+	}
+
+	if err = v.Rename("Github.com", "github.com"); err != nil {
+		t.Fatal("expected renaming to change only the case to succeed, got", err)
+	}
+	if err = v.Delete("GitHub.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("github.com"); err != ErrNoSuchCredential {
+		t.Fatal("expected ErrNoSuchCredential after deleting, got", err)
+	}
+
+	if err = v.SetNormalizeLocations(false); err != nil {
+		t.Fatal(err)
+	}
+	for _, location := range []string{"Github.com", "github.com"} {
+		if err = v.Add(location, Credential{Username: "testuser"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = v.SetNormalizeLocations(true); err != ErrLocationCollision {
+		t.Fatal("expected ErrLocationCollision enabling normalization with colliding locations, got", err)
+	}
+}
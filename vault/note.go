@@ -0,0 +1,20 @@
+package vault
+
+// AddNote adds a secure note with the body `body` at `location`. Notes have
+// no username or password; the location serves as their title.
+func (v *Vault) AddNote(location string, body string) error {
+	return v.Add(location, Credential{Kind: KindNote, Notes: body})
+}
+
+// GetNote retrieves the body of the secure note at `location`. ErrWrongKind
+// is returned if the entry at `location` is not a note.
+func (v *Vault) GetNote(location string) (string, error) {
+	cred, err := v.Get(location)
+	if err != nil {
+		return "", err
+	}
+	if cred.kind() != KindNote {
+		return "", ErrWrongKind
+	}
+	return cred.Notes, nil
+}
@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSecureNotes(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.AddNote("wifi", "network: home\npassword: hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("github", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.AddNote("github", "body"); err != ErrCredentialExists {
+		t.Fatal("expected AddNote to fail for an existing location, got", err)
+	}
+
+	body, err := v.GetNote("wifi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "network: home\npassword: hunter2" {
+		t.Fatalf("GetNote returned the incorrect body %q", body)
+	}
+	if _, err = v.GetNote("github"); err != ErrWrongKind {
+		t.Fatal("expected ErrWrongKind for a login, got", err)
+	}
+	if _, err = v.GetNote("nonexistent"); err != ErrNoSuchCredential {
+		t.Fatal("expected ErrNoSuchCredential, got", err)
+	}
+
+	notes, err := v.LocationsOfKind(KindNote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(notes, []string{"wifi"}) {
+		t.Fatalf("unexpected notes %v", notes)
+	}
+	logins, err := v.LocationsOfKind(KindLogin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(logins, []string{"github"}) {
+		t.Fatalf("unexpected logins %v", logins)
+	}
+
+	metadata, err := v.LocationsMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata[0].Kind != KindLogin || metadata[1].Kind != KindNote {
+		t.Fatal("LocationsMetadata did not report entry kinds")
+	}
+}
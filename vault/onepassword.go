@@ -0,0 +1,463 @@
+package vault
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidOnePassword is returned from ImportOnePassword if the export is
+// not a valid 1PIF or 1PUX file.
+var ErrInvalidOnePassword = errors.New("invalid 1password export")
+
+// onePasswordKind maps 1Password categories, identified by their 1PUX
+// category UUID or 1PIF type name, to the kind of entry they are imported as.
+// Other categories are imported as logins.
+var onePasswordKind = map[string]Kind{
+	"002":                         KindCard,
+	"003":                         KindNote,
+	"004":                         KindIdentity,
+	"wallet.financial.CreditCard": KindCard,
+	"securenotes.SecureNote":      KindNote,
+	"identities.Identity":         KindIdentity,
+}
+
+// onePasswordHistory is a previous password of a 1Password item.
+type onePasswordHistory struct {
+	Value string `json:"value"`
+	Time  int64  `json:"time"`
+}
+
+// onePasswordLoginField is a field of a 1Password login form.
+type onePasswordLoginField struct {
+	Value       string `json:"value"`
+	Name        string `json:"name"`
+	Designation string `json:"designation"`
+}
+
+// onePasswordItem accumulates the contents of a 1Password item, in either
+// export format, as it is converted to a Credential.
+type onePasswordItem struct {
+	title string
+	cred  Credential
+}
+
+// onePasswordTime converts a 1Password timestamp, in seconds since the Unix
+// epoch, to a time.Time.
+func onePasswordTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// onePasswordValue converts the value of a 1Password field of the given kind
+// to a string. Values of kinds that cannot be represented as text, such as
+// attachments, are returned as the empty string.
+func onePasswordValue(kind string, value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	var n int64
+	if json.Unmarshal(value, &n) == nil {
+		switch kind {
+		case "date":
+			return onePasswordTime(n).Format("2006-01-02")
+		case "monthYear":
+			return fmt.Sprintf("%02d/%04d", n%100, n/100)
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(value, &obj) != nil {
+		return ""
+	}
+	var keys []string
+	switch kind {
+	case "email":
+		keys = []string{"email_address"}
+	case "sshKey":
+		keys = []string{"privateKey"}
+	case "address":
+		keys = []string{"street", "city", "state", "zip", "country"}
+	}
+	var parts []string
+	for _, key := range keys {
+		if part := onePasswordValue("", obj[key]); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// setLoginFields sets the username and password of the item from the fields
+// of its login form. Other non-empty form fields become custom fields.
+func (item *onePasswordItem) setLoginFields(fields []onePasswordLoginField) {
+	for _, field := range fields {
+		switch field.Designation {
+		case "username":
+			item.cred.Username = field.Value
+		case "password":
+			item.cred.Password = field.Value
+		default:
+			item.cred.importField(field.Name, field.Value)
+		}
+	}
+}
+
+// setHistory sets the history of the item from its previous passwords.
+func (item *onePasswordItem) setHistory(history []onePasswordHistory) {
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Time < history[j].Time
+	})
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	for _, h := range history {
+		item.cred.History = append(item.cred.History, CredentialVersion{
+			Credential: Credential{Username: item.cred.Username, Password: h.Value},
+			ReplacedAt: onePasswordTime(h.Time),
+		})
+	}
+}
+
+// setField sets the field identified by `id`, titled `key`, to `value`.
+// Card and identity details are stored in the item's Card or Identity, and
+// other fields become custom fields.
+func (item *onePasswordItem) setField(id, key, value string) {
+	if card := item.cred.Card; card != nil {
+		switch id {
+		case "ccnum":
+			card.Number = value
+			return
+		case "cardholder":
+			card.Holder = value
+			return
+		case "expiry":
+			card.Expiry = value
+			return
+		case "cvv":
+			card.CVV = value
+			return
+		case "pin":
+			card.PIN = value
+			return
+		}
+	}
+	if identity := item.cred.Identity; identity != nil {
+		switch id {
+		case "firstname", "initial", "lastname":
+			if identity.Name != "" {
+				identity.Name += " "
+			}
+			identity.Name += value
+			return
+		case "address":
+			identity.Address = value
+			return
+		case "defphone", "cellphone", "homephone", "busphone":
+			if identity.Phone == "" {
+				identity.Phone = value
+				return
+			}
+		case "email":
+			identity.Email = value
+			return
+		}
+	}
+	item.cred.importField(key, value)
+}
+
+// setCategory sets the kind of the item from its category.
+func (item *onePasswordItem) setCategory(category string) {
+	item.cred.Kind = onePasswordKind[category]
+	switch item.cred.Kind {
+	case KindCard:
+		item.cred.Card = &Card{}
+	case KindIdentity:
+		item.cred.Identity = &Identity{}
+	}
+}
+
+// onePUXExport is the export.data document of a 1PUX archive.
+type onePUXExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Attrs struct {
+				Name string `json:"name"`
+			} `json:"attrs"`
+			Items []onePUXItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+// onePUXItem is an item in a 1PUX export.
+type onePUXItem struct {
+	CategoryUUID string `json:"categoryUuid"`
+	CreatedAt    int64  `json:"createdAt"`
+	UpdatedAt    int64  `json:"updatedAt"`
+	Details      struct {
+		LoginFields []onePasswordLoginField `json:"loginFields"`
+		NotesPlain  string                  `json:"notesPlain"`
+		Password    string                  `json:"password"`
+		Sections    []struct {
+			Title  string `json:"title"`
+			Fields []struct {
+				Title string                     `json:"title"`
+				ID    string                     `json:"id"`
+				Value map[string]json.RawMessage `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+		PasswordHistory []onePasswordHistory `json:"passwordHistory"`
+	} `json:"details"`
+	Overview struct {
+		Title string   `json:"title"`
+		URL   string   `json:"url"`
+		Tags  []string `json:"tags"`
+		URLs  []struct {
+			URL string `json:"url"`
+		} `json:"urls"`
+	} `json:"overview"`
+}
+
+// item converts the 1PUX item to a onePasswordItem.
+func (i *onePUXItem) item() *onePasswordItem {
+	item := &onePasswordItem{
+		title: i.Overview.Title,
+		cred: Credential{
+			Password:  i.Details.Password,
+			Notes:     i.Details.NotesPlain,
+			URL:       i.Overview.URL,
+			Tags:      i.Overview.Tags,
+			CreatedAt: onePasswordTime(i.CreatedAt),
+			UpdatedAt: onePasswordTime(i.UpdatedAt),
+		},
+	}
+	item.setCategory(i.CategoryUUID)
+	if item.cred.URL == "" && len(i.Overview.URLs) > 0 {
+		item.cred.URL = i.Overview.URLs[0].URL
+	}
+	item.setLoginFields(i.Details.LoginFields)
+	for _, section := range i.Details.Sections {
+		for _, field := range section.Fields {
+			for kind, value := range field.Value {
+				if kind == "totp" && item.cred.TOTPSecret == "" {
+					item.cred.TOTPSecret = onePasswordValue(kind, value)
+					continue
+				}
+				key := field.Title
+				if key == "" {
+					key = field.ID
+				}
+				item.setField(field.ID, key, onePasswordValue(kind, value))
+			}
+		}
+	}
+	item.setHistory(i.Details.PasswordHistory)
+	return item
+}
+
+// readOnePUX calls `fn` with each item of the 1PUX archive `data` and the
+// name of the vault it belongs to.
+func readOnePUX(data []byte, fn func(folder string, item *onePasswordItem) error) error {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ErrInvalidOnePassword
+	}
+	var export onePUXExport
+	found := false
+	for _, f := range archive.File {
+		if f.Name != "export.data" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return ErrInvalidOnePassword
+		}
+		err = json.NewDecoder(rc).Decode(&export)
+		rc.Close()
+		if err != nil {
+			return ErrInvalidOnePassword
+		}
+		found = true
+	}
+	if !found {
+		return ErrInvalidOnePassword
+	}
+
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			folder := strings.Replace(vault.Attrs.Name, FolderSeparator, "-", -1)
+			for i := range vault.Items {
+				if err := fn(folder, vault.Items[i].item()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// onePIFSeparator separates the items of a 1PIF export.
+const onePIFSeparator = "***5642bee8-a5ff-11dc-8314-0800200c9a66***"
+
+// onePIFItem is an item in a 1PIF export. Folders are items of type
+// "system.folder.Regular".
+type onePIFItem struct {
+	UUID           string `json:"uuid"`
+	TypeName       string `json:"typeName"`
+	Title          string `json:"title"`
+	Location       string `json:"location"`
+	FolderUUID     string `json:"folderUuid"`
+	CreatedAt      int64  `json:"createdAt"`
+	UpdatedAt      int64  `json:"updatedAt"`
+	Trashed        bool   `json:"trashed"`
+	SecureContents struct {
+		Fields     []onePasswordLoginField `json:"fields"`
+		NotesPlain string                  `json:"notesPlain"`
+		Password   string                  `json:"password"`
+		Sections   []struct {
+			Title  string `json:"title"`
+			Fields []struct {
+				Kind  string          `json:"k"`
+				Name  string          `json:"n"`
+				Title string          `json:"t"`
+				Value json.RawMessage `json:"v"`
+			} `json:"fields"`
+		} `json:"sections"`
+		URLs []struct {
+			URL string `json:"url"`
+		} `json:"URLs"`
+		PasswordHistory []onePasswordHistory `json:"passwordHistory"`
+	} `json:"secureContents"`
+	OpenContents struct {
+		Tags []string `json:"tags"`
+	} `json:"openContents"`
+}
+
+// item converts the 1PIF item to a onePasswordItem.
+func (i *onePIFItem) item() *onePasswordItem {
+	item := &onePasswordItem{
+		title: i.Title,
+		cred: Credential{
+			Password:  i.SecureContents.Password,
+			Notes:     i.SecureContents.NotesPlain,
+			URL:       i.Location,
+			Tags:      i.OpenContents.Tags,
+			CreatedAt: onePasswordTime(i.CreatedAt),
+			UpdatedAt: onePasswordTime(i.UpdatedAt),
+		},
+	}
+	item.setCategory(i.TypeName)
+	if item.cred.URL == "" && len(i.SecureContents.URLs) > 0 {
+		item.cred.URL = i.SecureContents.URLs[0].URL
+	}
+	item.setLoginFields(i.SecureContents.Fields)
+	for _, section := range i.SecureContents.Sections {
+		for _, field := range section.Fields {
+			// one-time password fields are named "TOTP_" followed by an
+			// identifier.
+			if strings.HasPrefix(field.Name, "TOTP_") && item.cred.TOTPSecret == "" {
+				item.cred.TOTPSecret = onePasswordValue(field.Kind, field.Value)
+				continue
+			}
+			key := field.Title
+			if key == "" {
+				key = field.Name
+			}
+			item.setField(field.Name, key, onePasswordValue(field.Kind, field.Value))
+		}
+	}
+	item.setHistory(i.SecureContents.PasswordHistory)
+	return item
+}
+
+// readOnePIF calls `fn` with each item of the 1PIF export `data` and the
+// path of the folder it belongs to. Trashed items are skipped.
+func readOnePIF(data []byte, fn func(folder string, item *onePasswordItem) error) error {
+	var items []*onePIFItem
+	folders := make(map[string]*onePIFItem)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == onePIFSeparator {
+			continue
+		}
+		var i onePIFItem
+		if err := json.Unmarshal([]byte(line), &i); err != nil {
+			return ErrInvalidOnePassword
+		}
+		switch i.TypeName {
+		case "system.folder.Regular":
+			folders[i.UUID] = &i
+		case "system.folder.SavedSearch", "system.Tombstone":
+		default:
+			if !i.Trashed {
+				items = append(items, &i)
+			}
+		}
+	}
+
+	path := func(uuid string) string {
+		var segments []string
+		// folders are nested by reference, so bound the depth in case of
+		// cycles.
+		for depth := 0; depth < len(folders); depth++ {
+			folder, ok := folders[uuid]
+			if !ok {
+				break
+			}
+			segments = append([]string{strings.Replace(folder.Title, FolderSeparator, "-", -1)}, segments...)
+			uuid = folder.FolderUUID
+		}
+		return strings.Join(segments, FolderSeparator)
+	}
+
+	for _, i := range items {
+		if err := fn(path(i.FolderUUID), i.item()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportOnePassword imports the items of the 1Password export read from `r`,
+// which may be a 1PUX archive or the data.1pif file of a 1PIF export. Logins
+// and passwords become credentials, and secure notes, cards and identities
+// are imported as entries of the corresponding kind. One-time password
+// seeds are stored as the TOTPSecret, and additional item fields become
+// custom fields. Items are stored under a folder named after their vault
+// (1PUX) or folder (1PIF). Trashed items are not imported, and existing
+// locations are skipped.
+func (v *Vault) ImportOnePassword(r io.Reader) (ImportReport, error) {
+	var report ImportReport
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return report, err
+	}
+	read := readOnePIF
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		read = readOnePUX
+	}
+
+	err = v.Batch(func(tx *Tx) error {
+		return read(data, func(folder string, item *onePasswordItem) error {
+			location := importLocation(folder, item.title, item.cred.URL)
+			return report.add(tx, location, item.cred)
+		})
+	})
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	return report, nil
+}
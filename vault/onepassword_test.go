@@ -0,0 +1,205 @@
+package vault
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testOnePUX = `{
+  "accounts": [{
+    "vaults": [{
+      "attrs": {"name": "Personal"},
+      "items": [
+        {
+          "categoryUuid": "001",
+          "createdAt": 1500000000,
+          "updatedAt": 1600000000,
+          "details": {
+            "loginFields": [
+              {"value": "testuser", "name": "email", "designation": "username"},
+              {"value": "testpass", "name": "password", "designation": "password"}
+            ],
+            "notesPlain": "some notes",
+            "sections": [{"title": "", "fields": [
+              {"title": "pin", "id": "pin", "value": {"concealed": "1234"}}
+            ]}],
+            "passwordHistory": [
+              {"value": "newer", "time": 1550000000},
+              {"value": "older", "time": 1510000000}
+            ]
+          },
+          "overview": {"title": "github", "url": "https://github.com/login", "tags": ["work"]}
+        },
+        {
+          "categoryUuid": "002",
+          "details": {
+            "sections": [{"title": "", "fields": [
+              {"title": "cardholder name", "id": "cardholder", "value": {"string": "Test User"}},
+              {"title": "number", "id": "ccnum", "value": {"creditCardNumber": "4111111111111111"}},
+              {"title": "expiry date", "id": "expiry", "value": {"monthYear": 202512}}
+            ]}]
+          },
+          "overview": {"title": "visa"}
+        },
+        {
+          "categoryUuid": "003",
+          "details": {"notesPlain": "secret note"},
+          "overview": {"title": "wifi/home"}
+        }
+      ]
+    }]
+  }]
+}`
+
+func TestImportOnePUX(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, err := archive.Create("export.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(testOnePUX)); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := v.ImportOnePassword(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(report.Imported)
+	if !reflect.DeepEqual(report.Imported, []string{"Personal/github", "Personal/visa", "Personal/wifi-home"}) {
+		t.Fatalf("unexpected imported locations %v", report.Imported)
+	}
+
+	cred, err := v.Get("Personal/github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" || cred.Notes != "some notes" || cred.URL != "https://github.com/login" {
+		t.Fatal("ImportOnePassword did not import login data")
+	}
+	if cred.Fields["pin"] != "1234" || !reflect.DeepEqual(cred.Tags, []string{"work"}) {
+		t.Fatal("ImportOnePassword did not import fields and tags", cred.Fields, cred.Tags)
+	}
+	if cred.CreatedAt.Unix() != 1500000000 || cred.UpdatedAt.Unix() != 1600000000 {
+		t.Fatal("ImportOnePassword did not import timestamps")
+	}
+	if len(cred.History) != 2 || cred.History[0].Credential.Password != "older" || cred.History[1].Credential.Password != "newer" {
+		t.Fatal("ImportOnePassword did not import password history in order")
+	}
+
+	cred, err = v.Get("Personal/visa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Kind != KindCard || !reflect.DeepEqual(*cred.Card, Card{Number: "4111111111111111", Holder: "Test User", Expiry: "12/2025"}) {
+		t.Fatal("ImportOnePassword did not import the card", cred.Card)
+	}
+
+	cred, err = v.Get("Personal/wifi-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Notes != "secret note" || cred.Kind != KindNote {
+		t.Fatal("ImportOnePassword did not import the secure note")
+	}
+}
+
+func TestImportOnePIF(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("existing", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		`{"uuid":"f1","typeName":"system.folder.Regular","title":"work"}`,
+		onePIFSeparator,
+		`{"uuid":"f2","typeName":"system.folder.Regular","title":"email","folderUuid":"f1"}`,
+		onePIFSeparator,
+		`{"uuid":"i1","typeName":"webforms.WebForm","title":"gmail","location":"https://mail.google.com","folderUuid":"f2","secureContents":{"fields":[{"value":"testuser","name":"Email","designation":"username"},{"value":"testpass","name":"Passwd","designation":"password"}]},"openContents":{"tags":["mail"]}}`,
+		onePIFSeparator,
+		`{"uuid":"i2","typeName":"passwords.Password","title":"existing","secureContents":{"password":"newpass"}}`,
+		onePIFSeparator,
+		`{"uuid":"i3","typeName":"webforms.WebForm","title":"deleted","trashed":true}`,
+		onePIFSeparator,
+		`{"uuid":"i4","typeName":"identities.Identity","title":"me","secureContents":{"sections":[{"title":"Identification","fields":[{"k":"string","n":"firstname","t":"first name","v":"Test"},{"k":"date","n":"birthdate","t":"birth date","v":946684800},{"k":"address","n":"address","t":"address","v":{"street":"1 Main St","city":"Springfield"}}]}]}}`,
+		onePIFSeparator,
+	}, "\n")
+
+	report, err := v.ImportOnePassword(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(report.Imported)
+	if !reflect.DeepEqual(report.Imported, []string{"me", "work/email/gmail"}) {
+		t.Fatalf("unexpected imported locations %v", report.Imported)
+	}
+	if !reflect.DeepEqual(report.Skipped, []string{"existing"}) {
+		t.Fatalf("unexpected skipped locations %v", report.Skipped)
+	}
+
+	cred, err := v.Get("work/email/gmail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" || cred.URL != "https://mail.google.com" {
+		t.Fatal("ImportOnePassword did not import login data")
+	}
+
+	cred, err = v.Get("me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Kind != KindIdentity || cred.Identity == nil {
+		t.Fatal("ImportOnePassword did not import the identity as an identity")
+	}
+	if cred.Identity.Name != "Test" || cred.Identity.Address != "1 Main St, Springfield" {
+		t.Fatal("ImportOnePassword did not import the identity", cred.Identity)
+	}
+	if cred.Fields["birth date"] != "2000-01-01" {
+		t.Fatal("ImportOnePassword did not import the date field", cred.Fields["birth date"])
+	}
+}
+
+func TestImportOnePasswordInvalid(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.ImportOnePassword(strings.NewReader("not json\n"))
+	if err != ErrInvalidOnePassword {
+		t.Fatal("expected ErrInvalidOnePassword for an invalid 1pif, got", err)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	if _, err := archive.Create("other.data"); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.ImportOnePassword(&buf)
+	if err != ErrInvalidOnePassword {
+		t.Fatal("expected ErrInvalidOnePassword for an invalid 1pux, got", err)
+	}
+}
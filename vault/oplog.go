@@ -0,0 +1,278 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// maxOperations is the number of operations kept in a vault's log. The oldest
+// are discarded first, and the log is verified from the hash of the last one
+// discarded.
+const maxOperations = 1000
+
+// logChunkSize is the number of operations sealed together as each chunk of
+// the operation log, of which only the last, if it is not full, is sealed
+// again when an operation is logged. The oldest are discarded a chunk at a
+// time.
+const logChunkSize = 50
+
+// ErrLogTampered is returned from VerifyLog if the vault's operation log is
+// not an unbroken chain, or does not match the vault's credentials.
+var ErrLogTampered = errors.New("vault operation log is inconsistent: the vault was modified out-of-band")
+
+// Operation is a modification of a vault, recorded in its encrypted operation
+// log. Like Changes, it never contains credential data.
+type Operation struct {
+	Time time.Time
+	// User is the user and host that made the modification.
+	User    string
+	Changes Changes
+	// Digest is the digest of the vault's credentials after the
+	// modification, as credentialsDigest returns.
+	Digest [32]byte
+	// Hash chains the operation to the previous one: it is the SHA-256 of
+	// the previous operation's Hash and this operation's other fields.
+	Hash [32]byte
+}
+
+var (
+	// currentUserName is the name currentUser returns, which is only looked
+	// up once, as it does not change while the program runs.
+	currentUserName string
+	currentUserOnce sync.Once
+)
+
+// currentUser returns the name of the current user and host.
+func currentUser() string {
+	currentUserOnce.Do(func() {
+		currentUserName = "unknown"
+		if u, err := user.Current(); err == nil {
+			currentUserName = u.Username
+		}
+		if host, err := os.Hostname(); err == nil {
+			currentUserName += "@" + host
+		}
+	})
+	return currentUserName
+}
+
+// credentialsDigest returns the digest of `p`'s credentials, which must all
+// be opened: the sum modulo 2^256 of their hashes, as entryHash returns. As a
+// sum, it is updated by subtracting the hash of each credential that changed
+// and adding its new hash, without hashing the others.
+func credentialsDigest(p *payload) ([32]byte, error) {
+	var digest [32]byte
+	for location, cred := range p.Credentials {
+		hash, err := entryHash(location, cred)
+		if err != nil {
+			return digest, err
+		}
+		digest = addDigest(digest, hash)
+	}
+	return digest, nil
+}
+
+// addDigest returns the sum of the big-endian numbers `a` and `b` modulo
+// 2^256.
+func addDigest(a [32]byte, b [32]byte) [32]byte {
+	var sum [32]byte
+	carry := 0
+	for i := len(sum) - 1; i >= 0; i-- {
+		n := int(a[i]) + int(b[i]) + carry
+		sum[i], carry = byte(n), n>>8
+	}
+	return sum
+}
+
+// subtractDigest returns `a` minus `b`, both big-endian numbers, modulo
+// 2^256.
+func subtractDigest(a [32]byte, b [32]byte) [32]byte {
+	var difference [32]byte
+	borrow := 0
+	for i := len(difference) - 1; i >= 0; i-- {
+		n := int(a[i]) - int(b[i]) - borrow
+		borrow = 0
+		if n < 0 {
+			n, borrow = n+256, 1
+		}
+		difference[i] = byte(n)
+	}
+	return difference
+}
+
+// chainHash returns the Hash of `op` following the hash `previous`.
+func (op Operation) chainHash(previous [32]byte) [32]byte {
+	h := sha256.New()
+	h.Write(previous[:])
+	binary.Write(h, binary.BigEndian, op.Time.UnixNano())
+	binary.Write(h, binary.BigEndian, uint32(len(op.User)))
+	h.Write([]byte(op.User))
+	h.Write([]byte(op.Changes.String()))
+	h.Write(op.Digest[:])
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// logOperation appends an operation making `changes` to `p`'s log, updating
+// the digest of its credentials from the hashes of those that changed.
+func (p *payload) logOperation(changes []change) {
+	op := Operation{
+		Time: p.Meta.ModifiedAt,
+		User: currentUser(),
+	}
+	for _, c := range changes {
+		switch {
+		case c.before == [32]byte{}:
+			op.Changes.Added = append(op.Changes.Added, c.location)
+		case c.after == [32]byte{}:
+			op.Changes.Removed = append(op.Changes.Removed, c.location)
+		default:
+			op.Changes.Modified = append(op.Changes.Modified, c.location)
+		}
+		p.Digest = addDigest(subtractDigest(p.Digest, c.before), c.after)
+	}
+	op.Digest = p.Digest
+	op.Hash = op.chainHash(p.LogHead)
+	p.LogHead = op.Hash
+	p.LogLength++
+	if p.source == nil || p.source.logOpened {
+		p.Log = append(p.Log, op)
+	} else {
+		p.newOperations = append(p.newOperations, op)
+	}
+}
+
+// log returns `p`'s operation log, opening it if the payload was opened
+// without it.
+func (p *payload) log() ([]Operation, error) {
+	if s := p.source; s != nil && !s.logOpened {
+		var log []Operation
+		for _, chunk := range s.env.Log {
+			var operations []Operation
+			if err := openValue(s.c, chunk, &operations, s.compression); err != nil {
+				return nil, err
+			}
+			log = append(log, operations...)
+		}
+		p.Log = append(log, p.newOperations...)
+		p.newOperations = nil
+		s.logOpened = true
+	}
+	return p.Log, nil
+}
+
+// sealLog seals `p`'s operation log in chunks of logChunkSize operations
+// using `c`, discarding the oldest chunks while there are more than
+// maxOperations operations. If the log was not opened from `source`, its
+// full chunks are kept as they are, and only its last chunk, if it was not
+// full, is sealed again along with the operations logged since.
+func (p *payload) sealLog(c Cipher, source *entrySource, padding Padding, compression Compression) ([][]byte, error) {
+	var chunks [][]byte
+	log := p.Log
+	if source != nil && !source.logOpened {
+		chunks = source.env.Log
+		base, length := source.index.Payload.LogBase, source.index.Payload.LogLength
+		if length%logChunkSize != 0 && len(p.newOperations) > 0 {
+			var last []Operation
+			if err := openValue(source.c, chunks[len(chunks)-1], &last, source.compression); err != nil {
+				return nil, err
+			}
+			chunks = chunks[:len(chunks)-1]
+			length -= len(last)
+			log = append(last, p.newOperations...)
+		} else {
+			log = p.newOperations
+		}
+		chunks = append([][]byte(nil), chunks...)
+		for length+len(log) > maxOperations && len(chunks) > 0 {
+			var first []Operation
+			if err := openValue(source.c, chunks[0], &first, source.compression); err != nil {
+				return nil, err
+			}
+			if len(first) == 0 {
+				return nil, ErrCouldNotDecrypt
+			}
+			base = first[len(first)-1].Hash
+			chunks = chunks[1:]
+			length -= len(first)
+		}
+		p.LogBase, p.LogLength = base, length+len(log)
+	} else {
+		for len(log) > maxOperations {
+			p.LogBase = log[logChunkSize-1].Hash
+			log = log[logChunkSize:]
+		}
+		p.Log, p.LogLength = log, len(log)
+	}
+
+	for len(log) > 0 {
+		n := logChunkSize
+		if len(log) < n {
+			n = len(log)
+		}
+		chunk, err := sealValue(c, log[:n], padding, compression)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+		log = log[n:]
+	}
+	return chunks, nil
+}
+
+// Log returns the operations recorded in the vault's log, oldest first.
+func (v *Vault) Log() ([]Operation, error) {
+	p, err := v.decryptIndex()
+	if err != nil {
+		return nil, err
+	}
+	return p.log()
+}
+
+// VerifyLog checks that the vault's operation log is an unbroken hash chain
+// whose last operation matches the vault's credentials, and returns the hex
+// encoded hash of the last operation. Comparing it with the hash from before
+// a vault was backed up tells whether the restored vault was modified since,
+// and ErrLogTampered is returned if it was modified by anything that did not
+// record the modification. Vaults modified before the log was introduced
+// have an empty log, and an empty hash.
+func (v *Vault) VerifyLog() (string, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return "", err
+	}
+	log, err := p.log()
+	if err != nil {
+		return "", err
+	}
+	if len(log) == 0 {
+		return "", nil
+	}
+
+	head := p.LogBase
+	for _, op := range log {
+		if op.chainHash(head) != op.Hash {
+			return "", ErrLogTampered
+		}
+		head = op.Hash
+	}
+	if head != p.LogHead || len(log) != p.LogLength {
+		return "", ErrLogTampered
+	}
+	digest, err := credentialsDigest(p)
+	if err != nil {
+		return "", err
+	}
+	if digest != p.Digest || !bytes.Equal(digest[:], log[len(log)-1].Digest[:]) {
+		return "", ErrLogTampered
+	}
+	return hex.EncodeToString(head[:]), nil
+}
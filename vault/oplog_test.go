@@ -0,0 +1,106 @@
+package vault
+
+import (
+	"os"
+	"testing"
+)
+
+func TestOperationLog(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Edit("testlocation", Credential{Username: "testuser", Password: "newpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	v, err = Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := v.VerifyLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := v.Log()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 3 {
+		t.Fatal("expected an operation for the creation, addition and edit, got", len(log))
+	}
+	if len(log[1].Changes.Added) != 1 || len(log[2].Changes.Modified) != 1 || log[2].User == "" {
+		t.Fatal("operations do not record their changes:", log)
+	}
+
+	if err = v.Delete("testlocation"); err != nil {
+		t.Fatal(err)
+	}
+	newHead, err := v.VerifyLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHead == head {
+		t.Fatal("log head did not change after a modification")
+	}
+
+	// Modifying the credentials without recording the modification, as
+	// another tool might, is detected.
+	p, err := v.decrypt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Credentials["otherlocation"] = &Credential{Username: "intruder"}
+	if err = v.seal(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.VerifyLog(); err != ErrLogTampered {
+		t.Fatal("expected ErrLogTampered after an unrecorded modification, got", err)
+	}
+
+	// So is rewriting the log.
+	delete(p.Credentials, "otherlocation")
+	log, err = p.log()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log[1].User = "someone else"
+	if err = v.seal(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.VerifyLog(); err != ErrLogTampered {
+		t.Fatal("expected ErrLogTampered after rewriting the log, got", err)
+	}
+}
+
+func TestLogMigration(t *testing.T) {
+	p := newPayload()
+	p.Meta.Version = 2
+	p.Credentials["testlocation"] = &Credential{Username: "testuser", Password: "testpassword"}
+	// Version 2 operations recorded the SHA-256 of every credential.
+	op := Operation{User: "testuser", Changes: Changes{Added: []string{"testlocation"}}, Digest: [32]byte{1}}
+	op.Hash = op.chainHash(p.LogBase)
+	p.Log = []Operation{op}
+	if err := migratePayload(p); err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := credentialsDigest(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Log) != 2 || p.LogLength != 2 || p.Log[1].Digest != digest || p.Digest != digest {
+		t.Fatal("expected an operation recording the new digest, got", p.Log)
+	}
+	if p.Log[1].chainHash(op.Hash) != p.Log[1].Hash || p.LogHead != p.Log[1].Hash {
+		t.Fatal("expected the operation to continue the log")
+	}
+}
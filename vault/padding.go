@@ -0,0 +1,132 @@
+package vault
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+// minPaddedEntries is the fewest entries an envelope is padded to, so that
+// small vaults cannot be told apart by their number of credentials.
+const minPaddedEntries = 16
+
+// Padding is the scheme the sizes of a vault's sealed values, and its number
+// of entries, are padded with, so that an observer of the vault file cannot
+// tell how many credentials it holds or how long they are. Each save only
+// seals again the entries of the credentials that changed, so an observer who
+// compares two versions of the file can still tell how many entries changed
+// between them, though not which credentials they hold.
+type Padding int
+
+const (
+	// PaddingPadme pads each size up to the Padmé scheme's next bucket,
+	// whose granularity grows with the size so that no more than 12% is
+	// added. It is the default.
+	PaddingPadme Padding = iota
+	// PaddingPowerOfTwo pads each size up to the next power of two, which
+	// hides more at the cost of up to doubling the vault.
+	PaddingPowerOfTwo
+	// PaddingNone does not pad the vault.
+	PaddingNone
+)
+
+// ErrUnsupportedPadding is returned if a padding scheme is not known.
+var ErrUnsupportedPadding = errors.New("unsupported padding scheme")
+
+// validate returns ErrUnsupportedPadding if `padding` is not known.
+func (padding Padding) validate() error {
+	if padding < PaddingPadme || padding > PaddingNone {
+		return ErrUnsupportedPadding
+	}
+	return nil
+}
+
+// padme returns `n` rounded up to the next Padmé bucket: keeping only the
+// top bits of `n` needed to encode its binary length, as described in
+// "Reducing Metadata Leakage from Encrypted Files and Communication with
+// PURBs".
+func padme(n int) int {
+	if n < 2 {
+		return n
+	}
+	e := bits.Len(uint(n)) - 1
+	s := bits.Len(uint(e))
+	mask := 1<<uint(e-s) - 1
+	return (n + mask) &^ mask
+}
+
+// bucket returns the size `n` is padded to.
+func (padding Padding) bucket(n int) int {
+	switch padding {
+	case PaddingNone:
+		return n
+	case PaddingPowerOfTwo:
+		size := 1
+		for size < n {
+			size <<= 1
+		}
+		return size
+	default:
+		return padme(n)
+	}
+}
+
+// entries returns the number of entries an envelope holding `n` credentials
+// is padded to.
+func (padding Padding) entries(n int) int {
+	if padding == PaddingNone {
+		return n
+	}
+	if n < minPaddedEntries {
+		n = minPaddedEntries
+	}
+	return padding.bucket(n)
+}
+
+// padEntries appends random entries to `entries`, whose first `real` are
+// sealed entries, until there are as many as `padding` requires. Each is as
+// long as a randomly chosen real entry, or `empty` if there are none, and
+// cannot be told from a sealed entry without the record key.
+func (padding Padding) padEntries(entries [][]byte, real int, empty int) [][]byte {
+	for len(entries) < padding.entries(real) {
+		size := empty
+		if real > 0 {
+			i, err := rand.Int(rand.Reader, big.NewInt(int64(real)))
+			if err != nil {
+				panic(err)
+			}
+			size = len(entries[i.Int64()])
+		}
+		dummy := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, dummy); err != nil {
+			panic(err)
+		}
+		entries = append(entries, dummy)
+	}
+	return entries
+}
+
+// SetPadding sets the scheme the vault is padded with when it is next saved,
+// returning ErrUnsupportedPadding if it is not one of the Padding constants.
+func (v *Vault) SetPadding(padding Padding) error {
+	if err := padding.validate(); err != nil {
+		return err
+	}
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+	p.Padding = padding
+	return v.encrypt(p)
+}
+
+// Padding returns the scheme the vault is padded with.
+func (v *Vault) Padding() (Padding, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return 0, err
+	}
+	return p.Padding, nil
+}
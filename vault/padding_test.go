@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"testing"
+)
+
+func TestPadme(t *testing.T) {
+	for n, padded := range map[int]int{0: 0, 1: 1, 8: 8, 9: 10, 100: 104, 1000: 1024, 1025: 1088} {
+		if padme(n) != padded {
+			t.Fatalf("expected padme(%v) to be %v, got %v", n, padded, padme(n))
+		}
+	}
+	if PaddingPowerOfTwo.bucket(1025) != 2048 || PaddingNone.bucket(1025) != 1025 {
+		t.Fatal("unexpected bucket")
+	}
+	if PaddingPadme.entries(3) != minPaddedEntries || PaddingPadme.entries(17) != 18 || PaddingNone.entries(3) != 3 {
+		t.Fatal("unexpected number of entries")
+	}
+}
+
+// envelopeShape returns the number of entries of the vault's envelope, and
+// the size of its index and each entry.
+func envelopeShape(t *testing.T, v *Vault) []int {
+	var env envelope
+	if err := gob.NewDecoder(bytes.NewReader(v.data)).Decode(&env); err != nil {
+		t.Fatal(err)
+	}
+	shape := []int{len(env.Entries), len(env.Index)}
+	for _, entry := range env.Entries {
+		shape = append(shape, len(entry))
+	}
+	return shape
+}
+
+func TestPadding(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("location1", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	one := envelopeShape(t, v)
+	if err = v.Add("location2", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	two := envelopeShape(t, v)
+	if len(one) != len(two) {
+		t.Fatal("adding a credential changed the number of entries")
+	}
+	for i := 2; i < len(one); i++ {
+		if one[i] != one[2] || two[i] != one[2] {
+			t.Fatal("entries of similar credentials have different sizes")
+		}
+	}
+
+	if err = v.SetPadding(PaddingNone); err != nil {
+		t.Fatal(err)
+	}
+	if shape := envelopeShape(t, v); shape[0] != 2 {
+		t.Fatal("expected an entry for each credential without padding, got", shape[0])
+	}
+	for _, padding := range []Padding{-1, PaddingNone + 1} {
+		if err = v.SetPadding(padding); err != ErrUnsupportedPadding {
+			t.Fatal("expected ErrUnsupportedPadding, got", err)
+		}
+	}
+	if err = v.SetPadding(PaddingPowerOfTwo); err != nil {
+		t.Fatal(err)
+	}
+	if shape := envelopeShape(t, v); shape[0] != minPaddedEntries {
+		t.Fatal("expected the entries to be padded to", minPaddedEntries, "got", shape[0])
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if padding, err := vopen.Padding(); err != nil || padding != PaddingPowerOfTwo {
+		t.Fatal("expected the padding to be kept, got", padding, err)
+	}
+	locations, err := vopen.Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 2 {
+		t.Fatal("expected the padding to be ignored when opening the vault, got", locations)
+	}
+}
@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PassDecryptFunc decrypts the pass entry stored in the file `filename`.
+type PassDecryptFunc func(filename string) ([]byte, error)
+
+// GPGDecrypt decrypts `filename` by running gpg, which uses the user's
+// gpg-agent to unlock their private key.
+func GPGDecrypt(filename string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("gpg", "--quiet", "--batch", "--decrypt", filename)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gpg: %v: %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// passCredential converts the decrypted contents of a pass entry to a
+// Credential. The first line is the password. Following lines of the form
+// "username: value", "login: value", "user: value" or "url: value" set the
+// corresponding fields, and the remaining lines become notes.
+func passCredential(contents string) Credential {
+	lines := strings.Split(strings.TrimRight(contents, "\n"), "\n")
+	c := Credential{Password: lines[0]}
+
+	var notes []string
+	for _, line := range lines[1:] {
+		key, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			key, value = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "username", "login", "user":
+			if c.Username == "" {
+				c.Username = value
+				continue
+			}
+		case "url":
+			if c.URL == "" {
+				c.URL = value
+				continue
+			}
+		}
+		notes = append(notes, line)
+	}
+	c.Notes = strings.TrimSpace(strings.Join(notes, "\n"))
+	return c
+}
+
+// ImportPass imports the entries of the password store (as used by pass) in
+// the directory `dir`, decrypting each entry using `decrypt`, or GPGDecrypt
+// if `decrypt` is nil. Each entry's path relative to `dir`, without its
+// ".gpg" extension, becomes its location. Hidden files and directories, such
+// as the store's git repository, are ignored, and existing locations are
+// skipped. The import is atomic: if any entry cannot be decrypted, no
+// credentials are imported.
+func (v *Vault) ImportPass(dir string, decrypt PassDecryptFunc) (ImportReport, error) {
+	var report ImportReport
+	if decrypt == nil {
+		decrypt = GPGDecrypt
+	}
+
+	var entries []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".gpg") {
+			entries = append(entries, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	err = v.Batch(func(tx *Tx) error {
+		for _, path := range entries {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			location := filepath.ToSlash(strings.TrimSuffix(rel, ".gpg"))
+
+			contents, err := decrypt(path)
+			if err != nil {
+				return fmt.Errorf("could not decrypt %v: %v", location, err)
+			}
+			if err := report.add(tx, location, passCredential(string(contents))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	return report, nil
+}
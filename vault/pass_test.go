@@ -0,0 +1,90 @@
+package vault
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestImportPass(t *testing.T) {
+	relaxDefaults(t)
+	dir, err := ioutil.TempDir("", "password-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	entries := map[string]string{
+		"email/gmail.gpg":  "testpass\nlogin: testuser\nurl: https://mail.google.com\nrecovery codes:\n1234 5678\n",
+		"bank.gpg":         "testpass2\n",
+		".gpg-id":          "test@example.com\n",
+		".git/objects.gpg": "not an entry\n",
+	}
+	for name, contents := range entries {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := v.ImportPass(dir, ioutil.ReadFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(report.Imported)
+	if !reflect.DeepEqual(report.Imported, []string{"bank", "email/gmail"}) {
+		t.Fatalf("unexpected imported locations %v", report.Imported)
+	}
+
+	cred, err := v.Get("email/gmail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpass" || cred.Username != "testuser" || cred.URL != "https://mail.google.com" {
+		t.Fatal("ImportPass did not import credential data", cred)
+	}
+	if cred.Notes != "recovery codes:\n1234 5678" {
+		t.Fatalf("ImportPass did not import notes: %q", cred.Notes)
+	}
+
+	cred, err = v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpass2" || cred.Username != "" || cred.Notes != "" {
+		t.Fatal("ImportPass did not import a password-only entry")
+	}
+
+	// imports are atomic if decryption fails.
+	v, err = New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = v.ImportPass(dir, func(filename string) ([]byte, error) {
+		if filepath.Base(filename) == "gmail.gpg" {
+			return nil, errors.New("no secret key")
+		}
+		return ioutil.ReadFile(filename)
+	})
+	if err == nil {
+		t.Fatal("expected ImportPass to fail when an entry cannot be decrypted")
+	}
+	locations, err := v.Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 0 {
+		t.Fatal("ImportPass imported credentials after failing")
+	}
+}
@@ -0,0 +1,61 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MinPassphraseEntropy is the estimated entropy, in bits, a passphrase must
+// have to be accepted by NewWithOptions and ChangePassphraseWithOptions when
+// their options do not set a minimum. Forty bits take a random seven
+// character password, or four words from a wordlist.
+var MinPassphraseEntropy = 40.0
+
+type (
+	// PassphraseOptions configures the strength check of a passphrase
+	// protecting a vault. The zero value requires MinPassphraseEntropy.
+	PassphraseOptions struct {
+		// MinEntropy, if set, is the estimated entropy in bits the
+		// passphrase must have, in place of MinPassphraseEntropy.
+		MinEntropy float64
+		// Force accepts the passphrase however weak it is.
+		Force bool
+	}
+
+	// WeakPassphraseError is returned if a passphrase protecting a vault is
+	// estimated by EstimateStrength to have less than the minimum entropy.
+	WeakPassphraseError struct {
+		// Entropy is the passphrase's estimated entropy in bits, and
+		// MinEntropy the minimum it needed.
+		Entropy    float64
+		MinEntropy float64
+		// Feedback contains suggestions for a stronger passphrase.
+		Feedback []string
+	}
+)
+
+func (e *WeakPassphraseError) Error() string {
+	msg := fmt.Sprintf("passphrase is too weak: it has an estimated %.0f bits of entropy, but at least %.0f are needed", e.Entropy, e.MinEntropy)
+	if len(e.Feedback) > 0 {
+		msg += ". " + strings.Join(e.Feedback, ". ")
+	}
+	return msg
+}
+
+// check returns a WeakPassphraseError if `passphrase` is too weak to protect a
+// vault under `opts`. The passphrase of a key slot also needing `factors`,
+// such as a keyfile, is not checked, as it need not be the only secret.
+func (opts PassphraseOptions) check(passphrase string, factors unlockFactors) error {
+	if opts.Force || !factors.empty() {
+		return nil
+	}
+	min := opts.MinEntropy
+	if min == 0 {
+		min = MinPassphraseEntropy
+	}
+	strength := EstimateStrength(passphrase)
+	if strength.Entropy >= min {
+		return nil
+	}
+	return &WeakPassphraseError{Entropy: strength.Entropy, MinEntropy: min, Feedback: strength.Feedback}
+}
@@ -0,0 +1,67 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs the tests, then removes the backups Save kept of the vault
+// files they saved.
+func TestMain(m *testing.M) {
+	code := m.Run()
+	backups, _ := filepath.Glob("*.bak.*")
+	for _, backup := range backups {
+		os.Remove(backup)
+	}
+	os.Exit(code)
+}
+
+// relaxDefaults runs the test `t` without a minimum passphrase strength or
+// KDF calibration, as most tests use short passphrases and create many
+// vaults, restoring the defaults when it ends.
+func relaxDefaults(t *testing.T) {
+	min, target := MinPassphraseEntropy, KDFTargetDuration
+	MinPassphraseEntropy, KDFTargetDuration = 0, 0
+	t.Cleanup(func() {
+		MinPassphraseEntropy, KDFTargetDuration = min, target
+	})
+}
+
+// TestWeakPassphrase runs with the default minimum passphrase strength.
+func TestWeakPassphrase(t *testing.T) {
+	_, err := NewWithOptions("password1", Options{KDF: KDFParams{N: 1024}})
+	weak, ok := err.(*WeakPassphraseError)
+	if !ok {
+		t.Fatal("expected a WeakPassphraseError, got", err)
+	}
+	if weak.Entropy >= weak.MinEntropy || weak.MinEntropy != 40 || len(weak.Feedback) == 0 {
+		t.Fatal("unexpected WeakPassphraseError", weak)
+	}
+	if _, err = NewWithOptions("password1", Options{KDF: KDFParams{N: 1024}, PassphraseOptions: PassphraseOptions{Force: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewWithOptions("password1", Options{KDF: KDFParams{N: 1024}, PassphraseOptions: PassphraseOptions{MinEntropy: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewWithOptions("correct horse battery staple", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.ChangePassphrase("correct horse battery staple", "letmein"); err == nil {
+		t.Fatal("expected ChangePassphrase to reject a weak passphrase")
+	}
+	if err = v.ChangePassphraseWithOptions("correct horse battery staple", "letmein", PassphraseOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A passphrase needing a keyfile as well is not the only secret.
+	if err = GenerateKeyfile("test.key"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("test.key")
+	if _, err = NewWithOptions("", Options{KDF: KDFParams{N: 1024}, Keyfile: "test.key"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,293 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sort"
+	"time"
+)
+
+type (
+	// payload is the plaintext contents of a vault, which is gob encoded and
+	// encrypted as a single unit.
+	payload struct {
+		Credentials map[string]*Credential
+		Trash       map[string]*TrashedCredential
+		Policies    map[string]*Policy
+		// Aliases maps alternative names to the locations of the
+		// credentials they refer to.
+		Aliases   map[string]string
+		Templates map[string]*Template
+		// NormalizeLocations is set if locations are compared ignoring
+		// case and Unicode normalization.
+		NormalizeLocations bool
+		// Padding is the scheme the envelope is padded with.
+		Padding Padding
+		// SigningKey, if set, signs the vault file on every Save.
+		SigningKey []byte
+		// EntryKey keys the IDs of the envelope's entries, which are
+		// derived from the credentials' locations.
+		EntryKey []byte
+		// KeyCacheEpoch is replaced whenever the data key is rotated or
+		// the passphrase changed, so that keys cached by CacheKey before
+		// then no longer open the vault.
+		KeyCacheEpoch [32]byte
+		// Backups, if set, configures the backups Save keeps.
+		Backups *BackupOptions
+		Meta    Meta
+		// Log is the vault's operation log, and LogBase the hash its
+		// first operation is chained to. LogHead is the hash of its
+		// last operation, and LogLength its number of operations, which
+		// are kept in the index while the log itself is not.
+		Log       []Operation
+		LogBase   [32]byte
+		LogHead   [32]byte
+		LogLength int
+		// Digest is the digest of the credentials, as credentialsDigest
+		// returns, which is kept up to date as they change, so that an
+		// operation is logged without opening every credential.
+		Digest [32]byte
+		// RecordKey is the key the envelope's entries, trash and
+		// operation log are sealed with, so that the records that did
+		// not change can be kept as they are across sessions, each of
+		// which has a data key of its own.
+		RecordKey [32]byte
+
+		// source is the envelope the payload was opened from, holding
+		// the credentials whose values are nil, and the trash and
+		// operation log if they were not opened yet.
+		source *entrySource
+		// opened holds the hash, as entryHash returns, of each
+		// credential as decryptForUpdate opened it, which encrypt
+		// compares with to find the credentials that changed. It is nil
+		// unless the payload is being updated.
+		opened map[string][32]byte
+		// newOperations are the operations logged while the operation
+		// log was not opened.
+		newOperations []Operation
+	}
+
+	// change is a change to one of a payload's credentials: its location
+	// and the hashes, as entryHash returns, of its credential before and
+	// after, which are zero if it was added or removed.
+	change struct {
+		location      string
+		before, after [32]byte
+	}
+
+	// TrashedCredential is a credential that has been moved to the trash,
+	// along with the time at which it was trashed.
+	TrashedCredential struct {
+		Credential Credential
+		TrashedAt  time.Time
+	}
+)
+
+// newPayload returns an empty payload.
+func newPayload() *payload {
+	p := &payload{Meta: Meta{CreatedAt: time.Now()}}
+	p.init()
+	return p
+}
+
+// init allocates any sections of the payload that were empty when it was
+// encoded.
+func (p *payload) init() {
+	if p.Credentials == nil {
+		p.Credentials = make(map[string]*Credential)
+	}
+	if p.Trash == nil {
+		p.Trash = make(map[string]*TrashedCredential)
+	}
+	if p.Policies == nil {
+		p.Policies = make(map[string]*Policy)
+	}
+	if p.Aliases == nil {
+		p.Aliases = make(map[string]string)
+	}
+	if p.Templates == nil {
+		p.Templates = make(map[string]*Template)
+	}
+}
+
+// decodePayload decodes a gob encoded payload. Vaults written before the
+// payload was introduced contain only a map of locations to credentials,
+// which is decoded into the Credentials section of a new payload.
+func decodePayload(data []byte) (*payload, error) {
+	p := &payload{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(p); err != nil {
+		credentials := make(map[string]*Credential)
+		if gob.NewDecoder(bytes.NewReader(data)).Decode(&credentials) != nil {
+			return nil, err
+		}
+		p.Credentials = credentials
+	}
+	p.init()
+	return p, nil
+}
+
+// credential returns the credential at `location`, opening its entry if the
+// payload was opened without it, or ErrNoSuchCredential if there is none.
+func (p *payload) credential(location string) (*Credential, error) {
+	cred, ok := p.Credentials[location]
+	if !ok {
+		return nil, ErrNoSuchCredential
+	}
+	if cred != nil {
+		return cred, nil
+	}
+	if err := p.open([]string{location}); err != nil {
+		return nil, err
+	}
+	return p.Credentials[location], nil
+}
+
+// openAll opens every credential the payload was opened without.
+func (p *payload) openAll() error {
+	var locations []string
+	for location, cred := range p.Credentials {
+		if cred == nil {
+			locations = append(locations, location)
+		}
+	}
+	return p.open(locations)
+}
+
+// open opens the credentials at `locations` from the payload's source,
+// recording their hashes if the payload is being updated.
+func (p *payload) open(locations []string) error {
+	if len(locations) == 0 {
+		return nil
+	}
+	if p.source == nil {
+		return ErrCouldNotDecrypt
+	}
+	sort.Strings(locations)
+	return p.source.openEntries(locations, func(location string, cred *Credential) error {
+		if p.opened != nil {
+			hash, err := entryHash(location, cred)
+			if err != nil {
+				return err
+			}
+			p.opened[location] = hash
+		}
+		p.Credentials[location] = cred
+		return nil
+	})
+}
+
+// detach opens whatever the payload was opened without from its source, and
+// detaches it from the source, so that it is sealed again whole.
+func (p *payload) detach() error {
+	if err := p.openAll(); err != nil {
+		return err
+	}
+	if _, err := p.trash(); err != nil {
+		return err
+	}
+	if _, err := p.log(); err != nil {
+		return err
+	}
+	p.source = nil
+	return nil
+}
+
+// trash returns the payload's trash, opening it if the payload was opened
+// without it.
+func (p *payload) trash() (map[string]*TrashedCredential, error) {
+	if s := p.source; s != nil && !s.trashOpened {
+		trash := make(map[string]*TrashedCredential)
+		if s.env.Trash != nil {
+			if err := openValue(s.c, s.env.Trash, &trash, s.compression); err != nil {
+				return nil, err
+			}
+		}
+		p.Trash = trash
+		s.trashOpened = true
+	}
+	return p.Trash, nil
+}
+
+// unchanged returns true if the credential at `location` was not opened, or
+// is as it was opened.
+func (p *payload) unchanged(location string) (bool, error) {
+	cred := p.Credentials[location]
+	if cred == nil {
+		return true, nil
+	}
+	before, ok := p.opened[location]
+	if !ok {
+		return false, nil
+	}
+	after, err := entryHash(location, cred)
+	return after == before, err
+}
+
+// previousHash returns the hash, as entryHash returns, of the credential at
+// `location` as it was before the payload was updated, opening it from the
+// payload's source if needed, or zero if there was none.
+func (p *payload) previousHash(location string) ([32]byte, error) {
+	if hash, ok := p.opened[location]; ok {
+		return hash, nil
+	}
+	var hash [32]byte
+	if p.source == nil {
+		return hash, nil
+	}
+	if _, ok := p.source.position(location); !ok {
+		return hash, nil
+	}
+	err := p.source.openEntries([]string{location}, func(location string, cred *Credential) error {
+		var err error
+		hash, err = entryHash(location, cred)
+		return err
+	})
+	return hash, err
+}
+
+// changes returns the changes to the credentials of `p` since
+// decryptForUpdate opened it, sorted by location. Only the credentials that
+// were opened, added, or removed are compared, so that the others are not
+// opened.
+func (p *payload) changes() ([]change, error) {
+	var changes []change
+	for location, cred := range p.Credentials {
+		if cred == nil {
+			continue
+		}
+		before, err := p.previousHash(location)
+		if err != nil {
+			return nil, err
+		}
+		after, err := entryHash(location, cred)
+		if err != nil {
+			return nil, err
+		}
+		if before != after {
+			changes = append(changes, change{location: location, before: before, after: after})
+		}
+	}
+	for location, before := range p.opened {
+		if _, ok := p.Credentials[location]; !ok {
+			changes = append(changes, change{location: location, before: before})
+		}
+	}
+	if p.source != nil {
+		for _, location := range p.source.index.Locations {
+			_, exists := p.Credentials[location]
+			_, opened := p.opened[location]
+			if exists || opened {
+				continue
+			}
+			before, err := p.previousHash(location)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, change{location: location, before: before})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].location < changes[j].location
+	})
+	return changes, nil
+}
@@ -0,0 +1,33 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestDecodeLegacyPayload(t *testing.T) {
+	credentials := map[string]*Credential{
+		"testlocation": {Username: "testuser", Password: "testpass"},
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(credentials); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := decodePayload(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, ok := p.Credentials["testlocation"]
+	if !ok || cred.Username != "testuser" || cred.Password != "testpass" {
+		t.Fatal("decodePayload did not decode a legacy credential map")
+	}
+	if p.Trash == nil {
+		t.Fatal("decodePayload did not initialize the trash")
+	}
+
+	if _, err = decodePayload([]byte("not a payload")); err == nil {
+		t.Fatal("expected decodePayload to fail on invalid data")
+	}
+}
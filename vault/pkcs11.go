@@ -0,0 +1,134 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// maxPKCS11LabelSize bounds the size of the key label of a PKCS#11
+	// slot.
+	maxPKCS11LabelSize = 1024
+
+	// maxPKCS11KeySize bounds the size of the wrapped master key of a
+	// PKCS#11 slot.
+	maxPKCS11KeySize = 16 * 1024
+
+	// pkcs11Mechanism is the mechanism PKCS11Tool wraps keys with.
+	pkcs11Mechanism = "RSA-PKCS-OAEP"
+)
+
+// PKCS11Token wraps and unwraps keys using a key held by a PKCS#11 token,
+// such as a smartcard or an HSM, which never leaves it.
+type PKCS11Token interface {
+	// Wrap wraps `key` using the token's key labelled `label`.
+	Wrap(label string, key []byte) ([]byte, error)
+	// Unwrap unwraps `wrapped` using the token's key labelled `label`.
+	Unwrap(label string, wrapped []byte) ([]byte, error)
+}
+
+// PKCS11Tool is a PKCS#11 token used through OpenSC's pkcs11-tool, which
+// wraps keys by encrypting them to the public key of an RSA key pair on the
+// token using RSA-OAEP, and unwraps them by decrypting them on the token.
+// Unwrapping needs the user to log in to the token, and pkcs11-tool prompts
+// for its PIN.
+type PKCS11Tool struct {
+	// Module is the path of the token's PKCS#11 module, such as
+	// /usr/lib/softhsm/libsofthsm2.so.
+	Module string
+}
+
+// runPKCS11Tool runs pkcs11-tool with `args`, reading its standard input from
+// `stdin`, and returns its output.
+func runPKCS11Tool(stdin io.Reader, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("pkcs11-tool", args...)
+	cmd.Stdin = stdin
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11-tool: %v: %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Wrap encrypts `key` to the public key labelled `label`, passing it to
+// pkcs11-tool on its standard input.
+func (t PKCS11Tool) Wrap(label string, key []byte) ([]byte, error) {
+	return runPKCS11Tool(bytes.NewReader(key), "--module", t.Module, "--encrypt", "--label", label, "--mechanism", pkcs11Mechanism)
+}
+
+// Unwrap decrypts `wrapped` using the private key labelled `label`, logging
+// in to the token, for which pkcs11-tool prompts on the terminal. The wrapped
+// key is passed in a temporary file, as standard input is left to the
+// prompt.
+func (t PKCS11Tool) Unwrap(label string, wrapped []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "masterkey-pkcs11")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "wrapped")
+	if err = ioutil.WriteFile(input, wrapped, 0600); err != nil {
+		return nil, err
+	}
+	return runPKCS11Tool(os.Stdin, "--module", t.Module, "--login", "--decrypt", "--label", label, "--mechanism", pkcs11Mechanism, "--input-file", input)
+}
+
+// newPKCS11KeySlot returns a PKCS#11 slot wrapping the vault's master key
+// using the key labelled `label` on `token`.
+func (v *Vault) newPKCS11KeySlot(label string, token PKCS11Token) (keySlot, error) {
+	if label == "" || len(label) > maxPKCS11LabelSize {
+		return keySlot{}, fmt.Errorf("invalid PKCS#11 key label %q", label)
+	}
+	wrapped, err := token.Wrap(label, v.keys.master[:])
+	if err != nil {
+		return keySlot{}, err
+	}
+	if len(wrapped) == 0 || len(wrapped) > maxPKCS11KeySize {
+		return keySlot{}, fmt.Errorf("PKCS#11 token returned %v bytes for %v", len(wrapped), label)
+	}
+	return keySlot{
+		kdf:         KDFParams{}.withDefaults(),
+		salt:        randomSalt(),
+		pkcs11Label: label,
+		wrapped:     wrapped,
+	}, nil
+}
+
+// AddPKCS11KeySlot adds a PKCS#11 slot to the vault, wrapping the master key
+// using the key labelled `label` on `token`, such as a PKCS11Tool, and
+// returns the number of the new slot. The vault can then be opened without a
+// passphrase using OpenWithOptions with PKCS11 set to the token, so once it
+// is reopened that way, its passphrase slots can be removed to leave it
+// unlocked only by keys that never leave the token. The slot is written by
+// the next Save.
+func (v *Vault) AddPKCS11KeySlot(label string, token PKCS11Token) (int, error) {
+	if v.closed {
+		return 0, ErrLocked
+	}
+	if v.readOnly {
+		return 0, ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return 0, ErrAgeVault
+	}
+	if v.outer != nil {
+		return 0, ErrHiddenVault
+	}
+	if len(v.slots) >= maxKeySlots {
+		return 0, ErrTooManyKeySlots
+	}
+	slot, err := v.newPKCS11KeySlot(label, token)
+	if err != nil {
+		return 0, err
+	}
+	v.slots = append(v.slots, slot)
+	return len(v.slots) - 1, nil
+}
@@ -0,0 +1,93 @@
+package vault
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"testing"
+)
+
+var errNoSuchKey = errors.New("no key with that label")
+
+// testToken is a PKCS#11 token wrapping keys by XORing them with its keys,
+// held in memory.
+type testToken map[string][keyLen]byte
+
+func (token testToken) xor(label string, b []byte) ([]byte, error) {
+	key, ok := token[label]
+	if !ok {
+		return nil, errNoSuchKey
+	}
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ key[i%keyLen]
+	}
+	return out, nil
+}
+
+func (token testToken) Wrap(label string, key []byte) ([]byte, error) {
+	return token.xor(label, key)
+}
+
+func (token testToken) Unwrap(label string, wrapped []byte) ([]byte, error) {
+	return token.xor(label, wrapped)
+}
+
+func TestPKCS11(t *testing.T) {
+	relaxDefaults(t)
+	var key [keyLen]byte
+	rand.Read(key[:])
+	token := testToken{"vault-key": key}
+
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.AddPKCS11KeySlot("missing", token); err != errNoSuchKey {
+		t.Fatal("expected the token's error, got", err)
+	}
+	slot, err := v.AddPKCS11KeySlot("vault-key", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.ListKeySlots()[slot].PKCS11Label != "vault-key" {
+		t.Fatal("expected the new slot to be labelled vault-key, got", v.ListKeySlots()[slot])
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	// Once opened using the token, the passphrase slot can be removed so
+	// that only the token unlocks the vault.
+	vopen, err := OpenWithOptions("pass.db", "", OpenOptions{PKCS11: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.ChangePassphrase("", "newpass"); err != ErrNoPassphrase {
+		t.Fatal("expected ErrNoPassphrase, got", err)
+	}
+	if err = vopen.RemoveKeySlot(0); err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "testpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt opening with the removed passphrase, got", err)
+	}
+	vopen, err = OpenWithOptions("pass.db", "", OpenOptions{PKCS11: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := vopen.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpassword" {
+		t.Fatal("vault opened using the token had the wrong password:", cred.Password)
+	}
+}
@@ -0,0 +1,168 @@
+package vault
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPolicyAttempts is the number of passwords generated for a location with a
+// policy before giving up on finding one that conforms.
+const maxPolicyAttempts = 100
+
+var (
+	// ErrNoPolicy is returned from GetPolicy if the location has no policy.
+	ErrNoPolicy = errors.New("no password policy for specified location")
+
+	// ErrPolicyViolation is returned from Policy.Check if a password does
+	// not conform to the policy.
+	ErrPolicyViolation = errors.New("password does not conform to the password policy")
+)
+
+// Policy is a set of rules a site imposes on its passwords. Policies are
+// attached to locations using SetPolicy, and passwords generated for those
+// locations conform to them.
+type Policy struct {
+	// MinLength and MaxLength bound the length of the password. Zero means
+	// no bound.
+	MinLength int
+	MaxLength int
+	// Classes are the character classes the site allows. If zero, every
+	// class is allowed.
+	Classes CharClass
+	// Required are the character classes of which the password must contain
+	// at least one character.
+	Required CharClass
+	// Symbols are the symbols the site allows. If empty, every ASCII
+	// punctuation character is allowed.
+	Symbols string
+}
+
+// apply returns `opts` adjusted to generate passwords conforming to the
+// policy.
+func (policy Policy) apply(opts GenerateOptions) GenerateOptions {
+	if opts.Mode == ModePassphrase {
+		return opts
+	}
+	if opts.Length == 0 {
+		opts.Length = DefaultGenerateLength
+	}
+	if policy.MinLength != 0 && opts.Length < policy.MinLength {
+		opts.Length = policy.MinLength
+	}
+	if policy.MaxLength != 0 && opts.Length > policy.MaxLength {
+		opts.Length = policy.MaxLength
+	}
+	if policy.Classes != 0 {
+		if opts.Classes == 0 {
+			opts.Classes = ClassAll
+		}
+		opts.Classes &= policy.Classes
+		opts.Required &= policy.Classes
+		if policy.Classes&ClassSymbols == 0 {
+			opts.NoSymbols = true
+		}
+	}
+	opts.Required |= policy.Required
+	if policy.Symbols != "" {
+		opts.Symbols = policy.Symbols
+	}
+	return opts
+}
+
+// Check returns ErrPolicyViolation if `password` does not conform to the
+// policy.
+func (policy Policy) Check(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength || (policy.MaxLength != 0 && length > policy.MaxLength) {
+		return ErrPolicyViolation
+	}
+
+	allowed := policy.Classes
+	if allowed == 0 {
+		allowed = ClassAll
+	}
+	symbols := policy.Symbols
+	if symbols == "" {
+		symbols = symbolCharacters
+	}
+	var present CharClass
+	for _, r := range password {
+		var class CharClass
+		switch {
+		case strings.ContainsRune(lowerCharacters, r):
+			class = ClassLower
+		case strings.ContainsRune(upperCharacters, r):
+			class = ClassUpper
+		case strings.ContainsRune(digitCharacters, r):
+			class = ClassDigits
+		case strings.ContainsRune(symbols, r):
+			class = ClassSymbols
+		default:
+			return ErrPolicyViolation
+		}
+		if allowed&class == 0 {
+			return ErrPolicyViolation
+		}
+		present |= class
+	}
+	if present&policy.Required != policy.Required {
+		return ErrPolicyViolation
+	}
+	return nil
+}
+
+// SetPolicy attaches `policy` to `location`, which need not exist yet. A zero
+// `policy` removes the location's policy. Policies belong to locations rather
+// than credentials, so they are unaffected by deleting or replacing the
+// credential at `location`.
+func (v *Vault) SetPolicy(location string, policy Policy) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	if policy == (Policy{}) {
+		delete(p.Policies, location)
+	} else {
+		p.Policies[location] = &policy
+	}
+
+	return v.encrypt(p)
+}
+
+// GetPolicy returns the policy attached to `location`, or ErrNoPolicy if it
+// has none.
+func (v *Vault) GetPolicy(location string) (*Policy, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	policy, ok := p.Policies[location]
+	if !ok {
+		return nil, ErrNoPolicy
+	}
+	return policy, nil
+}
+
+// generate generates a password for `location` according to `opts` and the
+// location's policy, if any.
+func (p *payload) generate(location string, opts GenerateOptions) (string, error) {
+	policy, ok := p.Policies[location]
+	if !ok {
+		return GeneratePassword(opts)
+	}
+
+	opts = policy.apply(opts)
+	for i := 0; i < maxPolicyAttempts; i++ {
+		password, err := GeneratePassword(opts)
+		if err != nil {
+			return "", err
+		}
+		if policy.Check(password) == nil {
+			return password, nil
+		}
+	}
+	return "", ErrInvalidGenerateOptions
+}
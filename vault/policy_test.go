@@ -0,0 +1,77 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxLength: 12, Classes: ClassLower | ClassDigits | ClassSymbols, Required: ClassDigits, Symbols: "!-"}
+	valid := []string{"abcdefg1", "abc-def!1234"}
+	invalid := []string{"abcdef1", "abcdefghijk12", "abcdefgh", "Abcdefg1", "abcdefg1#", "abcdefg1 "}
+	for _, password := range valid {
+		if err := policy.Check(password); err != nil {
+			t.Fatalf("expected %q to conform to the policy, got %v", password, err)
+		}
+	}
+	for _, password := range invalid {
+		if err := policy.Check(password); err != ErrPolicyViolation {
+			t.Fatalf("expected ErrPolicyViolation for %q, got %v", password, err)
+		}
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.GetPolicy("bank"); err != ErrNoPolicy {
+		t.Fatal("expected ErrNoPolicy for a location without a policy, got", err)
+	}
+
+	policy := Policy{MaxLength: 12, Classes: ClassLower | ClassUpper | ClassDigits, Required: ClassDigits}
+	if err = v.SetPolicy("bank", policy); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := v.GetPolicy("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stored != policy {
+		t.Fatal("GetPolicy did not return the stored policy")
+	}
+
+	if err = v.GenerateWithOptions("bank", "testuser", GenerateOptions{Length: 32, Required: ClassSymbols}); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cred.Password) != 12 || policy.Check(cred.Password) != nil {
+		t.Fatalf("GenerateWithOptions generated %q, which does not conform to the policy", cred.Password)
+	}
+
+	if err = v.Delete("bank"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Generate("bank", "testuser"); err != nil {
+		t.Fatal(err)
+	}
+	cred, err = v.Get("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Check(cred.Password) != nil || strings.Contains(cred.Password, " ") {
+		t.Fatalf("Generate generated %q, which does not conform to the policy", cred.Password)
+	}
+
+	if err = v.SetPolicy("bank", Policy{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.GetPolicy("bank"); err != ErrNoPolicy {
+		t.Fatal("expected a zero policy to remove the policy, got", err)
+	}
+}
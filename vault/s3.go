@@ -0,0 +1,150 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// S3Storage stores a vault as an object in an S3 bucket, or in a bucket of an
+// S3-compatible service such as MinIO, using the AWS CLI, which finds
+// credentials in the standard places: the environment, the shared
+// credentials and config files, or the instance's role. Concurrent writers
+// are detected using conditional puts: Store only replaces the object if it
+// is still the one last loaded or stored through the same S3Storage, and
+// otherwise returns ErrStorageConflict, so the same *S3Storage must be used
+// to open and save a vault.
+type S3Storage struct {
+	// Bucket and Key name the object the vault is stored as.
+	Bucket string
+	Key    string
+	// Endpoint, if set, is the URL of an S3-compatible service, such as
+	// http://localhost:9000 for MinIO.
+	Endpoint string
+	// Region, if set, is the bucket's region, overriding the configured
+	// one.
+	Region string
+	// ServerSideEncryption, if set, is the server-side encryption the
+	// object is stored with, AES256 or aws:kms, using the KMS key
+	// KMSKeyID if it is set.
+	ServerSideEncryption string
+	KMSKeyID             string
+	// etag is the ETag of the object as last loaded or stored, or empty
+	// if there was none.
+	etag string
+}
+
+// s3Object is the output of the AWS CLI's get-object and put-object commands.
+type s3Object struct {
+	ETag string
+}
+
+// runAWS runs the AWS CLI's s3api command `command` on the object with
+// `args`, and returns its decoded output. Errors reported by S3 for a missing
+// object or a failed condition are returned as errors for which os.IsNotExist
+// is true, and as ErrStorageConflict.
+func (s *S3Storage) runAWS(command string, args ...string) (s3Object, error) {
+	args = append([]string{"s3api", command, "--bucket", s.Bucket, "--key", s.Key, "--output", "json"}, args...)
+	if s.Endpoint != "" {
+		args = append(args, "--endpoint-url", s.Endpoint)
+	}
+	if s.Region != "" {
+		args = append(args, "--region", s.Region)
+	}
+
+	var object s3Object
+	var stderr bytes.Buffer
+	cmd := exec.Command("aws", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return object, s.awsError(err, stderr.String())
+	}
+	if err = json.Unmarshal(out, &object); err != nil {
+		return object, fmt.Errorf("aws: unexpected output: %v", err)
+	}
+	return object, nil
+}
+
+// awsError returns the error of the AWS CLI exiting with `err`, having written
+// `stderr`.
+func (s *S3Storage) awsError(err error, stderr string) error {
+	switch {
+	case strings.Contains(stderr, "NoSuchKey") || strings.Contains(stderr, "(404)"):
+		return &os.PathError{Op: "load", Path: fmt.Sprintf("s3://%v/%v", s.Bucket, s.Key), Err: os.ErrNotExist}
+	case strings.Contains(stderr, "PreconditionFailed") || strings.Contains(stderr, "ConditionalRequestConflict"):
+		return ErrStorageConflict
+	}
+	return fmt.Errorf("aws: %v: %v", err, strings.TrimSpace(stderr))
+}
+
+// Load downloads the object, recording its ETag.
+func (s *S3Storage) Load() ([]byte, error) {
+	dir, err := ioutil.TempDir("", "masterkey-s3")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "vault")
+	object, err := s.runAWS("get-object", path)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s.etag = object.ETag
+	return contents, nil
+}
+
+// Store uploads `contents` as the object if it still has the ETag it was last
+// loaded or stored with, or if it does not exist and none was loaded, and
+// returns ErrStorageConflict otherwise. A put replaces the object whole, so
+// a failed one leaves it as it was.
+func (s *S3Storage) Store(contents []byte) error {
+	dir, err := ioutil.TempDir("", "masterkey-s3")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "vault")
+	if err = ioutil.WriteFile(path, contents, 0600); err != nil {
+		return err
+	}
+
+	args := []string{"--body", path}
+	if s.etag != "" {
+		args = append(args, "--if-match", s.etag)
+	} else {
+		args = append(args, "--if-none-match", "*")
+	}
+	if s.ServerSideEncryption != "" {
+		args = append(args, "--server-side-encryption", s.ServerSideEncryption)
+	}
+	if s.KMSKeyID != "" {
+		args = append(args, "--ssekms-key-id", s.KMSKeyID)
+	}
+	object, err := s.runAWS("put-object", args...)
+	if err != nil {
+		return err
+	}
+	s.etag = object.ETag
+	return nil
+}
+
+// Lock does nothing, as S3 has no locks. Store detects concurrent writers
+// instead.
+func (s *S3Storage) Lock() error {
+	return nil
+}
+
+// Unlock does nothing.
+func (s *S3Storage) Unlock() error {
+	return nil
+}
@@ -0,0 +1,137 @@
+package vault
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestS3Error(t *testing.T) {
+	s := &S3Storage{Bucket: "testbucket", Key: "pass.db"}
+	exit := errors.New("exit status 254")
+	if err := s.awsError(exit, "An error occurred (NoSuchKey) when calling the GetObject operation: The specified key does not exist."); !os.IsNotExist(err) {
+		t.Fatal("expected a missing object not to exist, got", err)
+	}
+	if err := s.awsError(exit, "An error occurred (PreconditionFailed) when calling the PutObject operation: At least one of the pre-conditions you specified did not hold"); err != ErrStorageConflict {
+		t.Fatal("expected ErrStorageConflict, got", err)
+	}
+	if err := s.awsError(exit, "Unable to locate credentials."); err == nil || os.IsNotExist(err) || err == ErrStorageConflict {
+		t.Fatal("expected the CLI's error, got", err)
+	}
+}
+
+// fakeAWSScript emulates the AWS CLI's s3api get-object and put-object
+// commands on a single object, honoring --if-match and --if-none-match as S3
+// does, and records the arguments of each call in the file calls, one call
+// per line.
+const fakeAWSScript = `echo "$@" >> calls
+command=$2
+shift 2
+while [ $# -gt 0 ]; do
+	case $1 in
+	--if-match) ifmatch=$2; shift ;;
+	--if-none-match) ifnonematch=$2; shift ;;
+	--body) body=$2; shift ;;
+	--*) shift ;;
+	*) outfile=$1 ;;
+	esac
+	shift
+done
+version=$(cat version 2>/dev/null || echo 0)
+case $command in
+get-object)
+	if [ ! -f object ]; then
+		echo "An error occurred (NoSuchKey) when calling the GetObject operation: The specified key does not exist." >&2
+		exit 254
+	fi
+	cp object "$outfile"
+	;;
+put-object)
+	if [ -n "$ifmatch" ] && [ "$ifmatch" != "\"$version\"" ]; then
+		echo "An error occurred (PreconditionFailed) when calling the PutObject operation: At least one of the pre-conditions you specified did not hold" >&2
+		exit 254
+	fi
+	if [ "$ifnonematch" = "*" ] && [ -f object ]; then
+		echo "An error occurred (PreconditionFailed) when calling the PutObject operation: At least one of the pre-conditions you specified did not hold" >&2
+		exit 254
+	fi
+	cp "$body" object
+	version=$((version + 1))
+	echo $version > version
+	;;
+esac
+echo "{\"ETag\": \"\\\"$version\\\"\"}"
+`
+
+func TestS3Storage(t *testing.T) {
+	relaxDefaults(t)
+	dir := fakeTool(t, "aws", fakeAWSScript)
+	lastCall := func() string {
+		calls, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(calls)), "\n")
+		return lines[len(lines)-1]
+	}
+
+	s := &S3Storage{Bucket: "testbucket", Key: "pass.db", Endpoint: "http://localhost:9000", ServerSideEncryption: "AES256"}
+	if _, err := OpenStorage(s, "testpass", OpenOptions{}); !os.IsNotExist(err) {
+		t.Fatal("expected a missing object not to exist, got", err)
+	}
+	if call := lastCall(); !strings.HasPrefix(call, "s3api get-object --bucket testbucket --key pass.db --output json ") || !strings.HasSuffix(call, " --endpoint-url http://localhost:9000") {
+		t.Fatal("unexpected get-object call", call)
+	}
+
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SaveStorage(s); err != nil {
+		t.Fatal(err)
+	}
+	if call := lastCall(); !strings.Contains(call, " --if-none-match * ") || !strings.Contains(call, " --server-side-encryption AES256") {
+		t.Fatal("expected the first put to require a missing object, got", call)
+	}
+
+	// Another writer loads the object, recording its ETag, and puts it
+	// only if it is unchanged.
+	other := &S3Storage{Bucket: "testbucket", Key: "pass.db", Endpoint: "http://localhost:9000"}
+	vother, err := OpenStorage(other, "testpass", OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.etag != `"1"` {
+		t.Fatal("expected the loaded object's ETag to be recorded, got", other.etag)
+	}
+	if err = vother.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = vother.SaveStorage(other); err != nil {
+		t.Fatal(err)
+	}
+	if call := lastCall(); !strings.Contains(call, ` --if-match "1" `) {
+		t.Fatal("expected the put to require the loaded ETag, got", call)
+	}
+	if other.etag != `"2"` {
+		t.Fatal("expected the stored object's ETag to be recorded, got", other.etag)
+	}
+
+	// The first writer's save would overwrite the other's.
+	if err = v.SaveStorage(s); err != ErrStorageConflict {
+		t.Fatal("expected ErrStorageConflict, got", err)
+	}
+	if call := lastCall(); !strings.Contains(call, ` --if-match "1" `) {
+		t.Fatal("expected the put to require the stored ETag, got", call)
+	}
+	vopen, err := OpenStorage(s, "testpass", OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = vopen.Get("testlocation"); err != nil {
+		t.Fatal("the other writer's save was lost:", err)
+	}
+}
@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Match is a location matched by Search, along with its score. Higher scores
+// are better matches.
+type Match struct {
+	Location string
+	Score    int
+}
+
+const (
+	scoreExact       = 1000
+	scorePrefix      = 500
+	scoreSubstring   = 250
+	scoreConsecutive = 10
+	scoreBoundary    = 8
+	scoreChar        = 1
+)
+
+// isBoundary returns true if the byte at `i` in `s` starts a new word, i.e.
+// it is the first byte or follows a separator such as '/', '.', '-' or '_'.
+func isBoundary(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	return strings.ContainsRune("/.-_ @:", rune(s[i-1]))
+}
+
+// fuzzyScore scores `location` against `query`, both of which must already be
+// lowercased. The query matches if its characters appear in order in the
+// location, not necessarily consecutively; ok is false if it does not match.
+func fuzzyScore(location string, query string) (score int, ok bool) {
+	switch {
+	case location == query:
+		score += scoreExact
+	case strings.HasPrefix(location, query):
+		score += scorePrefix
+	case strings.Contains(location, query):
+		score += scoreSubstring
+	}
+
+	last := -2
+	i := 0
+	for _, r := range query {
+		j := strings.IndexRune(location[i:], r)
+		if j < 0 {
+			return 0, false
+		}
+		pos := i + j
+
+		score += scoreChar
+		if pos == last+1 {
+			score += scoreConsecutive
+		}
+		if isBoundary(location, pos) {
+			score += scoreBoundary
+		}
+
+		last = pos
+		i = pos + utf8.RuneLen(r)
+	}
+
+	return score, true
+}
+
+// Search fuzzy matches `query` against the locations in the vault,
+// case-insensitively, and returns the matches ordered best first, with
+// shorter locations first among equal scores. A location
+// matches if the characters of `query` appear in it in order, so "gh"
+// matches "github.com/personal".
+func (v *Vault) Search(query string) ([]Match, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	var matches []Match
+	for location := range p.Credentials {
+		if score, ok := fuzzyScore(strings.ToLower(location), query); ok {
+			matches = append(matches, Match{Location: location, Score: score})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if len(matches[i].Location) != len(matches[j].Location) {
+			return len(matches[i].Location) < len(matches[j].Location)
+		}
+		return matches[i].Location < matches[j].Location
+	})
+	return matches, nil
+}
@@ -0,0 +1,57 @@
+package vault
+
+import (
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locs := []string{"github.com/personal", "github.com/work", "gmail.com", "bank", "Highway"}
+	for _, loc := range locs {
+		if err = v.Add(loc, Credential{Username: "testuser", Password: "testpass"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, err := v.Search("gh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 3 {
+		t.Fatalf("expected 3 matches for gh, got %v", matches)
+	}
+	if matches[0].Location != "Highway" {
+		t.Fatalf("expected a substring match to be the best match for gh, got %v", matches)
+	}
+	if matches[1].Location != "github.com/work" || matches[2].Location != "github.com/personal" {
+		t.Fatalf("expected github locations to match gh, got %v", matches)
+	}
+
+	matches, err = v.Search("BANK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Location != "bank" {
+		t.Fatalf("expected case-insensitive exact match for BANK, got %v", matches)
+	}
+
+	matches, err = v.Search("ghp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Location != "github.com/personal" {
+		t.Fatalf("expected only github.com/personal to match ghp, got %v", matches)
+	}
+
+	matches, err = v.Search("zzz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Fatal("expected no matches for zzz")
+	}
+}
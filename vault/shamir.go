@@ -0,0 +1,203 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidThreshold is returned from SplitKey if the key cannot be
+	// split into the given number of shares with the given threshold.
+	ErrInvalidThreshold = errors.New("the threshold must be at least 2 and no more than the number of shares, which must be at most 255")
+
+	// ErrInvalidShare is returned if a share is malformed, or does not
+	// belong to the same split as the others.
+	ErrInvalidShare = errors.New("invalid share")
+
+	// ErrNotEnoughShares is returned from OpenWithShares if fewer distinct
+	// shares than the threshold are given.
+	ErrNotEnoughShares = errors.New("not enough distinct shares to recover the master key")
+)
+
+// Share is one of the shares the vault's master key is split into by
+// SplitKey. Any `Threshold` distinct shares of the same split recover it.
+type Share struct {
+	Threshold int
+	Index     int
+	Value     [32]byte
+}
+
+// String encodes the share as text that can be written down or printed, and
+// decoded by ParseShare.
+func (s Share) String() string {
+	return fmt.Sprintf("%d-%d-%s", s.Threshold, s.Index, hex.EncodeToString(s.Value[:]))
+}
+
+// ParseShare decodes a share encoded by Share.String.
+func ParseShare(s string) (Share, error) {
+	var share Share
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 3 {
+		return share, ErrInvalidShare
+	}
+	if _, err := fmt.Sscanf(parts[0]+" "+parts[1], "%d %d", &share.Threshold, &share.Index); err != nil {
+		return share, ErrInvalidShare
+	}
+	value, err := hex.DecodeString(parts[2])
+	if err != nil || len(value) != len(share.Value) || share.Index < 1 || share.Index > 255 || share.Threshold < 2 {
+		return share, ErrInvalidShare
+	}
+	copy(share.Value[:], value)
+	return share, nil
+}
+
+// gfMul multiplies `a` and `b` in GF(2^8) with the AES polynomial, in time
+// independent of their values.
+func gfMul(a byte, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		p ^= -(b & 1) & a
+		carry := -(a >> 7)
+		a = a<<1 ^ carry&0x1b
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv returns the multiplicative inverse of nonzero `a` in GF(2^8), which
+// is a^254.
+func gfInv(a byte) byte {
+	result := byte(1)
+	for i := 0; i < 7; i++ {
+		a = gfMul(a, a)
+		result = gfMul(result, a)
+	}
+	return result
+}
+
+// splitSecret splits `secret` into `n` shares using Shamir's secret sharing,
+// any `k` of which recover it. Each byte of the secret is the constant term of
+// its own random polynomial of degree k-1, and share x holds the value of
+// every polynomial at x.
+func splitSecret(secret [32]byte, n int, k int) ([]Share, error) {
+	if k < 2 || k > n || n > 255 {
+		return nil, ErrInvalidThreshold
+	}
+	coefficients := make([]byte, (k-1)*len(secret))
+	if _, err := rand.Read(coefficients); err != nil {
+		return nil, err
+	}
+	defer wipe(coefficients)
+
+	shares := make([]Share, n)
+	for i := range shares {
+		x := byte(i + 1)
+		shares[i] = Share{Threshold: k, Index: i + 1}
+		for j := range secret {
+			// Horner's method, from the highest degree coefficient.
+			var y byte
+			for d := k - 2; d >= 0; d-- {
+				y = gfMul(y, x) ^ coefficients[d*len(secret)+j]
+			}
+			shares[i].Value[j] = gfMul(y, x) ^ secret[j]
+		}
+	}
+	return shares, nil
+}
+
+// combineShares recovers the secret split by splitSecret from `shares`, by
+// Lagrange interpolation of each polynomial at 0. Shares beyond the threshold
+// are ignored.
+func combineShares(shares []Share) ([32]byte, error) {
+	var secret [32]byte
+	if len(shares) == 0 {
+		return secret, ErrNotEnoughShares
+	}
+	k := shares[0].Threshold
+	var distinct []Share
+	seen := make(map[int]bool)
+	for _, share := range shares {
+		if share.Threshold != k || share.Index < 1 || share.Index > 255 {
+			return secret, ErrInvalidShare
+		}
+		if !seen[share.Index] {
+			seen[share.Index] = true
+			distinct = append(distinct, share)
+		}
+	}
+	if k < 2 || len(distinct) < k {
+		return secret, ErrNotEnoughShares
+	}
+	distinct = distinct[:k]
+
+	for i, share := range distinct {
+		// The Lagrange basis polynomial of share i at 0 is the product of
+		// x_m / (x_m - x_i) over the other shares; subtraction is XOR.
+		basis := byte(1)
+		xi := byte(share.Index)
+		for m, other := range distinct {
+			if m == i {
+				continue
+			}
+			xm := byte(other.Index)
+			basis = gfMul(basis, gfMul(xm, gfInv(xm^xi)))
+		}
+		for j := range secret {
+			secret[j] ^= gfMul(basis, share.Value[j])
+		}
+	}
+	return secret, nil
+}
+
+// SplitKey splits the vault's master key into `n` shares, any `k` of which
+// open the vault using OpenWithShares, such as to let trustees recover it if
+// its passphrases are lost. Fewer than `k` shares reveal nothing about the
+// key. The shares stay valid as long as the master key does: they remain
+// valid after passphrases are changed and key slots added or removed, unless
+// the passphrase of a vault with a single key slot is changed, which replaces
+// its master key. Shares of a vault whose master key is not yet saved, such
+// as a new one, are only valid once it is.
+func (v *Vault) SplitKey(n int, k int) ([]Share, error) {
+	if v.closed {
+		return nil, ErrLocked
+	}
+	if v.ageRecipients != nil {
+		return nil, ErrAgeVault
+	}
+	if v.outer != nil {
+		return nil, ErrHiddenVault
+	}
+	return splitSecret(v.keys.master, n, k)
+}
+
+// OpenWithShares opens the vault at `filename` using the master key recovered
+// from `shares`, split by SplitKey. The vault is opened as if unlocked with
+// its first key slot, so a key slot can be added with a new passphrase, and
+// others removed once the vault is reopened with it. ErrCouldNotDecrypt is
+// returned if the shares do not recover the vault's master key.
+func OpenWithShares(filename string, shares []Share) (*Vault, error) {
+	master, err := combineShares(shares)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(master[:])
+
+	header, data, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(header.slots) == 0 || header.slots[0].wrapped == nil {
+		return nil, ErrCouldNotDecrypt
+	}
+	v, p, err := openWithKeys(header, data, &master, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = v.startSession(p); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
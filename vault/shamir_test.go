@@ -0,0 +1,97 @@
+package vault
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSplitSecret(t *testing.T) {
+	secret := randomKey()
+	shares, err := splitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var given []Share
+		for _, i := range subset {
+			given = append(given, shares[i])
+		}
+		recovered, err := combineShares(given)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recovered != secret {
+			t.Fatalf("shares %v did not recover the secret", subset)
+		}
+	}
+	if _, err = combineShares([]Share{shares[0], shares[1], shares[1]}); err != ErrNotEnoughShares {
+		t.Fatal("expected ErrNotEnoughShares for duplicate shares, got", err)
+	}
+	if _, err = splitSecret(secret, 2, 3); err != ErrInvalidThreshold {
+		t.Fatal("expected ErrInvalidThreshold, got", err)
+	}
+
+	parsed, err := ParseShare(shares[3].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != shares[3] {
+		t.Fatal("parsed share does not match", shares[3])
+	}
+	if _, err = ParseShare("3-0-00"); err != ErrInvalidShare {
+		t.Fatal("expected ErrInvalidShare, got", err)
+	}
+}
+
+func TestOpenWithShares(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	shares, err := v.SplitKey(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenWithShares("pass.db", shares[:1]); err != ErrNotEnoughShares {
+		t.Fatal("expected ErrNotEnoughShares, got", err)
+	}
+	other, err := v.SplitKey(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenWithShares("pass.db", []Share{shares[0], other[1]}); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt for shares of different splits, got", err)
+	}
+
+	recovered, err := OpenWithShares("pass.db", shares[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := recovered.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpassword" {
+		t.Fatal("recovered vault had the wrong password:", cred.Password)
+	}
+
+	// The trustees can then give the vault a passphrase of their own.
+	if _, err = recovered.AddKeySlot("newpass", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = recovered.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open("pass.db", "newpass"); err != nil {
+		t.Fatal(err)
+	}
+}
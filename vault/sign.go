@@ -0,0 +1,142 @@
+package vault
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// signatureContext prefixes the digest of a vault file signed by Save, so
+// that its signatures cannot be mistaken for signatures of anything else.
+const signatureContext = "masterkey vault signature v1\x00"
+
+var (
+	// ErrInvalidSignature is returned from Verify if the vault file's
+	// signature was not made by the given key over its contents.
+	ErrInvalidSignature = errors.New("vault signature is invalid")
+
+	// ErrNotSigned is returned from Verify if the vault file has no
+	// signature.
+	ErrNotSigned = errors.New("vault is not signed")
+
+	// ErrInvalidSigningKey is returned from SetSigningKey if the key is not
+	// an Ed25519 private key.
+	ErrInvalidSigningKey = errors.New("invalid Ed25519 signing key")
+)
+
+// signaturePath returns the path of the detached signature of the vault file
+// at `filename`.
+func signaturePath(filename string) string {
+	return filename + ".sig"
+}
+
+// signatureMessage returns the message signed for a vault file whose SHA-512
+// digest is `digest`.
+func signatureMessage(digest []byte) []byte {
+	return append([]byte(signatureContext), digest...)
+}
+
+// SetSigningKey sets the Ed25519 key, stored in the vault, that signs the
+// vault file on every Save, or stops signing it if `key` is nil. The
+// signature covers the whole file, header and ciphertext, and is written next
+// to it with the suffix .sig, so that Verify can check the file's provenance
+// using the key's public key without decrypting it. Once signing stops, the
+// next Save removes the signature.
+//
+// A hidden vault is signed with its outer vault's key, which it can only use
+// while the outer vault is open, as after NewHidden. A hidden vault opened by
+// Open cannot sign the file, so its Save leaves the outer vault's signature
+// invalid, which tells anyone holding the public key that something other
+// than the outer vault wrote the file, and so hints that there is a hidden
+// vault. Age vaults cannot be signed.
+func (v *Vault) SetSigningKey(key ed25519.PrivateKey) error {
+	if v.ageRecipients != nil {
+		return ErrAgeVault
+	}
+	if key != nil && len(key) != ed25519.PrivateKeySize {
+		return ErrInvalidSigningKey
+	}
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+	p.SigningKey = key
+	return v.encrypt(p)
+}
+
+// SigningPublicKey returns the public key of the vault's signing key, or nil
+// if it is not signed.
+func (v *Vault) SigningPublicKey() (ed25519.PublicKey, error) {
+	key, err := v.signingKey()
+	if err != nil || key == nil {
+		return nil, err
+	}
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+// signingKey returns the vault's signing key, or nil if it has none. A hidden
+// vault's files are signed by its outer vault, if it is open.
+func (v *Vault) signingKey() (ed25519.PrivateKey, error) {
+	if v.outer != nil {
+		if v.outer.vault == nil {
+			return nil, nil
+		}
+		return v.outer.vault.signingKey()
+	}
+	p, err := v.decryptIndex()
+	if err != nil {
+		return nil, err
+	}
+	return p.SigningKey, nil
+}
+
+// writeSignature signs the vault file at `filename`, whose SHA-512 digest is
+// `digest`, if the vault has a signing key, replacing its signature
+// atomically, and otherwise removes any signature left from when it had one.
+func (v *Vault) writeSignature(filename string, digest []byte) error {
+	key, err := v.signingKey()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		// A hidden vault whose outer vault is not open cannot tell
+		// whether the file should be signed, so leaves its signature.
+		if v.outer != nil && v.outer.vault == nil {
+			return nil
+		}
+		if err = os.Remove(signaturePath(filename)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return writeAtomic(signaturePath(filename), func(w io.Writer) error {
+		_, err := w.Write(ed25519.Sign(key, signatureMessage(digest)))
+		return err
+	})
+}
+
+// Verify checks that the vault file at `filename` was saved by a vault whose
+// signing key's public key is `publicKey`, using its detached signature,
+// without decrypting it. ErrNotSigned is returned if it has no signature, and
+// ErrInvalidSignature if the signature is not valid.
+func Verify(filename string, publicKey ed25519.PublicKey) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	signature, err := ioutil.ReadFile(signaturePath(filename))
+	if os.IsNotExist(err) {
+		return ErrNotSigned
+	}
+	if err != nil {
+		return err
+	}
+	digest := sha512.Sum512(data)
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, signatureMessage(digest[:]), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package vault
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub, err := v.SigningPublicKey(); err != nil || pub != nil {
+		t.Fatal("expected a new vault to have no signing key, got", pub, err)
+	}
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SetSigningKey(key[:10]); err != ErrInvalidSigningKey {
+		t.Fatal("expected ErrInvalidSigningKey, got", err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	defer os.Remove(signaturePath("pass.db"))
+	if err = Verify("pass.db", pub); err != ErrNotSigned {
+		t.Fatal("expected ErrNotSigned, got", err)
+	}
+
+	if err = v.SetSigningKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("location1", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify("pass.db", pub); err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify("pass.db", other); err != ErrInvalidSignature {
+		t.Fatal("expected ErrInvalidSignature with another key, got", err)
+	}
+
+	data, err := ioutil.ReadFile("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 1
+	if err = ioutil.WriteFile("pass.db", data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify("pass.db", pub); err != ErrInvalidSignature {
+		t.Fatal("expected ErrInvalidSignature for a modified vault, got", err)
+	}
+	data[len(data)-1] ^= 1
+	if err = ioutil.WriteFile("pass.db", data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := vopen.SigningPublicKey(); err != nil || !opened.Equal(pub) {
+		t.Fatal("expected the signing key to be kept, got", opened, err)
+	}
+	if err = vopen.Add("location2", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify("pass.db", pub); err != nil {
+		t.Fatal("expected the reopened vault to sign its saves, got", err)
+	}
+
+	if err = vopen.SetSigningKey(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify("pass.db", pub); err != ErrNotSigned {
+		t.Fatal("expected ErrNotSigned once signing stops, got", err)
+	}
+}
@@ -0,0 +1,281 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// sqliteSchema creates the tables of a SQLite database a vault is stored in.
+// The vault table holds a single row: the vault's header, in which nothing is
+// secret, the sealed index of its envelope, the IDs of its entries in order,
+// and a copy of the header's fields that can be queried. The entries table
+// holds the envelope's sealed entries, keyed by their IDs, the trash table its
+// sealed trash, if any, and the log table the sealed chunks of its operation
+// log, in order. Incremental vacuuming must be chosen before the tables are
+// created, and lets each Save return the pages freed by the last one.
+const sqliteSchema = `PRAGMA auto_vacuum = INCREMENTAL;
+CREATE TABLE IF NOT EXISTS vault (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	format_version INTEGER NOT NULL,
+	cipher INTEGER NOT NULL,
+	compression INTEGER NOT NULL,
+	key_slots INTEGER NOT NULL,
+	saved_at TEXT NOT NULL,
+	header BLOB NOT NULL,
+	idx BLOB NOT NULL,
+	ids BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS entries (
+	id BLOB PRIMARY KEY,
+	entry BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS trash (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	trash BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS log (
+	ordinal INTEGER PRIMARY KEY,
+	chunk BLOB NOT NULL
+);
+`
+
+// ErrNoSQLiteVault is returned from OpenSQLite if the database holds no vault.
+var ErrNoSQLiteVault = errors.New("no vault is stored in the SQLite database")
+
+// SQLiteDB is a SQLite database a vault is stored in, used through the sqlite3
+// shell, or through SQLCipher's sqlcipher shell if it is encrypted. Each of
+// the vault's credentials is stored sealed in its own row, and the header's
+// fields, which are not secret, are stored in columns of the vault table, so
+// that other tools can inspect them using SQL without the passphrase. A
+// *SQLiteDB is a Storage, locked using a lock file next to the database.
+//
+// Like every Storage, it loads and stores the vault whole, but each Save only
+// writes the rows of the credentials and chunks of the operation log that
+// changed, deleting those no longer in the vault, so that saving a large vault
+// after changing a credential does not rewrite the database. The rows are not
+// meant for reading or updating credentials one at a time without the vault.
+// The shell, which is not part of Go, must be installed.
+type SQLiteDB struct {
+	// Path is the path of the database file.
+	Path string
+	// Key, if set, is the key SQLCipher encrypts the whole database with,
+	// including the columns that are otherwise readable by anyone.
+	Key  string
+	lock storageLock
+}
+
+// run runs the database's shell on the database, passing it `script` on its
+// standard input, so that the key and the vault's contents are never on its
+// command line, and returns its output. The shell stops at the first error,
+// rolling back any transaction it began.
+func (db *SQLiteDB) run(script string) ([]byte, error) {
+	name := "sqlite3"
+	var b strings.Builder
+	b.WriteString(".bail on\n.mode list\n.headers off\n.separator |\n")
+	if db.Key != "" {
+		name = "sqlcipher"
+		fmt.Fprintf(&b, "PRAGMA key = '%v';\n", strings.Replace(db.Key, "'", "''", -1))
+	}
+	b.WriteString(script)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, "-batch", db.Path)
+	cmd.Stdin = strings.NewReader(b.String())
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v: %v", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// query runs `script` on the database as run does, passing the fields of each
+// line of its output to `fn`, which is expected to ignore lines it does not
+// recognize, as sqlcipher prints the result of setting the key. Fields are
+// separated by "|", so queries should tag their lines and return hex.
+func (db *SQLiteDB) query(script string, fn func(fields []string) error) error {
+	out, err := db.run(script)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if err = fn(strings.Split(strings.TrimSpace(line), "|")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteRows holds the rows of a vault stored in a SQLite database, with the
+// envelope's entries keyed by their IDs.
+type sqliteRows struct {
+	header  []byte
+	index   []byte
+	ids     []byte
+	entries map[string][]byte
+	trash   []byte
+	log     [][]byte
+}
+
+// rows reads the rows of the vault stored in the database, running `prefix`
+// first.
+func (db *SQLiteDB) rows(prefix string) (*sqliteRows, error) {
+	rows := &sqliteRows{entries: make(map[string][]byte)}
+	err := db.query(prefix+`SELECT 'vault', hex(header), hex(idx), hex(ids) FROM vault WHERE id = 1;
+SELECT 'entry', hex(id), hex(entry) FROM entries;
+SELECT 'trash', hex(trash) FROM trash WHERE id = 1;
+SELECT 'log', hex(chunk) FROM log ORDER BY ordinal;
+`, func(fields []string) error {
+		values := make([][]byte, len(fields)-1)
+		for i, field := range fields[1:] {
+			value, err := hex.DecodeString(field)
+			if err != nil {
+				return ErrCouldNotDecrypt
+			}
+			values[i] = value
+		}
+		switch {
+		case fields[0] == "vault" && len(values) == 3:
+			rows.header, rows.index, rows.ids = values[0], values[1], values[2]
+		case fields[0] == "entry" && len(values) == 2:
+			rows.entries[string(values[0])] = values[1]
+		case fields[0] == "trash" && len(values) == 1:
+			rows.trash = values[0]
+		case fields[0] == "log" && len(values) == 1:
+			rows.log = append(rows.log, values[0])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Load reads the vault stored in the database, returning its contents as
+// Save would have written them to a file.
+func (db *SQLiteDB) Load() ([]byte, error) {
+	// The shell would create a missing database.
+	if _, err := os.Stat(db.Path); err != nil {
+		return nil, err
+	}
+	rows, err := db.rows("")
+	if err != nil {
+		return nil, err
+	}
+	if rows.header == nil {
+		return nil, ErrNoSQLiteVault
+	}
+
+	env := envelope{Index: rows.index, Trash: rows.trash, Log: rows.log}
+	if len(rows.ids)%entryIDSize != 0 {
+		return nil, ErrCouldNotDecrypt
+	}
+	for ids := rows.ids; len(ids) > 0; ids = ids[entryIDSize:] {
+		id := ids[:entryIDSize]
+		entry, ok := rows.entries[string(id)]
+		if !ok {
+			return nil, ErrCouldNotDecrypt
+		}
+		env.IDs = append(env.IDs, id)
+		env.Entries = append(env.Entries, entry)
+	}
+
+	contents := bytes.NewBuffer(rows.header)
+	if err = gob.NewEncoder(contents).Encode(&env); err != nil {
+		return nil, err
+	}
+	return contents.Bytes(), nil
+}
+
+// Store replaces the vault stored in the database with `contents`, as Save
+// would write them to a file, in a single transaction. Only the rows whose
+// entries, trash or chunks of the operation log changed are written, and
+// those no longer in the vault are deleted.
+func (db *SQLiteDB) Store(contents []byte) error {
+	header, data, err := readHeader(contents)
+	if err != nil {
+		return err
+	}
+	var env envelope
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&env); err != nil {
+		return err
+	}
+	if len(env.IDs) != len(env.Entries) {
+		return ErrCouldNotDecrypt
+	}
+	// The caller holds the lock, so the rows cannot change before they are
+	// written.
+	rows, err := db.rows(sqliteSchema)
+	if err != nil {
+		return err
+	}
+
+	var script strings.Builder
+	script.WriteString("BEGIN IMMEDIATE;\n")
+	fmt.Fprintf(&script, `INSERT INTO vault VALUES (1, %d, %d, %d, %d, '%v', X'%x', X'%x', X'%x')
+	ON CONFLICT(id) DO UPDATE SET format_version = excluded.format_version, cipher = excluded.cipher,
+	compression = excluded.compression, key_slots = excluded.key_slots, saved_at = excluded.saved_at,
+	header = excluded.header, idx = excluded.idx, ids = excluded.ids;
+`, header.version, header.cipher, header.compression, len(header.slots),
+		time.Now().UTC().Format(time.RFC3339), contents[:len(contents)-len(data)], env.Index, bytes.Join(env.IDs, nil))
+	for i, id := range env.IDs {
+		stored, ok := rows.entries[string(id)]
+		delete(rows.entries, string(id))
+		if ok && bytes.Equal(stored, env.Entries[i]) {
+			continue
+		}
+		fmt.Fprintf(&script, "INSERT INTO entries VALUES (X'%x', X'%x') ON CONFLICT(id) DO UPDATE SET entry = excluded.entry;\n", id, env.Entries[i])
+	}
+	// The entries left are no longer in the vault.
+	for id := range rows.entries {
+		fmt.Fprintf(&script, "DELETE FROM entries WHERE id = X'%x';\n", id)
+	}
+	if env.Trash == nil {
+		script.WriteString("DELETE FROM trash;\n")
+	} else if !bytes.Equal(rows.trash, env.Trash) {
+		fmt.Fprintf(&script, "INSERT INTO trash VALUES (1, X'%x') ON CONFLICT(id) DO UPDATE SET trash = excluded.trash;\n", env.Trash)
+	}
+	for i, chunk := range env.Log {
+		if i < len(rows.log) && bytes.Equal(rows.log[i], chunk) {
+			continue
+		}
+		fmt.Fprintf(&script, "INSERT INTO log VALUES (%d, X'%x') ON CONFLICT(ordinal) DO UPDATE SET chunk = excluded.chunk;\n", i, chunk)
+	}
+	fmt.Fprintf(&script, "DELETE FROM log WHERE ordinal >= %d;\n", len(env.Log))
+	script.WriteString("COMMIT;\nPRAGMA incremental_vacuum;\n")
+	_, err = db.run(script.String())
+	return err
+}
+
+// Lock locks the database, which does nothing if it is already locked by db.
+func (db *SQLiteDB) Lock() error {
+	return db.lock.acquire(db.Path)
+}
+
+// Unlock releases the lock on the database, removing the lock file.
+func (db *SQLiteDB) Unlock() error {
+	return db.lock.release()
+}
+
+// OpenSQLite opens the vault stored in the SQLite database `db` by SaveSQLite,
+// decrypting it using `passphrase` as OpenWithOptions does with `opts`. The
+// files recording failed attempts and holding the lock are kept next to the
+// database file.
+func OpenSQLite(db SQLiteDB, passphrase string, opts OpenOptions) (*Vault, error) {
+	return openStorage(&db, db.Path, passphrase, opts)
+}
+
+// SaveSQLite persists the vault to the SQLite database `db`, creating it if it
+// does not exist, replacing the vault stored in it in a single transaction, as
+// SaveStorage does.
+func (v *Vault) SaveSQLite(db SQLiteDB) error {
+	return v.SaveStorage(&db)
+}
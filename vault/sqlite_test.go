@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSQLite(t *testing.T) {
+	relaxDefaults(t)
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	db := SQLiteDB{Path: "pass.sqlite"}
+	defer os.Remove("pass.sqlite")
+	if _, err := OpenSQLite(db, "testpass", OpenOptions{}); !os.IsNotExist(err) {
+		t.Fatal("expected a missing database not to be created, got", err)
+	}
+
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, location := range []string{"location1", "location2", "location3"} {
+		if err = v.Add(location, Credential{Username: "testuser", Password: "testpass-" + location}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = v.Trash("location3"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.RestoreFromTrash("location3"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SaveSQLite(db); err != nil {
+		t.Fatal(err)
+	}
+	p, err := v.decrypt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := fmt.Sprintf("SELECT hex(entry) FROM entries WHERE id = X'%x';\n", entryID(p.EntryKey, "location1"))
+	saved, err := db.run(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Delete("location2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SaveSQLite(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unchanged credential's row is kept as it was, and the deleted
+	// one's row is gone.
+	if out, err := db.run(entry); err != nil || len(saved) == 0 || !bytes.Equal(out, saved) {
+		t.Fatal("expected the unchanged credential's row to be kept", err)
+	}
+	out, err := db.run(fmt.Sprintf("SELECT count(*) FROM entries WHERE id = X'%x';\n", entryID(p.EntryKey, "location2")))
+	if err != nil || strings.TrimSpace(string(out)) != "0" {
+		t.Fatal("expected the deleted credential's row to be gone", string(out), err)
+	}
+	if out, err = db.run("PRAGMA auto_vacuum;\n"); err != nil || strings.TrimSpace(string(out)) != "2" {
+		t.Fatal("expected incremental vacuuming, got", string(out), err)
+	}
+
+	out, err = db.run("SELECT format_version, key_slots FROM vault;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(out)) != fmt.Sprintf("%d|1", vaultVersion) {
+		t.Fatal("unexpected metadata", string(out))
+	}
+
+	if _, err = OpenSQLite(db, "wrongpass", OpenOptions{}); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt, got", err)
+	}
+	vopen, err := OpenSQLite(db, "testpass", OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred, err := vopen.Get("location3"); err != nil || cred.Password != "testpass-location3" {
+		t.Fatal("could not read the stored credential", err)
+	}
+	if _, err = vopen.Get("location2"); err != ErrNoSuchCredential {
+		t.Fatal("expected the deleted credential to be gone, got", err)
+	}
+	if _, err = vopen.VerifyLog(); err != nil {
+		t.Fatal("expected the operation log to be stored, got", err)
+	}
+
+	if err = ioutil.WriteFile("pass.sqlite", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenSQLite(db, "testpass", OpenOptions{}); err == nil {
+		t.Fatal("expected an empty database not to open")
+	}
+}
@@ -0,0 +1,130 @@
+package vault
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// KindSSHKey is an SSH key pair, stored in SSHKey.
+const KindSSHKey Kind = "sshkey"
+
+// ErrInvalidSSHKey is returned if an SSH private key cannot be parsed or
+// decrypted using its passphrase.
+var ErrInvalidSSHKey = errors.New("invalid ssh private key or passphrase")
+
+// SSHKey is an SSH key pair.
+type SSHKey struct {
+	// PrivateKey is the PEM encoded private key, which may itself be
+	// encrypted using Passphrase.
+	PrivateKey string `json:"private_key"`
+	Passphrase string `json:"passphrase,omitempty"`
+	// PublicKey is the public key in authorized_keys format.
+	PublicKey string `json:"public_key"`
+}
+
+// parse parses and, if necessary, decrypts the private key.
+func (key *SSHKey) parse() (interface{}, error) {
+	var raw interface{}
+	var err error
+	if key.Passphrase == "" {
+		raw, err = ssh.ParseRawPrivateKey([]byte(key.PrivateKey))
+	} else {
+		raw, err = ssh.ParseRawPrivateKeyWithPassphrase([]byte(key.PrivateKey), []byte(key.Passphrase))
+	}
+	if err != nil {
+		return nil, ErrInvalidSSHKey
+	}
+	return raw, nil
+}
+
+// AddSSHKey adds the SSH key pair `key` at `location`. If the public key is
+// not provided it is derived from the private key. ErrInvalidSSHKey is
+// returned if the private key cannot be parsed using its passphrase.
+func (v *Vault) AddSSHKey(location string, key SSHKey) error {
+	raw, err := key.parse()
+	if err != nil {
+		return err
+	}
+	if key.PublicKey == "" {
+		signer, err := ssh.NewSignerFromKey(raw)
+		if err != nil {
+			return ErrInvalidSSHKey
+		}
+		key.PublicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	}
+	return v.Add(location, Credential{Kind: KindSSHKey, SSHKey: &key})
+}
+
+// GetSSHKey retrieves the SSH key pair at `location`. ErrWrongKind is
+// returned if the entry at `location` is not an SSH key.
+func (v *Vault) GetSSHKey(location string) (*SSHKey, error) {
+	cred, err := v.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if cred.kind() != KindSSHKey || cred.SSHKey == nil {
+		return nil, ErrWrongKind
+	}
+	return cred.SSHKey, nil
+}
+
+// WriteSSHKey writes the private key at `location` to a new file, readable
+// only by the current user, in the directory `dir` (or the default temporary
+// directory if `dir` is empty) so that it can be passed to ssh -i. The
+// returned function removes the file, and should be called as soon as the
+// key is no longer needed.
+func (v *Vault) WriteSSHKey(location string, dir string) (filename string, remove func() error, err error) {
+	key, err := v.GetSSHKey(location)
+	if err != nil {
+		return "", nil, err
+	}
+
+	f, err := ioutil.TempFile(dir, "masterkey-ssh-")
+	if err != nil {
+		return "", nil, err
+	}
+	remove = func() error {
+		return os.Remove(f.Name())
+	}
+	if err = f.Chmod(0600); err != nil {
+		f.Close()
+		remove()
+		return "", nil, err
+	}
+	if _, err = f.WriteString(key.PrivateKey); err != nil {
+		f.Close()
+		remove()
+		return "", nil, err
+	}
+	if err = f.Close(); err != nil {
+		remove()
+		return "", nil, err
+	}
+	return f.Name(), remove, nil
+}
+
+// AddSSHKeyToAgent decrypts the private key at `location` and adds it to
+// `a`, typically the user's ssh-agent, so that the key never touches the
+// disk. If `lifetime` is non-zero the agent forgets the key after it has
+// elapsed.
+func (v *Vault) AddSSHKeyToAgent(location string, a agent.Agent, lifetime time.Duration) error {
+	key, err := v.GetSSHKey(location)
+	if err != nil {
+		return err
+	}
+	raw, err := key.parse()
+	if err != nil {
+		return err
+	}
+	return a.Add(agent.AddedKey{
+		PrivateKey:   raw,
+		Comment:      location,
+		LifetimeSecs: uint32(lifetime / time.Second),
+	})
+}
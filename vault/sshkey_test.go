@@ -0,0 +1,133 @@
+package vault
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testSSHKey generates an ed25519 key encrypted with `passphrase`, returning
+// the PEM encoded private key and authorized_keys formatted public key.
+func testSSHKey(t *testing.T, passphrase string) (string, string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(private, "test", []byte(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(block)), strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublic)))
+}
+
+func TestSSHKey(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, public := testSSHKey(t, "keypass")
+
+	err = v.AddSSHKey("servers/web", SSHKey{PrivateKey: private, Passphrase: "wrongpass"})
+	if err != ErrInvalidSSHKey {
+		t.Fatal("expected ErrInvalidSSHKey for an incorrect passphrase, got", err)
+	}
+	err = v.AddSSHKey("servers/web", SSHKey{PrivateKey: private, Passphrase: "keypass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := v.GetSSHKey("servers/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.PrivateKey != private || key.Passphrase != "keypass" {
+		t.Fatal("GetSSHKey did not return the stored key")
+	}
+	if key.PublicKey != public {
+		t.Fatalf("AddSSHKey did not derive the public key, got %q wanted %q", key.PublicKey, public)
+	}
+
+	err = v.Add("github", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.GetSSHKey("github"); err != ErrWrongKind {
+		t.Fatal("expected ErrWrongKind for a login, got", err)
+	}
+}
+
+func TestWriteSSHKey(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, _ := testSSHKey(t, "keypass")
+	if err = v.AddSSHKey("web", SSHKey{PrivateKey: private, Passphrase: "keypass"}); err != nil {
+		t.Fatal(err)
+	}
+
+	filename, remove, err := v.WriteSSHKey("web", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("WriteSSHKey wrote the key with mode %v", info.Mode().Perm())
+	}
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != private {
+		t.Fatal("WriteSSHKey did not write the private key")
+	}
+	if err = remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal("WriteSSHKey's remove did not remove the key")
+	}
+}
+
+func TestAddSSHKeyToAgent(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, public := testSSHKey(t, "keypass")
+	if err = v.AddSSHKey("web", SSHKey{PrivateKey: private, Passphrase: "keypass"}); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := agent.NewKeyring()
+	if err = v.AddSSHKeyToAgent("web", keyring, 0); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := keyring.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Comment != "web" {
+		t.Fatal("AddSSHKeyToAgent did not add the key to the agent")
+	}
+	if strings.TrimSpace(string(ssh.MarshalAuthorizedKey(keys[0]))) != public {
+		t.Fatal("AddSSHKeyToAgent added the wrong key")
+	}
+}
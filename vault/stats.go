@@ -0,0 +1,60 @@
+package vault
+
+import (
+	"strings"
+	"time"
+)
+
+// Stats summarizes the contents of a vault.
+type Stats struct {
+	// Entries is the number of credentials in the vault.
+	Entries int
+	// Size is the size, in bytes, of the encrypted vault.
+	Size int
+	// Folders is the number of credentials underneath each folder,
+	// including those in nested folders.
+	Folders map[string]int
+	// Oldest and Newest are the earliest and latest times at which a
+	// credential was modified. Credentials without timestamps are ignored.
+	Oldest time.Time
+	Newest time.Time
+	// MissingUsername is the number of credentials without a username.
+	MissingUsername int
+}
+
+// Stats computes statistics about the vault.
+func (v *Vault) Stats() (Stats, error) {
+	stats := Stats{
+		Size:    len(v.data),
+		Folders: make(map[string]int),
+	}
+
+	p, err := v.decrypt()
+	if err != nil {
+		return stats, err
+	}
+
+	for location, cred := range p.Credentials {
+		stats.Entries++
+		if cred.Username == "" {
+			stats.MissingUsername++
+		}
+
+		segments := strings.Split(location, FolderSeparator)
+		for i := 1; i < len(segments); i++ {
+			stats.Folders[strings.Join(segments[:i], FolderSeparator)]++
+		}
+
+		if cred.UpdatedAt.IsZero() {
+			continue
+		}
+		if stats.Oldest.IsZero() || cred.UpdatedAt.Before(stats.Oldest) {
+			stats.Oldest = cred.UpdatedAt
+		}
+		if cred.UpdatedAt.After(stats.Newest) {
+			stats.Newest = cred.UpdatedAt
+		}
+	}
+
+	return stats, nil
+}
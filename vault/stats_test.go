@@ -0,0 +1,51 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := v.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 0 || !stats.Oldest.IsZero() || stats.Size == 0 {
+		t.Fatalf("unexpected stats for an empty vault: %v", stats)
+	}
+
+	now := time.Now()
+	err = v.Add("work/aws/prod", Credential{Username: "testuser", Password: "testpass", UpdatedAt: now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("work/github", Credential{Password: "testpass", UpdatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("bank", Credential{Username: "testuser", Password: "testpass", UpdatedAt: now.Add(-2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err = v.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 3 || stats.MissingUsername != 1 {
+		t.Fatalf("unexpected entry counts: %v", stats)
+	}
+	if !reflect.DeepEqual(stats.Folders, map[string]int{"work": 2, "work/aws": 1}) {
+		t.Fatalf("unexpected folder counts: %v", stats.Folders)
+	}
+	if !stats.Oldest.Equal(now.Add(-2*time.Hour)) || !stats.Newest.Equal(now) {
+		t.Fatalf("unexpected modification times: %v", stats)
+	}
+}
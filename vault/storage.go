@@ -0,0 +1,197 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// ErrStorageConflict is returned from a Storage's Store if the vault it holds
+// was replaced by another writer since it was loaded.
+var ErrStorageConflict = errors.New("the stored vault was changed by another writer since it was loaded")
+
+// Storage persists the encrypted contents of a vault, such as to a file, a
+// database or an object store, so that vaults can be kept anywhere a
+// Storage can be written for. The contents are those Save writes to a file,
+// and are only ever read and written whole, so a Storage never needs to
+// understand them.
+type Storage interface {
+	// Load returns the stored contents, or an error for which os.IsNotExist
+	// is true if nothing is stored.
+	Load() ([]byte, error)
+	// Store replaces the stored contents with `contents`, so that a
+	// failure leaves either the old contents or the new ones. Storages
+	// that detect concurrent writers rather than locking them out return
+	// ErrStorageConflict if the contents were replaced since they were
+	// loaded.
+	Store(contents []byte) error
+	// Lock takes a lock on the storage, held until Unlock, so that
+	// other processes cannot write it meanwhile, returning a
+	// *VaultLockedError if another process holds it.
+	Lock() error
+	// Unlock releases the lock taken by Lock.
+	Unlock() error
+}
+
+type (
+	// FileStorage stores a vault in a file, as Save and Open do. It is
+	// locked using a lock file next to it, as LockFile does.
+	FileStorage struct {
+		// Path is the path of the vault file.
+		Path string
+		lock storageLock
+	}
+
+	// MemoryStorage stores a vault in memory, such as to pass it between
+	// the parts of a program, or to test a Storage's users. Its zero
+	// value stores nothing. Its lock only excludes other users of the
+	// same MemoryStorage.
+	MemoryStorage struct {
+		mu       sync.Mutex
+		contents []byte
+		locked   bool
+	}
+)
+
+// Load reads the vault file.
+func (s *FileStorage) Load() ([]byte, error) {
+	return ioutil.ReadFile(s.Path)
+}
+
+// Store replaces the vault file atomically, flushing it to disk, as Save does.
+func (s *FileStorage) Store(contents []byte) error {
+	return writeAtomic(s.Path, func(w io.Writer) error {
+		_, err := w.Write(contents)
+		return err
+	})
+}
+
+// Lock locks the vault file, which does nothing if it is already locked by s.
+func (s *FileStorage) Lock() error {
+	return s.lock.acquire(s.Path)
+}
+
+// Unlock releases the lock on the vault file, removing the lock file.
+func (s *FileStorage) Unlock() error {
+	return s.lock.release()
+}
+
+// Load returns a copy of the stored contents.
+func (s *MemoryStorage) Load() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contents == nil {
+		return nil, os.ErrNotExist
+	}
+	return append([]byte{}, s.contents...), nil
+}
+
+// Store replaces the stored contents with a copy of `contents`.
+func (s *MemoryStorage) Store(contents []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contents = append([]byte{}, contents...)
+	return nil
+}
+
+// Lock locks the storage, returning a *VaultLockedError if it is already
+// locked.
+func (s *MemoryStorage) Lock() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return &VaultLockedError{}
+	}
+	s.locked = true
+	return nil
+}
+
+// Unlock unlocks the storage.
+func (s *MemoryStorage) Unlock() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locked = false
+	return nil
+}
+
+// OpenStorage opens the vault stored in `s`, decrypting it using `passphrase`
+// as OpenWithOptions does with `opts`, except that Throttle is ignored, as
+// there is no file to record failed attempts next to. If `opts` sets Lock,
+// `s` is locked until the vault is Closed.
+func OpenStorage(s Storage, passphrase string, opts OpenOptions) (*Vault, error) {
+	opts.Throttle = false
+	return openStorage(s, "", passphrase, opts)
+}
+
+// SaveStorage persists the vault to `s`, as Save does to a file, but keeps no
+// backups and writes no signature. Age vaults cannot be saved to a Storage.
+func (v *Vault) SaveStorage(s Storage) error {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return ErrAgeVault
+	}
+	var contents bytes.Buffer
+	if err := v.writeFile(&contents); err != nil {
+		return err
+	}
+	if err := s.Store(contents.Bytes()); err != nil {
+		return err
+	}
+	if v.undo.clearOnSave {
+		v.undo.clear()
+	}
+	return nil
+}
+
+// WriteTo writes the vault to `w` as Save writes it to a file, or as an age
+// file if it is an age vault, so that it can be embedded in other programs,
+// piped, or kept anywhere, and read back using Read or OpenAge. Like
+// SaveStorage, it keeps no backups and writes no signature. It implements
+// io.WriterTo.
+func (v *Vault) WriteTo(w io.Writer) (int64, error) {
+	if v.closed {
+		return 0, ErrLocked
+	}
+	if v.readOnly {
+		return 0, ErrReadOnly
+	}
+	// The vault is written whole, so that nothing is written to `w` if
+	// encoding it fails.
+	var contents bytes.Buffer
+	var err error
+	if v.ageRecipients != nil {
+		err = v.writeAge(&contents, v.ageRecipients)
+	} else {
+		err = v.writeFile(&contents)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := contents.WriteTo(w)
+	if err != nil {
+		return n, err
+	}
+	if v.undo.clearOnSave {
+		v.undo.clear()
+	}
+	return n, nil
+}
+
+// Read reads a vault written by WriteTo or Save from `r` and decrypts it using
+// `passphrase`, as Open does. To open it using other unlock factors, store it
+// in a MemoryStorage and use OpenStorage.
+func Read(r io.Reader, passphrase string) (*Vault, error) {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return OpenStorage(&MemoryStorage{contents: contents}, passphrase, OpenOptions{})
+}
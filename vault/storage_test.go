@@ -0,0 +1,105 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	relaxDefaults(t)
+	s := &MemoryStorage{}
+	if _, err := OpenStorage(s, "testpass", OpenOptions{}); !os.IsNotExist(err) {
+		t.Fatal("expected an empty storage not to exist, got", err)
+	}
+
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SaveStorage(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = OpenStorage(s, "wrongpass", OpenOptions{Lock: true}); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt, got", err)
+	}
+	vopen, err := OpenStorage(s, "testpass", OpenOptions{Lock: true})
+	if err != nil {
+		t.Fatal("expected a failed open to release the lock, got", err)
+	}
+	if cred, err := vopen.Get("testlocation"); err != nil || cred.Password != "testpass" {
+		t.Fatal("could not read the stored credential", err)
+	}
+	if _, err = OpenStorage(s, "testpass", OpenOptions{Lock: true}); !errors.Is(err, ErrVaultLocked) {
+		t.Fatal("expected ErrVaultLocked, got", err)
+	}
+	if err = vopen.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Lock(); err != nil {
+		t.Fatal("expected Close to release the lock, got", err)
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SaveStorage(&FileStorage{Path: "pass.db"}); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred, err := vopen.Get("testlocation"); err != nil || cred.Password != "testpass" {
+		t.Fatal("could not read the stored credential", err)
+	}
+}
+
+func TestWriteToRead(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := v.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatal("expected WriteTo to return the bytes written, got", n, "rather than", buf.Len())
+	}
+
+	if _, err = Read(bytes.NewReader(buf.Bytes()), "wrongpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt, got", err)
+	}
+	vread, err := Read(&buf, "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred, err := vread.Get("testlocation"); err != nil || cred.Password != "testpass" {
+		t.Fatal("could not read the written credential", err)
+	}
+
+	vread.Close()
+	if _, err = vread.WriteTo(&buf); err != ErrLocked {
+		t.Fatal("expected ErrLocked, got", err)
+	}
+}
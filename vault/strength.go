@@ -0,0 +1,301 @@
+package vault
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// crackGuessesPerSecond is the guess rate assumed by CrackTime: an
+	// offline attack against a slow hash such as bcrypt or scrypt.
+	crackGuessesPerSecond = 1e4
+
+	// minDictionaryMatch is the length of the shortest dictionary word or
+	// pattern matched by EstimateStrength.
+	minDictionaryMatch = 3
+)
+
+// commonPasswords are among the most frequently used passwords, most common
+// first. They are guessed before anything else.
+var commonPasswords = []string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345", "1234",
+	"111111", "1234567", "dragon", "123123", "baseball", "abc123", "football",
+	"monkey", "letmein", "696969", "shadow", "master", "666666", "qwertyuiop",
+	"123321", "mustang", "1234567890", "michael", "654321", "superman",
+	"1qaz2wsx", "7777777", "121212", "000000", "qazwsx", "123qwe", "killer",
+	"trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter", "buster",
+	"soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou",
+	"2000", "charlie", "robert", "thomas", "hockey", "ranger", "daniel",
+	"starwars", "klaster", "112233", "george", "computer", "michelle",
+	"jessica", "pepper", "1111", "zxcvbn", "555555", "11111111", "131313",
+	"freedom", "777777", "pass", "maggie", "159753", "aaaaaa", "ginger",
+	"princess", "joshua", "cheese", "amanda", "summer", "love", "ashley",
+	"nicole", "chelsea", "biteme", "matthew", "access", "yankees", "987654321",
+	"dallas", "austin", "thunder", "taylor", "matrix", "admin", "welcome",
+	"login", "passw0rd", "secret", "changeme",
+}
+
+// keyboardRows are the rows of a QWERTY keyboard, matched forwards and
+// backwards as keyboard patterns.
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890"}
+
+// strengthDictionary maps each lowercase dictionary word to the number of
+// guesses needed to reach it.
+var (
+	strengthDictionary     map[string]float64
+	strengthDictionaryOnce sync.Once
+)
+
+// Strength rates how hard a password is to guess.
+type Strength int
+
+// Password strengths, from weakest to strongest.
+const (
+	// StrengthVeryWeak passwords take fewer than a thousand guesses.
+	StrengthVeryWeak Strength = iota
+	// StrengthWeak passwords take fewer than a million guesses.
+	StrengthWeak
+	// StrengthFair passwords take fewer than a hundred million guesses.
+	StrengthFair
+	// StrengthStrong passwords take fewer than ten billion guesses.
+	StrengthStrong
+	// StrengthVeryStrong passwords take at least ten billion guesses.
+	StrengthVeryStrong
+)
+
+// StrengthResult is an estimate of how hard a password is to guess.
+type StrengthResult struct {
+	// Entropy is the estimated number of bits of entropy, taking common
+	// passwords, dictionary words and patterns into account.
+	Entropy float64
+	// Guesses is the estimated number of guesses needed to find the
+	// password.
+	Guesses float64
+	// CrackTime is the estimated time to find the password in an offline
+	// attack against a slow hash.
+	CrackTime time.Duration
+	// Score rates the password. Passwords scoring less than StrengthStrong
+	// should be considered weak.
+	Score Strength
+	// Feedback contains suggestions for improving the password.
+	Feedback []string
+}
+
+// Weak returns true if the password should not be used.
+func (r StrengthResult) Weak() bool {
+	return r.Score < StrengthStrong
+}
+
+// strengthMatch is a run of a password matched by a pattern.
+type strengthMatch struct {
+	start, end int
+	guesses    float64
+	feedback   string
+}
+
+// loadStrengthDictionary builds strengthDictionary from commonPasswords and
+// EFFLargeWordlist.
+func loadStrengthDictionary() {
+	strengthDictionary = make(map[string]float64, len(commonPasswords)+len(EFFLargeWordlist))
+	for _, word := range EFFLargeWordlist {
+		strengthDictionary[word] = float64(len(EFFLargeWordlist))
+	}
+	for i, password := range commonPasswords {
+		strengthDictionary[password] = float64(i + 1)
+	}
+}
+
+// cardinality returns the size of the character set `password` is drawn
+// from, judged by the classes of the characters it contains.
+func cardinality(password []rune) float64 {
+	var lower, upper, digits, symbols, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digits = true
+		case r <= unicode.MaxASCII:
+			symbols = true
+		default:
+			other = true
+		}
+	}
+	var n float64
+	if lower {
+		n += 26
+	}
+	if upper {
+		n += 26
+	}
+	if digits {
+		n += 10
+	}
+	if symbols {
+		n += 33
+	}
+	if other {
+		n += 100
+	}
+	return n
+}
+
+// strengthMatches returns every run of `password` matched by a dictionary
+// word or a predictable pattern.
+func strengthMatches(password []rune) []strengthMatch {
+	strengthDictionaryOnce.Do(loadStrengthDictionary)
+	lower := make([]rune, len(password))
+	for i, r := range password {
+		lower[i] = unicode.ToLower(r)
+	}
+	var matches []strengthMatch
+
+	for i := range lower {
+		for j := i + minDictionaryMatch; j <= len(lower); j++ {
+			word := string(lower[i:j])
+			guesses, ok := strengthDictionary[word]
+			if !ok {
+				continue
+			}
+			feedback := "Avoid dictionary words"
+			if guesses < float64(len(EFFLargeWordlist)) {
+				feedback = "Avoid common passwords"
+			}
+			// Capitalization adds little, as it is usually predictable.
+			if word != string(password[i:j]) {
+				guesses *= 2
+			}
+			matches = append(matches, strengthMatch{i, j, guesses, feedback})
+		}
+	}
+
+	for i := 0; i < len(lower); {
+		j := i + 1
+		for j < len(lower) && lower[j] == lower[i] {
+			j++
+		}
+		if j-i >= minDictionaryMatch {
+			matches = append(matches, strengthMatch{i, j, cardinality(lower[i:i+1]) * float64(j-i), "Avoid repeated characters"})
+		}
+		i = j
+	}
+
+	for i := 0; i+1 < len(lower); {
+		delta := lower[i+1] - lower[i]
+		j := i + 1
+		for j < len(lower) && lower[j]-lower[j-1] == delta && (delta == 1 || delta == -1) {
+			j++
+		}
+		if j-i >= minDictionaryMatch {
+			matches = append(matches, strengthMatch{i, j, cardinality(lower[i:i+1]) * float64(j-i) * 2, "Avoid sequences like abc or 321"})
+		}
+		if j > i+1 {
+			i = j - 1
+		} else {
+			i++
+		}
+	}
+
+	for _, row := range keyboardRows {
+		reversed := []rune(row)
+		for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+			reversed[i], reversed[j] = reversed[j], reversed[i]
+		}
+		for _, r := range []string{row, string(reversed)} {
+			for i := range lower {
+				j := i
+				for j < len(lower) && strings.Contains(r, string(lower[i:j+1])) {
+					j++
+				}
+				if j-i > minDictionaryMatch {
+					matches = append(matches, strengthMatch{i, j, float64(len(keyboardRows)*len(row)) * float64(j-i), "Avoid keyboard patterns"})
+				}
+			}
+		}
+	}
+
+	for i := 0; i+4 <= len(lower); i++ {
+		year := string(lower[i : i+4])
+		if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) && strings.Trim(year, "0123456789") == "" {
+			matches = append(matches, strengthMatch{i, i + 4, 200, "Avoid years and dates"})
+		}
+	}
+	return matches
+}
+
+// EstimateStrength estimates how hard `password` is to guess, in the style of
+// zxcvbn: the password is split into the runs that are cheapest for an
+// attacker to guess, whether common passwords, dictionary words, repeats,
+// sequences, keyboard patterns, years or brute force, and the guesses needed
+// for each run are multiplied together.
+func EstimateStrength(password string) StrengthResult {
+	runes := []rune(password)
+	bruteForceBits := math.Log2(math.Max(cardinality(runes), 1))
+	matches := strengthMatches(runes)
+
+	// bits[i] is the fewest bits needed to guess the first i characters, and
+	// via[i] is the match used to reach i, or nil for a brute forced
+	// character.
+	bits := make([]float64, len(runes)+1)
+	via := make([]*strengthMatch, len(runes)+1)
+	for i := 1; i <= len(runes); i++ {
+		bits[i] = bits[i-1] + bruteForceBits
+		for m := range matches {
+			match := &matches[m]
+			if match.end != i {
+				continue
+			}
+			if b := bits[match.start] + math.Log2(match.guesses); b < bits[i] {
+				bits[i] = b
+				via[i] = match
+			}
+		}
+	}
+
+	result := StrengthResult{Entropy: bits[len(runes)]}
+	result.Guesses = math.Pow(2, result.Entropy)
+	seconds := result.Guesses / crackGuessesPerSecond
+	if seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		result.CrackTime = time.Duration(math.MaxInt64)
+	} else {
+		result.CrackTime = time.Duration(seconds * float64(time.Second))
+	}
+	switch {
+	case result.Guesses < 1e3:
+		result.Score = StrengthVeryWeak
+	case result.Guesses < 1e6:
+		result.Score = StrengthWeak
+	case result.Guesses < 1e8:
+		result.Score = StrengthFair
+	case result.Guesses < 1e10:
+		result.Score = StrengthStrong
+	default:
+		result.Score = StrengthVeryStrong
+	}
+
+	seen := make(map[string]bool)
+	for i := len(runes); i > 0; {
+		match := via[i]
+		if match == nil {
+			i--
+			continue
+		}
+		if !seen[match.feedback] {
+			seen[match.feedback] = true
+			result.Feedback = append(result.Feedback, match.feedback)
+		}
+		i = match.start
+	}
+	if result.Weak() && len(runes) < 12 {
+		result.Feedback = append(result.Feedback, "Use a longer password")
+	}
+	if result.Weak() {
+		result.Feedback = append(result.Feedback, "Add more unusual words or characters, or generate a password")
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateStrength(t *testing.T) {
+	weak := map[string]string{
+		"password":   "Avoid common passwords",
+		"Password1":  "Avoid common passwords",
+		"aaaaaaaa":   "Avoid repeated characters",
+		"abcdefgh":   "Avoid sequences like abc or 321",
+		"asdfghjk":   "Avoid keyboard patterns",
+		"monkey1987": "Avoid years and dates",
+	}
+	for password, feedback := range weak {
+		result := EstimateStrength(password)
+		if !result.Weak() {
+			t.Fatalf("expected %q to be weak, got score %v", password, result.Score)
+		}
+		found := false
+		for _, f := range result.Feedback {
+			found = found || f == feedback
+		}
+		if !found {
+			t.Fatalf("expected feedback %q for %q, got %v", feedback, password, result.Feedback)
+		}
+	}
+
+	if result := EstimateStrength(""); result.Score != StrengthVeryWeak || result.Entropy != 0 {
+		t.Fatalf("expected an empty password to score 0, got %+v", result)
+	}
+
+	password, err := GeneratePassword(GenerateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A random password may happen to contain a short sequence, which is
+	// reported as feedback without making it weak.
+	result := EstimateStrength(password)
+	if result.Score != StrengthVeryStrong || result.Weak() {
+		t.Fatalf("expected generated password %q to be strong, got %+v", password, result)
+	}
+	if result.CrackTime < 100*365*24*time.Hour {
+		t.Fatalf("expected generated password %q to take centuries to crack, got %v", password, result.CrackTime)
+	}
+
+	phrase, err := GeneratePassphrase(6, " ", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result = EstimateStrength(phrase); result.Score != StrengthVeryStrong {
+		t.Fatalf("expected passphrase %q to be strong, got %+v", phrase, result)
+	}
+	if result.Entropy > 6*14+6*7 {
+		t.Fatalf("expected passphrase %q to be rated by its words, got %v bits", phrase, result.Entropy)
+	}
+}
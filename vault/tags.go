@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"time"
+)
+
+// hasTag returns true if `tag` is present in `tags`.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Tag adds the provided `tags` to the credential at `location`. Tags that are
+// already present on the credential are ignored.
+func (v *Vault) Tag(location string, tags ...string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	location = p.resolve(location)
+	cred, err := p.credential(location)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if !hasTag(cred.Tags, tag) {
+			cred.Tags = append(cred.Tags, tag)
+		}
+	}
+	cred.UpdatedAt = time.Now()
+
+	return v.encrypt(p)
+}
+
+// Untag removes the provided `tags` from the credential at `location`. Tags
+// that are not present on the credential are ignored.
+func (v *Vault) Untag(location string, tags ...string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	location = p.resolve(location)
+	cred, err := p.credential(location)
+	if err != nil {
+		return err
+	}
+
+	var remaining []string
+	for _, t := range cred.Tags {
+		if !hasTag(tags, t) {
+			remaining = append(remaining, t)
+		}
+	}
+	cred.Tags = remaining
+	cred.UpdatedAt = time.Now()
+
+	return v.encrypt(p)
+}
+
+// FindByTag returns the credentials tagged with `tag`, keyed by location.
+func (v *Vault) FindByTag(tag string) (map[string]*Credential, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]*Credential)
+	for location, cred := range p.Credentials {
+		if hasTag(cred.Tags, tag) {
+			matches[location] = cred
+		}
+	}
+	return matches, nil
+}
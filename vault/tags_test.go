@@ -0,0 +1,80 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagUntag(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.Tag("testlocation", "work"); err != ErrNoSuchCredential {
+		t.Fatal("expected Tag on non-existant location to return ErrNoSuchCredential")
+	}
+
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Tag("testlocation", "work", "prod", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cred.Tags, []string{"work", "prod"}) {
+		t.Fatalf("expected tags [work prod], got %v", cred.Tags)
+	}
+
+	err = v.Untag("testlocation", "work", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err = v.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cred.Tags, []string{"prod"}) {
+		t.Fatalf("expected tags [prod], got %v", cred.Tags)
+	}
+}
+
+func TestFindByTag(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Add("testlocation1", Credential{Username: "testuser1", Password: "testpass1", Tags: []string{"work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation2", Credential{Username: "testuser2", Password: "testpass2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Tag("testlocation2", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation3", Credential{Username: "testuser3", Password: "testpass3", Tags: []string{"personal"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := v.FindByTag("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches["testlocation1"] == nil || matches["testlocation2"] == nil {
+		t.Fatalf("FindByTag returned the wrong credentials: %v", matches)
+	}
+}
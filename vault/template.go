@@ -0,0 +1,127 @@
+package vault
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrNoSuchTemplate is returned if a template does not exist.
+var ErrNoSuchTemplate = errors.New("template does not exist")
+
+// Template is the common structure of a set of credentials, such as the
+// access keys of many cloud accounts, used to create credentials with
+// AddFromTemplate.
+type Template struct {
+	Kind     Kind
+	Username string
+	URL      string
+	Notes    string
+	Tags     []string
+	// Fields are the custom fields of credentials created from the
+	// template. Fields with empty values are placeholders to be filled in.
+	Fields map[string]string
+	// Policy, if set, is attached to the location of credentials created
+	// from the template.
+	Policy *Policy
+	// Generate, if set, generates a password for credentials created from
+	// the template, conforming to Policy.
+	Generate *GenerateOptions
+}
+
+// credential returns a new credential pre-populated from the template.
+func (template *Template) credential() Credential {
+	cred := Credential{
+		Kind:     template.Kind,
+		Username: template.Username,
+		URL:      template.URL,
+		Notes:    template.Notes,
+		Tags:     template.Tags,
+		Fields:   template.Fields,
+	}
+	return cred.clone()
+}
+
+// SetTemplate saves `template` under the name `name`, replacing any existing
+// template of that name.
+func (v *Vault) SetTemplate(name string, template Template) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	p.Templates[name] = &template
+
+	return v.encrypt(p)
+}
+
+// GetTemplate returns the template named `name`.
+func (v *Vault) GetTemplate(name string) (*Template, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	template, ok := p.Templates[name]
+	if !ok {
+		return nil, ErrNoSuchTemplate
+	}
+	return template, nil
+}
+
+// DeleteTemplate removes the template named `name`. Credentials created from
+// the template are unaffected.
+func (v *Vault) DeleteTemplate(name string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := p.Templates[name]; !ok {
+		return ErrNoSuchTemplate
+	}
+	delete(p.Templates, name)
+
+	return v.encrypt(p)
+}
+
+// Templates returns the names of the vault's templates, sorted.
+func (v *Vault) Templates() ([]string, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range p.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// AddFromTemplate adds a credential at `location` pre-populated from the
+// template named `template`, attaching the template's policy to `location`
+// and generating a password if the template asks for one.
+func (v *Vault) AddFromTemplate(location string, template string) error {
+	return v.Batch(func(tx *Tx) error {
+		t, ok := tx.p.Templates[template]
+		if !ok {
+			return ErrNoSuchTemplate
+		}
+
+		cred := t.credential()
+		if t.Policy != nil {
+			policy := *t.Policy
+			tx.p.Policies[location] = &policy
+		}
+		if t.Generate != nil {
+			password, err := tx.p.generate(location, *t.Generate)
+			if err != nil {
+				return err
+			}
+			cred.Password = password
+			cred.recordGenerated(password)
+		}
+		return tx.Add(location, cred)
+	})
+}
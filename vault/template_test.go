@@ -0,0 +1,66 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddFromTemplate(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := Template{
+		URL:      "https://console.aws.amazon.com",
+		Tags:     []string{"aws"},
+		Fields:   map[string]string{"access key id": "", "secret access key": ""},
+		Policy:   &Policy{MinLength: 32, Classes: ClassAll &^ ClassSymbols},
+		Generate: &GenerateOptions{},
+	}
+	if err = v.SetTemplate("aws", template); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.AddFromTemplate("aws/prod", "nonexistent"); err != ErrNoSuchTemplate {
+		t.Fatal("expected ErrNoSuchTemplate using a nonexistent template, got", err)
+	}
+	if err = v.AddFromTemplate("aws/prod", "aws"); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("aws/prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.URL != template.URL || !reflect.DeepEqual(cred.Tags, template.Tags) || !reflect.DeepEqual(cred.Fields, template.Fields) {
+		t.Fatal("credential was not pre-populated from the template")
+	}
+	if err = template.Policy.Check(cred.Password); err != nil {
+		t.Fatal("generated password does not conform to the template's policy:", err)
+	}
+	if _, err = v.GetPolicy("aws/prod"); err != nil {
+		t.Fatal("template's policy was not attached to the location:", err)
+	}
+
+	if err = v.SetField("aws/prod", "access key id", "AKIAEXAMPLE"); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := v.GetTemplate("aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Fields["access key id"] != "" {
+		t.Fatal("editing a credential modified its template")
+	}
+
+	if err = v.DeleteTemplate("aws"); err != nil {
+		t.Fatal(err)
+	}
+	names, err := v.Templates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Fatal("template was not deleted")
+	}
+}
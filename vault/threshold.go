@@ -0,0 +1,221 @@
+package vault
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// maxApprovers is the maximum number of approvers of a threshold slot.
+	maxApprovers = 16
+
+	// maxApproverNameSize bounds the size of the name of an approver.
+	maxApproverNameSize = 256
+)
+
+// ErrInvalidApprovers is returned from AddThresholdKeySlot if the approvers
+// are too few or too many, or their names are not distinct.
+var ErrInvalidApprovers = errors.New("a threshold slot needs at least 2 and at most 16 approvers with distinct names")
+
+type (
+	// Approver is one of the keyholders of a threshold slot, added using
+	// AddThresholdKeySlot, identified by their Name and unlocking their
+	// share using their Passphrase.
+	Approver struct {
+		Name       string
+		Passphrase string
+	}
+
+	// ApproveFunc asks the approver `name` of a threshold slot for their
+	// passphrase, such as by prompting on the terminal, returning an empty
+	// passphrase if they are not present to approve.
+	ApproveFunc func(name string) (string, error)
+
+	// approver is an approver of a threshold slot: their share of the
+	// slot's key, wrapped by a key derived from their passphrase.
+	approver struct {
+		name    string
+		salt    [24]byte
+		wrapped []byte
+	}
+
+	// thresholdHeader follows the slotHeader of a threshold slot, which is
+	// followed in turn by the length and name, salt and wrapped share of
+	// each approver.
+	thresholdHeader struct {
+		Threshold uint32
+		Approvers uint32
+	}
+)
+
+// unwrapThreshold recovers the key of the threshold `slot`, by asking its
+// approvers in turn for their passphrases using `approve` until enough of
+// their shares are unwrapped to combine them. An approver whose passphrase is
+// wrong is skipped, as is one who gives none. ErrCouldNotDecrypt is returned
+// if too few approve.
+func (factors unlockFactors) unwrapThreshold(slot keySlot, cipher CipherID) ([32]byte, error) {
+	var shares []Share
+	defer func() {
+		for i := range shares {
+			wipe(shares[i].Value[:])
+		}
+	}()
+	for i, a := range slot.approvers {
+		if len(shares) == slot.threshold {
+			break
+		}
+		passphrase, err := factors.approve(a.name)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		if passphrase == "" {
+			continue
+		}
+		kek, err := slot.kdf.derive(compositeKey(passphrase), a.salt)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		value, err := unwrapKey(cipher, &kek, a.wrapped)
+		wipe(kek[:])
+		if err != nil {
+			continue
+		}
+		shares = append(shares, Share{Threshold: slot.threshold, Index: i + 1, Value: value})
+	}
+	if len(shares) < slot.threshold {
+		return [32]byte{}, ErrCouldNotDecrypt
+	}
+	return combineShares(shares)
+}
+
+// readApprovers reads the threshold and approvers of a threshold slot from
+// `r`, whose shares are wrapped using the cipher `cipher`.
+func (slot *keySlot) readApprovers(r io.Reader, cipher CipherID) error {
+	var th thresholdHeader
+	if err := binary.Read(r, binary.BigEndian, &th); err != nil {
+		return ErrCouldNotDecrypt
+	}
+	if th.Threshold < 2 || th.Approvers > maxApprovers || th.Threshold > th.Approvers {
+		return ErrCouldNotDecrypt
+	}
+	size, err := wrappedKeySize(cipher)
+	if err != nil {
+		return err
+	}
+	slot.threshold = int(th.Threshold)
+	for i := uint32(0); i < th.Approvers; i++ {
+		name, err := readSized(r, maxApproverNameSize)
+		if err != nil {
+			return err
+		}
+		a := approver{name: string(name), wrapped: make([]byte, size)}
+		if _, err = io.ReadFull(r, a.salt[:]); err != nil {
+			return ErrCouldNotDecrypt
+		}
+		if _, err = io.ReadFull(r, a.wrapped); err != nil {
+			return ErrCouldNotDecrypt
+		}
+		slot.approvers = append(slot.approvers, a)
+	}
+	return nil
+}
+
+// writeApprovers writes the threshold and approvers of the threshold `slot`
+// to `w`.
+func (slot keySlot) writeApprovers(w io.Writer) error {
+	th := thresholdHeader{Threshold: uint32(slot.threshold), Approvers: uint32(len(slot.approvers))}
+	if err := binary.Write(w, binary.BigEndian, &th); err != nil {
+		return err
+	}
+	for _, a := range slot.approvers {
+		if err := writeSized(w, []byte(a.name)); err != nil {
+			return err
+		}
+		if _, err := w.Write(a.salt[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(a.wrapped); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newThresholdKeySlot returns a threshold slot wrapping the vault's master key
+// by a random key split between `approvers`, any `threshold` of whom recover
+// it.
+func (v *Vault) newThresholdKeySlot(threshold int, approvers []Approver) (keySlot, error) {
+	if len(approvers) < 2 || len(approvers) > maxApprovers {
+		return keySlot{}, ErrInvalidApprovers
+	}
+	names := make(map[string]bool)
+	for _, a := range approvers {
+		if a.Name == "" || len(a.Name) > maxApproverNameSize || names[a.Name] {
+			return keySlot{}, ErrInvalidApprovers
+		}
+		names[a.Name] = true
+	}
+	key := randomKey()
+	defer wipe(key[:])
+	shares, err := splitSecret(key, len(approvers), threshold)
+	if err != nil {
+		return keySlot{}, err
+	}
+	slot := keySlot{
+		kdf:       v.KDFParams(),
+		salt:      randomSalt(),
+		threshold: threshold,
+	}
+	for i, a := range approvers {
+		sealed := approver{name: a.Name, salt: randomSalt()}
+		kek, err := slot.kdf.derive(compositeKey(a.Passphrase), sealed.salt)
+		if err != nil {
+			return keySlot{}, err
+		}
+		sealed.wrapped, err = wrapKey(v.cipher, &kek, shares[i].Value)
+		wipe(kek[:])
+		wipe(shares[i].Value[:])
+		if err != nil {
+			return keySlot{}, err
+		}
+		slot.approvers = append(slot.approvers, sealed)
+	}
+	if slot.wrapped, err = wrapKey(v.cipher, &key, v.keys.master); err != nil {
+		return keySlot{}, err
+	}
+	return slot, nil
+}
+
+// AddThresholdKeySlot adds a threshold slot to the vault, such as for a team
+// vault, and returns the number of the new slot. Each of `approvers` holds a
+// share of the slot's key, wrapped by a key derived from their own
+// passphrase, and any `threshold` of them together unlock the vault using
+// OpenWithOptions with Approve set, which asks each for their passphrase in
+// turn. Fewer approvers learn nothing about the slot's key, so the vault
+// cannot be decrypted without enough of them. Unlike the shares of SplitKey,
+// no share ever leaves the vault unwrapped. The slot is written by the next
+// Save.
+func (v *Vault) AddThresholdKeySlot(threshold int, approvers []Approver) (int, error) {
+	if v.closed {
+		return 0, ErrLocked
+	}
+	if v.readOnly {
+		return 0, ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return 0, ErrAgeVault
+	}
+	if v.outer != nil {
+		return 0, ErrHiddenVault
+	}
+	if len(v.slots) >= maxKeySlots {
+		return 0, ErrTooManyKeySlots
+	}
+	slot, err := v.newThresholdKeySlot(threshold, approvers)
+	if err != nil {
+		return 0, err
+	}
+	v.slots = append(v.slots, slot)
+	return len(v.slots) - 1, nil
+}
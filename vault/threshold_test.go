@@ -0,0 +1,75 @@
+package vault
+
+import (
+	"os"
+	"testing"
+)
+
+// approvals returns an ApproveFunc answering with `passphrases`, keyed by
+// approver, and recording who was asked in `asked`.
+func approvals(passphrases map[string]string, asked *[]string) ApproveFunc {
+	return func(name string) (string, error) {
+		*asked = append(*asked, name)
+		return passphrases[name], nil
+	}
+}
+
+func TestThresholdKeySlot(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	approvers := []Approver{{"alice", "alicepass"}, {"bob", "bobpass"}, {"carol", "carolpass"}}
+	if _, err = v.AddThresholdKeySlot(2, approvers[:1]); err != ErrInvalidApprovers {
+		t.Fatal("expected ErrInvalidApprovers for a single approver, got", err)
+	}
+	if _, err = v.AddThresholdKeySlot(2, []Approver{{"alice", "a"}, {"alice", "b"}}); err != ErrInvalidApprovers {
+		t.Fatal("expected ErrInvalidApprovers for duplicate names, got", err)
+	}
+	if _, err = v.AddThresholdKeySlot(4, approvers); err != ErrInvalidThreshold {
+		t.Fatal("expected ErrInvalidThreshold, got", err)
+	}
+	slot, err := v.AddThresholdKeySlot(2, approvers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listed := v.ListKeySlots()[slot]; listed.Threshold != 2 || len(listed.Approvers) != 3 || listed.Approvers[1] != "bob" {
+		t.Fatal("unexpected threshold slot", listed)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	var asked []string
+	approve := approvals(map[string]string{"alice": "alicepass", "carol": "carolpass"}, &asked)
+	vopen, err := OpenWithOptions("pass.db", "", OpenOptions{Approve: approve})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(asked) != 3 {
+		t.Fatal("expected every approver to be asked until two approved, got", asked)
+	}
+	if cred, err := vopen.Get("testlocation"); err != nil || cred.Password != "testpassword" {
+		t.Fatal("could not get the credential after approval", cred, err)
+	}
+	if err = vopen.ChangePassphrase("", "newpass"); err != ErrNoPassphrase {
+		t.Fatal("expected ErrNoPassphrase, got", err)
+	}
+
+	asked = nil
+	approve = approvals(map[string]string{"alice": "alicepass", "bob": "wrongpass", "carol": ""}, &asked)
+	if _, err = OpenWithOptions("pass.db", "", OpenOptions{Approve: approve}); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt with a single approval, got", err)
+	}
+	if _, err = Open("pass.db", "alicepass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected an approver's passphrase not to open the vault alone, got", err)
+	}
+	if _, err = Open("pass.db", "testpass"); err != nil {
+		t.Fatal(err)
+	}
+}
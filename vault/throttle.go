@@ -0,0 +1,113 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	// freeAttempts is the number of consecutive failed attempts to open a
+	// vault allowed before attempts are delayed.
+	freeAttempts = 3
+
+	// firstAttemptDelay is the delay after the first failed attempt beyond
+	// freeAttempts, which doubles with each further failure up to
+	// maxAttemptDelay.
+	firstAttemptDelay = time.Second
+	maxAttemptDelay   = time.Hour
+)
+
+// ErrTooManyAttempts is returned from OpenWithOptions with Throttle set if
+// the vault is opened again before the delay imposed by previous failed
+// attempts has passed.
+var ErrTooManyAttempts = errors.New("too many failed attempts to open the vault, try again later")
+
+// failedAttempts records the consecutive failed attempts to open a vault.
+type failedAttempts struct {
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// attemptsPath returns the path of the file recording the failed attempts to
+// open the vault at `filename`, which is kept next to it.
+func attemptsPath(filename string) string {
+	return filename + ".attempts"
+}
+
+// readAttempts returns the failed attempts recorded for the vault at
+// `filename`, which are none if no file records them.
+func readAttempts(filename string) (failedAttempts, error) {
+	var attempts failedAttempts
+	data, err := ioutil.ReadFile(attemptsPath(filename))
+	if os.IsNotExist(err) {
+		return attempts, nil
+	}
+	if err != nil {
+		return attempts, err
+	}
+	// A corrupt record is treated as the maximum delay rather than none,
+	// so it cannot be used to reset the count.
+	if err = json.Unmarshal(data, &attempts); err != nil {
+		attempts = failedAttempts{Count: 64, Last: time.Now()}
+	}
+	return attempts, nil
+}
+
+// delay returns the delay imposed after `attempts`, from the last of them.
+func (attempts failedAttempts) delay() time.Duration {
+	if attempts.Count < freeAttempts {
+		return 0
+	}
+	delay := firstAttemptDelay
+	for i := freeAttempts; i < attempts.Count && delay < maxAttemptDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxAttemptDelay {
+		delay = maxAttemptDelay
+	}
+	return delay
+}
+
+// AttemptDelay returns how long remains before the vault at `filename` may be
+// opened with Throttle set, which is zero unless the last few attempts to
+// open it failed. Each failure beyond the third doubles the delay, from one
+// second up to an hour, so an interactive program can wait it out before
+// prompting for a passphrase again.
+func AttemptDelay(filename string) (time.Duration, error) {
+	attempts, err := readAttempts(filename)
+	if err != nil {
+		return 0, err
+	}
+	remaining := time.Until(attempts.Last.Add(attempts.delay()))
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// recordFailedAttempt records a failed attempt to open the vault at
+// `filename`.
+func recordFailedAttempt(filename string) error {
+	attempts, err := readAttempts(filename)
+	if err != nil {
+		return err
+	}
+	attempts.Count++
+	attempts.Last = time.Now()
+	data, err := json.Marshal(attempts)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(attemptsPath(filename), data, 0600)
+}
+
+// resetAttempts forgets the failed attempts to open the vault at `filename`.
+func resetAttempts(filename string) error {
+	if err := os.Remove(attemptsPath(filename)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
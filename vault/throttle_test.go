@@ -0,0 +1,68 @@
+package vault
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	relaxDefaults(t)
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	defer os.Remove(attemptsPath("pass.db"))
+
+	opts := OpenOptions{Throttle: true}
+	for i := 0; i < freeAttempts; i++ {
+		if _, err = OpenWithOptions("pass.db", "wrongpass", opts); err != ErrCouldNotDecrypt {
+			t.Fatal("expected ErrCouldNotDecrypt, got", err)
+		}
+	}
+	delay, err := AttemptDelay("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay <= 0 || delay > firstAttemptDelay {
+		t.Fatal("expected a delay of up to", firstAttemptDelay, "got", delay)
+	}
+	if _, err = OpenWithOptions("pass.db", "testpass", opts); err != ErrTooManyAttempts {
+		t.Fatal("expected ErrTooManyAttempts, got", err)
+	}
+	if _, err = Open("pass.db", "testpass"); err != nil {
+		t.Fatal("expected Open without Throttle to ignore the delay, got", err)
+	}
+
+	// Each further failure doubles the delay.
+	attempts := failedAttempts{Count: freeAttempts + 3, Last: time.Now()}
+	if attempts.delay() != 8*firstAttemptDelay {
+		t.Fatal("expected a delay of", 8*firstAttemptDelay, "got", attempts.delay())
+	}
+	if (failedAttempts{Count: 1000}).delay() != maxAttemptDelay {
+		t.Fatal("expected the delay to be capped at", maxAttemptDelay)
+	}
+
+	// Once the delay has passed, a successful attempt forgets the
+	// failures.
+	attempts.Last = time.Now().Add(-time.Minute)
+	data, err := json.Marshal(attempts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(attemptsPath("pass.db"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenWithOptions("pass.db", "testpass", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(attemptsPath("pass.db")); !os.IsNotExist(err) {
+		t.Fatal("expected the failed attempts to be forgotten, got", err)
+	}
+}
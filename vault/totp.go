@@ -0,0 +1,134 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoTOTP is returned from TOTP if the credential does not have a
+	// TOTP secret.
+	ErrNoTOTP = errors.New("credential does not have a totp secret")
+	// ErrInvalidTOTP is returned from TOTP if the credential's TOTP secret
+	// cannot be parsed.
+	ErrInvalidTOTP = errors.New("invalid totp secret")
+)
+
+// otpParams are the parameters of a one-time password generator.
+type otpParams struct {
+	key       []byte
+	algorithm func() hash.Hash
+	digits    int
+	period    int64
+	counter   uint64
+}
+
+// parseOTP parses `secret`, which is either a base32 encoded key or an
+// otpauth:// URI. Base32 keys use SHA1, 6 digits and a 30 second period,
+// while URIs may specify the "algorithm" (SHA1, SHA256 or SHA512), "digits"
+// (6 or 8), "period" and, for HOTP, initial "counter" parameters.
+func parseOTP(secret string) (*otpParams, error) {
+	params := &otpParams{
+		algorithm: sha1.New,
+		digits:    6,
+		period:    30,
+	}
+
+	if strings.HasPrefix(strings.ToLower(secret), "otpauth://") {
+		u, err := url.Parse(secret)
+		if err != nil {
+			return nil, ErrInvalidTOTP
+		}
+		query := u.Query()
+		secret = query.Get("secret")
+
+		switch strings.ToUpper(query.Get("algorithm")) {
+		case "", "SHA1":
+		case "SHA256":
+			params.algorithm = sha256.New
+		case "SHA512":
+			params.algorithm = sha512.New
+		default:
+			return nil, ErrInvalidTOTP
+		}
+		if digits := query.Get("digits"); digits != "" {
+			if params.digits, err = strconv.Atoi(digits); err != nil || (params.digits != 6 && params.digits != 8) {
+				return nil, ErrInvalidTOTP
+			}
+		}
+		if period := query.Get("period"); period != "" {
+			if params.period, err = strconv.ParseInt(period, 10, 64); err != nil || params.period <= 0 {
+				return nil, ErrInvalidTOTP
+			}
+		}
+		if counter := query.Get("counter"); counter != "" {
+			if params.counter, err = strconv.ParseUint(counter, 10, 64); err != nil {
+				return nil, ErrInvalidTOTP
+			}
+		}
+	}
+
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	secret = strings.TrimRight(secret, "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidTOTP
+	}
+	params.key = key
+	return params, nil
+}
+
+// hotp computes the RFC 4226 one-time password for `counter`.
+func (params *otpParams) hotp(counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(params.algorithm, params.key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < params.digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", params.digits, code%mod)
+}
+
+// totp computes the RFC 6238 one-time password at `t`, and the number of
+// seconds for which it remains valid.
+func (params *otpParams) totp(t time.Time) (string, int) {
+	unix := t.Unix()
+	return params.hotp(uint64(unix / params.period)), int(params.period - unix%params.period)
+}
+
+// TOTP returns the current RFC 6238 time-based one-time password for the
+// credential at `location`, and the number of seconds until it changes. The
+// credential's TOTPSecret may be a base32 encoded key, or an otpauth:// URI
+// specifying the algorithm, number of digits and period.
+func (v *Vault) TOTP(location string) (code string, secondsRemaining int, err error) {
+	cred, err := v.Get(location)
+	if err != nil {
+		return "", 0, err
+	}
+	if cred.TOTPSecret == "" {
+		return "", 0, ErrNoTOTP
+	}
+	params, err := parseOTP(cred.TOTPSecret)
+	if err != nil {
+		return "", 0, err
+	}
+	code, secondsRemaining = params.totp(time.Now())
+	return code, secondsRemaining, nil
+}
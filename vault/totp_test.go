@@ -0,0 +1,93 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B test vectors.
+func TestTOTPVectors(t *testing.T) {
+	sha1Secret := "otpauth://totp/test?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8"
+	sha256Secret := "otpauth://totp/test?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA&digits=8&algorithm=SHA256"
+
+	tests := []struct {
+		secret string
+		unix   int64
+		code   string
+	}{
+		{sha1Secret, 59, "94287082"},
+		{sha1Secret, 1111111109, "07081804"},
+		{sha1Secret, 20000000000, "65353130"},
+		{sha256Secret, 59, "46119246"},
+		{sha256Secret, 1111111109, "68084774"},
+		{sha256Secret, 20000000000, "77737706"},
+	}
+	for _, test := range tests {
+		params, err := parseOTP(test.secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, remaining := params.totp(time.Unix(test.unix, 0))
+		if code != test.code {
+			t.Fatalf("expected code %v at %v, got %v", test.code, test.unix, code)
+		}
+		if want := int(30 - test.unix%30); remaining != want {
+			t.Fatalf("expected %v seconds remaining at %v, got %v", want, test.unix, remaining)
+		}
+	}
+}
+
+func TestParseOTP(t *testing.T) {
+	params, err := parseOTP("gezd gnbv gy3t qojq gezd gnbv gy3t qojq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.digits != 6 || params.period != 30 || string(params.key) != "12345678901234567890" {
+		t.Fatal("parseOTP did not use the default parameters for a base32 secret")
+	}
+
+	params, err = parseOTP("otpauth://totp/test?secret=GEZDGNBVGY3TQOJQ&period=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.period != 60 {
+		t.Fatal("parseOTP did not parse the period")
+	}
+
+	for _, secret := range []string{"not base32!", "otpauth://totp/test?secret=GEZDGNBV&digits=7", "otpauth://totp/test?secret=GEZDGNBV&algorithm=MD5", "otpauth://totp/test?secret=GEZDGNBV&period=0"} {
+		if _, err := parseOTP(secret); err != ErrInvalidTOTP {
+			t.Fatalf("expected ErrInvalidTOTP for %q, got %v", secret, err)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("github", Credential{Username: "testuser", Password: "testpass", TOTPSecret: "JBSWY3DPEHPK3PXP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("bank", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, remaining, err := v.TOTP("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 6 || remaining < 1 || remaining > 30 {
+		t.Fatalf("unexpected totp %v with %v seconds remaining", code, remaining)
+	}
+
+	if _, _, err = v.TOTP("bank"); err != ErrNoTOTP {
+		t.Fatal("expected ErrNoTOTP, got", err)
+	}
+	if _, _, err = v.TOTP("nonexistent"); err != ErrNoSuchCredential {
+		t.Fatal("expected ErrNoSuchCredential, got", err)
+	}
+}
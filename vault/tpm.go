@@ -0,0 +1,187 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// maxTPMBlobSize bounds the size of the sealed master key of a TPM
+	// slot.
+	maxTPMBlobSize = 16 * 1024
+
+	// defaultTPMPCRs are the PCRs a TPM2Tools seals to if none are set:
+	// those measuring the firmware, the boot loader and Secure Boot
+	// policy.
+	defaultTPMPCRs = "sha256:0,2,4,7"
+)
+
+// TPM seals secrets to a Trusted Platform Module, so that they can only be
+// unsealed by the same TPM while the machine is in the same state.
+type TPM interface {
+	// Seal seals `secret`, returning the sealed blob.
+	Seal(secret []byte) ([]byte, error)
+	// Unseal returns the secret sealed in `sealed`, failing if the
+	// machine's state has changed since it was sealed.
+	Unseal(sealed []byte) ([]byte, error)
+}
+
+// TPM2Tools is a TPM 2.0 used through the tools of tpm2-tools, which seal
+// secrets under the owner hierarchy's storage key with a policy requiring
+// the values of a set of PCRs to be unchanged.
+type TPM2Tools struct {
+	// PCRs is the selection of PCRs secrets are sealed to, such as
+	// "sha256:0,2,4,7", which is used if it is empty.
+	PCRs string
+}
+
+// runTPM2 runs the tpm2-tools tool `name` with `args`, writing `input` to its
+// standard input, and returns its output.
+func runTPM2(input []byte, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v: %v", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Seal seals `secret` to the current values of the PCRs using tpm2_create.
+// The sealed blob holds the PCR selection and the public and private parts of
+// the sealed object, which can only be loaded by the same TPM.
+func (t TPM2Tools) Seal(secret []byte) ([]byte, error) {
+	pcrs := t.PCRs
+	if pcrs == "" {
+		pcrs = defaultTPMPCRs
+	}
+	dir, err := ioutil.TempDir("", "masterkey-tpm")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	primary := filepath.Join(dir, "primary.ctx")
+	policy := filepath.Join(dir, "policy.dat")
+	public := filepath.Join(dir, "seal.pub")
+	private := filepath.Join(dir, "seal.priv")
+
+	if _, err = runTPM2(nil, "tpm2_createprimary", "-Q", "-C", "o", "-c", primary); err != nil {
+		return nil, err
+	}
+	if _, err = runTPM2(nil, "tpm2_createpolicy", "-Q", "--policy-pcr", "-l", pcrs, "-L", policy); err != nil {
+		return nil, err
+	}
+	// The secret is passed on standard input so that it is never written
+	// to disk.
+	if _, err = runTPM2(secret, "tpm2_create", "-Q", "-C", primary, "-L", policy, "-i", "-", "-u", public, "-r", private); err != nil {
+		return nil, err
+	}
+
+	var sealed bytes.Buffer
+	if err = writeSized(&sealed, []byte(pcrs)); err != nil {
+		return nil, err
+	}
+	for _, path := range []string{public, private} {
+		part, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = writeSized(&sealed, part); err != nil {
+			return nil, err
+		}
+	}
+	return sealed.Bytes(), nil
+}
+
+// Unseal loads the sealed object in `sealed` using tpm2_load and unseals it
+// using tpm2_unseal, which fails unless the PCRs it was sealed to still have
+// the values they had when it was sealed.
+func (t TPM2Tools) Unseal(sealed []byte) ([]byte, error) {
+	r := bytes.NewReader(sealed)
+	var parts [3][]byte
+	for i := range parts {
+		var err error
+		if parts[i], err = readSized(r, maxTPMBlobSize); err != nil {
+			return nil, err
+		}
+	}
+	dir, err := ioutil.TempDir("", "masterkey-tpm")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	primary := filepath.Join(dir, "primary.ctx")
+	public := filepath.Join(dir, "seal.pub")
+	private := filepath.Join(dir, "seal.priv")
+	object := filepath.Join(dir, "seal.ctx")
+	if err = ioutil.WriteFile(public, parts[1], 0600); err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(private, parts[2], 0600); err != nil {
+		return nil, err
+	}
+
+	if _, err = runTPM2(nil, "tpm2_createprimary", "-Q", "-C", "o", "-c", primary); err != nil {
+		return nil, err
+	}
+	if _, err = runTPM2(nil, "tpm2_load", "-Q", "-C", primary, "-u", public, "-r", private, "-c", object); err != nil {
+		return nil, err
+	}
+	return runTPM2(nil, "tpm2_unseal", "-c", object, "-p", "pcr:"+string(parts[0]))
+}
+
+// newTPMKeySlot returns a TPM slot sealing the vault's master key using
+// `tpm`.
+func (v *Vault) newTPMKeySlot(tpm TPM) (keySlot, error) {
+	sealed, err := tpm.Seal(v.keys.master[:])
+	if err != nil {
+		return keySlot{}, err
+	}
+	if len(sealed) == 0 || len(sealed) > maxTPMBlobSize {
+		return keySlot{}, fmt.Errorf("TPM returned %v bytes", len(sealed))
+	}
+	return keySlot{
+		kdf:     KDFParams{}.withDefaults(),
+		salt:    randomSalt(),
+		tpm:     true,
+		wrapped: sealed,
+	}, nil
+}
+
+// AddTPMKeySlot adds a TPM slot to the vault, sealing the master key using
+// `tpm`, such as TPM2Tools, and returns the number of the new slot. The vault
+// can then be opened without a passphrase on this machine using
+// OpenWithOptions with TPM set, for as long as its boot state is unchanged.
+// Keep a passphrase slot as a fallback: a firmware or boot loader update
+// changes the state, after which the TPM slot should be removed and added
+// again. The slot is written by the next Save.
+func (v *Vault) AddTPMKeySlot(tpm TPM) (int, error) {
+	if v.closed {
+		return 0, ErrLocked
+	}
+	if v.readOnly {
+		return 0, ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return 0, ErrAgeVault
+	}
+	if v.outer != nil {
+		return 0, ErrHiddenVault
+	}
+	if len(v.slots) >= maxKeySlots {
+		return 0, ErrTooManyKeySlots
+	}
+	slot, err := v.newTPMKeySlot(tpm)
+	if err != nil {
+		return 0, err
+	}
+	v.slots = append(v.slots, slot)
+	return len(v.slots) - 1, nil
+}
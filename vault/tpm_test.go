@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"testing"
+)
+
+var errPCRMismatch = errors.New("PCR values changed")
+
+// testTPM is a TPM that seals secrets by XORing them with a key, to the
+// value of a single PCR.
+type testTPM struct {
+	key [keyLen]byte
+	pcr byte
+}
+
+func (tpm *testTPM) Seal(secret []byte) ([]byte, error) {
+	sealed := []byte{tpm.pcr}
+	for i, b := range secret {
+		sealed = append(sealed, b^tpm.key[i%keyLen])
+	}
+	return sealed, nil
+}
+
+func (tpm *testTPM) Unseal(sealed []byte) ([]byte, error) {
+	if sealed[0] != tpm.pcr {
+		return nil, errPCRMismatch
+	}
+	var secret []byte
+	for i, b := range sealed[1:] {
+		secret = append(secret, b^tpm.key[i%keyLen])
+	}
+	return secret, nil
+}
+
+func TestTPM(t *testing.T) {
+	relaxDefaults(t)
+	tpm := &testTPM{}
+	rand.Read(tpm.key[:])
+
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpassword"}); err != nil {
+		t.Fatal(err)
+	}
+	slot, err := v.AddTPMKeySlot(tpm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.ListKeySlots()[slot].TPM {
+		t.Fatal("expected the new slot to be a TPM slot")
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	vopen, err := OpenWithOptions("pass.db", "", OpenOptions{TPM: tpm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := vopen.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpassword" {
+		t.Fatal("vault opened using the TPM had the wrong password:", cred.Password)
+	}
+	if err = vopen.ChangePassphrase("", "newpass"); err != ErrNoPassphrase {
+		t.Fatal("expected ErrNoPassphrase, got", err)
+	}
+	if err = vopen.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the machine's state changes, the passphrase is the fallback.
+	tpm.pcr++
+	if _, err = OpenWithOptions("pass.db", "", OpenOptions{TPM: tpm}); err != errPCRMismatch {
+		t.Fatal("expected the TPM's error, got", err)
+	}
+	if _, err = Open("pass.db", "testpass"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"sort"
+	"time"
+)
+
+// Trash moves the credential at `location` into the vault's trash, where it
+// remains encrypted until it is restored with RestoreFromTrash or purged with
+// EmptyTrash. Trashing a location that is already in the trash replaces the
+// previously trashed credential.
+func (v *Vault) Trash(location string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	location = p.resolve(location)
+	cred, err := p.credential(location)
+	if err != nil {
+		return err
+	}
+	trash, err := p.trash()
+	if err != nil {
+		return err
+	}
+
+	delete(p.Credentials, location)
+	p.removeAliases(location)
+	trash[location] = &TrashedCredential{
+		Credential: *cred,
+		TrashedAt:  time.Now(),
+	}
+
+	return v.encrypt(p)
+}
+
+// RestoreFromTrash moves the credential at `location` out of the trash and
+// back into the vault. RestoreFromTrash returns ErrNoSuchCredential if
+// `location` is not in the trash, and ErrCredentialExists if `location` has
+// since been reused.
+func (v *Vault) RestoreFromTrash(location string) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	trash, err := p.trash()
+	if err != nil {
+		return err
+	}
+	trashed, ok := trash[location]
+	if !ok {
+		return ErrNoSuchCredential
+	}
+	if err = p.available(location); err != nil {
+		return err
+	}
+
+	delete(trash, location)
+	p.Credentials[location] = &trashed.Credential
+
+	return v.encrypt(p)
+}
+
+// TrashedLocations returns the locations currently in the trash.
+func (v *Vault) TrashedLocations() ([]string, error) {
+	p, err := v.decryptIndex()
+	if err != nil {
+		return nil, err
+	}
+	trash, err := p.trash()
+	if err != nil {
+		return nil, err
+	}
+
+	var locations []string
+	for location := range trash {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	return locations, nil
+}
+
+// EmptyTrash permanently removes every credential in the trash. The trashed
+// credentials will no longer be present on disk after the next Save.
+func (v *Vault) EmptyTrash() error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	// The trash is opened so that it is not kept as it was.
+	if _, err = p.trash(); err != nil {
+		return err
+	}
+	p.Trash = make(map[string]*TrashedCredential)
+
+	return v.encrypt(p)
+}
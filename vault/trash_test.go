@@ -0,0 +1,108 @@
+package vault
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTrashRestore(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.Trash("testlocation"); err != ErrNoSuchCredential {
+		t.Fatal("expected Trash on non-existant location to return ErrNoSuchCredential")
+	}
+
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Trash("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("testlocation"); err != ErrNoSuchCredential {
+		t.Fatal("expected trashed credential to be removed from the vault")
+	}
+
+	err = v.Save("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trashed, err := vopen.TrashedLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(trashed, []string{"testlocation"}) {
+		t.Fatalf("expected trash to contain testlocation after Save/Open, got %v", trashed)
+	}
+
+	err = vopen.Add("testlocation", Credential{Username: "testuser2", Password: "testpass2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vopen.RestoreFromTrash("testlocation"); err != ErrCredentialExists {
+		t.Fatal("expected RestoreFromTrash onto a reused location to return ErrCredentialExists")
+	}
+	err = vopen.Delete("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = vopen.RestoreFromTrash("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := vopen.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" {
+		t.Fatal("RestoreFromTrash did not restore the trashed credential")
+	}
+	if err = vopen.RestoreFromTrash("testlocation"); err != ErrNoSuchCredential {
+		t.Fatal("expected RestoreFromTrash on a location not in the trash to return ErrNoSuchCredential")
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Trash("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.EmptyTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trashed, err := v.TrashedLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 0 {
+		t.Fatal("EmptyTrash did not remove trashed credentials")
+	}
+	if err = v.RestoreFromTrash("testlocation"); err != ErrNoSuchCredential {
+		t.Fatal("expected RestoreFromTrash after EmptyTrash to return ErrNoSuchCredential")
+	}
+}
@@ -0,0 +1,139 @@
+package vault
+
+import (
+	"time"
+)
+
+// Tx is a transaction against the decrypted contents of a vault. A Tx is
+// only valid for the duration of the function passed to Batch.
+type Tx struct {
+	p *payload
+}
+
+// Batch decrypts the vault once, runs `fn` against its contents, and
+// re-encrypts the vault only if `fn` returns nil. If `fn` returns an error,
+// none of the changes made through `tx` are applied.
+func (v *Vault) Batch(fn func(tx *Tx) error) error {
+	p, err := v.decryptForUpdate()
+	if err != nil {
+		return err
+	}
+
+	if err = fn(&Tx{p: p}); err != nil {
+		return err
+	}
+
+	return v.encrypt(p)
+}
+
+// Add adds `credential` at `location`. CreatedAt and UpdatedAt are set to
+// the current time unless they are already set, e.g. by an importer.
+func (tx *Tx) Add(location string, credential Credential) error {
+	if err := tx.p.available(location); err != nil {
+		return err
+	}
+
+	if credential.CreatedAt.IsZero() {
+		credential.CreatedAt = time.Now()
+	}
+	if credential.UpdatedAt.IsZero() {
+		credential.UpdatedAt = credential.CreatedAt
+	}
+	tx.p.Credentials[location] = &credential
+
+	return nil
+}
+
+// Get retrieves the credential at `location`.
+func (tx *Tx) Get(location string) (*Credential, error) {
+	return tx.p.credential(tx.p.resolve(location))
+}
+
+// Edit replaces the credential at `location` with `credential`, keeping the
+// replaced credential in its History. The credential's GeneratedPasswords are
+// kept too.
+func (tx *Tx) Edit(location string, credential Credential) error {
+	location = tx.p.resolve(location)
+	old, err := tx.p.credential(location)
+	if err != nil {
+		return err
+	}
+
+	credential.History = old.archive()
+	credential.GeneratedPasswords = old.GeneratedPasswords
+	credential.CreatedAt = old.CreatedAt
+	credential.UpdatedAt = time.Now()
+	tx.p.Credentials[location] = &credential
+
+	return nil
+}
+
+// Delete removes the credential at `location` along with its aliases. If
+// `location` is an alias, only the alias is removed.
+func (tx *Tx) Delete(location string) error {
+	location = tx.p.canonical(location)
+	if _, ok := tx.p.Aliases[location]; ok {
+		delete(tx.p.Aliases, location)
+		return nil
+	}
+	if _, ok := tx.p.Credentials[location]; !ok {
+		return ErrNoSuchCredential
+	}
+
+	delete(tx.p.Credentials, location)
+	tx.p.removeAliases(location)
+
+	return nil
+}
+
+// Rename moves the credential at `oldLocation` to `newLocation`, keeping its
+// aliases. If `oldLocation` is an alias, the alias is renamed instead.
+func (tx *Tx) Rename(oldLocation string, newLocation string) error {
+	oldLocation = tx.p.canonical(oldLocation)
+	target, isAlias := tx.p.Aliases[oldLocation]
+	cred, err := tx.p.credential(oldLocation)
+	if err != nil && (err != ErrNoSuchCredential || !isAlias) {
+		return err
+	}
+	// Changing only the case or normalization of a location is allowed,
+	// even though it collides with itself.
+	err = tx.p.available(newLocation)
+	if err == ErrCredentialExists || (err == ErrLocationCollision && tx.p.canonical(newLocation) != oldLocation) {
+		return err
+	}
+
+	if isAlias {
+		delete(tx.p.Aliases, oldLocation)
+		tx.p.Aliases[newLocation] = target
+		return nil
+	}
+	delete(tx.p.Credentials, oldLocation)
+	tx.p.Credentials[newLocation] = cred
+	tx.p.retargetAliases(oldLocation, newLocation)
+	return tx.p.retargetLinks(oldLocation, newLocation)
+}
+
+// Copy clones the credential at `srcLocation` to `dstLocation`.
+func (tx *Tx) Copy(srcLocation string, dstLocation string) error {
+	cred, err := tx.p.credential(tx.p.resolve(srcLocation))
+	if err != nil {
+		return err
+	}
+
+	clone := cred.clone()
+	clone.History = nil
+	clone.GeneratedPasswords = nil
+	clone.CreatedAt = time.Time{}
+	clone.UpdatedAt = time.Time{}
+
+	return tx.Add(dstLocation, clone)
+}
+
+// Locations returns the locations in the vault.
+func (tx *Tx) Locations() []string {
+	var locations []string
+	for location := range tx.p.Credentials {
+		locations = append(locations, location)
+	}
+	return locations
+}
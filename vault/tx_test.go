@@ -0,0 +1,106 @@
+package vault
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Batch(func(tx *Tx) error {
+		if err := tx.Add("testlocation2", Credential{Username: "testuser2", Password: "testpass2"}); err != nil {
+			return err
+		}
+		if err := tx.Edit("testlocation", Credential{Username: "testuser", Password: "newpass"}); err != nil {
+			return err
+		}
+		if err := tx.Rename("testlocation2", "testlocation3"); err != nil {
+			return err
+		}
+		cred, err := tx.Get("testlocation3")
+		if err != nil {
+			return err
+		}
+		if cred.Username != "testuser2" {
+			t.Fatal("tx.Get did not see changes made earlier in the transaction")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locations, err := v.Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(locations)
+	if !reflect.DeepEqual(locations, []string{"testlocation", "testlocation3"}) {
+		t.Fatalf("Batch did not apply all changes, got locations %v", locations)
+	}
+	cred, err := v.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "newpass" {
+		t.Fatal("Batch did not apply Edit")
+	}
+}
+
+func TestBatchRollback(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testerr := errors.New("testerr")
+	err = v.Batch(func(tx *Tx) error {
+		if err := tx.Delete("testlocation"); err != nil {
+			return err
+		}
+		if err := tx.Add("testlocation2", Credential{Username: "testuser2", Password: "testpass2"}); err != nil {
+			return err
+		}
+		return testerr
+	})
+	if err != testerr {
+		t.Fatal("expected Batch to return the error returned by the transaction")
+	}
+
+	locations, err := v.Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(locations, []string{"testlocation"}) {
+		t.Fatalf("expected failed Batch to leave the vault unmodified, got locations %v", locations)
+	}
+
+	err = v.Batch(func(tx *Tx) error {
+		if err := tx.Add("testlocation2", Credential{Username: "testuser2", Password: "testpass2"}); err != nil {
+			return err
+		}
+		return tx.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	})
+	if err != ErrCredentialExists {
+		t.Fatal("expected Batch to return ErrCredentialExists")
+	}
+	if _, err = v.Get("testlocation2"); err != ErrNoSuchCredential {
+		t.Fatal("expected failed Batch to roll back Add")
+	}
+}
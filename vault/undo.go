@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"errors"
+)
+
+// defaultUndoLimit is the number of modifications that can be undone unless
+// configured otherwise using ConfigureUndo.
+const defaultUndoLimit = 20
+
+// ErrNothingToUndo is returned from Undo if there are no modifications to
+// undo.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// undoStack holds the encrypted contents of a vault before each of its most
+// recent modifications, oldest first.
+type undoStack struct {
+	states      [][]byte
+	limit       int
+	clearOnSave bool
+}
+
+// newUndoStack returns an empty undoStack with the default configuration.
+func newUndoStack() undoStack {
+	return undoStack{limit: defaultUndoLimit, clearOnSave: true}
+}
+
+// push records `state`, discarding the oldest state if the stack is full.
+// The empty state of a vault that is being created is ignored.
+func (s *undoStack) push(state []byte) {
+	if s.limit <= 0 || state == nil {
+		return
+	}
+	s.states = append(s.states, state)
+	if len(s.states) > s.limit {
+		s.states = s.states[len(s.states)-s.limit:]
+	}
+}
+
+// clear discards every state.
+func (s *undoStack) clear() {
+	s.states = nil
+}
+
+// ConfigureUndo sets the number of modifications that can be undone to
+// `limit`, discarding the oldest if there are already more, and whether they
+// are forgotten when the vault is saved. A `limit` of zero disables Undo. By
+// default the 20 most recent modifications can be undone until the next
+// Save.
+func (v *Vault) ConfigureUndo(limit int, clearOnSave bool) {
+	if limit < 0 {
+		limit = 0
+	}
+	v.undo.limit = limit
+	v.undo.clearOnSave = clearOnSave
+	if len(v.undo.states) > limit {
+		v.undo.states = v.undo.states[len(v.undo.states)-limit:]
+	}
+}
+
+// Undo reverts the most recent modification of the vault, such as an Add,
+// Edit, Delete or Rotate. Undo can be called repeatedly to revert earlier
+// modifications, and returns ErrNothingToUndo once there are none left.
+// Modifications are only kept in memory, and changing the passphrase
+// forgets them.
+func (v *Vault) Undo() error {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	if len(v.undo.states) == 0 {
+		return ErrNothingToUndo
+	}
+
+	last := len(v.undo.states) - 1
+	v.data = v.undo.states[last]
+	v.undo.states = v.undo.states[:last]
+	return nil
+}
@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Undo(); err != ErrNothingToUndo {
+		t.Fatal("expected ErrNothingToUndo for a new vault, got", err)
+	}
+
+	if err = v.Add("github", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Edit("github", Credential{Username: "testuser", Password: "typo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Delete("github"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("github")
+	if err != nil {
+		t.Fatal("Undo did not revert the Delete:", err)
+	}
+	if cred.Password != "typo" {
+		t.Fatal("Undo reverted more than the Delete")
+	}
+	if err = v.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if cred, err = v.Get("github"); err != nil || cred.Password != "testpass" {
+		t.Fatal("Undo did not revert the Edit")
+	}
+	if err = v.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("github"); err != ErrNoSuchCredential {
+		t.Fatal("Undo did not revert the Add")
+	}
+	if err = v.Undo(); err != ErrNothingToUndo {
+		t.Fatal("expected ErrNothingToUndo after undoing everything, got", err)
+	}
+
+	if err = v.Add("github", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+	if err = v.Undo(); err != ErrNothingToUndo {
+		t.Fatal("expected Save to clear the undo stack, got", err)
+	}
+}
+
+func TestConfigureUndo(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.ConfigureUndo(1, false)
+	for _, location := range []string{"a", "b"} {
+		if err = v.Add(location, Credential{Password: "testpass"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if err = v.Undo(); err != nil {
+		t.Fatal("expected the undo stack to be kept after Save, got", err)
+	}
+	if _, err = v.Get("b"); err != ErrNoSuchCredential {
+		t.Fatal("Undo did not revert the last Add")
+	}
+	if err = v.Undo(); err != ErrNothingToUndo {
+		t.Fatal("expected only one modification to be kept, got", err)
+	}
+
+	v.ConfigureUndo(0, true)
+	if err = v.Add("c", Credential{Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Undo(); err != ErrNothingToUndo {
+		t.Fatal("expected a limit of zero to disable undo, got", err)
+	}
+}
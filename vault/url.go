@@ -0,0 +1,53 @@
+package vault
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// normalizeHost extracts the host from `rawURL`, stripping the scheme, any
+// leading "www.", and the port. `rawURL` may omit the scheme entirely, e.g.
+// "github.com/login". An empty string is returned if no host can be found.
+func normalizeHost(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "//" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return strings.TrimPrefix(host, "www.")
+}
+
+// FindByURL returns the credentials whose URL refers to the same host as
+// `rawURL`, keyed by location. Hosts are compared after normalization, so
+// "https://www.github.com:443/login" matches a credential with the URL
+// "github.com".
+func (v *Vault) FindByURL(rawURL string) (map[string]*Credential, error) {
+	matches := make(map[string]*Credential)
+
+	host := normalizeHost(rawURL)
+	if host == "" {
+		return matches, nil
+	}
+
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	for location, cred := range p.Credentials {
+		if cred.URL != "" && normalizeHost(cred.URL) == host {
+			matches[location] = cred
+		}
+	}
+	return matches, nil
+}
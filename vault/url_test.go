@@ -0,0 +1,65 @@
+package vault
+
+import (
+	"testing"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := map[string]string{
+		"https://www.github.com:443/login": "github.com",
+		"http://GitHub.com":                "github.com",
+		"github.com/login":                 "github.com",
+		"www.example.com:8080":             "example.com",
+		"mail.example.com.":                "mail.example.com",
+		"":                                 "",
+	}
+	for rawURL, expected := range tests {
+		if host := normalizeHost(rawURL); host != expected {
+			t.Fatalf("expected normalizeHost(%q) to be %q, got %q", rawURL, expected, host)
+		}
+	}
+}
+
+func TestFindByURL(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Add("github/personal", Credential{Username: "user1", Password: "pass1", URL: "https://github.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("github/work", Credential{Username: "user2", Password: "pass2", URL: "www.github.com/login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("gitlab", Credential{Username: "user3", Password: "pass3", URL: "https://gitlab.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("nourl", Credential{Username: "user4", Password: "pass4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := v.FindByURL("https://www.GitHub.com:443/settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches for github.com, got %v", len(matches))
+	}
+	if matches["github/personal"] == nil || matches["github/work"] == nil {
+		t.Fatal("FindByURL did not return the expected locations")
+	}
+
+	matches, err = v.FindByURL("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Fatal("expected FindByURL with an empty URL to return no matches")
+	}
+}
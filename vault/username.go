@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// usernameTagCharacters are the characters of the random tags added to
+	// plus-addressed emails.
+	usernameTagCharacters = lowerCharacters + digitCharacters
+
+	// usernameTagLength is the length of the random tags added to
+	// plus-addressed emails.
+	usernameTagLength = 4
+)
+
+// UsernameStyle controls the usernames generated by GenerateUsername. The
+// zero value generates a random handle of two words and a number, such as
+// "frostyotter42".
+type UsernameStyle struct {
+	// Email, if set, is an address such as me@example.com, and usernames are
+	// generated by plus addressing it with a random tag, such as
+	// me+x8f2@example.com, so that mail still reaches the same inbox.
+	Email string
+}
+
+// GenerateUsername generates a random username in the style `style`, for
+// throwaway accounts.
+func GenerateUsername(style UsernameStyle) (string, error) {
+	if style.Email != "" {
+		at := strings.LastIndex(style.Email, "@")
+		if at <= 0 || at == len(style.Email)-1 {
+			return "", ErrInvalidGenerateOptions
+		}
+		tag := make([]byte, usernameTagLength)
+		for i := range tag {
+			tag[i] = usernameTagCharacters[randomIndex(len(usernameTagCharacters))]
+		}
+		return fmt.Sprintf("%v+%s%v", style.Email[:at], tag, style.Email[at:]), nil
+	}
+
+	var handle string
+	for i := 0; i < 2; i++ {
+		word := EFFLargeWordlist[randomIndex(len(EFFLargeWordlist))]
+		for strings.Contains(word, "-") {
+			word = EFFLargeWordlist[randomIndex(len(EFFLargeWordlist))]
+		}
+		handle += word
+	}
+	return fmt.Sprintf("%v%v", handle, randomIndex(100)), nil
+}
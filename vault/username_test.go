@@ -0,0 +1,46 @@
+package vault
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGenerateUsername(t *testing.T) {
+	relaxDefaults(t)
+	handle, err := GenerateUsername(UsernameStyle{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[a-z]+[0-9]{1,2}$`).MatchString(handle) {
+		t.Fatalf("unexpected handle %q", handle)
+	}
+
+	email, err := GenerateUsername(UsernameStyle{Email: "me@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^me\+[a-z0-9]{4}@example\.com$`).MatchString(email) {
+		t.Fatalf("unexpected plus-addressed email %q", email)
+	}
+	for _, invalid := range []string{"me", "@example.com", "me@"} {
+		if _, err = GenerateUsername(UsernameStyle{Email: invalid}); err != ErrInvalidGenerateOptions {
+			t.Fatalf("expected ErrInvalidGenerateOptions for %q, got %v", invalid, err)
+		}
+	}
+
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.GenerateWithOptions("forum", "", GenerateOptions{Username: &UsernameStyle{Email: "me@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("forum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^me\+[a-z0-9]{4}@example\.com$`).MatchString(cred.Username) || cred.Password == "" {
+		t.Fatalf("GenerateWithOptions did not generate the username, got %q", cred.Username)
+	}
+}
@@ -3,22 +3,20 @@ package vault
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
 	"errors"
 	"io"
-	"io/ioutil"
 	"os"
-	"path"
+	"sort"
+	"strings"
+	"time"
 
-	"encoding/gob"
+	"filippo.io/age"
 	"github.com/NebulousLabs/entropy-mnemonics"
-	"golang.org/x/crypto/nacl/secretbox"
-	"golang.org/x/crypto/scrypt"
 )
 
 const (
-	scryptN        = 16384
-	scryptR        = 8
-	scryptP        = 1
 	keyLen         = 32
 	genEntropySize = 16
 )
@@ -28,52 +26,231 @@ var (
 	// credential does not exist
 	ErrNoSuchCredential = errors.New("credential at specified location does not exist in vault")
 
-	// ErrCouldNotDecrypt is returned if secretbox decryption fails.
+	// ErrCouldNotDecrypt is returned if decryption fails.
 	ErrCouldNotDecrypt = errors.New("provided decryption key is incorrect or the provided vault is corrupt")
 
 	// ErrCredentialExists is returned from Add if a credential already exists
 	// at the provided location.
 	ErrCredentialExists = errors.New("credential at specified location already exists")
+
+	// ErrIncorrectPassphrase is returned from ChangePassphrase if the provided
+	// current passphrase does not match the vault's passphrase.
+	ErrIncorrectPassphrase = errors.New("provided passphrase is incorrect")
+
+	// ErrReadOnly is returned from methods that would modify or save a vault
+	// opened with OpenReadOnly.
+	ErrReadOnly = errors.New("vault is open read-only")
 )
 
 type (
 	// Vault is a secure password vault. It can be created by calling New()
 	// with a passphrase. Passwords, usernames, and locations are encrypted
-	// using nacl/secretbox.
+	// using nacl/secretbox, or the cipher chosen when the vault was
+	// created, with a data key chosen for each session. The data key is
+	// wrapped by the vault's master key, which is in turn wrapped by each
+	// of the vault's key slots using a key derived from the slot's
+	// passphrase with the KDF and salt recorded in the vault's header.
 	Vault struct {
-		data   []byte
-		nonce  [24]byte
-		secret [32]byte
+		data []byte
+		// singleBlob is set if data is the whole payload sealed as one,
+		// as written before vault version 6, rather than an envelope.
+		singleBlob bool
+		// keys are the data and master keys, which are kept in enclave.
+		keys    *vaultKeys
+		enclave *enclave
+		slots   []keySlot
+		// slot is the key slot the vault was unlocked with.
+		slot   int
+		cipher CipherID
+		// compression is the algorithm the envelope's values are
+		// compressed with.
+		compression Compression
+		// factors are the secrets other than the passphrase needed by
+		// the slot the vault was unlocked with.
+		factors unlockFactors
+		// ageRecipients, if set, are the recipients of the age file the
+		// vault is saved as, in place of its key slots.
+		ageRecipients []age.Recipient
+		// hidden is the hidden section written after the header, which
+		// is kept as it is, and outer is set instead if the vault is
+		// itself a hidden vault.
+		hidden     []byte
+		outer      *outerVault
+		hiddenSalt [24]byte
+		readOnly   bool
+		undo       undoStack
+		// pruneBackups is set when the vault's keys change, so that its
+		// next Save removes the backups encrypted under the old ones.
+		pruneBackups bool
+		// locked is the storage whose lock the vault holds, if any.
+		locked Storage
+		// closed is set once the vault is locked by Close.
+		closed bool
+	}
+
+	// Options configures a vault created with NewWithOptions. The zero
+	// value creates the same vault as New.
+	Options struct {
+		// KDF selects the key derivation function and its parameters.
+		// If only the function is set, its parameters are calibrated to
+		// take KDFTargetDuration on this machine.
+		KDF    KDFParams
+		Cipher CipherID
+		// Keyfile, if set, is the path of a keyfile needed along with the
+		// passphrase to open the vault.
+		Keyfile string
+		// ChallengeResponse, if set, answers a challenge whose response
+		// is needed along with the passphrase to open the vault, such as
+		// YubiKeyChallengeResponse.
+		ChallengeResponse ChallengeResponseFunc
+		// FIDO2, if set, is the authenticator on which a credential is
+		// created whose hmac-secret is needed along with the passphrase
+		// to open the vault.
+		FIDO2 FIDO2Authenticator
+		// PassphraseOptions configures the check that the passphrase
+		// is strong enough.
+		PassphraseOptions
 	}
 
-	// Credential defines a Username and Password to store inside the vault.
+	// OpenOptions configures how OpenWithOptions opens a vault. The zero
+	// value opens the vault as Open does.
+	OpenOptions struct {
+		// Keyfile is the path of the keyfile of the key slot to unlock.
+		Keyfile string
+		// ChallengeResponse answers the challenge of the key slot to
+		// unlock.
+		ChallengeResponse ChallengeResponseFunc
+		// FIDO2 is the authenticator holding the credential of the key
+		// slot to unlock.
+		FIDO2 FIDO2Authenticator
+		// GPGDecrypt, if set, unlocks the vault's GPG slots, such as
+		// GPGDecryptBytes, and the passphrase is ignored.
+		GPGDecrypt GPGDecryptFunc
+		// TPM, if set, unseals the vault's TPM slots, such as
+		// TPM2Tools, and the passphrase is ignored.
+		TPM TPM
+		// PKCS11, if set, unwraps the master key of the vault's PKCS#11
+		// slots, such as a PKCS11Tool, and the passphrase is ignored.
+		PKCS11 PKCS11Token
+		// Approve, if set, asks the approvers of the vault's threshold
+		// slots for their passphrases, and the passphrase is ignored.
+		Approve ApproveFunc
+		// ReadOnly opens the vault as OpenReadOnly does.
+		ReadOnly bool
+		// Throttle records failed attempts to open the vault in a file
+		// next to it, and returns ErrTooManyAttempts until the delay
+		// they impose, given by AttemptDelay, has passed. It slows
+		// guessing through programs using it, not an attacker with a
+		// copy of the vault.
+		Throttle bool
+		// Lock locks the vault file before reading it, as LockFile
+		// does, so that other processes cannot open it for writing
+		// until the vault is Closed. It is ignored if ReadOnly is set.
+		Lock bool
+	}
+
+	// Credential defines a Username and Password to store inside the vault,
+	// along with optional free-form Notes, the URL of the site the credential
+	// belongs to, a set of Tags used to organize the vault, and arbitrary
+	// custom Fields such as PINs or API tokens. History holds the previous
+	// versions of the credential and, along with CreatedAt and UpdatedAt, is
+	// maintained by the vault. ExpiresAt is optional; the zero value means
+	// the credential never expires. Favorite credentials are listed first.
 	Credential struct {
+		Kind     Kind
 		Username string
 		Password string
+		Notes    string
+		URL      string
+		Tags     []string
+		Fields   map[string]string
+		History  []CredentialVersion
+		// GeneratedPasswords are the passwords most recently generated
+		// for the credential, maintained by the vault.
+		GeneratedPasswords []GeneratedPassword
+		Favorite           bool
+		// TOTPSecret is the base32 encoded key, or otpauth:// URI, used
+		// to generate time-based one-time passwords for the credential.
+		TOTPSecret string
+		// HOTPSecret is the base32 encoded key, or otpauth:// URI, used
+		// to generate counter-based one-time passwords, and HOTPCounter
+		// is the counter used to generate the next one.
+		HOTPSecret  string
+		HOTPCounter uint64
+		// SSHKey is the key pair of an entry of kind KindSSHKey.
+		SSHKey *SSHKey
+		// Card is the payment card of an entry of kind KindCard.
+		Card *Card
+		// Identity is the personal information of an entry of kind
+		// KindIdentity.
+		Identity *Identity
+		// Link is the location of the credential whose password an
+		// entry of kind KindLink uses.
+		Link string
+
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		ExpiresAt time.Time
+	}
+
+	// LocationMetadata describes a location in the vault without exposing
+	// the credential stored there.
+	LocationMetadata struct {
+		Location  string
+		Kind      Kind
+		Favorite  bool
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		ExpiresAt time.Time
 	}
 )
 
 // New creates a new, empty, vault using the passphrase provided to
-// `passphrase`.
+// `passphrase`. Its key is derived using scrypt, calibrated by CalibrateKDF to
+// take KDFTargetDuration on this machine.
 func New(passphrase string) (*Vault, error) {
-	var nonce [24]byte
-	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
-		panic(err)
-	}
+	return NewWithOptions(passphrase, Options{})
+}
 
-	var secret [32]byte
-	key, err := scrypt.Key([]byte(passphrase), nonce[:], scryptN, scryptR, scryptP, keyLen)
+// NewWithOptions creates a new, empty, vault using the passphrase provided to
+// `passphrase`, configured by `opts`. The vault's key derivation function and
+// cipher are recorded in the vault's header, so Open honors them. A
+// WeakPassphraseError is returned if the passphrase is the vault's only secret
+// and is too weak, unless `opts` sets Force.
+func NewWithOptions(passphrase string, opts Options) (*Vault, error) {
+	kdf := opts.KDF.withDefaults()
+	if opts.KDF == (KDFParams{KDF: opts.KDF.KDF}) {
+		kdf = opts.KDF.calibrate(KDFTargetDuration)
+	}
+	if err := kdf.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := newCipher(opts.Cipher, &[32]byte{}); err != nil {
+		return nil, err
+	}
+	factors, err := newUnlockFactors(opts.Keyfile, opts.ChallengeResponse, opts.FIDO2)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if err = opts.PassphraseOptions.check(passphrase, factors); err != nil {
+		return nil, err
 	}
-	copy(secret[:], key)
 
+	keys, enclave := newVaultKeys()
 	v := &Vault{
-		nonce:  nonce,
-		secret: secret,
+		keys:    keys,
+		enclave: enclave,
+		cipher:  opts.Cipher,
+		factors: factors,
+		undo:    newUndoStack(),
 	}
+	slot, err := v.newKeySlot(passphrase, factors, kdf, nil)
+	if err != nil {
+		return nil, err
+	}
+	v.slots = []keySlot{slot}
 
-	err = v.encrypt(make(map[string]*Credential))
+	err = v.encrypt(newPayload())
 	if err != nil {
 		return nil, err
 	}
@@ -81,66 +258,327 @@ func New(passphrase string) (*Vault, error) {
 	return v, nil
 }
 
+// randomSalt returns a new random salt.
+func randomSalt() [24]byte {
+	var salt [24]byte
+	if _, err := io.ReadFull(rand.Reader, salt[:]); err != nil {
+		panic(err)
+	}
+	return salt
+}
+
 // Open reads a vault from the location provided to `filename` and decrypts
-// it using `passphrase`. If decryption succeeds, new nonce is chosen and the
-// vault is re-encrypted, ensuring nonces are unique and not reused across
-// sessions.
+// it using `passphrase`, which may be the passphrase of any of its key slots
+// needing nothing else. If decryption succeeds, the vault is re-encrypted
+// under a new data key, so that each session uses its own key.
 func Open(filename string, passphrase string) (*Vault, error) {
-	f, err := os.Open(filename)
+	return OpenWithOptions(filename, passphrase, OpenOptions{})
+}
+
+// OpenReadOnly reads a vault from the location provided to `filename` and
+// decrypts it using `passphrase`, without re-encrypting it. Methods that
+// would modify the returned vault, or Save it, return ErrReadOnly, so it is
+// safe to use for inspecting backups.
+func OpenReadOnly(filename string, passphrase string) (*Vault, error) {
+	return OpenWithOptions(filename, passphrase, OpenOptions{ReadOnly: true})
+}
+
+// OpenWithOptions is Open, configured by `opts`. Only the key slots needing
+// the keyfile, challenge-response and FIDO2 authenticator given by `opts`,
+// and no others, are tried, so a vault can be opened using a passphrase slot
+// as a fallback by leaving them unset. If `opts` sets GPGDecrypt, only GPG
+// slots are tried, if it sets TPM, only TPM slots, and if it sets PKCS11,
+// only PKCS#11 slots.
+// If `opts` sets Throttle, repeated failures delay further attempts.
+func OpenWithOptions(filename string, passphrase string, opts OpenOptions) (*Vault, error) {
+	return openStorage(&FileStorage{Path: filename}, filename, passphrase, opts)
+}
+
+// openStorage is OpenStorage, recording failed attempts next to `filename`
+// if `opts` sets Throttle.
+func openStorage(s Storage, filename string, passphrase string, opts OpenOptions) (*Vault, error) {
+	factors, err := newUnlockFactors(opts.Keyfile, opts.ChallengeResponse, opts.FIDO2)
 	if err != nil {
 		return nil, err
 	}
-
-	var encryptedData bytes.Buffer
-	_, err = io.Copy(&encryptedData, f)
+	factors.gpgDecrypt = opts.GPGDecrypt
+	factors.tpm = opts.TPM
+	factors.pkcs11 = opts.PKCS11
+	factors.approve = opts.Approve
+	if opts.Throttle {
+		delay, err := AttemptDelay(filename)
+		if err != nil {
+			return nil, err
+		}
+		if delay > 0 {
+			return nil, ErrTooManyAttempts
+		}
+	}
+	lock := opts.Lock && !opts.ReadOnly
+	if lock {
+		if err = s.Lock(); err != nil {
+			return nil, err
+		}
+	}
+	vault, p, err := read(s.Load, passphrase, factors)
+	if err != nil && lock {
+		s.Unlock()
+	}
+	if opts.Throttle && err == ErrCouldNotDecrypt {
+		if rerr := recordFailedAttempt(filename); rerr != nil {
+			return nil, rerr
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if opts.Throttle {
+		if err = resetAttempts(filename); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ReadOnly {
+		vault.readOnly = true
+		return vault, nil
+	}
 
-	var nonce [24]byte
-	copy(nonce[:], encryptedData.Bytes()[:24])
+	if lock {
+		vault.locked = s
+	}
+	if err = vault.startSession(p); err != nil {
+		return nil, err
+	}
 
-	key, err := scrypt.Key([]byte(passphrase), nonce[:], scryptN, scryptR, scryptP, keyLen)
+	return vault, nil
+}
+
+// read reads the vault using `load` and decrypts it using `passphrase` and
+// `factors`, returning the vault and its payload.
+func read(load func() ([]byte, error), passphrase string, factors unlockFactors) (*Vault, *payload, error) {
+	contents, err := load()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	header, data, err := readHeader(contents)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	var secret [32]byte
-	copy(secret[:], key)
+	vault := newVault(header, data)
+	vault.factors = factors
+	if err = vault.unlock(passphrase, header.dataKey); err == ErrCouldNotDecrypt && factors.empty() && header.hidden != nil {
+		vault.enclave.destroy()
+		return openHidden(header, data, passphrase)
+	}
+	if err != nil {
+		vault.enclave.destroy()
+		return nil, nil, err
+	}
+	p, err := vault.open(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vault, p, nil
+}
 
-	vault := &Vault{
-		data:   encryptedData.Bytes(),
-		nonce:  nonce,
-		secret: secret,
+// newVault returns the vault read with `header` and its encrypted `data`,
+// whose keys are yet to be set by unlocking it.
+func newVault(header fileHeader, data []byte) *Vault {
+	keys, enclave := newVaultKeys()
+	return &Vault{
+		data:        data,
+		singleBlob:  header.version < 6,
+		keys:        keys,
+		enclave:     enclave,
+		slots:       header.slots,
+		hidden:      header.hidden,
+		cipher:      header.cipher,
+		compression: header.compression,
+		undo:        newUndoStack(),
 	}
+}
 
-	creds, err := vault.decrypt()
+// open decrypts the vault's index once its keys are set, verifying the
+// `header` it was read with, and returns its payload. The vault's enclave is
+// destroyed if it does not open.
+func (v *Vault) open(header fileHeader) (*payload, error) {
+	p, err := v.decryptIndex()
+	if err == nil {
+		err = v.verifyHeader(header, v.data, p)
+	}
 	if err != nil {
+		v.enclave.destroy()
 		return nil, err
 	}
+	return p, nil
+}
 
-	if _, err = io.ReadFull(rand.Reader, nonce[:]); err != nil {
-		panic(err)
+// openWithKeys opens the vault read with `header` and its encrypted `data`
+// using its master key, `master`, as if unlocked with key slot `slot`, such as
+// when the master key is recovered from shares or a keychain rather than from
+// a key slot, returning the vault and its payload. ErrCouldNotDecrypt is
+// returned if `master` does not unwrap the vault's data key.
+func openWithKeys(header fileHeader, data []byte, master *[32]byte, slot int) (*Vault, *payload, error) {
+	if slot >= len(header.slots) {
+		return nil, nil, ErrCouldNotDecrypt
 	}
+	v := newVault(header, data)
+	v.keys.master = *master
+	v.slot = slot
+	var err error
+	if v.keys.secret, err = unwrapKey(header.cipher, &v.keys.master, header.dataKey); err != nil {
+		v.enclave.destroy()
+		return nil, nil, ErrCouldNotDecrypt
+	}
+	p, err := v.open(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, p, nil
+}
 
-	key, err = scrypt.Key([]byte(passphrase), nonce[:], scryptN, scryptR, scryptP, keyLen)
+// startSession re-encrypts the vault's payload `p` under a new data key, so
+// that each session uses its own key. Only the index is sealed with the data
+// key, so the other records of a vault in the current version are kept as
+// they are.
+func (v *Vault) startSession(p *payload) error {
+	v.keys.secret = randomKey()
+	return v.seal(p)
+}
+
+// readFile reads the vault at `filename`, returning its header and encrypted
+// data.
+func readFile(filename string) (fileHeader, []byte, error) {
+	f, err := os.Open(filename)
 	if err != nil {
-		panic(err)
+		return fileHeader{}, nil, err
 	}
-	copy(secret[:], key)
+	defer f.Close()
 
-	vault.secret = secret
-	vault.nonce = nonce
-	if err = vault.encrypt(creds); err != nil {
-		return nil, err
+	var encryptedData bytes.Buffer
+	if _, err = io.Copy(&encryptedData, f); err != nil {
+		return fileHeader{}, nil, err
 	}
+	return readHeader(encryptedData.Bytes())
+}
 
-	return vault, nil
+// ChangePassphrase verifies that `oldPassphrase` unlocks the key slot the
+// vault was unlocked with, then chooses a new salt, derives a new key from
+// `newPassphrase`, and rewraps the master key with it. The next Save will
+// persist the vault under the new passphrase, removing the vault file's
+// backups, which the old one still opens, and invalidating keys cached by
+// CacheKey. A WeakPassphraseError is returned if `newPassphrase` is too weak,
+// as for NewWithOptions.
+func (v *Vault) ChangePassphrase(oldPassphrase string, newPassphrase string) error {
+	return v.ChangePassphraseWithOptions(oldPassphrase, newPassphrase, PassphraseOptions{})
+}
+
+// ChangePassphraseWithOptions is ChangePassphrase, checking the strength of
+// `newPassphrase` as configured by `opts`.
+func (v *Vault) ChangePassphraseWithOptions(oldPassphrase string, newPassphrase string, opts PassphraseOptions) error {
+	if err := opts.check(newPassphrase, v.factors); err != nil {
+		return err
+	}
+	if err := v.rekey(oldPassphrase, newPassphrase, v.KDFParams(), true); err != nil {
+		return err
+	}
+	v.pruneBackups = true
+	return v.renewKeyCacheEpoch()
+}
+
+// RotateDataKey replaces the vault's data key, and the key its credentials
+// are sealed with, with new random keys and re-encrypts every credential with
+// them. The next Save wraps the new data key by the master key, so every key
+// slot still unlocks the vault, and removes the vault file's backups and
+// invalidates keys cached by CacheKey. Modifications that could be undone are
+// forgotten, as they are encrypted with the old keys. The master key is
+// unchanged, so if it may have been exposed too, the passphrase should be
+// changed as well.
+func (v *Vault) RotateDataKey() error {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	p, err := v.decryptIndex()
+	if err != nil {
+		return err
+	}
+
+	previous := v.keys.secret
+	v.keys.secret = randomKey()
+	p.KeyCacheEpoch = randomKey()
+	p.RecordKey = randomKey()
+	if err = v.seal(p); err != nil {
+		v.keys.secret = previous
+		return err
+	}
+	wipe(previous[:])
+	for _, state := range v.undo.states {
+		wipe(state)
+	}
+	v.undo.clear()
+	v.pruneBackups = true
+	return nil
+}
+
+// rekey verifies that `oldPassphrase` unlocks the key slot the vault was
+// unlocked with, then replaces the slot with one wrapping the master key by a
+// key derived from `newPassphrase` using `kdf`. If `rotate` is set and it is
+// the vault's only slot, the master key is replaced too, so that copies of
+// the vault saved under the old passphrase reveal nothing about later saves.
+// The slot keeps its FIDO2 credential, if any.
+func (v *Vault) rekey(oldPassphrase string, newPassphrase string, kdf KDFParams, rotate bool) error {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	if v.ageRecipients != nil {
+		return ErrAgeVault
+	}
+	if v.outer != nil {
+		if kdf != hiddenKDF {
+			return ErrInvalidKDFParams
+		}
+		return v.rekeyHidden(oldPassphrase, newPassphrase)
+	}
+
+	slot := v.slots[v.slot]
+	if slot.gpgRecipient != "" || slot.tpm || slot.pkcs11Label != "" || slot.threshold > 0 {
+		return ErrNoPassphrase
+	}
+	oldKey, err := v.factors.derive(oldPassphrase, slot)
+	if err != nil {
+		return err
+	}
+	master, err := unwrapKey(v.cipher, &oldKey, slot.wrapped)
+	if err != nil || subtle.ConstantTimeCompare(master[:], v.keys.master[:]) != 1 {
+		return ErrIncorrectPassphrase
+	}
+
+	previous := v.keys.master
+	if rotate && len(v.slots) == 1 {
+		v.keys.master = randomKey()
+	}
+	if slot, err = v.newKeySlot(newPassphrase, v.factors, kdf, slot.fido2Credential); err != nil {
+		v.keys.master = previous
+		return err
+	}
+	v.slots[v.slot] = slot
+	return nil
 }
 
 // Generate generates a new strong mnemonic passphrase and Add()s it to the
-// vault.
+// vault. If `location` has a policy, a random password conforming to the
+// policy is generated instead.
 func (v *Vault) Generate(location string, username string) error {
+	if _, err := v.GetPolicy(location); err == nil {
+		return v.GenerateWithOptions(location, username, GenerateOptions{})
+	}
+
 	buf := new(bytes.Buffer)
 	_, err := io.CopyN(buf, rand.Reader, genEntropySize)
 	if err != nil {
@@ -155,6 +593,7 @@ func (v *Vault) Generate(location string, username string) error {
 		Username: username,
 		Password: phrase.String(),
 	}
+	cred.recordGenerated(cred.Password)
 
 	err = v.Add(location, cred)
 	if err != nil {
@@ -163,126 +602,439 @@ func (v *Vault) Generate(location string, username string) error {
 	return nil
 }
 
-// decrypt decrypts the vault and returns the credential data as a map of
-// strings (locations) to Credentials.
-func (v *Vault) decrypt() (map[string]*Credential, error) {
-	decryptedData, success := secretbox.Open([]byte{}, v.data[len(v.nonce):], &v.nonce, &v.secret)
-	if !success {
-		return nil, ErrCouldNotDecrypt
+// decryptIndex decrypts the vault's index and returns its payload, whose
+// credentials, trash and operation log are opened from the vault's envelope
+// when they are needed. The payload of a vault written in an older version is
+// opened whole, and migrated.
+func (v *Vault) decryptIndex() (*payload, error) {
+	if v.closed {
+		return nil, ErrLocked
+	}
+	c, err := newCipher(v.cipher, &v.keys.secret)
+	if err != nil {
+		return nil, err
+	}
+	var p *payload
+	if !v.singleBlob {
+		env, index, err := openIndex(c, v.data, v.compression)
+		if err != nil {
+			return nil, err
+		}
+		// The payload is a copy, so that the index keeps the fields
+		// its records were sealed with.
+		p = new(payload)
+		*p = index.Payload
+		source := &entrySource{c: c, cipher: v.cipher, compression: v.compression, env: env, index: index}
+		switch {
+		case p.Meta.Version < payloadVersion:
+			// Older versions seal the entries with the data key, and
+			// keep the trash and operation log in the index.
+			p.source = source
+			if err = p.openAll(); err != nil {
+				return nil, err
+			}
+			p.source = nil
+		case p.Meta.Version == payloadVersion:
+			if source.c, err = newCipher(v.cipher, &p.RecordKey); err != nil {
+				return nil, err
+			}
+			p.source = source
+		}
+	} else {
+		if len(v.data) < c.NonceSize()+c.Overhead() {
+			return nil, ErrCouldNotDecrypt
+		}
+		plaintext := newEnclave(len(v.data))
+		defer plaintext.destroy()
+		decryptedData, err := c.Open(plaintext.data[:0], v.data[:c.NonceSize()], v.data[c.NonceSize():], nil)
+		if err != nil {
+			return nil, ErrCouldNotDecrypt
+		}
+		if p, err = decodePayload(decryptedData); err != nil {
+			return nil, err
+		}
+	}
+	if err = migratePayload(p); err != nil {
+		return nil, err
 	}
+	return p, nil
+}
 
-	credentials := make(map[string]*Credential)
-	err := gob.NewDecoder(bytes.NewBuffer(decryptedData)).Decode(&credentials)
+// decrypt decrypts the vault and returns its payload, with every credential
+// opened.
+func (v *Vault) decrypt() (*payload, error) {
+	p, err := v.decryptIndex()
 	if err != nil {
 		return nil, err
 	}
+	if err = p.openAll(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
 
-	return credentials, nil
+// decryptForUpdate decrypts the vault's index as decryptIndex does, recording
+// the hash of each credential as it is opened, so that encrypt can tell which
+// credentials changed without opening the others.
+func (v *Vault) decryptForUpdate() (*payload, error) {
+	p, err := v.decryptIndex()
+	if err != nil {
+		return nil, err
+	}
+	p.opened = make(map[string][32]byte)
+	if p.source == nil {
+		for location, cred := range p.Credentials {
+			if p.opened[location], err = entryHash(location, cred); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
 }
 
-// encrypt encrypts the supplied credential map and updates the vault's
-// encrypted data.
-func (v *Vault) encrypt(creds map[string]*Credential) error {
-	var buf bytes.Buffer
-	err := gob.NewEncoder(&buf).Encode(creds)
+// encrypt records the modification of the vault in the supplied payload's
+// metadata and operation log, then seals it. Every modification of the vault
+// goes through encrypt, which keeps the previous contents so that it can be
+// undone.
+func (v *Vault) encrypt(p *payload) error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	p.Meta.ModifiedAt = time.Now()
+	changes, err := p.changes()
 	if err != nil {
 		return err
 	}
+	p.logOperation(changes)
 
-	v.data = secretbox.Seal(v.nonce[:], buf.Bytes(), &v.nonce, &v.secret)
-
+	previous := v.data
+	if err = v.seal(p); err != nil {
+		return err
+	}
+	v.undo.push(previous)
 	return nil
 }
 
-// Add adds the credential provided to `credential` at the location provided
-// by `location` to the vault.
-func (v *Vault) Add(location string, credential Credential) error {
-	creds, err := v.decrypt()
+// seal encrypts the supplied payload as an envelope, sealing each credential
+// separately, and updates the vault's encrypted data. Only the records that
+// changed since the payload was opened are sealed again, unless they must be
+// sealed differently. It returns ErrReadOnly if the vault was opened with
+// OpenReadOnly.
+func (v *Vault) seal(p *payload) error {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	p.Meta.Version = payloadVersion
+	if p.RecordKey == [32]byte{} {
+		p.RecordKey = randomKey()
+	}
+	if s := p.source; s != nil && (s.cipher != v.cipher || s.compression != v.compression ||
+		s.index.Payload.RecordKey != p.RecordKey || s.index.Payload.Padding != p.Padding) {
+		if err := p.detach(); err != nil {
+			return err
+		}
+	}
+
+	c, err := newCipher(v.cipher, &v.keys.secret)
 	if err != nil {
 		return err
 	}
-
-	if _, exists := creds[location]; exists {
-		return ErrCredentialExists
+	records, err := newCipher(v.cipher, &p.RecordKey)
+	if err != nil {
+		return err
 	}
-
-	creds[location] = &credential
-
-	err = v.encrypt(creds)
+	// A hidden vault is padded as a whole, so its envelope need not be.
+	padding := p.Padding
+	if v.outer != nil {
+		padding = PaddingNone
+	}
+	data, err := sealEnvelope(c, records, p, padding, v.compression)
 	if err != nil {
 		return err
 	}
+	v.data, v.singleBlob = data, false
 
 	return nil
 }
 
-// Get retrieves a Credential at the provided `location`.
+// Add adds the credential provided to `credential` at the location provided
+// by `location` to the vault. CreatedAt and UpdatedAt are set to the current
+// time unless they are already set, e.g. by an importer.
+func (v *Vault) Add(location string, credential Credential) error {
+	return v.Batch(func(tx *Tx) error {
+		return tx.Add(location, credential)
+	})
+}
+
+// Get retrieves a Credential at the provided `location`. The Password of a
+// link is the current password of the credential it links to. Only the
+// vault's index and the credential are decrypted, and the credential it links
+// to if it is a link.
 func (v *Vault) Get(location string) (*Credential, error) {
-	creds, err := v.decrypt()
+	p, err := v.decryptIndex()
 	if err != nil {
 		return nil, err
 	}
+	return p.get(location)
+}
 
-	cred, ok := creds[location]
-	if !ok {
-		return nil, ErrNoSuchCredential
+// get retrieves the Credential at `location`, following links.
+func (p *payload) get(location string) (*Credential, error) {
+	cred, err := p.credential(p.resolve(location))
+	if err != nil {
+		return nil, err
+	}
+	if cred.kind() == KindLink {
+		return p.linked(cred)
 	}
 	return cred, nil
 }
 
+// GetAll retrieves every Credential in the vault, keyed by location, using a
+// single decryption of the vault. As with Get, links are resolved, except
+// broken ones, which are returned as stored, and the credentials are copies,
+// which the caller may modify.
+func (v *Vault) GetAll() (map[string]*Credential, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+	creds := make(map[string]*Credential, len(p.Credentials))
+	for location, cred := range p.Credentials {
+		if cred.kind() == KindLink {
+			if resolved, err := p.linked(cred); err == nil {
+				creds[location] = resolved
+				continue
+			}
+		}
+		clone := cred.clone()
+		creds[location] = &clone
+	}
+	return creds, nil
+}
+
 // Save safely (atomically) persists the vault to disk at the filename
-// provided to `filename`.
+// provided to `filename`, preceded by a header recording the vault's key
+// derivation function, salt and cipher. The vault is written to a temporary
+// file that is flushed to disk before it replaces the old one, so a crash or
+// a full disk during Save leaves the previous version intact. Vaults written
+// in older versions of the format, including before the header was
+// introduced, are upgraded to the current one. Vaults created with NewAge or
+// opened with OpenAge are saved as age files.
 func (v *Vault) Save(filename string) error {
-	tempfile, err := ioutil.TempFile(path.Dir(filename), "masterkey-temp")
-	if err != nil {
+	if v.closed {
+		return ErrLocked
+	}
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	if err := v.rotateBackups(filename); err != nil {
 		return err
 	}
+	if v.ageRecipients != nil {
+		if err := v.SaveAge(filename, v.ageRecipients...); err != nil {
+			return err
+		}
+		if v.undo.clearOnSave {
+			v.undo.clear()
+		}
+		return nil
+	}
 
-	_, err = io.Copy(tempfile, bytes.NewBuffer(v.data))
+	digest := sha512.New()
+	err := writeAtomic(filename, func(w io.Writer) error {
+		return v.writeFile(io.MultiWriter(w, digest))
+	})
 	if err != nil {
 		return err
 	}
-
-	err = os.Rename(tempfile.Name(), filename)
-	if err != nil {
+	if err = v.writeSignature(filename, digest.Sum(nil)); err != nil {
 		return err
 	}
+	v.pruneBackups = false
 
+	if v.undo.clearOnSave {
+		v.undo.clear()
+	}
 	return nil
 }
 
 // Edit replaces the credential at location with the provided `credential`.
+// The replaced credential is kept in the credential's History.
 func (v *Vault) Edit(location string, credential Credential) error {
-	creds, err := v.decrypt()
-	if err != nil {
-		return err
+	return v.Batch(func(tx *Tx) error {
+		return tx.Edit(location, credential)
+	})
+}
+
+// Delete removes the credential at `location` from the vault. The vault is
+// re-encrypted without the credential, so it will no longer be present on
+// disk after the next Save.
+func (v *Vault) Delete(location string) error {
+	return v.Batch(func(tx *Tx) error {
+		return tx.Delete(location)
+	})
+}
+
+// Rename moves the credential at `oldLocation` to `newLocation`. Rename
+// returns ErrNoSuchCredential if `oldLocation` does not exist and
+// ErrCredentialExists if `newLocation` is already in use.
+func (v *Vault) Rename(oldLocation string, newLocation string) error {
+	return v.Batch(func(tx *Tx) error {
+		return tx.Rename(oldLocation, newLocation)
+	})
+}
+
+// Copy clones the credential at `srcLocation`, including its notes, URL,
+// tags, custom fields and expiry, to `dstLocation`. The copy starts with its
+// own empty history and timestamps, so the two credentials can be rotated
+// independently. Copy returns ErrCredentialExists if `dstLocation` is already
+// in use.
+func (v *Vault) Copy(srcLocation string, dstLocation string) error {
+	return v.Batch(func(tx *Tx) error {
+		return tx.Copy(srcLocation, dstLocation)
+	})
+}
+
+// clone returns a deep copy of `c`.
+func (c *Credential) clone() Credential {
+	clone := *c
+	if c.Tags != nil {
+		clone.Tags = append([]string(nil), c.Tags...)
 	}
+	if c.Fields != nil {
+		clone.Fields = make(map[string]string, len(c.Fields))
+		for key, value := range c.Fields {
+			clone.Fields[key] = value
+		}
+	}
+	if c.History != nil {
+		clone.History = make([]CredentialVersion, len(c.History))
+		for i, version := range c.History {
+			clone.History[i] = CredentialVersion{Credential: version.Credential.clone(), ReplacedAt: version.ReplacedAt}
+		}
+	}
+	if c.GeneratedPasswords != nil {
+		clone.GeneratedPasswords = append([]GeneratedPassword(nil), c.GeneratedPasswords...)
+	}
+	if c.SSHKey != nil {
+		sshKey := *c.SSHKey
+		clone.SSHKey = &sshKey
+	}
+	if c.Card != nil {
+		card := *c.Card
+		clone.Card = &card
+	}
+	if c.Identity != nil {
+		identity := *c.Identity
+		if c.Identity.Documents != nil {
+			identity.Documents = make(map[string]string, len(c.Identity.Documents))
+			for name, number := range c.Identity.Documents {
+				identity.Documents[name] = number
+			}
+		}
+		clone.Identity = &identity
+	}
+	return clone
+}
 
-	if _, ok := creds[location]; !ok {
-		return ErrNoSuchCredential
+// Locations() retrieves the locations in the vault and returns them as a
+// slice of strings. Only the vault's index is decrypted.
+func (v *Vault) Locations() ([]string, error) {
+	var locations []string
+	p, err := v.decryptIndex()
+	if err != nil {
+		return locations, err
 	}
 
-	creds[location] = &credential
+	for location, _ := range p.Credentials {
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
 
-	err = v.encrypt(creds)
+// ForEach calls `fn` for every credential in the vault, in no particular
+// order. If `fn` returns an error, iteration stops and the error is returned.
+// Changes made to the credentials passed to `fn` are not persisted. The
+// credentials are decrypted one at a time, as `fn` is called, rather than all
+// of them beforehand.
+func (v *Vault) ForEach(fn func(location string, c *Credential) error) error {
+	p, err := v.decryptIndex()
 	if err != nil {
 		return err
 	}
+	if p.source != nil {
+		return p.source.openEntries(p.source.index.Locations, fn)
+	}
 
+	for location, cred := range p.Credentials {
+		if err = fn(location, cred); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Locations() retrieves the locations in the vault and returns them as a
-// slice of strings.
-func (v *Vault) Locations() ([]string, error) {
-	var locations []string
-	creds, err := v.decrypt()
+// LocationsPage retrieves at most `limit` locations beginning with `prefix`,
+// in sorted order, skipping the first `offset` matching locations. A `limit`
+// of zero or less returns every remaining location. Only the vault's index is
+// decrypted.
+func (v *Vault) LocationsPage(prefix string, offset int, limit int) ([]string, error) {
+	p, err := v.decryptIndex()
 	if err != nil {
-		return locations, err
+		return nil, err
 	}
 
-	for location, _ := range creds {
-		locations = append(locations, location)
+	var locations []string
+	for location := range p.Credentials {
+		if strings.HasPrefix(location, prefix) {
+			locations = append(locations, location)
+		}
+	}
+	sort.Strings(locations)
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(locations) {
+		return nil, nil
+	}
+	locations = locations[offset:]
+	if limit > 0 && limit < len(locations) {
+		locations = locations[:limit]
 	}
 	return locations, nil
 }
+
+// metadata returns the LocationMetadata for `c` stored at `location`.
+func (c *Credential) metadata(location string) LocationMetadata {
+	return LocationMetadata{
+		Location:  location,
+		Kind:      c.kind(),
+		Favorite:  c.Favorite,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		ExpiresAt: c.ExpiresAt,
+	}
+}
+
+// LocationsMetadata retrieves the metadata for every location in the vault,
+// sorted by location.
+func (v *Vault) LocationsMetadata() ([]LocationMetadata, error) {
+	p, err := v.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata []LocationMetadata
+	for location, cred := range p.Credentials {
+		metadata = append(metadata, cred.metadata(location))
+	}
+	sort.Slice(metadata, func(i, j int) bool {
+		return metadata[i].Location < metadata[j].Location
+	})
+	return metadata, nil
+}
@@ -1,37 +1,43 @@
 package vault
 
 import (
+	"bytes"
+	"encoding/gob"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 )
 
 func TestEditLocationNonexisting(t *testing.T) {
+	relaxDefaults(t)
 	v, err := New("testpass")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = v.Edit("testlocation", Credential{"testusername", "testpassword"})
+	err = v.Edit("testlocation", Credential{Username: "testusername", Password: "testpassword"})
 	if err != ErrNoSuchCredential {
 		t.Fatal("expected Edit on non-existant location to return ErrNoSuchCredential")
 	}
 }
 
 func TestEditLocation(t *testing.T) {
+	relaxDefaults(t)
 	v, err := New("testpass")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = v.Add("testlocation", Credential{"testusername", "testpassword"})
+	err = v.Add("testlocation", Credential{Username: "testusername", Password: "testpassword"})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = v.Edit("testlocation", Credential{"testusername2", "testpassword2"})
+	err = v.Edit("testlocation", Credential{Username: "testusername2", Password: "testpassword2"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -47,28 +53,31 @@ func TestEditLocation(t *testing.T) {
 }
 
 func TestGetInvalidKey(t *testing.T) {
+	relaxDefaults(t)
 	v, err := New("testpass")
 	if err != nil {
 		t.Fatal(err)
 	}
-	v.secret = [32]byte{}
+	v.keys.secret = [32]byte{}
 	if _, err = v.Get("test"); err != ErrCouldNotDecrypt {
 		t.Fatal("expected v.Get to return ErrCouldNotDecrypt with invalid secret")
 	}
 }
 
 func TestAddInvalidKey(t *testing.T) {
+	relaxDefaults(t)
 	v, err := New("testpass")
 	if err != nil {
 		t.Fatal(err)
 	}
-	v.secret = [32]byte{}
-	if err = v.Add("testlocation", Credential{"test", "test2"}); err != ErrCouldNotDecrypt {
+	v.keys.secret = [32]byte{}
+	if err = v.Add("testlocation", Credential{Username: "test", Password: "test2"}); err != ErrCouldNotDecrypt {
 		t.Fatal("expected v.Add to return ErrCouldNotDecrypt with invalid secret")
 	}
 }
 
 func TestHeavyVault(t *testing.T) {
+	relaxDefaults(t)
 	if testing.Short() {
 		t.SkipNow()
 	}
@@ -81,7 +90,7 @@ func TestHeavyVault(t *testing.T) {
 	}
 
 	for i := 0; i < size; i++ {
-		err = v.Add(fmt.Sprintf("testlocation%v", i), Credential{"testuser", "testpassword"})
+		err = v.Add(fmt.Sprintf("testlocation%v", i), Credential{Username: "testuser", Password: "testpassword"})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -117,6 +126,7 @@ func TestNonexistentVaultOpen(t *testing.T) {
 }
 
 func TestGenerate(t *testing.T) {
+	relaxDefaults(t)
 	v, err := New("testpass")
 	if err != nil {
 		t.Fatal(err)
@@ -137,11 +147,12 @@ func TestGenerate(t *testing.T) {
 }
 
 func TestGenerateExisting(t *testing.T) {
+	relaxDefaults(t)
 	v, err := New("testpass")
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = v.Add("testlocation", Credential{"testuser", "testpass"})
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -152,10 +163,11 @@ func TestGenerateExisting(t *testing.T) {
 }
 
 func TestGetLocations(t *testing.T) {
+	relaxDefaults(t)
 	creds := []Credential{
-		{"test1", "testpass1"},
-		{"test2", "testpass2"},
-		{"test3", "testpass3"},
+		{Username: "test1", Password: "testpass1"},
+		{Username: "test2", Password: "testpass2"},
+		{Username: "test3", Password: "testpass3"},
 	}
 	locs := []string{"testloc1", "testloc2", "testloc3"}
 
@@ -181,6 +193,7 @@ func TestGetLocations(t *testing.T) {
 }
 
 func TestGetNonexisting(t *testing.T) {
+	relaxDefaults(t)
 	v, err := New("testpass")
 	if err != nil {
 		t.Fatal(err)
@@ -191,7 +204,8 @@ func TestGetNonexisting(t *testing.T) {
 }
 
 func TestAddExisting(t *testing.T) {
-	testCredential := Credential{"testuser", "testpass"}
+	relaxDefaults(t)
+	testCredential := Credential{Username: "testuser", Password: "testpass"}
 	v, err := New("testpass")
 	if err != nil {
 		t.Fatal(err)
@@ -207,7 +221,8 @@ func TestAddExisting(t *testing.T) {
 }
 
 func TestNewSaveOpen(t *testing.T) {
-	testCredential := Credential{"testuser", "testpass"}
+	relaxDefaults(t)
+	testCredential := Credential{Username: "testuser", Password: "testpass"}
 
 	v, err := New("testpass")
 	if err != nil {
@@ -231,7 +246,7 @@ func TestNewSaveOpen(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(&testCredential, credential) {
+	if credential.Username != testCredential.Username || credential.Password != testCredential.Password {
 		t.Fatalf("vault did not store credential correctly. wanted %v got %v", testCredential, credential)
 	}
 
@@ -241,16 +256,26 @@ func TestNewSaveOpen(t *testing.T) {
 	}
 }
 
+// indexNonce returns the nonce of the index of `v`'s envelope.
+func indexNonce(t *testing.T, v *Vault) []byte {
+	var env envelope
+	if err := gob.NewDecoder(bytes.NewReader(v.data)).Decode(&env); err != nil {
+		t.Fatal(err)
+	}
+	return env.Index[:24]
+}
+
 func TestNonceRotation(t *testing.T) {
-	testCredential := Credential{"testuser", "testpass"}
+	relaxDefaults(t)
+	testCredential := Credential{Username: "testuser", Password: "testpass"}
 
 	v, err := New("testpass")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	oldnonce := v.nonce
-	oldsecret := v.secret
+	oldnonce := indexNonce(t, v)
+	oldsecret := v.keys.secret
 
 	v.Add("testlocation", testCredential)
 	err = v.Save("pass.db")
@@ -263,21 +288,533 @@ func TestNonceRotation(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if vopen.secret == oldsecret {
+	if vopen.keys.secret == oldsecret {
 		t.Fatal("opened vault had the same secret as the previous vault")
 	}
-	if vopen.nonce == oldnonce {
+	if bytes.Equal(indexNonce(t, vopen), oldnonce) {
 		t.Fatal("opened vault had the same nonce as the previous vault")
 	}
 }
 
+func TestOpenReadOnly(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if _, err = OpenReadOnly("pass.db", "wrongpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected ErrCouldNotDecrypt with the wrong passphrase, got", err)
+	}
+	vopen, err := OpenReadOnly("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vopen.keys.secret != v.keys.secret || !bytes.Equal(vopen.data, v.data) {
+		t.Fatal("OpenReadOnly re-encrypted the vault")
+	}
+	cred, err := vopen.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" {
+		t.Fatal("read-only vault did not contain the credential")
+	}
+
+	if err = vopen.Add("other", Credential{}); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly from Add, got", err)
+	}
+	if err = vopen.Delete("testlocation"); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly from Delete, got", err)
+	}
+	if err = vopen.SetField("testlocation", "pin", "1234"); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly from SetField, got", err)
+	}
+	if err = vopen.ChangePassphrase("testpass", "newpass"); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly from ChangePassphrase, got", err)
+	}
+	if err = vopen.Save("pass.db"); err != ErrReadOnly {
+		t.Fatal("expected ErrReadOnly from Save, got", err)
+	}
+	if _, err = vopen.Get("other"); err != ErrNoSuchCredential {
+		t.Fatal("read-only vault was modified")
+	}
+}
+
 func BenchmarkVaultAdd(b *testing.B) {
 	v, err := New("testpass")
 	if err != nil {
 	}
 	for i := 0; i < b.N; i++ {
-		err = v.Add(fmt.Sprintf("testlocation%v", i), Credential{"testuser", "testpass"})
+		err = v.Add(fmt.Sprintf("testlocation%v", i), Credential{Username: "testuser", Password: "testpass"})
 		if err != nil {
 		}
 	}
 }
+
+func TestDelete(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Delete("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = v.Get("testlocation"); err != ErrNoSuchCredential {
+		t.Fatal("expected Get on deleted location to return ErrNoSuchCredential")
+	}
+
+	if err = v.Delete("testlocation"); err != ErrNoSuchCredential {
+		t.Fatal("expected Delete on non-existant location to return ErrNoSuchCredential")
+	}
+}
+
+func TestRename(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation2", Credential{Username: "testuser2", Password: "testpass2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.Rename("testlocation", "testlocation2"); err != ErrCredentialExists {
+		t.Fatal("expected Rename onto an existing location to return ErrCredentialExists")
+	}
+	if err = v.Rename("nonexistent", "testlocation3"); err != ErrNoSuchCredential {
+		t.Fatal("expected Rename of non-existant location to return ErrNoSuchCredential")
+	}
+
+	err = v.Rename("testlocation", "testlocation3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.Get("testlocation"); err != ErrNoSuchCredential {
+		t.Fatal("expected old location to be removed after Rename")
+	}
+	cred, err := v.Get("testlocation3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" {
+		t.Fatal("Rename did not preserve credential data")
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	relaxDefaults(t)
+	testCredential := Credential{Username: "testuser", Password: "testpass"}
+
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation", testCredential)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.ChangePassphrase("wrongpass", "newpass"); err != ErrIncorrectPassphrase {
+		t.Fatal("expected ChangePassphrase with the wrong passphrase to return ErrIncorrectPassphrase")
+	}
+
+	err = v.ChangePassphrase("testpass", "newpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Save("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if _, err = Open("pass.db", "testpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("expected Open with the old passphrase to fail after ChangePassphrase")
+	}
+
+	vopen, err := Open("pass.db", "newpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := vopen.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credential.Username != testCredential.Username || credential.Password != testCredential.Password {
+		t.Fatalf("ChangePassphrase did not preserve credentials. wanted %v got %v", testCredential, credential)
+	}
+}
+
+func TestRotateDataKey(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.AddKeySlot("otherpass", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+
+	oldsecret := v.keys.secret
+	if err = v.RotateDataKey(); err != nil {
+		t.Fatal(err)
+	}
+	if v.keys.secret == oldsecret {
+		t.Fatal("RotateDataKey did not replace the data key")
+	}
+	if err = v.Undo(); err != ErrNothingToUndo {
+		t.Fatal("expected modifications encrypted with the old data key to be forgotten, got", err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	for _, passphrase := range []string{"testpass", "otherpass"} {
+		vopen, err := Open("pass.db", passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cred, err := vopen.Get("testlocation"); err != nil || cred.Password != "testpass" {
+			t.Fatal("RotateDataKey did not preserve credentials:", err)
+		}
+	}
+}
+
+func TestNotes(t *testing.T) {
+	relaxDefaults(t)
+	testCredential := Credential{
+		Username: "testuser",
+		Password: "testpass",
+		Notes:    "security question: first pet\nanswer: testanswer",
+	}
+
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("testlocation", testCredential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Save("pass.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	vopen, err := Open("pass.db", "testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := vopen.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Notes != testCredential.Notes {
+		t.Fatalf("expected notes %q to survive Save/Open, got %q", testCredential.Notes, cred.Notes)
+	}
+
+	testCredential.Notes = "updated notes"
+	err = vopen.Edit("testlocation", testCredential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err = vopen.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Notes != "updated notes" {
+		t.Fatal("Edit did not update credential notes")
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.CreatedAt.Before(before) || !cred.UpdatedAt.Equal(cred.CreatedAt) {
+		t.Fatal("Add did not set CreatedAt and UpdatedAt")
+	}
+	created := cred.CreatedAt
+
+	time.Sleep(10 * time.Millisecond)
+	err = v.Edit("testlocation", Credential{Username: "testuser", Password: "testpass2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err = v.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cred.CreatedAt.Equal(created) {
+		t.Fatal("Edit changed CreatedAt")
+	}
+	if !cred.UpdatedAt.After(created) {
+		t.Fatal("Edit did not update UpdatedAt")
+	}
+
+	metadata, err := v.LocationsMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) != 1 || metadata[0].Location != "testlocation" || !metadata[0].UpdatedAt.Equal(cred.UpdatedAt) {
+		t.Fatalf("LocationsMetadata returned incorrect metadata: %v", metadata)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = v.Copy("testlocation", "testlocation2"); err != ErrNoSuchCredential {
+		t.Fatal("expected Copy of non-existant location to return ErrNoSuchCredential")
+	}
+
+	err = v.Add("testlocation", Credential{
+		Username: "testuser",
+		Password: "testpass",
+		Notes:    "testnotes",
+		Tags:     []string{"work"},
+		Fields:   map[string]string{"pin": "1234"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Add("existing", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Copy("testlocation", "existing"); err != ErrCredentialExists {
+		t.Fatal("expected Copy onto an existing location to return ErrCredentialExists")
+	}
+
+	err = v.Copy("testlocation", "testlocation2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := v.Get("testlocation2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Username != "testuser" || cred.Password != "testpass" || cred.Notes != "testnotes" {
+		t.Fatal("Copy did not copy the credential")
+	}
+	if !reflect.DeepEqual(cred.Tags, []string{"work"}) || cred.Fields["pin"] != "1234" {
+		t.Fatal("Copy did not copy the credential's metadata")
+	}
+
+	err = v.SetField("testlocation2", "pin", "5678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := v.GetField("testlocation", "pin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "1234" {
+		t.Fatal("modifying a copy modified the original credential")
+	}
+}
+
+func TestLocationsPage(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err = v.Add(fmt.Sprintf("work/testlocation%v", i), Credential{Username: "testuser", Password: "testpass"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = v.Add("personal/testlocation", Credential{Username: "testuser", Password: "testpass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := v.LocationsPage("work/", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(page, []string{"work/testlocation0", "work/testlocation1"}) {
+		t.Fatalf("unexpected first page %v", page)
+	}
+	page, err = v.LocationsPage("work/", 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(page, []string{"work/testlocation4"}) {
+		t.Fatalf("unexpected last page %v", page)
+	}
+	page, err = v.LocationsPage("work/", 5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 0 {
+		t.Fatal("expected an empty page past the end of the locations")
+	}
+	page, err = v.LocationsPage("", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 6 || page[0] != "personal/testlocation" {
+		t.Fatalf("expected an unlimited page to return every location, got %v", page)
+	}
+
+	// Only the index is decrypted, so the entries are not even read.
+	env := decodeEnvelope(t, v)
+	for i := range env.Entries {
+		env.Entries[i] = nil
+	}
+	encodeEnvelope(t, v, env)
+	if page, err = v.LocationsPage("work/", 0, 0); err != nil || len(page) != 5 {
+		t.Fatal("expected the locations without their entries, got", page, err)
+	}
+	if locations, err := v.Locations(); err != nil || len(locations) != 6 {
+		t.Fatal("expected the locations without their entries, got", locations, err)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = v.Add(fmt.Sprintf("testlocation%v", i), Credential{Username: fmt.Sprintf("testuser%v", i), Password: "testpass"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]string)
+	err = v.ForEach(func(location string, c *Credential) error {
+		seen[location] = c.Username
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"testlocation0": "testuser0", "testlocation1": "testuser1", "testlocation2": "testuser2"}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Fatalf("ForEach visited %v, expected %v", seen, expected)
+	}
+
+	calls := 0
+	testerr := errors.New("testerr")
+	err = v.ForEach(func(location string, c *Credential) error {
+		calls++
+		return testerr
+	})
+	if err != testerr {
+		t.Fatal("expected ForEach to return the callback's error")
+	}
+	if calls != 1 {
+		t.Fatal("expected ForEach to stop iterating after an error")
+	}
+
+	// The credentials are decrypted as they are visited, so one that does
+	// not decrypt only stops the iteration once it is reached.
+	env := decodeEnvelope(t, v)
+	env.Entries[2] = env.Entries[1]
+	encodeEnvelope(t, v, env)
+	calls = 0
+	err = v.ForEach(func(location string, c *Credential) error {
+		calls++
+		return nil
+	})
+	if err != ErrCouldNotDecrypt || calls != 2 {
+		t.Fatal("expected the credentials before the corrupt one to be visited, got", calls, err)
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	relaxDefaults(t)
+	v, err := New("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := v.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 0 {
+		t.Fatal("expected GetAll on an empty vault to return no credentials")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err = v.Add(fmt.Sprintf("testlocation%v", i), Credential{Username: fmt.Sprintf("testuser%v", i), Password: "testpass"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	creds, err = v.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 3 {
+		t.Fatalf("expected GetAll to return 3 credentials, got %v", len(creds))
+	}
+	if creds["testlocation1"].Username != "testuser1" {
+		t.Fatal("GetAll returned incorrect credential data")
+	}
+
+	if err = v.AddLink("testlink", "testlocation0", "testlinkuser"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SetField("testlocation0", "pin", "1234"); err != nil {
+		t.Fatal(err)
+	}
+	if creds, err = v.GetAll(); err != nil {
+		t.Fatal(err)
+	}
+	if creds["testlink"].Password != "testpass" || creds["testlink"].Username != "testlinkuser" {
+		t.Fatal("expected GetAll to resolve links, got", creds["testlink"])
+	}
+	creds["testlocation0"].Fields["pin"] = "0000"
+	if cred, err := v.Get("testlocation0"); err != nil || cred.Fields["pin"] != "1234" {
+		t.Fatal("expected the credentials GetAll returns to be copies", err)
+	}
+}
@@ -0,0 +1,115 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+// WebDAVStorage stores a vault as a file on a WebDAV server, such as
+// Nextcloud or ownCloud. Like S3Storage, it detects concurrent writers using
+// conditional requests: Store only replaces the file if its ETag is still the
+// one it was last loaded or stored with, and otherwise returns
+// ErrStorageConflict, so the same *WebDAVStorage must be used to open and save
+// a vault. Servers that return no ETag for the file cannot detect concurrent
+// writers, and Store then replaces it unconditionally.
+type WebDAVStorage struct {
+	// URL is the URL of the vault file, such as
+	// https://cloud.example.com/remote.php/dav/files/alice/pass.db for
+	// Nextcloud.
+	URL string
+	// Username and Password, if set, authenticate to the server using
+	// HTTP basic authentication, such as with a Nextcloud app password.
+	Username string
+	Password string
+	// Client makes the requests, or http.DefaultClient if it is nil.
+	Client *http.Client
+	// etag is the ETag of the file as last loaded or stored, or empty if
+	// there was none.
+	etag string
+	// exists is set once the file was loaded or stored, even if the
+	// server returned no ETag for it.
+	exists bool
+}
+
+// do makes a request with `method` and `body` to the file, setting `header`,
+// and returns the response, whose body must be closed.
+func (s *WebDAVStorage) do(method string, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if s.Username != "" || s.Password != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// Load downloads the file, recording its ETag.
+func (s *WebDAVStorage) Load() ([]byte, error) {
+	resp, err := s.do("GET", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &os.PathError{Op: "load", Path: s.URL, Err: os.ErrNotExist}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webdav: GET %v: %v", s.URL, resp.Status)
+	}
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.etag, s.exists = resp.Header.Get("ETag"), true
+	return contents, nil
+}
+
+// Store uploads `contents` as the file if it still has the ETag it was last
+// loaded or stored with, or if it does not exist and was neither loaded nor
+// stored, and returns ErrStorageConflict otherwise. If the file was loaded or
+// stored without an ETag, it is replaced unconditionally.
+func (s *WebDAVStorage) Store(contents []byte) error {
+	header := make(http.Header)
+	if s.etag != "" {
+		header.Set("If-Match", s.etag)
+	} else if !s.exists {
+		header.Set("If-None-Match", "*")
+	}
+	resp, err := s.do("PUT", contents, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return ErrStorageConflict
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webdav: PUT %v: %v", s.URL, resp.Status)
+	}
+
+	// Asking for the ETag of an upload that returned none could return
+	// that of another writer's upload since, so none is kept.
+	s.etag, s.exists = resp.Header.Get("ETag"), true
+	return nil
+}
+
+// Lock does nothing. Store detects concurrent writers instead.
+func (s *WebDAVStorage) Lock() error {
+	return nil
+}
+
+// Unlock does nothing.
+func (s *WebDAVStorage) Unlock() error {
+	return nil
+}
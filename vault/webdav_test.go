@@ -0,0 +1,134 @@
+package vault
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+// testWebDAVServer serves a single file, honoring If-Match and If-None-Match
+// as a WebDAV server does. It only returns ETags for uploads if putETags is
+// set, and for downloads unless noGetETags is set.
+type testWebDAVServer struct {
+	mu         sync.Mutex
+	contents   []byte
+	version    int
+	putETags   bool
+	noGetETags bool
+}
+
+func (s *testWebDAVServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if username, password, ok := r.BasicAuth(); !ok || username != "alice" || password != "apppassword" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	etag := fmt.Sprintf(`"%d"`, s.version)
+	switch r.Method {
+	case "GET", "HEAD":
+		if s.contents == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !s.noGetETags {
+			w.Header().Set("ETag", etag)
+		}
+		w.Write(s.contents)
+	case "PUT":
+		if match := r.Header.Get("If-Match"); match != "" && (s.contents == nil || match != etag) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if r.Header.Get("If-None-Match") == "*" && s.contents != nil {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		s.contents, _ = ioutil.ReadAll(r.Body)
+		s.version++
+		if s.putETags {
+			w.Header().Set("ETag", fmt.Sprintf(`"%d"`, s.version))
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func TestWebDAVStorage(t *testing.T) {
+	relaxDefaults(t)
+	for _, putETags := range []bool{true, false} {
+		server := httptest.NewServer(&testWebDAVServer{putETags: putETags})
+		defer server.Close()
+		newStorage := func() *WebDAVStorage {
+			return &WebDAVStorage{URL: server.URL + "/pass.db", Username: "alice", Password: "apppassword"}
+		}
+
+		if _, err := OpenStorage(newStorage(), "testpass", OpenOptions{}); !os.IsNotExist(err) {
+			t.Fatal("expected a missing file not to exist, got", err)
+		}
+		v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newStorage()
+		if err = v.SaveStorage(s); err != nil {
+			t.Fatal(err)
+		}
+		if err = v.SaveStorage(newStorage()); err != ErrStorageConflict {
+			t.Fatal("expected creating an existing file to conflict, got", err)
+		}
+
+		other := newStorage()
+		vother, err := OpenStorage(other, "testpass", OpenOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+			t.Fatal(err)
+		}
+		if err = v.SaveStorage(s); err != nil {
+			t.Fatal("expected saving over the vault's own save to succeed, got", err)
+		}
+		if err = vother.SaveStorage(other); err != ErrStorageConflict {
+			t.Fatal("expected saving over another writer's save to conflict, got", err)
+		}
+
+		vopen, err := OpenStorage(newStorage(), "testpass", OpenOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cred, err := vopen.Get("testlocation"); err != nil || cred.Password != "testpass" {
+			t.Fatal("could not read the stored credential", err)
+		}
+	}
+
+	server := httptest.NewServer(&testWebDAVServer{noGetETags: true})
+	defer server.Close()
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &WebDAVStorage{URL: server.URL + "/pass.db", Username: "alice", Password: "apppassword"}
+	if err = v.SaveStorage(s); err != nil {
+		t.Fatal(err)
+	}
+	untagged := &WebDAVStorage{URL: server.URL + "/pass.db", Username: "alice", Password: "apppassword"}
+	vuntagged, err := OpenStorage(untagged, "testpass", OpenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vuntagged.SaveStorage(untagged); err != nil {
+		t.Fatal("expected saving a file loaded without an ETag to succeed, got", err)
+	}
+	if err = v.SaveStorage(s); err != nil {
+		t.Fatal("expected saving a file stored without an ETag to succeed, got", err)
+	}
+
+	s = &WebDAVStorage{URL: server.URL + "/pass.db", Username: "alice", Password: "wrongpassword"}
+	if _, err := s.Load(); err == nil || os.IsNotExist(err) {
+		t.Fatal("expected an authentication failure, got", err)
+	}
+}
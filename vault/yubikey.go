@@ -0,0 +1,44 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrInvalidChallengeResponse is returned if a YubiKey's response to a
+// challenge is malformed.
+var ErrInvalidChallengeResponse = errors.New("invalid challenge-response")
+
+// ChallengeResponseFunc answers `challenge`, typically using a secret held in
+// hardware, such as a YubiKey. The same challenge must always get the same
+// response.
+type ChallengeResponseFunc func(challenge []byte) ([]byte, error)
+
+// YubiKeyChallengeResponse returns a ChallengeResponseFunc which answers
+// challenges using the HMAC-SHA1 challenge-response configured in slot
+// `slot`, 1 or 2, of a YubiKey, as KeePassXC does. It runs ykchalresp, from
+// yubikey-personalization, which waits for the YubiKey to be touched if the
+// slot requires it. A slot can be configured using, for example,
+// `ykman otp chalresp --generate 2`. Program a second YubiKey with the same
+// secret, or enroll it in a key slot of its own, in case the first is lost.
+func YubiKeyChallengeResponse(slot int) ChallengeResponseFunc {
+	return func(challenge []byte) ([]byte, error) {
+		var stderr bytes.Buffer
+		cmd := exec.Command("ykchalresp", fmt.Sprintf("-%v", slot), "-x", hex.EncodeToString(challenge))
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("ykchalresp: %v: %v", err, strings.TrimSpace(stderr.String()))
+		}
+		response, err := hex.DecodeString(strings.TrimSpace(string(out)))
+		if err != nil || len(response) != sha1.Size {
+			return nil, ErrInvalidChallengeResponse
+		}
+		return response, nil
+	}
+}
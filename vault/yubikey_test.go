@@ -0,0 +1,80 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"errors"
+	"os"
+	"testing"
+)
+
+// hmacChallengeResponse answers challenges as a YubiKey programmed with
+// `secret` does.
+func hmacChallengeResponse(secret string) ChallengeResponseFunc {
+	return func(challenge []byte) ([]byte, error) {
+		mac := hmac.New(sha1.New, []byte(secret))
+		mac.Write(challenge)
+		return mac.Sum(nil), nil
+	}
+}
+
+func TestChallengeResponse(t *testing.T) {
+	yubikey := hmacChallengeResponse("yubikey secret")
+	v, err := NewWithOptions("testpass", Options{KDF: KDFParams{N: 1024}, ChallengeResponse: yubikey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Add("testlocation", Credential{Username: "testuser", Password: "testpass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("pass.db")
+
+	if _, err = Open("pass.db", "testpass"); err != ErrCouldNotDecrypt {
+		t.Fatal("vault was opened without the challenge-response")
+	}
+	wrongKey := OpenOptions{ChallengeResponse: hmacChallengeResponse("other secret")}
+	if _, err = OpenWithOptions("pass.db", "testpass", wrongKey); err != ErrCouldNotDecrypt {
+		t.Fatal("vault was opened with the wrong YubiKey")
+	}
+	errMissing := errors.New("no YubiKey found")
+	missing := OpenOptions{ChallengeResponse: func([]byte) ([]byte, error) { return nil, errMissing }}
+	if _, err = OpenWithOptions("pass.db", "testpass", missing); err != errMissing {
+		t.Fatal("expected the challenge-response error, got", err)
+	}
+
+	v, err = OpenWithOptions("pass.db", "testpass", OpenOptions{ChallengeResponse: yubikey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots := v.ListKeySlots(); len(slots) != 1 || !slots[0].ChallengeResponse {
+		t.Fatal("challenge-response slot was not recorded", slots)
+	}
+
+	// A passphrase slot is a fallback for when the YubiKey is lost.
+	if _, err = v.AddKeySlot("recoverypass", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.ChangePassphrase("testpass", "newpass"); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Save("pass.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenWithOptions("pass.db", "newpass", OpenOptions{ChallengeResponse: yubikey}); err != nil {
+		t.Fatal(err)
+	}
+	vopen, err := Open("pass.db", "recoverypass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := vopen.Get("testlocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Password != "testpass" {
+		t.Fatal("fallback slot did not unlock the vault's credentials")
+	}
+}